@@ -0,0 +1,458 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/meysamhadeli/codai/agent/contracts"
+	contracts_analyzer "github.com/meysamhadeli/codai/code_analyzer/contracts"
+	"github.com/meysamhadeli/codai/code_analyzer/models"
+	"github.com/meysamhadeli/codai/patch"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+)
+
+// maxReadLines limits the lines of a file returned by read_file
+const maxReadLines = 2000
+
+// maxGrepMatches limits the matching lines returned by grep
+const maxGrepMatches = 100
+
+// maxGrepLineLength limits the length of a matching line returned by grep
+const maxGrepLineLength = 300
+
+// maxSymbolOccurrences limits the lines of a file where find_symbol reports a symbol
+const maxSymbolOccurrences = 5
+
+// definitionKeywords are the keywords of the definition lines, to report them before the other occurrences of a symbol
+var definitionKeywords = regexp.MustCompile(`\b(func|type|class|struct|interface|enum|trait|impl|def|fn|function|const|var|let|namespace|module)\b`)
+
+// builtinTool is a tool of codai with the function running it
+type builtinTool struct {
+	definition provider_models.ToolDefinition
+	run        func(arguments json.RawMessage) (string, error)
+}
+
+// builtinTools explores the files of the project found by the analyzer, like the context of the code sessions, and
+// collects the proposed changes for the review of the user
+type builtinTools struct {
+	rootDir  string
+	analyzer contracts_analyzer.ICodeAnalyzer
+	tools    []builtinTool
+
+	mu sync.Mutex
+	// proposals stages the proposed changes, so a change proposed after another one of the same file applies to it
+	proposals contracts_analyzer.IChangeTransaction
+	proposed  []models.CodeChange
+}
+
+// NewBuiltinTools creates the built-in tools of the project in rootDir
+func NewBuiltinTools(rootDir string, analyzer contracts_analyzer.ICodeAnalyzer) contracts.IBuiltinTools {
+	tools := &builtinTools{rootDir: rootDir, analyzer: analyzer, proposals: analyzer.BeginTransaction()}
+	tools.tools = []builtinTool{
+		{
+			definition: provider_models.ToolDefinition{
+				Name:        "read_file",
+				Description: "Read a file of the project, or a range of its lines.",
+				Parameters: json.RawMessage(`{"type": "object", "properties": {` +
+					`"path": {"type": "string", "description": "Path of the file relative to the project root"}, ` +
+					`"start_line": {"type": "integer", "description": "First line to read, from 1"}, ` +
+					`"end_line": {"type": "integer", "description": "Last line to read"}}, "required": ["path"]}`),
+			},
+			run: tools.readFile,
+		},
+		{
+			definition: provider_models.ToolDefinition{
+				Name:        "list_dir",
+				Description: "List the files and directories of a directory of the project.",
+				Parameters:  json.RawMessage(`{"type": "object", "properties": {"path": {"type": "string", "description": "Path of the directory relative to the project root, the root by default"}}}`),
+			},
+			run: tools.listDir,
+		},
+		{
+			definition: provider_models.ToolDefinition{
+				Name:        "grep",
+				Description: "Search the files of the project for a regular expression, returning the matching lines with their file and line number.",
+				Parameters: json.RawMessage(`{"type": "object", "properties": {` +
+					`"pattern": {"type": "string", "description": "Regular expression in Go syntax"}, ` +
+					`"path": {"type": "string", "description": "File or directory to search, the whole project by default"}, ` +
+					`"ignore_case": {"type": "boolean"}}, "required": ["pattern"]}`),
+			},
+			run: tools.grep,
+		},
+		{
+			definition: provider_models.ToolDefinition{
+				Name:        "find_symbol",
+				Description: "Find the files defining a symbol (function, method, type, class...) with the lines where it appears.",
+				Parameters:  json.RawMessage(`{"type": "object", "properties": {"name": {"type": "string", "description": "Name of the symbol"}}, "required": ["name"]}`),
+			},
+			run: tools.findSymbol,
+		},
+		{
+			definition: provider_models.ToolDefinition{
+				Name: "propose_edit",
+				Description: "Propose a change of a file: its full new content, a unified diff or SEARCH/REPLACE blocks, an empty code deletes it. " +
+					"The change is not written, the user reviews the proposed changes after your answer.",
+				Parameters: json.RawMessage(`{"type": "object", "properties": {` +
+					`"path": {"type": "string", "description": "Path of the file relative to the project root"}, ` +
+					`"code": {"type": "string"}}, "required": ["path", "code"]}`),
+			},
+			run: tools.proposeEdit,
+		},
+	}
+	return tools
+}
+
+func (tools *builtinTools) Tools() []provider_models.ToolDefinition {
+	var definitions []provider_models.ToolDefinition
+	for _, tool := range tools.tools {
+		definitions = append(definitions, tool.definition)
+	}
+	return definitions
+}
+
+func (tools *builtinTools) Describe(name string) (string, bool) {
+	for _, tool := range tools.tools {
+		if tool.definition.Name == name {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// AutoApproved reports true for every tool: they only read the project, and the proposed changes are reviewed later
+func (tools *builtinTools) AutoApproved(name string) bool {
+	_, ok := tools.Describe(name)
+	return ok
+}
+
+func (tools *builtinTools) CallTool(ctx context.Context, call provider_models.ToolCall) provider_models.ToolResult {
+	result := provider_models.ToolResult{CallID: call.ID, Name: call.Name}
+	for _, tool := range tools.tools {
+		if tool.definition.Name != call.Name {
+			continue
+		}
+		arguments := json.RawMessage(strings.TrimSpace(call.Arguments))
+		if len(arguments) == 0 {
+			arguments = json.RawMessage("{}")
+		}
+		content, err := tool.run(arguments)
+		if err != nil {
+			// The model sees the errors of the tools to correct its call
+			result.Content, result.IsError = err.Error(), true
+			return result
+		}
+		result.Content = content
+		return result
+	}
+	result.Content, result.IsError = fmt.Sprintf("Unknown tool '%s'.", call.Name), true
+	return result
+}
+
+func (tools *builtinTools) TakeProposedChanges() []models.CodeChange {
+	tools.mu.Lock()
+	defer tools.mu.Unlock()
+
+	proposed := tools.proposed
+	tools.proposed = nil
+	tools.proposals.Discard()
+	return proposed
+}
+
+// decodeArguments decodes the arguments of a tool
+func decodeArguments(arguments json.RawMessage, value interface{}) error {
+	if err := json.Unmarshal(arguments, value); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	return nil
+}
+
+// cleanPath normalizes a path of the project given by the model, the root being ""
+func cleanPath(relativePath string) string {
+	cleaned := path.Clean(filepath.ToSlash(strings.TrimSpace(relativePath)))
+	if cleaned == "." || cleaned == "/" {
+		return ""
+	}
+	return strings.TrimPrefix(cleaned, "./")
+}
+
+// projectFiles returns the files of the project by path, with their sorted paths. Only the files of the context of
+// the code sessions are returned, not the ignored files or the files outside of the project.
+func (tools *builtinTools) projectFiles() (map[string]models.FileData, []string, error) {
+	fullContext, err := tools.analyzer.GetProjectFilesWithDisplayMode(tools.rootDir, "info")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load the files of the project: %v", err)
+	}
+
+	files := make(map[string]models.FileData)
+	var paths []string
+	for _, fileData := range fullContext.FileData {
+		relativePath := cleanPath(fileData.RelativePath)
+		files[relativePath] = fileData
+		paths = append(paths, relativePath)
+	}
+	sort.Strings(paths)
+	return files, paths, nil
+}
+
+// inScope reports whether a file is the path to search or in its directory
+func inScope(filePath string, scope string) bool {
+	return scope == "" || filePath == scope || strings.HasPrefix(filePath, scope+"/")
+}
+
+func (tools *builtinTools) readFile(arguments json.RawMessage) (string, error) {
+	var readArguments struct {
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+	}
+	if err := decodeArguments(arguments, &readArguments); err != nil {
+		return "", err
+	}
+
+	files, _, err := tools.projectFiles()
+	if err != nil {
+		return "", err
+	}
+	relativePath := cleanPath(readArguments.Path)
+	file, ok := files[relativePath]
+	if !ok {
+		return "", fmt.Errorf("the file '%s' is not in the project, use list_dir or grep to find the files", readArguments.Path)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(file.Code, "\n"), "\n")
+	start, end := max(readArguments.StartLine, 1), len(lines)
+	if readArguments.EndLine > 0 {
+		end = min(readArguments.EndLine, len(lines))
+	}
+	if start > end {
+		return "", fmt.Errorf("the file '%s' has %d lines", relativePath, len(lines))
+	}
+
+	truncated := end-start+1 > maxReadLines
+	if truncated {
+		end = start + maxReadLines - 1
+	}
+
+	var builder strings.Builder
+	if start == 1 && end == len(lines) {
+		builder.WriteString(fmt.Sprintf("File: %s (%d lines)\n\n", relativePath, len(lines)))
+	} else {
+		builder.WriteString(fmt.Sprintf("File: %s (lines %d-%d of %d)\n\n", relativePath, start, end, len(lines)))
+	}
+	builder.WriteString(strings.Join(lines[start-1:end], "\n"))
+	if truncated {
+		builder.WriteString(fmt.Sprintf("\n\n... (truncated, read from line %d for the rest)", end+1))
+	}
+	return builder.String(), nil
+}
+
+func (tools *builtinTools) listDir(arguments json.RawMessage) (string, error) {
+	var listArguments struct {
+		Path string `json:"path"`
+	}
+	if err := decodeArguments(arguments, &listArguments); err != nil {
+		return "", err
+	}
+
+	_, paths, err := tools.projectFiles()
+	if err != nil {
+		return "", err
+	}
+
+	dir := cleanPath(listArguments.Path)
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	// The files of the directory, and the number of files of its subdirectories
+	var files []string
+	subdirectories := make(map[string]int)
+	for _, filePath := range paths {
+		if !strings.HasPrefix(filePath, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(filePath, prefix)
+		if index := strings.Index(name, "/"); index >= 0 {
+			subdirectories[name[:index]]++
+		} else {
+			files = append(files, name)
+		}
+	}
+	if len(files) == 0 && len(subdirectories) == 0 {
+		return "", fmt.Errorf("the directory '%s' has no files in the project", listArguments.Path)
+	}
+
+	var entries []string
+	for name, count := range subdirectories {
+		entries = append(entries, fmt.Sprintf("%s/ (%d files)", name, count))
+	}
+	sort.Strings(entries)
+	entries = append(entries, files...)
+	return strings.Join(entries, "\n"), nil
+}
+
+func (tools *builtinTools) grep(arguments json.RawMessage) (string, error) {
+	var grepArguments struct {
+		Pattern    string `json:"pattern"`
+		Path       string `json:"path"`
+		IgnoreCase bool   `json:"ignore_case"`
+	}
+	if err := decodeArguments(arguments, &grepArguments); err != nil {
+		return "", err
+	}
+	if grepArguments.Pattern == "" {
+		return "", fmt.Errorf("no pattern to search")
+	}
+
+	pattern := grepArguments.Pattern
+	if grepArguments.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	expression, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %v", err)
+	}
+
+	files, paths, err := tools.projectFiles()
+	if err != nil {
+		return "", err
+	}
+
+	scope := cleanPath(grepArguments.Path)
+	var matches []string
+	for _, filePath := range paths {
+		if !inScope(filePath, scope) {
+			continue
+		}
+		for i, line := range strings.Split(files[filePath].Code, "\n") {
+			if !expression.MatchString(line) {
+				continue
+			}
+			if len(matches) == maxGrepMatches {
+				return strings.Join(matches, "\n") + fmt.Sprintf("\n... (stopped after %d matches, narrow the pattern or the path)", maxGrepMatches), nil
+			}
+			if len(line) > maxGrepLineLength {
+				line = line[:maxGrepLineLength] + "..."
+			}
+			matches = append(matches, fmt.Sprintf("%s:%d: %s", filePath, i+1, line))
+		}
+	}
+	if len(matches) == 0 {
+		return "No matches.", nil
+	}
+	return strings.Join(matches, "\n"), nil
+}
+
+// findSymbol finds the files whose tree-sitter outline has the symbol, with the lines where it appears, the
+// definitions first
+func (tools *builtinTools) findSymbol(arguments json.RawMessage) (string, error) {
+	var symbolArguments struct {
+		Name string `json:"name"`
+	}
+	if err := decodeArguments(arguments, &symbolArguments); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(symbolArguments.Name)
+	if name == "" {
+		return "", fmt.Errorf("no symbol to find")
+	}
+
+	files, paths, err := tools.projectFiles()
+	if err != nil {
+		return "", err
+	}
+
+	word := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+	var results []string
+	for _, filePath := range paths {
+		file := files[filePath]
+
+		// The outline has a 'kind: name' line for every symbol
+		var kinds []string
+		for _, element := range strings.Split(file.TreeSitterCode, "\n") {
+			kind, symbol, ok := strings.Cut(element, ": ")
+			if ok && strings.TrimSpace(symbol) == name {
+				kinds = append(kinds, kind)
+			}
+		}
+		if len(kinds) == 0 {
+			continue
+		}
+
+		var definitions, occurrences []string
+		for i, line := range strings.Split(file.Code, "\n") {
+			if !word.MatchString(line) {
+				continue
+			}
+			occurrence := fmt.Sprintf("  %d: %s", i+1, strings.TrimSpace(line))
+			if definitionKeywords.MatchString(line) {
+				definitions = append(definitions, occurrence)
+			} else {
+				occurrences = append(occurrences, occurrence)
+			}
+		}
+		lines := append(definitions, occurrences...)
+		if len(lines) > maxSymbolOccurrences {
+			lines = lines[:maxSymbolOccurrences]
+		}
+
+		results = append(results, fmt.Sprintf("%s (%s)\n%s", filePath, strings.Join(kinds, ", "), strings.Join(lines, "\n")))
+	}
+	if len(results) == 0 {
+		return fmt.Sprintf("No definition of '%s' found, use grep to search for it.", name), nil
+	}
+	return strings.Join(results, "\n\n"), nil
+}
+
+// proposeEdit checks that a change applies to the file, including the changes proposed before it, and keeps it for
+// the review of the user
+func (tools *builtinTools) proposeEdit(arguments json.RawMessage) (string, error) {
+	var editArguments struct {
+		Path string `json:"path"`
+		Code string `json:"code"`
+	}
+	if err := decodeArguments(arguments, &editArguments); err != nil {
+		return "", err
+	}
+	relativePath := cleanPath(editArguments.Path)
+	if relativePath == "" {
+		return "", fmt.Errorf("no path of the file to change")
+	}
+
+	tools.mu.Lock()
+	defer tools.mu.Unlock()
+
+	before, after, err := tools.proposals.Preview(relativePath, editArguments.Code)
+	if err != nil {
+		return "", fmt.Errorf("the change of %s does not apply: %v", relativePath, err)
+	}
+
+	added, removed := 0, 0
+	for _, hunk := range patch.Diff(before, after, 0) {
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case patch.Addition:
+				added++
+			case patch.Deletion:
+				removed++
+			}
+		}
+	}
+	if added == 0 && removed == 0 {
+		return fmt.Sprintf("The change does not modify %s.", relativePath), nil
+	}
+
+	if err := tools.proposals.StageContent(relativePath, after); err != nil {
+		return "", fmt.Errorf("the change of %s does not apply: %v", relativePath, err)
+	}
+	tools.proposed = append(tools.proposed, models.CodeChange{RelativePath: relativePath, Code: editArguments.Code})
+	return fmt.Sprintf("Proposed the change of %s (+%d -%d lines), the user reviews it after your answer.", relativePath, added, removed), nil
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meysamhadeli/codai/code_analyzer"
+	"github.com/meysamhadeli/codai/code_analyzer/models"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTools(t *testing.T) (*builtinTools, string) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":              "package main\n\nfunc main() {\n\tgreet(\"world\")\n}\n",
+		"greeting/greeting.go": "package greeting\n\n// Greet greets someone\nfunc Greet(name string) string {\n\treturn \"Hello \" + name\n}\n",
+		"greeting/doc.txt":     "Greet is used by main.\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return NewBuiltinTools(dir, code_analyzer.NewCodeAnalyzer(dir, false)).(*builtinTools), dir
+}
+
+func call(tools *builtinTools, name string, arguments string) provider_models.ToolResult {
+	return tools.CallTool(context.Background(), provider_models.ToolCall{ID: "call_1", Name: name, Arguments: arguments})
+}
+
+func TestBuiltinTools_ReadFile(t *testing.T) {
+	tools, _ := newTestTools(t)
+
+	result := call(tools, "read_file", `{"path": "./greeting/greeting.go"}`)
+	assert.False(t, result.IsError)
+	assert.Equal(t, "call_1", result.CallID)
+	assert.True(t, strings.HasPrefix(result.Content, "File: greeting/greeting.go (6 lines)\n\npackage greeting"))
+
+	result = call(tools, "read_file", `{"path": "greeting/greeting.go", "start_line": 4, "end_line": 5}`)
+	assert.Equal(t, "File: greeting/greeting.go (lines 4-5 of 6)\n\nfunc Greet(name string) string {\n\treturn \"Hello \" + name", result.Content)
+
+	result = call(tools, "read_file", `{"path": "../../etc/passwd"}`)
+	assert.True(t, result.IsError)
+
+	result = call(tools, "read_file", `{"path": `)
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.Content, "invalid arguments")
+}
+
+func TestBuiltinTools_ListDir(t *testing.T) {
+	tools, _ := newTestTools(t)
+
+	result := call(tools, "list_dir", "")
+	assert.False(t, result.IsError)
+	assert.Equal(t, "greeting/ (2 files)\nmain.go", result.Content)
+
+	result = call(tools, "list_dir", `{"path": "greeting"}`)
+	assert.Equal(t, "doc.txt\ngreeting.go", result.Content)
+
+	result = call(tools, "list_dir", `{"path": "missing"}`)
+	assert.True(t, result.IsError)
+}
+
+func TestBuiltinTools_Grep(t *testing.T) {
+	tools, _ := newTestTools(t)
+
+	result := call(tools, "grep", `{"pattern": "greet", "ignore_case": true, "path": "greeting"}`)
+	assert.False(t, result.IsError)
+	assert.Equal(t, "greeting/doc.txt:1: Greet is used by main.\n"+
+		"greeting/greeting.go:1: package greeting\n"+
+		"greeting/greeting.go:3: // Greet greets someone\n"+
+		"greeting/greeting.go:4: func Greet(name string) string {", result.Content)
+
+	result = call(tools, "grep", `{"pattern": "nothing matches this"}`)
+	assert.Equal(t, "No matches.", result.Content)
+
+	result = call(tools, "grep", `{"pattern": "("}`)
+	assert.True(t, result.IsError)
+}
+
+func TestBuiltinTools_FindSymbol(t *testing.T) {
+	tools, _ := newTestTools(t)
+
+	result := call(tools, "find_symbol", `{"name": "Greet"}`)
+	assert.False(t, result.IsError)
+	assert.Equal(t, "greeting/greeting.go (function)\n  4: func Greet(name string) string {\n  3: // Greet greets someone", result.Content)
+
+	result = call(tools, "find_symbol", `{"name": "Missing"}`)
+	assert.Contains(t, result.Content, "No definition of 'Missing' found")
+}
+
+func TestBuiltinTools_ProposeEdit(t *testing.T) {
+	tools, dir := newTestTools(t)
+
+	result := call(tools, "propose_edit", `{"path": "main.go", "code": "package main\n\nfunc main() {\n}\n"}`)
+	assert.False(t, result.IsError)
+	assert.Contains(t, result.Content, "Proposed the change of main.go (+0 -1 lines)")
+
+	// The second change applies to the first one
+	result = call(tools, "propose_edit", `{"path": "main.go", "code": "<<<<<<< SEARCH\nfunc main() {\n}\n=======\nfunc main() {\n\tprintln()\n}\n>>>>>>> REPLACE"}`)
+	assert.False(t, result.IsError, result.Content)
+
+	result = call(tools, "propose_edit", `{"path": "../outside.go", "code": "package outside\n"}`)
+	assert.True(t, result.IsError)
+
+	content, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "greet", "proposed changes are not written")
+
+	changes := tools.TakeProposedChanges()
+	assert.Len(t, changes, 2)
+	assert.Equal(t, models.CodeChange{RelativePath: "main.go", Code: "package main\n\nfunc main() {\n}\n"}, changes[0])
+	assert.Empty(t, tools.TakeProposedChanges())
+}
+
+func TestToolSets(t *testing.T) {
+	tools, _ := newTestTools(t)
+	combined := NewToolSets(tools, nil)
+
+	assert.Len(t, combined.Tools(), 5)
+	description, ok := combined.Describe("read_file")
+	assert.True(t, ok)
+	assert.Equal(t, "read_file", description)
+	assert.True(t, combined.AutoApproved("grep"))
+
+	result := combined.CallTool(context.Background(), provider_models.ToolCall{ID: "call_2", Name: "unknown"})
+	assert.True(t, result.IsError)
+	assert.Equal(t, "call_2", result.CallID)
+}
@@ -0,0 +1,25 @@
+package contracts
+
+import (
+	"context"
+
+	"github.com/meysamhadeli/codai/code_analyzer/models"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+)
+
+// IToolSet is a set of tools the AI can call in a code session
+type IToolSet interface {
+	Tools() []provider_models.ToolDefinition
+	// Describe describes a tool of the set for the user
+	Describe(name string) (string, bool)
+	// AutoApproved reports whether a tool is called without asking the user
+	AutoApproved(name string) bool
+	CallTool(ctx context.Context, call provider_models.ToolCall) provider_models.ToolResult
+}
+
+// IBuiltinTools are the tools of codai to explore the project and propose changes of its files
+type IBuiltinTools interface {
+	IToolSet
+	// TakeProposedChanges returns the changes proposed since the last call, to review them like the changes of an answer
+	TakeProposedChanges() []models.CodeChange
+}
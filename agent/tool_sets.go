@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/meysamhadeli/codai/agent/contracts"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+)
+
+// toolSets offers the tools of several sets, a name used by several sets is the tool of the first one
+type toolSets struct {
+	sets []contracts.IToolSet
+}
+
+// NewToolSets combines tool sets, skipping the nil ones
+func NewToolSets(sets ...contracts.IToolSet) contracts.IToolSet {
+	combined := &toolSets{}
+	for _, set := range sets {
+		if set != nil {
+			combined.sets = append(combined.sets, set)
+		}
+	}
+	return combined
+}
+
+func (combined *toolSets) Tools() []provider_models.ToolDefinition {
+	var tools []provider_models.ToolDefinition
+	seen := make(map[string]bool)
+	for _, set := range combined.sets {
+		for _, tool := range set.Tools() {
+			if !seen[tool.Name] {
+				seen[tool.Name] = true
+				tools = append(tools, tool)
+			}
+		}
+	}
+	return tools
+}
+
+// find returns the set of a tool
+func (combined *toolSets) find(name string) contracts.IToolSet {
+	for _, set := range combined.sets {
+		if _, ok := set.Describe(name); ok {
+			return set
+		}
+	}
+	return nil
+}
+
+func (combined *toolSets) Describe(name string) (string, bool) {
+	if set := combined.find(name); set != nil {
+		return set.Describe(name)
+	}
+	return "", false
+}
+
+func (combined *toolSets) AutoApproved(name string) bool {
+	if set := combined.find(name); set != nil {
+		return set.AutoApproved(name)
+	}
+	return false
+}
+
+func (combined *toolSets) CallTool(ctx context.Context, call provider_models.ToolCall) provider_models.ToolResult {
+	if set := combined.find(call.Name); set != nil {
+		return set.CallTool(ctx, call)
+	}
+	return provider_models.ToolResult{CallID: call.ID, Name: call.Name, Content: fmt.Sprintf("Unknown tool '%s'.", call.Name), IsError: true}
+}
@@ -0,0 +1,234 @@
+package change_journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/meysamhadeli/codai/change_journal/contracts"
+	"github.com/meysamhadeli/codai/change_journal/models"
+	contracts_analyzer "github.com/meysamhadeli/codai/code_analyzer/contracts"
+	analyzer_models "github.com/meysamhadeli/codai/code_analyzer/models"
+)
+
+const stateFileName = "journal.json"
+
+// ErrNothingToUndo is returned by Undo when every recorded change is already undone.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// ErrNothingToRedo is returned by Redo when no undone change is left.
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+// changeJournal keeps every applied transaction on disk, so changes can be undone and redone in order.
+type changeJournal struct {
+	dir      string
+	analyzer contracts_analyzer.ICodeAnalyzer
+}
+
+// journalState lists the recorded transactions in order and how many of them are currently applied.
+type journalState struct {
+	Entries []string `json:"entries"`
+	Applied int      `json:"applied"`
+}
+
+// Record stores the snapshot of a committed transaction. Undone changes that were not redone are discarded.
+func (journal *changeJournal) Record(record *analyzer_models.TransactionRecord) error {
+	if record == nil || len(record.Operations) == 0 {
+		return nil
+	}
+
+	state, err := journal.loadState()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range state.Entries[state.Applied:] {
+		_ = os.Remove(journal.recordPath(id))
+	}
+	state.Entries = state.Entries[:state.Applied]
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal record: %w", err)
+	}
+	if err := os.MkdirAll(journal.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create change journal directory: %w", err)
+	}
+	if err := os.WriteFile(journal.recordPath(record.ID), data, 0600); err != nil {
+		return fmt.Errorf("failed to write journal record: %w", err)
+	}
+
+	state.Entries = append(state.Entries, record.ID)
+	state.Applied = len(state.Entries)
+	return journal.saveState(state)
+}
+
+// Undo reverts the most recent applied transaction.
+func (journal *changeJournal) Undo() (*analyzer_models.TransactionRecord, error) {
+	state, err := journal.loadState()
+	if err != nil {
+		return nil, err
+	}
+	if state.Applied == 0 {
+		return nil, ErrNothingToUndo
+	}
+
+	record, err := journal.loadRecord(state.Entries[state.Applied-1])
+	if err != nil {
+		return nil, err
+	}
+	if err := journal.analyzer.RevertTransaction(record); err != nil {
+		return nil, err
+	}
+
+	state.Applied--
+	return record, journal.saveState(state)
+}
+
+// Redo applies the most recently undone transaction again.
+func (journal *changeJournal) Redo() (*analyzer_models.TransactionRecord, error) {
+	state, err := journal.loadState()
+	if err != nil {
+		return nil, err
+	}
+	if state.Applied == len(state.Entries) {
+		return nil, ErrNothingToRedo
+	}
+
+	record, err := journal.loadRecord(state.Entries[state.Applied])
+	if err != nil {
+		return nil, err
+	}
+	if err := journal.analyzer.ReapplyTransaction(record); err != nil {
+		return nil, err
+	}
+
+	state.Applied++
+	return record, journal.saveState(state)
+}
+
+// Changes returns every recorded transaction from oldest to newest.
+func (journal *changeJournal) Changes() ([]models.JournalEntry, error) {
+	state, err := journal.loadState()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []models.JournalEntry
+	for i, id := range state.Entries {
+		record, err := journal.loadRecord(id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, models.JournalEntry{Record: record, Applied: i < state.Applied})
+	}
+	return entries, nil
+}
+
+// Restore replaces the recorded transactions with the entries, e.g. the changes of a resumed session. The files are
+// not changed, the entries must describe the changes currently applied to them.
+func (journal *changeJournal) Restore(entries []models.JournalEntry) error {
+	if err := journal.Clear(); err != nil {
+		return err
+	}
+
+	state := &journalState{}
+	for _, entry := range entries {
+		if entry.Record == nil {
+			continue
+		}
+		if entry.Record.ID == "" || filepath.Base(entry.Record.ID) != entry.Record.ID || entry.Record.ID == ".." {
+			return fmt.Errorf("invalid journal record id %q", entry.Record.ID)
+		}
+		data, err := json.Marshal(entry.Record)
+		if err != nil {
+			return fmt.Errorf("failed to encode journal record: %w", err)
+		}
+		if err := os.MkdirAll(journal.dir, 0755); err != nil {
+			return fmt.Errorf("failed to create change journal directory: %w", err)
+		}
+		if err := os.WriteFile(journal.recordPath(entry.Record.ID), data, 0600); err != nil {
+			return fmt.Errorf("failed to write journal record: %w", err)
+		}
+
+		// The applied entries come before the undone ones
+		state.Entries = append(state.Entries, entry.Record.ID)
+		if entry.Applied {
+			state.Applied = len(state.Entries)
+		}
+	}
+	return journal.saveState(state)
+}
+
+// Clear removes every recorded transaction.
+func (journal *changeJournal) Clear() error {
+	if err := os.RemoveAll(journal.dir); err != nil {
+		return fmt.Errorf("failed to clear change journal: %w", err)
+	}
+	return nil
+}
+
+func (journal *changeJournal) recordPath(id string) string {
+	return filepath.Join(journal.dir, id+".json")
+}
+
+func (journal *changeJournal) loadRecord(id string) (*analyzer_models.TransactionRecord, error) {
+	data, err := os.ReadFile(journal.recordPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal record %s: %w", id, err)
+	}
+
+	var record analyzer_models.TransactionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode journal record %s: %w", id, err)
+	}
+	return &record, nil
+}
+
+func (journal *changeJournal) loadState() (*journalState, error) {
+	state := &journalState{}
+
+	data, err := os.ReadFile(filepath.Join(journal.dir, stateFileName))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read change journal: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to decode change journal: %w", err)
+	}
+	if state.Applied < 0 || state.Applied > len(state.Entries) {
+		state.Applied = len(state.Entries)
+	}
+	return state, nil
+}
+
+func (journal *changeJournal) saveState(state *journalState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode change journal: %w", err)
+	}
+
+	if err := os.MkdirAll(journal.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create change journal directory: %w", err)
+	}
+
+	// Write through a temporary file, so an interrupted write never corrupts the journal
+	statePath := filepath.Join(journal.dir, stateFileName)
+	if err := os.WriteFile(statePath+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write change journal: %w", err)
+	}
+	if err := os.Rename(statePath+".tmp", statePath); err != nil {
+		return fmt.Errorf("failed to write change journal: %w", err)
+	}
+	return nil
+}
+
+// NewChangeJournal creates a change journal stored in dir that reverts and reapplies changes through the analyzer.
+func NewChangeJournal(dir string, analyzer contracts_analyzer.ICodeAnalyzer) contracts.IChangeJournal {
+	return &changeJournal{dir: dir, analyzer: analyzer}
+}
@@ -0,0 +1,127 @@
+package change_journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/meysamhadeli/codai/code_analyzer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeJournal_UndoRedo(t *testing.T) {
+	dir := t.TempDir()
+	analyzer := code_analyzer.NewCodeAnalyzer(dir, false)
+	journal := NewChangeJournal(filepath.Join(dir, ".codai", "journal"), analyzer)
+
+	filePath := filepath.Join(dir, "pkg", "main.go")
+	apply := func(code string) {
+		transaction := analyzer.BeginTransaction()
+		assert.NoError(t, transaction.Stage(filePath, code))
+		record, err := transaction.Commit()
+		assert.NoError(t, err)
+		assert.NoError(t, journal.Record(record))
+	}
+
+	apply("package main\n")
+	apply("package main\n\nfunc main() {}\n")
+	apply("")
+
+	_, err := journal.Undo()
+	assert.NoError(t, err)
+	content, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {}\n", string(content))
+
+	_, err = journal.Undo()
+	assert.NoError(t, err)
+	_, err = journal.Undo()
+	assert.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(dir, "pkg"))
+
+	_, err = journal.Undo()
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+
+	_, err = journal.Redo()
+	assert.NoError(t, err)
+	content, err = os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\n", string(content))
+
+	entries, err := journal.Changes()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.True(t, entries[0].Applied)
+	assert.False(t, entries[1].Applied)
+
+	// Recording a new change discards the undone ones
+	apply("package other\n")
+	entries, err = journal.Changes()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	_, err = journal.Redo()
+	assert.ErrorIs(t, err, ErrNothingToRedo)
+}
+
+func TestChangeJournal_UndoRefusesConflictingEdit(t *testing.T) {
+	dir := t.TempDir()
+	analyzer := code_analyzer.NewCodeAnalyzer(dir, false)
+	journal := NewChangeJournal(filepath.Join(dir, ".codai", "journal"), analyzer)
+
+	filePath := filepath.Join(dir, "main.go")
+	transaction := analyzer.BeginTransaction()
+	assert.NoError(t, transaction.Stage(filePath, "package main\n"))
+	record, err := transaction.Commit()
+	assert.NoError(t, err)
+	assert.NoError(t, journal.Record(record))
+
+	assert.NoError(t, os.WriteFile(filePath, []byte("edited by hand\n"), 0644))
+
+	_, err = journal.Undo()
+	assert.Error(t, err)
+
+	content, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "edited by hand\n", string(content))
+}
+
+func TestChangeJournal_Restore(t *testing.T) {
+	dir := t.TempDir()
+	analyzer := code_analyzer.NewCodeAnalyzer(dir, false)
+	journal := NewChangeJournal(filepath.Join(dir, ".codai", "journal"), analyzer)
+
+	filePath := filepath.Join(dir, "main.go")
+	for _, code := range []string{"package main\n", "package main\n\nfunc main() {}\n"} {
+		transaction := analyzer.BeginTransaction()
+		assert.NoError(t, transaction.Stage(filePath, code))
+		record, err := transaction.Commit()
+		assert.NoError(t, err)
+		assert.NoError(t, journal.Record(record))
+	}
+	_, err := journal.Undo()
+	assert.NoError(t, err)
+
+	entries, err := journal.Changes()
+	assert.NoError(t, err)
+	assert.NoError(t, journal.Clear())
+
+	// A new journal restored with the entries undoes and redoes them like the original one
+	restored := NewChangeJournal(filepath.Join(dir, ".codai", "journal"), analyzer)
+	assert.NoError(t, restored.Restore(entries))
+	restoredEntries, err := restored.Changes()
+	assert.NoError(t, err)
+	assert.Equal(t, entries, restoredEntries)
+
+	_, err = restored.Redo()
+	assert.NoError(t, err)
+	content, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {}\n", string(content))
+
+	_, err = restored.Undo()
+	assert.NoError(t, err)
+	_, err = restored.Undo()
+	assert.NoError(t, err)
+	assert.NoFileExists(t, filePath)
+}
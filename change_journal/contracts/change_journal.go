@@ -0,0 +1,15 @@
+package contracts
+
+import (
+	"github.com/meysamhadeli/codai/change_journal/models"
+	analyzer_models "github.com/meysamhadeli/codai/code_analyzer/models"
+)
+
+type IChangeJournal interface {
+	Record(record *analyzer_models.TransactionRecord) error
+	Undo() (*analyzer_models.TransactionRecord, error)
+	Redo() (*analyzer_models.TransactionRecord, error)
+	Changes() ([]models.JournalEntry, error)
+	Restore(entries []models.JournalEntry) error
+	Clear() error
+}
@@ -0,0 +1,11 @@
+package models
+
+import (
+	"github.com/meysamhadeli/codai/code_analyzer/models"
+)
+
+// JournalEntry is a transaction recorded in the change journal and whether it is currently applied.
+type JournalEntry struct {
+	Record  *models.TransactionRecord `json:"record"`
+	Applied bool                      `json:"applied"`
+}
@@ -1,31 +1,31 @@
 package chat_history
 
 import (
-	"fmt"
 	"github.com/meysamhadeli/codai/chat_history/contracts"
+	"github.com/meysamhadeli/codai/providers/models"
 	"time"
 )
 
 // ChatHistory Define a struct for the chat session to keep the history
 type chatHistory struct {
-	History []string // Store each prompt-response as a string
+	History []models.Message // Store the user, assistant and tool messages in order
 }
 
-func (ch *chatHistory) GetHistory() []string {
+func (ch *chatHistory) GetHistory() []models.Message {
 	return ch.History
 }
 
-// AddToHistory Method to add conversation to the session history
-func (ch *chatHistory) AddToHistory(userInputPrompt string, aiResponse string) {
-
-	history := fmt.Sprintf(fmt.Sprintf("### History time:\n\n%v\n---------\n\n", time.Now())+"### Here is user request:\n\n%s\n---------\n\n### Here is the the response from using AI:\n\n%s", userInputPrompt, aiResponse)
-
-	ch.History = append(ch.History, history)
+// AddMessages Method to add the messages of a conversation to the session history, with the time they were added
+func (ch *chatHistory) AddMessages(messages ...models.Message) {
+	now := time.Now()
+	for _, message := range messages {
+		ch.History = append(ch.History, message.WithTime(now))
+	}
 }
 
 // ClearHistory Method to clear the chat session history
 func (ch *chatHistory) ClearHistory() {
-	ch.History = []string{}
+	ch.History = []models.Message{}
 }
 
 func NewChatHistory() contracts.IChatHistory {
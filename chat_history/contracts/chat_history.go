@@ -1,7 +1,10 @@
 package contracts
 
+import "github.com/meysamhadeli/codai/providers/models"
+
+// IChatHistory keeps the messages of a chat session, sent to the provider with the next requests
 type IChatHistory interface {
-	AddToHistory(userInputPrompt string, aiResponse string)
+	AddMessages(messages ...models.Message)
 	ClearHistory()
-	GetHistory() []string
+	GetHistory() []models.Message
 }
@@ -0,0 +1,11 @@
+package contracts
+
+import "github.com/meysamhadeli/codai/chat_session/models"
+
+type ISessionStore interface {
+	Save(session *models.Session) error
+	Load(id string) (*models.Session, error)
+	Latest() (*models.Session, []error, error)
+	List() ([]*models.Session, []error, error)
+	Delete(id string) error
+}
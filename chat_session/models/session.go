@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	journal_models "github.com/meysamhadeli/codai/change_journal/models"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+)
+
+// CurrentVersion is the version of the format of the session files written by this version of codai. The files of
+// older versions are upgraded when they are loaded, the files of newer versions are refused.
+const CurrentVersion = 1
+
+// Session is a chat session of the 'code' command saved in the project, to be resumed later
+type Session struct {
+	Version   int                           `json:"version"`
+	ID        string                        `json:"id"`
+	Title     string                        `json:"title"` // First request of the user
+	CreatedAt time.Time                     `json:"created_at"`
+	UpdatedAt time.Time                     `json:"updated_at"`
+	Provider  string                        `json:"provider"`
+	Model     string                        `json:"model"`
+	Messages  []provider_models.Message     `json:"messages"`
+	Changes   []journal_models.JournalEntry `json:"changes,omitempty"` // Changes applied in the session, for /undo
+	Usage     TokenUsage                    `json:"usage"`
+}
+
+// TokenUsage is the number of tokens used by a session
+type TokenUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
@@ -0,0 +1,232 @@
+package chat_session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/meysamhadeli/codai/chat_session/contracts"
+	"github.com/meysamhadeli/codai/chat_session/models"
+)
+
+const sessionFileExtension = ".json"
+
+// ErrSessionNotFound is returned when no saved session matches an id.
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionStore keeps the sessions of a project as one JSON file per session.
+type sessionStore struct {
+	dir string
+}
+
+// Save writes the session with the current format version.
+func (store *sessionStore) Save(session *models.Session) error {
+	if err := validateID(session.ID); err != nil {
+		return err
+	}
+
+	session.Version = models.CurrentVersion
+	session.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session %s: %w", session.ID, err)
+	}
+
+	if err := os.MkdirAll(store.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+
+	// Write through a temporary file, so an interrupted write never corrupts the session
+	path := store.sessionPath(session.ID)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write session %s: %w", session.ID, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write session %s: %w", session.ID, err)
+	}
+	return nil
+}
+
+// Load reads the session of the id, or of the only session whose id starts with it.
+func (store *sessionStore) Load(id string) (*models.Session, error) {
+	id, err := store.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	return store.load(id)
+}
+
+// Latest reads the most recently updated session, with the errors of the session files it skipped as warnings.
+func (store *sessionStore) Latest() (*models.Session, []error, error) {
+	sessions, warnings, err := store.List()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, warnings, ErrSessionNotFound
+	}
+	return sessions[0], warnings, nil
+}
+
+// List reads every session, the most recently updated first. The session files that cannot be read are skipped and
+// their errors returned as warnings.
+func (store *sessionStore) List() ([]*models.Session, []error, error) {
+	ids, err := store.ids()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sessions []*models.Session
+	var warnings []error
+	for _, id := range ids {
+		session, err := store.load(id)
+		if err != nil {
+			warnings = append(warnings, err)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, warnings, nil
+}
+
+// Delete removes the session of the id, or of the only session whose id starts with it.
+func (store *sessionStore) Delete(id string) error {
+	id, err := store.resolve(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(store.sessionPath(id)); err != nil {
+		return fmt.Errorf("failed to delete session %s: %w", id, err)
+	}
+	return nil
+}
+
+func (store *sessionStore) sessionPath(id string) string {
+	return filepath.Join(store.dir, id+sessionFileExtension)
+}
+
+// ids lists the ids of the session files
+func (store *sessionStore) ids() ([]string, error) {
+	entries, err := os.ReadDir(store.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), sessionFileExtension) {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(entry.Name(), sessionFileExtension))
+	}
+	return ids, nil
+}
+
+// resolve finds the id of the session matching id exactly or as the prefix of a single session
+func (store *sessionStore) resolve(id string) (string, error) {
+	if err := validateID(id); err != nil {
+		return "", err
+	}
+	ids, err := store.ids()
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, candidate := range ids {
+		if candidate == id {
+			return id, nil
+		}
+		if strings.HasPrefix(candidate, id) {
+			matches = append(matches, candidate)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("session id %s is ambiguous, it matches %s", id, strings.Join(matches, ", "))
+	}
+}
+
+func (store *sessionStore) load(id string) (*models.Session, error) {
+	data, err := os.ReadFile(store.sessionPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", id, err)
+	}
+
+	session, err := decodeSession(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode session %s: %w", id, err)
+	}
+	return session, nil
+}
+
+// decodeSession reads a session file of any version up to the current one
+func decodeSession(data []byte) (*models.Session, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	switch {
+	case header.Version < 1:
+		return nil, fmt.Errorf("missing format version")
+	case header.Version > models.CurrentVersion:
+		return nil, fmt.Errorf("format version %d is newer than the supported version %d, upgrade codai", header.Version, models.CurrentVersion)
+	}
+
+	// The upgrades of the files of older versions go here, once the format changes
+	var session models.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// validateID rejects the ids that are not a plain file name
+func validateID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid session id %q", id)
+	}
+	return nil
+}
+
+// NewSession creates a session of the provider and model, identified by its creation time and a random suffix.
+func NewSession(provider string, model string) (*models.Session, error) {
+	suffix := make([]byte, 2)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("failed to create the session id: %w", err)
+	}
+
+	now := time.Now().UTC()
+	return &models.Session{
+		Version:   models.CurrentVersion,
+		ID:        now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Provider:  provider,
+		Model:     model,
+	}, nil
+}
+
+// NewSessionStore creates a session store keeping the sessions in dir.
+func NewSessionStore(dir string) contracts.ISessionStore {
+	return &sessionStore{dir: dir}
+}
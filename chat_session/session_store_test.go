@@ -0,0 +1,115 @@
+package chat_session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	journal_models "github.com/meysamhadeli/codai/change_journal/models"
+	"github.com/meysamhadeli/codai/chat_session/models"
+	analyzer_models "github.com/meysamhadeli/codai/code_analyzer/models"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionStore_SaveLoad(t *testing.T) {
+	store := NewSessionStore(filepath.Join(t.TempDir(), ".codai", "sessions"))
+
+	session, err := NewSession("openai", "gpt-4o")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^\d{8}-\d{6}-[0-9a-f]{4}$`, session.ID)
+
+	session.Title = "add a main function"
+	session.Messages = []provider_models.Message{
+		provider_models.NewTextMessage(provider_models.RoleUser, "add a main function").WithTime(time.Now()),
+		provider_models.NewAssistantMessage("", []provider_models.ToolCall{{ID: "call_1", Name: "read_file", Arguments: `{"path": "main.go"}`}}),
+		provider_models.NewToolMessage(provider_models.ToolResult{CallID: "call_1", Name: "read_file", Content: "package main"}),
+		provider_models.NewTextMessage(provider_models.RoleAssistant, "Done."),
+	}
+	session.Changes = []journal_models.JournalEntry{{
+		Record: &analyzer_models.TransactionRecord{ID: "1", Operations: []analyzer_models.FileOperation{
+			{RelativePath: "main.go", Action: analyzer_models.FileModified, PreviousContent: []byte("package main\n")},
+		}},
+		Applied: true,
+	}}
+	session.Usage = models.TokenUsage{InputTokens: 10, OutputTokens: 5}
+	assert.NoError(t, store.Save(session))
+
+	loaded, err := store.Load(session.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.CurrentVersion, loaded.Version)
+	assert.Equal(t, session.Messages, loaded.Messages)
+	assert.Equal(t, session.Changes, loaded.Changes)
+	assert.Equal(t, session.Usage, loaded.Usage)
+	assert.Equal(t, "gpt-4o", loaded.Model)
+
+	// A prefix of a single session is enough
+	loaded, err = store.Load(session.ID[:10])
+	assert.NoError(t, err)
+	assert.Equal(t, session.ID, loaded.ID)
+
+	_, err = store.Load("../journal")
+	assert.Error(t, err)
+	_, err = store.Load("unknown")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestSessionStore_ListLatestDelete(t *testing.T) {
+	store := NewSessionStore(filepath.Join(t.TempDir(), ".codai", "sessions"))
+
+	_, _, err := store.Latest()
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	first := &models.Session{ID: "20260101-100000-aaaa"}
+	second := &models.Session{ID: "20260101-090000-bbbb"}
+	assert.NoError(t, store.Save(first))
+	assert.NoError(t, store.Save(second))
+
+	sessions, warnings, err := store.List()
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, second.ID, sessions[0].ID, "the most recently updated session comes first")
+
+	latest, _, err := store.Latest()
+	assert.NoError(t, err)
+	assert.Equal(t, second.ID, latest.ID)
+
+	_, err = store.Load("20260101")
+	assert.ErrorContains(t, err, "ambiguous")
+
+	assert.NoError(t, store.Delete("20260101-09"))
+	sessions, _, err = store.List()
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.ErrorIs(t, store.Delete(second.ID), ErrSessionNotFound)
+}
+
+func TestSessionStore_Versions(t *testing.T) {
+	dir := t.TempDir()
+	store := NewSessionStore(dir)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "newer.json"), []byte(`{"version": 99, "id": "newer"}`), 0600))
+	_, err := store.Load("newer")
+	assert.ErrorContains(t, err, "newer than the supported version")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "unversioned.json"), []byte(`{"id": "unversioned"}`), 0600))
+	_, err = store.Load("unversioned")
+	assert.ErrorContains(t, err, "missing format version")
+
+	// The sessions that cannot be read are skipped by the listing, with a warning each
+	valid := &models.Session{ID: "20260101-100000-aaaa"}
+	assert.NoError(t, store.Save(valid))
+	sessions, warnings, err := store.List()
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Len(t, warnings, 2)
+	latest, warnings, err := store.Latest()
+	assert.NoError(t, err)
+	assert.Equal(t, valid.ID, latest.ID)
+	assert.Len(t, warnings, 2)
+
+	// A session that cannot be read can still be deleted
+	assert.NoError(t, store.Delete("newer"))
+}
@@ -0,0 +1,416 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/meysamhadeli/codai/code_analyzer"
+	contracts_analyzer "github.com/meysamhadeli/codai/code_analyzer/contracts"
+	"github.com/meysamhadeli/codai/code_analyzer/models"
+	output_models "github.com/meysamhadeli/codai/output/models"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of the apply command, for scripts
+const (
+	applyExitFailure  = 1
+	applyExitCanceled = 130
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply the changes of one or more requests without interactive confirmation",
+	Long: `The 'apply' command runs requests through the whole pipeline of 'code' without a session: it sends each request
+to the AI with the context of the project, extracts the code changes of the answer and applies them. Requests come
+from '--prompt', or from a JSONL file given with '--file' where every line is an object with a 'prompt', or a 'title'
+and a 'body', and an optional 'id' or 'request_id'. Use '--yes' to write the changes to the project, or '--dry-run' to
+write them to a patch file for 'git apply' instead; the next requests of a dry run get the files as changed by the
+previous ones, and patch files are never part of the context. Changes that do not parse are skipped. The exit code
+is 1 when a request or a change failed.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Args:          cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prompt, _ := cmd.Flags().GetString("prompt")
+		file, _ := cmd.Flags().GetString("file")
+		yes, _ := cmd.Flags().GetBool("yes")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		patchFile, _ := cmd.Flags().GetString("patch")
+
+		return handleApplyCommand(cmd, prompt, file, yes, dryRun, patchFile)
+	},
+}
+
+func init() {
+	// Define command-specific flags
+	applyCmd.Flags().StringP("prompt", "p", "", "The request to apply")
+	applyCmd.Flags().StringP("file", "f", "", "JSONL file of requests to apply in order")
+	applyCmd.Flags().BoolP("yes", "y", false, "Write the changes to the project without confirmation")
+	applyCmd.Flags().Bool("dry-run", false, "Write the changes to a patch file instead of the project")
+	applyCmd.Flags().String("patch", "codai.patch", "Patch file written by '--dry-run', '-' for stdout")
+	applyCmd.MarkFlagsMutuallyExclusive("prompt", "file")
+	applyCmd.MarkFlagsOneRequired("prompt", "file")
+
+	// Add the apply command to the root command
+	rootCmd.AddCommand(applyCmd)
+}
+
+// applyRequest is one request of an apply run
+type applyRequest struct {
+	ID     string
+	Prompt string
+}
+
+// applyRequestLine is a line of a JSONL file of requests
+type applyRequestLine struct {
+	ID        string `json:"id"`
+	RequestID string `json:"request_id"`
+	Prompt    string `json:"prompt"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+}
+
+func handleApplyCommand(cmd *cobra.Command, prompt string, file string, yes bool, dryRun bool, patchFile string) error {
+	if !yes && !dryRun {
+		return fmt.Errorf("use '--yes' to write the changes to the project without confirmation, or '--dry-run' to write them to a patch file")
+	}
+
+	var requests []applyRequest
+	if file != "" {
+		var err error
+		if requests, err = readApplyRequests(file); err != nil {
+			return err
+		}
+	} else if strings.TrimSpace(prompt) != "" {
+		requests = []applyRequest{{ID: "prompt", Prompt: strings.TrimSpace(prompt)}}
+	}
+	if len(requests) == 0 {
+		return fmt.Errorf("there are no requests to apply")
+	}
+
+	// Keep stdout for the patch when it is written there
+	var textWriter io.Writer = os.Stdout
+	if dryRun && patchFile == "-" {
+		textWriter = os.Stderr
+	}
+	rootDependencies := handleRootCommandWithOutput(cmd, textWriter)
+	if rootDependencies == nil {
+		return fmt.Errorf("failed to initialize codai")
+	}
+	out := rootDependencies.Output
+	defer out.Close()
+
+	if dryRun && patchFile == "-" && out.Structured() {
+		return fmt.Errorf("the patch can't be written to stdout with the '%s' output, use '--patch <file>'", rootDependencies.Config.Output)
+	}
+
+	if err := runApply(rootDependencies, requests, dryRun, patchFile); err != nil {
+		if out.Structured() {
+			out.Error(err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// readApplyRequests reads the requests of a JSONL file, skipping blank lines
+func readApplyRequests(path string) ([]applyRequest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the requests: %w", err)
+	}
+	defer file.Close()
+
+	var requests []applyRequest
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var line applyRequestLine
+		if err := json.Unmarshal([]byte(text), &line); err != nil {
+			return nil, fmt.Errorf("line %d of %s is not a valid request: %w", number, path, err)
+		}
+
+		request := applyRequest{ID: line.ID, Prompt: strings.TrimSpace(line.Prompt)}
+		if request.ID == "" {
+			request.ID = line.RequestID
+		}
+		if request.ID == "" {
+			request.ID = fmt.Sprintf("line %d", number)
+		}
+		if request.Prompt == "" {
+			request.Prompt = strings.TrimSpace(strings.TrimSpace(line.Title) + "\n\n" + strings.TrimSpace(line.Body))
+		}
+		if request.Prompt == "" {
+			return nil, fmt.Errorf("line %d of %s has no 'prompt', 'title' or 'body'", number, path)
+		}
+		requests = append(requests, request)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the requests: %w", err)
+	}
+	return requests, nil
+}
+
+// applyRun applies the requests one after the other, so every request sees the changes of the previous ones
+type applyRun struct {
+	rootDependencies *RootDependencies
+	dryRun           bool
+
+	// transaction stages the changes of all the requests of a dry run, which are never committed, with stager
+	transaction contracts_analyzer.IChangeTransaction
+	stager      *code_analyzer.ChangeStager
+}
+
+func runApply(rootDependencies *RootDependencies, requests []applyRequest, dryRun bool, patchFile string) error {
+	out := rootDependencies.Output
+	if rootDependencies.CurrentChatProvider == nil {
+		return fmt.Errorf("no AI provider is configured")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	run := &applyRun{rootDependencies: rootDependencies, dryRun: dryRun}
+	if dryRun {
+		run.transaction = rootDependencies.Analyzer.BeginTransaction()
+		run.stager = code_analyzer.NewChangeStager(rootDependencies.Cwd, rootDependencies.Analyzer, run.transaction)
+		defer run.transaction.Discard()
+	}
+
+	failedRequests := 0
+	for i, request := range requests {
+		out.Title(fmt.Sprintf("[%d/%d] %s: %s", i+1, len(requests), request.ID, strings.SplitN(request.Prompt, "\n", 2)[0]))
+
+		failedFiles, err := run.apply(ctx, request)
+		if ctx.Err() != nil {
+			return &exitCodeError{code: applyExitCanceled, err: fmt.Errorf("the apply run was canceled")}
+		}
+		if err != nil {
+			out.Error(fmt.Sprintf("Request %s failed: %v", request.ID, err))
+			failedRequests++
+		} else if failedFiles > 0 {
+			out.Error(fmt.Sprintf("Request %s failed to change %d file(s).", request.ID, failedFiles))
+			failedRequests++
+		}
+	}
+
+	if dryRun {
+		if err := run.writePatch(patchFile); err != nil {
+			return err
+		}
+	}
+
+	rootDependencies.TokenManagement.DisplayTokens(rootDependencies.Config.AIProviderConfig.Provider, rootDependencies.Config.AIProviderConfig.Model)
+
+	if failedRequests > 0 {
+		return &exitCodeError{code: applyExitFailure, err: fmt.Errorf("%d of %d request(s) failed", failedRequests, len(requests))}
+	}
+	return nil
+}
+
+// apply runs one request through the pipeline and returns the number of file changes that failed
+func (run *applyRun) apply(ctx context.Context, request applyRequest) (int, error) {
+	rootDependencies := run.rootDependencies
+	out := rootDependencies.Output
+
+	codes, err := run.projectCodes()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load the context of the project: %w", err)
+	}
+
+	response, err := run.complete(ctx, codes, request.Prompt, "")
+	if err != nil {
+		return 0, err
+	}
+
+	// Send the full files the AI asked for when it only had the summary of their code
+	if requestedContext, err := rootDependencies.Analyzer.TryGetInCompletedCodeBlocK(response); requestedContext != "" && err == nil {
+		out.Info("🔄 Auto-accepting additional context for complete code blocks...")
+		if response, err = run.complete(ctx, codes, request.Prompt, requestedContext); err != nil {
+			return 0, err
+		}
+	}
+
+	var changes []models.CodeChange
+	if rootDependencies.Config.EditFormat == "search_replace" {
+		changes = rootDependencies.Analyzer.ExtractSearchReplaceChanges(response)
+	} else {
+		changes = rootDependencies.Analyzer.ExtractCodeChanges(response)
+	}
+	if len(changes) == 0 {
+		out.Warning("The AI answered without code changes.")
+		return 0, nil
+	}
+	out.CodeChanges(changes)
+
+	transaction, stager := run.transaction, run.stager
+	if !run.dryRun {
+		transaction = rootDependencies.Analyzer.BeginTransaction()
+		stager = code_analyzer.NewChangeStager(rootDependencies.Cwd, rootDependencies.Analyzer, transaction)
+	}
+
+	failed := 0
+	for _, change := range changes {
+		if !run.stage(stager, change) {
+			failed++
+		}
+	}
+
+	if run.dryRun {
+		return failed, nil
+	}
+
+	stagedPaths := transaction.StagedPaths()
+	if len(stagedPaths) == 0 {
+		return failed, nil
+	}
+	record, err := transaction.Commit()
+	if err != nil {
+		for _, path := range stagedPaths {
+			out.ChangeResult(path, output_models.ChangeFailed, fmt.Sprintf("Error applying changes to %s.", path))
+		}
+		return failed + len(stagedPaths), fmt.Errorf("failed to apply changes: %w", err)
+	}
+	for _, operation := range record.Operations {
+		out.ChangeResult(operation.RelativePath, output_models.ChangeApplied, fmt.Sprintf("✔️ Applied changes to %s.", operation.RelativePath))
+	}
+
+	// The next requests must see the changed files, not their cached content
+	if err := rootDependencies.Analyzer.ClearCache(); err != nil {
+		out.Warning(fmt.Sprintf("Failed to clear the cache of the project files: %v", err))
+	}
+	return failed, nil
+}
+
+// projectCodes returns the context of the project for a request. The files a dry run changed are not written, so the
+// next requests get the whole staged content of these files instead of the content on disk, whatever the display mode.
+func (run *applyRun) projectCodes() ([]string, error) {
+	rootDependencies := run.rootDependencies
+	fullContext, err := rootDependencies.Analyzer.GetProjectFilesWithDisplayMode(rootDependencies.Cwd, rootDependencies.Config.FileDisplayMode)
+	if err != nil {
+		return nil, err
+	}
+	if !run.dryRun {
+		return fullContext.RawCodes, nil
+	}
+
+	staged := run.stager.Staged()
+	var codes []string
+	for i, file := range fullContext.FileData {
+		path := filepath.ToSlash(filepath.Clean(file.RelativePath))
+		content, ok := staged[path]
+		switch {
+		case !ok:
+			codes = append(codes, fullContext.RawCodes[i])
+		case content != "":
+			codes = append(codes, fmt.Sprintf("**File: %s**\n\n%s", path, content))
+		}
+		delete(staged, path)
+	}
+
+	// The files created by the staged changes
+	var created []string
+	for path, content := range staged {
+		if content != "" {
+			created = append(created, path)
+		}
+	}
+	sort.Strings(created)
+	for _, path := range created {
+		codes = append(codes, fmt.Sprintf("**File: %s**\n\n%s", path, staged[path]))
+	}
+	return codes, nil
+}
+
+// complete sends a request to the AI and returns its whole answer
+func (run *applyRun) complete(ctx context.Context, codes []string, prompt string, requestedContext string) (string, error) {
+	rootDependencies := run.rootDependencies
+	finalPrompt, userInputPrompt := rootDependencies.Analyzer.GeneratePromptWithEditFormat(codes, prompt, requestedContext, rootDependencies.Config.EditFormat)
+
+	stopThinking := rootDependencies.Output.Thinking("AI is thinking...")
+	defer stopThinking()
+
+	answer, err := provider_models.CollectAnswer(rootDependencies.CurrentChatProvider.ChatCompletionRequest(ctx, provider_models.NewConversation(finalPrompt, nil, userInputPrompt)), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get AI response: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return answer.Content, nil
+}
+
+// stage stages the change of a file, skipping the files it would leave unparsable, and reports whether it succeeded
+func (run *applyRun) stage(stager *code_analyzer.ChangeStager, change models.CodeChange) bool {
+	out := run.rootDependencies.Output
+
+	hunks, err := stager.Stage(change.RelativePath, change.Code)
+	var rejectedError *code_analyzer.PathRejectedError
+	var syntaxError *code_analyzer.SyntaxCheckError
+	switch {
+	case errors.As(err, &rejectedError):
+		out.ChangeResult(change.RelativePath, output_models.ChangeRejected, fmt.Sprintf("🚫 Change rejected by path policy, %v", err))
+		return false
+	case errors.As(err, &syntaxError):
+		out.DiffHeader(change.RelativePath, hunks)
+		var details []string
+		for _, detail := range syntaxError.Errors {
+			details = append(details, "- "+detail.String())
+		}
+		out.ChangeResult(change.RelativePath, output_models.ChangeFailed, fmt.Sprintf("❌ %s does not parse after the change, it was skipped:\n%s", change.RelativePath, strings.Join(details, "\n")))
+		return false
+	case err != nil:
+		if len(hunks) > 0 {
+			out.DiffHeader(change.RelativePath, hunks)
+		}
+		out.ChangeResult(change.RelativePath, output_models.ChangeFailed, fmt.Sprintf("Error applying changes to %s: %v", change.RelativePath, err))
+		return false
+	case len(hunks) == 0:
+		out.ChangeResult(change.RelativePath, output_models.ChangeUnchanged, fmt.Sprintf("No changes for file %s.", change.RelativePath))
+		return true
+	}
+
+	out.DiffHeader(change.RelativePath, hunks)
+	if run.dryRun {
+		out.ChangeResult(change.RelativePath, output_models.ChangeAccepted, fmt.Sprintf("✔️ Added changes of %s to the patch.", change.RelativePath))
+	}
+	return true
+}
+
+// writePatch writes the changes of a dry run as a unified diff for 'git apply'
+func (run *applyRun) writePatch(patchFile string) error {
+	out := run.rootDependencies.Output
+
+	diff, files := run.stager.Patch()
+	if files == 0 {
+		out.Warning("No changes to write to the patch.")
+		return nil
+	}
+
+	if patchFile == "-" {
+		fmt.Print(diff)
+		return nil
+	}
+	if err := os.WriteFile(patchFile, []byte(diff), 0644); err != nil {
+		return fmt.Errorf("failed to write the patch: %w", err)
+	}
+	out.Success(fmt.Sprintf("✔️ Wrote the changes of %d file(s) to %s, apply them with 'git apply %s'.", files, patchFile, patchFile))
+	return nil
+}
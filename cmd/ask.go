@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of the ask command, for shell scripts
+const (
+	askExitFailure  = 1
+	askExitUsage    = 2
+	askExitCanceled = 130
+)
+
+// askCmd represents the ask command
+var askCmd = &cobra.Command{
+	Use:   `ask "<question>"`,
+	Short: "Ask the AI a one-shot question about the project",
+	Long: `The 'ask' command sends one question to the AI with the context of the project, streams the answer to stdout
+and exits, without the interactive session of 'code'. The input piped to codai is added to the question, e.g.
+'git diff | codai ask "review this"', and without a question the piped input is the question. Use '--no-stdin' when
+stdin is a pipe that is not meant for codai, like the stdin some CI jobs inherit. The exit code is 0 on success, 1
+when the request fails, 2 without a question and 130 when it is canceled.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return handleAskCommand(cmd, args)
+	},
+}
+
+func init() {
+	askCmd.Flags().Bool("no-stdin", false, "Don't read the input piped to codai, e.g. when stdin is a pipe inherited from a CI job")
+
+	// Add the ask command to the root command
+	rootCmd.AddCommand(askCmd)
+}
+
+func handleAskCommand(cmd *cobra.Command, args []string) error {
+	question := strings.TrimSpace(strings.Join(args, " "))
+
+	// Stdin is only read when it is a pipe or a file, and not with '--no-stdin' as the stdin inherited from CI jobs or
+	// task runners may be a pipe that never closes
+	var input string
+	if noStdin, _ := cmd.Flags().GetBool("no-stdin"); !noStdin && isPipedInput(os.Stdin) {
+		var err error
+		if input, err = readInput(os.Stdin); err != nil {
+			return &exitCodeError{code: askExitFailure, err: fmt.Errorf("failed to read stdin: %w", err)}
+		}
+	}
+	if question == "" && input == "" {
+		return &exitCodeError{code: askExitUsage, err: fmt.Errorf(`a question is required, e.g. codai ask "where is the config loaded?"`)}
+	}
+	if input != "" {
+		question = fmt.Sprintf("%s\n\n## Here is the input of the user\n\n```\n%s\n```", question, input)
+	}
+
+	// Keep stdout for the answer, the token usage goes to stderr unless the output is structured
+	rootDependencies := handleRootCommandWithOutput(cmd, os.Stderr)
+	if rootDependencies == nil {
+		return &exitCodeError{code: askExitFailure, err: fmt.Errorf("failed to initialize codai")}
+	}
+	out := rootDependencies.Output
+	defer out.Close()
+
+	if err := askQuestion(rootDependencies, question); err != nil {
+		if out.Structured() {
+			out.Error(err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// askQuestion sends the question to the AI and streams the answer, raw to stdout or as chunk events
+func askQuestion(rootDependencies *RootDependencies, question string) error {
+	out := rootDependencies.Output
+	if rootDependencies.CurrentChatProvider == nil {
+		return &exitCodeError{code: askExitFailure, err: fmt.Errorf("no AI provider is configured")}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	fullContext, err := rootDependencies.Analyzer.GetProjectFilesWithDisplayMode(rootDependencies.Cwd, rootDependencies.Config.FileDisplayMode)
+	if err != nil {
+		return &exitCodeError{code: askExitFailure, err: fmt.Errorf("failed to load the context of the project: %w", err)}
+	}
+
+	finalPrompt, userInputPrompt := rootDependencies.Analyzer.GeneratePrompt(fullContext.RawCodes, question, "")
+
+	// The spinner writes to stderr, only show it to a user watching the terminal
+	var spinnerAI *pterm.SpinnerPrinter
+	if !out.Structured() && isTerminal(os.Stderr) {
+		spinner := pterm.DefaultSpinner.WithStyle(pterm.NewStyle(pterm.FgLightBlue)).WithSequence("⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏").WithDelay(100).WithRemoveWhenDone(true)
+		spinnerAI, _ = spinner.Start("AI is thinking...")
+	}
+	stopSpinner := func() {
+		if spinnerAI != nil {
+			_ = spinnerAI.Stop()
+			spinnerAI = nil
+		}
+	}
+	defer stopSpinner()
+
+	responseChan := rootDependencies.CurrentChatProvider.ChatCompletionRequest(ctx, provider_models.NewConversation(finalPrompt, nil, userInputPrompt))
+
+	// The channel is drained until the provider closes it, after showing the token usage
+	var lastContent string
+	_, responseErr := provider_models.CollectAnswer(responseChan, func(content string) error {
+		stopSpinner()
+		if out.Structured() {
+			_ = out.Chunk(ctx, content)
+		} else {
+			fmt.Print(content)
+		}
+		lastContent = content
+		return nil
+	})
+	stopSpinner()
+
+	if !out.Structured() && lastContent != "" && !strings.HasSuffix(lastContent, "\n") {
+		fmt.Println()
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		return &exitCodeError{code: askExitCanceled, err: fmt.Errorf("the request was canceled")}
+	case responseErr != nil:
+		return &exitCodeError{code: askExitFailure, err: fmt.Errorf("failed to get AI response: %w", responseErr)}
+	case lastContent == "":
+		return &exitCodeError{code: askExitFailure, err: errors.New("the AI returned an empty answer")}
+	}
+
+	return nil
+}
+
+// readInput reads the input piped to codai, and nothing when stdin is a terminal
+func readInput(stdin *os.File) (string, error) {
+	if isTerminal(stdin) {
+		return "", nil
+	}
+
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(input)), nil
+}
+
+// isPipedInput reports whether stdin is a named pipe or a regular file, rather than a terminal or a device
+func isPipedInput(stdin *os.File) bool {
+	info, err := stdin.Stat()
+	return err == nil && (info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular())
+}
+
+// isTerminal reports whether file is a terminal rather than a pipe or a regular file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
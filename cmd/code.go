@@ -3,11 +3,21 @@ package cmd
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"github.com/meysamhadeli/codai/agent"
+	contracts_agent "github.com/meysamhadeli/codai/agent/contracts"
+	"github.com/meysamhadeli/codai/code_analyzer"
 	"github.com/meysamhadeli/codai/code_analyzer/models"
-	"github.com/meysamhadeli/codai/constants/lipgloss"
+	"github.com/meysamhadeli/codai/embed_data"
+	"github.com/meysamhadeli/codai/mcp"
+	contracts_mcp "github.com/meysamhadeli/codai/mcp/contracts"
+	contracts_output "github.com/meysamhadeli/codai/output/contracts"
+	output_models "github.com/meysamhadeli/codai/output/models"
+	"github.com/meysamhadeli/codai/patch"
+	contracts_provider "github.com/meysamhadeli/codai/providers/contracts"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
 	"github.com/meysamhadeli/codai/utils"
-	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 	"os"
 	"os/signal"
@@ -22,14 +32,35 @@ var codeCmd = &cobra.Command{
 	Long: `The 'code' subcommand allows users to leverage a session-based AI assistant for a range of coding tasks. 
 This assistant can suggest new code, refactor existing code, review code for improvements, and even propose new features 
 based on the current project context. Each interaction is part of a session, allowing for continuous context and 
-improved responses throughout the user experience.`,
+improved responses throughout the user experience. Sessions are saved in the project and can be resumed with
+'--resume', the latest one without an id.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		rootDependencies := handleRootCommand(cmd)
-		handleCodeCommand(rootDependencies)
+		resume, _ := cmd.Flags().GetString("resume")
+		// The id may follow '--resume' as an argument
+		if resume == resumeLatest && len(args) == 1 {
+			resume = args[0]
+		}
+
+		rootDependencies := handleRootCommandWithOutput(cmd, os.Stdout)
+		if rootDependencies == nil {
+			return
+		}
+		defer rootDependencies.Output.Close()
+		handleCodeCommand(rootDependencies, resume)
 	},
 }
 
-func handleCodeCommand(rootDependencies *RootDependencies) {
+// resumeLatest is the value of '--resume' without an id, resuming the latest session
+const resumeLatest = "latest"
+
+func init() {
+	codeCmd.Flags().String("resume", "", "Resume a saved session by id, or the latest one without an id")
+	codeCmd.Flags().Lookup("resume").NoOptDefVal = resumeLatest
+}
+
+// handleCodeCommand runs a chat session, resuming the saved session of resumeID when it is set
+func handleCodeCommand(rootDependencies *RootDependencies, resumeID string) {
 
 	// Create a context with cancel function
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -38,32 +69,93 @@ func handleCodeCommand(rootDependencies *RootDependencies) {
 	var requestedContext string
 	var fullContext *models.FullContextData
 
-	spinner := pterm.DefaultSpinner.WithStyle(pterm.NewStyle(pterm.FgLightBlue)).WithSequence("⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏").WithDelay(100).WithRemoveWhenDone(true)
+	out := rootDependencies.Output
+
+	// Save the session in the project after every turn, to resume it later
+	session, err := newCodeSession(rootDependencies)
+	if err != nil {
+		out.Error(fmt.Sprintf("%v", err))
+		return
+	}
 
 	go utils.GracefulShutdown(ctx, cancel, func() {
 
+		if err := session.autoSave(); err != nil {
+			out.Error(fmt.Sprintf("Error saving the session: %v", err))
+		}
 		rootDependencies.ChatHistory.ClearHistory()
 		rootDependencies.TokenManagement.ClearToken()
+		out.Close()
 	})
 
 	reader := bufio.NewReader(os.Stdin)
 
-	codeOptionsBox := lipgloss.BoxStyle.Render("/help  Help for code subcommand")
-	fmt.Println(codeOptionsBox)
+	// Start every session with an empty change journal, so /undo never reaches changes of a previous session
+	if err := rootDependencies.ChangeJournal.Clear(); err != nil {
+		out.Error(fmt.Sprintf("%v", err))
+	}
 
-	spinnerLoadContext, _ := spinner.Start("Loading Context...")
+	// A resumed session brings back its history, its changes for /undo and its token usage
+	if resumeID != "" {
+		if err := resumeCodeSession(session, resumeID); err != nil {
+			out.Error(fmt.Sprintf("Error resuming the session: %v", err))
+			return
+		}
+	}
+
+	// Commit every accepted change set to git when checkpoints are enabled
+	var checkpoints *utils.GitCheckpoints
+	if rootDependencies.Config.GitCheckpoints {
+		checkpoints = utils.NewGitCheckpoints(rootDependencies.Cwd, rootDependencies.CurrentChatProvider)
+		if err := checkpoints.CheckGitRepo(); err != nil {
+			out.Warning(fmt.Sprintf("Git checkpoints are disabled: %v", err))
+			checkpoints = nil
+		}
+	}
+
+	// Let the AI call the built-in tools and the tools of the configured MCP servers
+	var tools contracts_agent.IToolSet
+	var builtinTools contracts_agent.IBuiltinTools
+	toolProvider, supportsTools := rootDependencies.CurrentChatProvider.(contracts_provider.IToolCallingProvider)
+	switch {
+	case rootDependencies.Config.ToolCalling && supportsTools:
+		builtinTools = agent.NewBuiltinTools(rootDependencies.Cwd, rootDependencies.Analyzer)
+		toolManager := startMCPTools(ctx, rootDependencies)
+		if toolManager != nil {
+			defer toolManager.Close()
+		}
+		tools = agent.NewToolSets(builtinTools, toolManager)
+	case len(rootDependencies.Config.MCPServers) > 0 && !rootDependencies.Config.ToolCalling:
+		out.Warning("MCP servers are disabled, set 'tool_calling' to true to enable them.")
+	case len(rootDependencies.Config.MCPServers) > 0:
+		out.Warning(fmt.Sprintf("MCP servers are disabled, the provider '%s' does not support tool calling.", rootDependencies.Config.AIProviderConfig.Provider))
+	}
+	// The tools the user allowed for the whole session
+	alwaysAllowedTools := make(map[string]bool)
+
+	out.Box("/help  Help for code subcommand")
+
+	stopLoadContext := out.Progress("Loading Context...")
 
 	// Get all data files from the root directory using configured display mode
-	fullContext, err := rootDependencies.Analyzer.GetProjectFilesWithDisplayMode(rootDependencies.Cwd, rootDependencies.Config.FileDisplayMode)
+	fullContext, err = rootDependencies.Analyzer.GetProjectFilesWithDisplayMode(rootDependencies.Cwd, rootDependencies.Config.FileDisplayMode)
 
+	stopLoadContext()
 	if err != nil {
-		spinnerLoadContext.Stop()
-		fmt.Print("\r")
-		fmt.Println(lipgloss.Red.Render(fmt.Sprintf("%v", err)))
+		out.Error(fmt.Sprintf("%v", err))
 	}
 
-	spinnerLoadContext.Stop()
-	fmt.Print("\r")
+	// Request sent to the AI instead of reading user input, e.g. to correct syntax errors or a failed verify command
+	var pendingInput string
+
+	// Verify fix loop state: the number of fix requests sent for the current user request, and whether the
+	// current turn is one of them along with the token usage when it started
+	fixIteration := 0
+	pendingIsFix, inFixTurn := false, false
+	var fixTurnInputTokens, fixTurnOutputTokens int
+
+	// The last request typed by the user, kept for the body of checkpoint commits
+	var userRequest string
 
 	// Launch the user input handler in a goroutine
 startLoop: // Label for the start loop
@@ -75,24 +167,59 @@ startLoop: // Label for the start loop
 
 		default:
 			displayTokens := func() {
+				// Account the tokens of a verify fix turn before showing the session usage
+				if inFixTurn {
+					_, input, output := rootDependencies.TokenManagement.GetCurrentTokenUsage()
+					rootDependencies.TokenManagement.UsedFixTokens(input-fixTurnInputTokens, output-fixTurnOutputTokens)
+					inFixTurn = false
+				}
 				rootDependencies.TokenManagement.DisplayTokens(rootDependencies.Config.AIProviderConfig.Provider, rootDependencies.Config.AIProviderConfig.Model)
 			}
 
-			// Get user input with context cancellation support
-			userInput, err := utils.InputPromptWithContext(ctx, reader)
+			if err := session.autoSave(); err != nil {
+				out.Error(fmt.Sprintf("Error saving the session: %v", err))
+			}
+
+			// Get user input with context cancellation support, unless a follow-up request is queued
+			var userInput string
+			if pendingInput != "" {
+				userInput, pendingInput, err = pendingInput, "", nil
+				if pendingIsFix {
+					pendingIsFix, inFixTurn = false, true
+					_, fixTurnInputTokens, fixTurnOutputTokens = rootDependencies.TokenManagement.GetCurrentTokenUsage()
+					out.Info(fmt.Sprintf("🔁 Asking the AI to fix the verify failure (iteration %d/%d)...", fixIteration, rootDependencies.Config.VerifyMaxIterations))
+				} else {
+					out.Info("🔁 Asking the AI to correct the changes...")
+				}
+			} else {
+				out.Prompt("input", "Enter a request or a subcommand", nil)
+				userInput, err = utils.InputPromptWithContext(ctx, reader)
+				fixIteration, inFixTurn = 0, false
+				userRequest = userInput
+			}
 
 			if err != nil {
 				// Check if the error is due to context cancellation (Ctrl+C)
 				if err == context.Canceled {
-					fmt.Println(lipgloss.Yellow.Render("\n🔄 Exiting..."))
+					out.Warning("\n🔄 Exiting...")
+					if checkpoints != nil && len(checkpoints.Checkpoints()) > 0 {
+						out.Warning(fmt.Sprintf("Kept %d git checkpoint commit(s).", len(checkpoints.Checkpoints())))
+					}
 					return
 				}
-				fmt.Println(lipgloss.Red.Render(fmt.Sprintf("%v", err)))
+				out.Error(fmt.Sprintf("%v", err))
 				continue
 			}
 
 			if userInput == "" {
-				fmt.Print("\r")
+				continue
+			}
+
+			if findCheckpointSubCommand(ctx, out, userInput, checkpoints) {
+				continue
+			}
+
+			if findSessionSubCommand(userInput, session) {
 				continue
 			}
 
@@ -104,22 +231,28 @@ startLoop: // Label for the start loop
 			}
 
 			if exit {
+				finishCheckpoints(ctx, out, checkpoints, reader)
+				if len(rootDependencies.ChatHistory.GetHistory()) > 0 {
+					if err := session.autoSave(); err != nil {
+						out.Error(fmt.Sprintf("Error saving the session: %v", err))
+					} else {
+						out.Info(fmt.Sprintf("💾 Saved session %s, resume it with 'codai code --resume %s'.", session.ID(), session.ID()))
+					}
+				}
 				return
 			}
 
+			session.request(userRequest)
+
 			var aiResponseBuilder strings.Builder
 
 			chatRequestOperation := func() error {
 
-				finalPrompt, userInputPrompt := rootDependencies.Analyzer.GeneratePrompt(fullContext.RawCodes, rootDependencies.ChatHistory.GetHistory(), userInput, requestedContext)
+				finalPrompt, userInputPrompt := rootDependencies.Analyzer.GeneratePromptWithEditFormat(fullContext.RawCodes, userInput, requestedContext, rootDependencies.Config.EditFormat)
+				if tools != nil {
+					finalPrompt = fmt.Sprintf("%s\n\n______\n%s", finalPrompt, string(embed_data.ToolCallingPrompt))
+				}
 
-				// 启动AI思考动画
-				aiSpinner := pterm.DefaultSpinner.
-					WithStyle(pterm.NewStyle(pterm.FgCyan)).
-					WithSequence("🤔", "🧠", "💭", "✨", "🚀", "💡").
-					WithDelay(1000).
-					WithRemoveWhenDone(true)
-				
 				// 根据不同provider显示不同的动画文案
 				var spinnerText string
 				switch rootDependencies.Config.AIProviderConfig.Provider {
@@ -146,53 +279,83 @@ startLoop: // Label for the start loop
 				default:
 					spinnerText = "AI is thinking..."
 				}
-				
-				spinnerAI, _ := aiSpinner.Start(spinnerText)
-
-				// Step 7: Send the relevant code and user input to the AI API
-				responseChan := rootDependencies.CurrentChatProvider.ChatCompletionRequest(ctx, userInputPrompt, finalPrompt)
-
-				// Iterate over response channel to handle streamed data or errors.
-				firstResponse := true
-				for response := range responseChan {
-					if response.Err != nil {
-						spinnerAI.Stop()
-						return response.Err
+
+				compactHistory(ctx, out, rootDependencies, finalPrompt, userInputPrompt)
+
+				// Send the request again with the tool calls of the AI and their results, until it answers without calls.
+				// The messages of the exchange are added to the history once it is complete.
+				messages := provider_models.NewConversation(finalPrompt, rootDependencies.ChatHistory.GetHistory(), userInputPrompt)
+				exchangeStart := len(messages) - 1
+				for round := 1; ; round++ {
+					stopThinking := out.Thinking(spinnerText)
+
+					// Step 7: Send the relevant code and user input to the AI API
+					var responseChan <-chan provider_models.StreamResponse
+					if tools != nil {
+						responseChan = toolProvider.ChatCompletionRequestWithTools(ctx, messages, tools.Tools())
+					} else {
+						responseChan = rootDependencies.CurrentChatProvider.ChatCompletionRequest(ctx, messages)
 					}
 
-					if response.Done {
+					// Iterate over response channel to handle streamed data or errors.
+					firstResponse := true
+					answer, err := provider_models.CollectAnswer(responseChan, func(content string) error {
+						// 收到第一个响应内容时停止spinner并开始显示内容
 						if firstResponse {
-							spinnerAI.Stop()
+							stopThinking()
+							out.Text("") // 为输出内容留出空间
+							firstResponse = false
+						}
+
+						aiResponseBuilder.WriteString(content)
+
+						if err := out.Chunk(ctx, content); err != nil {
+							// Check if it was cancelled by user
+							if err == context.Canceled {
+								return fmt.Errorf("Output cancelled by user")
+							}
+							return fmt.Errorf("Error rendering markdown: %v", err)
 						}
-						rootDependencies.ChatHistory.AddToHistory(userInput, aiResponseBuilder.String())
 						return nil
+					})
+					if firstResponse {
+						stopThinking()
 					}
-
-					// 收到第一个响应内容时停止spinner并开始显示内容
-					if firstResponse && response.Content != "" {
-						spinnerAI.Stop()
-						fmt.Print("\n") // 为输出内容留出空间
-						firstResponse = false
+					if err != nil {
+						return err
 					}
 
-					aiResponseBuilder.WriteString(response.Content)
+					// A partial answer may end in the middle of a change, so nothing of it is applied nor kept
+					if !answer.Done {
+						return fmt.Errorf("the AI response was interrupted")
+					}
+					toolCalls := answer.ToolCalls
 
-					language := utils.DetectLanguageFromCodeBlock(response.Content)
-					if err := utils.RenderAndPrintMarkdownWithContext(ctx, response.Content, language, rootDependencies.Config.Theme); err != nil {
-						// Check if it was cancelled by user
-						if err == context.Canceled {
-							return fmt.Errorf("Output cancelled by user")
+					// The calls left unanswered are not kept, the providers reject them in the next requests
+					if len(toolCalls) == 0 || tools == nil || round == maxToolCallRounds {
+						if len(toolCalls) > 0 && tools != nil {
+							out.Warning(fmt.Sprintf("Stopped after %d rounds of tool calls.", maxToolCallRounds))
 						}
-						return fmt.Errorf("Error rendering markdown: %v", err)
+						messages = append(messages, provider_models.NewAssistantMessage(answer.Content, nil))
+						rootDependencies.ChatHistory.AddMessages(messages[exchangeStart:]...)
+						return nil
+					}
+
+					messages = append(messages, provider_models.NewAssistantMessage(answer.Content, toolCalls))
+					for _, call := range toolCalls {
+						messages = append(messages, provider_models.NewToolMessage(runToolCall(ctx, out, tools, alwaysAllowedTools, call, reader)))
 					}
 				}
+			}
 
-				return nil
+			// Drop the changes proposed with tools for a failed request
+			if builtinTools != nil {
+				builtinTools.TakeProposedChanges()
 			}
 
 			// First, execute the AI request
 			if err := chatRequestOperation(); err != nil {
-				fmt.Println(lipgloss.Red.Render(fmt.Sprintf("%v", err)))
+				out.Error(fmt.Sprintf("%v", err))
 				displayTokens()
 				continue startLoop
 			}
@@ -201,69 +364,471 @@ startLoop: // Label for the start loop
 			requestedContext, err = rootDependencies.Analyzer.TryGetInCompletedCodeBlocK(aiResponseBuilder.String())
 
 			if requestedContext != "" && err == nil {
-				fmt.Print("\n")
-				fmt.Println(lipgloss.Green.Render("🔄 Auto-accepting additional context for complete code blocks..."))
+				out.Text("")
+				out.Success("🔄 Auto-accepting additional context for complete code blocks...")
 
 				// Reset the builder for second request
 				aiResponseBuilder.Reset()
 
 				if err := chatRequestOperation(); err != nil {
-					fmt.Println(lipgloss.Red.Render(fmt.Sprintf("%v", err)))
+					out.Error(fmt.Sprintf("%v", err))
 					displayTokens()
 					continue
 				}
 			}
 
 			// Extract code from AI response and structure this code to apply to git
-			changes := rootDependencies.Analyzer.ExtractCodeChanges(aiResponseBuilder.String())
+			var changes []models.CodeChange
+			if rootDependencies.Config.EditFormat == "search_replace" {
+				changes = rootDependencies.Analyzer.ExtractSearchReplaceChanges(aiResponseBuilder.String())
+			} else {
+				changes = rootDependencies.Analyzer.ExtractCodeChanges(aiResponseBuilder.String())
+			}
+			if builtinTools != nil {
+				changes = append(changes, builtinTools.TakeProposedChanges()...)
+			}
 
 			if changes == nil {
-				fmt.Println()
+				out.Text("")
 				displayTokens()
 				continue
 			}
 
-			fmt.Print("\n")
+			out.CodeChanges(changes)
+			out.Text("")
+
+			// Review and apply the changes, queueing a request for corrections when the user sends syntax errors back
+			var record *models.TransactionRecord
+			record, pendingInput = reviewAndApplyChanges(rootDependencies, changes, reader)
+
+			// Commit the applied changes as a git checkpoint
+			if record != nil && checkpoints != nil {
+				createCheckpoint(ctx, out, checkpoints, record, userRequest)
+			}
+
+			// Run the verify command on the applied changes and offer its failures to the AI
+			if record != nil && pendingInput == "" && rootDependencies.Config.VerifyCommand != "" {
+				if fixRequest := verifyChanges(ctx, rootDependencies, reader, fixIteration); fixRequest != "" {
+					fixIteration++
+					pendingInput, pendingIsFix = fixRequest, true
+				}
+			}
 
-			// Try to apply changes
-			for _, change := range changes {
+			displayTokens()
+		}
+	}
+}
 
-				// Prompt the user to accept or reject the changes
-				promptAccepted, err := utils.ConfirmPrompt(change.RelativePath, reader)
+// reviewAndApplyChanges shows the diff of every change, stages the accepted hunks and writes them all together or not at all.
+// It returns the record of the applied changes, nil when no file was changed, and a request asking the AI to correct
+// the syntax errors the user chose to send back, if any.
+func reviewAndApplyChanges(rootDependencies *RootDependencies, changes []models.CodeChange, reader *bufio.Reader) (*models.TransactionRecord, string) {
+	out := rootDependencies.Output
+	var applied *models.TransactionRecord
+	transaction := rootDependencies.Analyzer.BeginTransaction()
+	acceptAll, quit := false, false
+	var syntaxFeedback []string
+	for _, change := range changes {
+		if quit {
+			break
+		}
+
+		before, after, err := transaction.Preview(change.RelativePath, change.Code)
+		if err != nil {
+			var rejectedError *code_analyzer.PathRejectedError
+			if errors.As(err, &rejectedError) {
+				out.ChangeResult(change.RelativePath, output_models.ChangeRejected, fmt.Sprintf("🚫 Change rejected by path policy, %v", err))
+				continue
+			}
+			out.ChangeResult(change.RelativePath, output_models.ChangeFailed, fmt.Sprintf("Error applying changes: %v", err))
+			continue
+		}
+
+		hunks := patch.Diff(before, after, 3)
+		if len(hunks) == 0 {
+			out.ChangeResult(change.RelativePath, output_models.ChangeUnchanged, fmt.Sprintf("No changes for file %s.", change.RelativePath))
+			continue
+		}
+
+		out.DiffHeader(change.RelativePath, hunks)
+
+		accepted := make([]bool, len(hunks))
+		acceptedCount := 0
+		editRequested := false
+		for i, hunk := range hunks {
+			out.Hunk(change.RelativePath, i+1, len(hunks), hunk)
+
+			decision := utils.ReviewAccept
+			if !acceptAll {
+				// Prompt the user to accept or reject the hunk
+				out.Prompt("hunk", fmt.Sprintf("Accept hunk %d/%d of %s ?", i+1, len(hunks), change.RelativePath), []string{"y", "n", "a", "e", "q"})
+				decision, err = utils.HunkPrompt(change.RelativePath, i+1, len(hunks), reader)
 				if err != nil {
-					fmt.Println(lipgloss.Red.Render(fmt.Sprintf("Error getting user prompt: %v", err)))
-					continue
+					out.Error(fmt.Sprintf("Error getting user prompt: %v", err))
 				}
+			}
 
-				if promptAccepted {
-					err := rootDependencies.Analyzer.ApplyChanges(change.RelativePath, change.Code)
-					if err != nil {
-						fmt.Println(lipgloss.Red.Render(fmt.Sprintf("Error applying changes: %v", err)))
-						continue
-					}
-					fmt.Println(lipgloss.Green.Render("✔️ Changes accepted!"))
+			if decision == utils.ReviewAcceptAll {
+				acceptAll = true
+			}
+			if decision == utils.ReviewQuit {
+				quit = true
+				break
+			}
+			if decision == utils.ReviewEdit {
+				editRequested = true
+				break
+			}
+			if decision == utils.ReviewAccept || decision == utils.ReviewAcceptAll {
+				accepted[i] = true
+				acceptedCount++
+			}
+		}
 
-					fmt.Print("\r")
+		// Let the user tweak the proposed file; an unchanged or emptied file is a reject
+		if editRequested {
+			edited, err := utils.EditInEditor(change.RelativePath, after)
+			if err != nil {
+				out.ChangeResult(change.RelativePath, output_models.ChangeFailed, fmt.Sprintf("Error editing changes: %v", err))
+				continue
+			}
+			if edited == after || strings.TrimSpace(edited) == "" {
+				out.ChangeResult(change.RelativePath, output_models.ChangeRejected, "❌ Changes rejected.")
+				continue
+			}
+			if accept, feedback := checkSyntax(rootDependencies, change.RelativePath, edited, reader); !accept {
+				syntaxFeedback = append(syntaxFeedback, feedback...)
+				continue
+			}
+			if err := transaction.StageContent(change.RelativePath, edited); err != nil {
+				out.ChangeResult(change.RelativePath, output_models.ChangeFailed, fmt.Sprintf("Error applying changes: %v", err))
+				continue
+			}
+			out.ChangeResult(change.RelativePath, output_models.ChangeAccepted, "✔️ Edited changes accepted!")
+			continue
+		}
 
-				} else {
-					fmt.Println(lipgloss.Red.Render("❌ Changes rejected."))
+		if acceptedCount == 0 {
+			out.ChangeResult(change.RelativePath, output_models.ChangeRejected, "❌ Changes rejected.")
+			continue
+		}
+
+		merged := patch.Merge(before, after, hunks, accepted)
+		if accept, feedback := checkSyntax(rootDependencies, change.RelativePath, merged, reader); !accept {
+			syntaxFeedback = append(syntaxFeedback, feedback...)
+			continue
+		}
+
+		if err := transaction.StageContent(change.RelativePath, merged); err != nil {
+			out.ChangeResult(change.RelativePath, output_models.ChangeFailed, fmt.Sprintf("Error applying changes: %v", err))
+			continue
+		}
+
+		if acceptedCount == len(hunks) {
+			out.ChangeResult(change.RelativePath, output_models.ChangeAccepted, "✔️ Changes accepted!")
+		} else {
+			out.ChangeResult(change.RelativePath, output_models.ChangeAccepted, fmt.Sprintf("✔️ Accepted %d of %d hunks.", acceptedCount, len(hunks)))
+		}
+	}
+
+	if stagedPaths := transaction.StagedPaths(); len(stagedPaths) > 0 {
+		record, err := transaction.Commit()
+		if err != nil {
+			for _, path := range stagedPaths {
+				out.ChangeResult(path, output_models.ChangeFailed, fmt.Sprintf("Error applying changes to %s.", path))
+			}
+			out.Error(fmt.Sprintf("Error applying changes: %v", err))
+		} else {
+			applied = record
+			if out.Structured() {
+				for _, operation := range record.Operations {
+					out.ChangeResult(operation.RelativePath, output_models.ChangeApplied, fmt.Sprintf("✔️ Applied changes to %s.", operation.RelativePath))
 				}
+			} else {
+				out.Success(fmt.Sprintf("✔️ Applied changes to %d file(s).", len(stagedPaths)))
 			}
+			if err := rootDependencies.ChangeJournal.Record(record); err != nil {
+				out.Error(fmt.Sprintf("Error recording changes for /undo: %v", err))
+			}
+		}
+	}
 
-			displayTokens()
+	if len(syntaxFeedback) == 0 {
+		return applied, ""
+	}
+	return applied, "The following files you changed do not parse, so they were not written to disk. " +
+		"Send corrected changes for these files only, based on their current content:\n\n" + strings.Join(syntaxFeedback, "\n\n")
+}
+
+// maxToolCallRounds limits the requests of an answer of the AI calling tools
+const maxToolCallRounds = 10
+
+// startMCPTools starts the MCP servers of the configuration, it returns nil when none of them has tools
+func startMCPTools(ctx context.Context, rootDependencies *RootDependencies) contracts_mcp.IToolManager {
+	out := rootDependencies.Output
+	if len(rootDependencies.Config.MCPServers) == 0 {
+		return nil
+	}
+
+	stopStart := out.Progress("Starting MCP servers...")
+	toolManager, errs := mcp.StartToolManager(ctx, rootDependencies.Cwd, rootDependencies.Config.MCPServers)
+	stopStart()
+
+	for _, err := range errs {
+		out.Warning(fmt.Sprintf("%v", err))
+	}
+	if len(toolManager.Tools()) == 0 {
+		toolManager.Close()
+		return nil
+	}
+
+	out.Info(fmt.Sprintf("🔧 %d tool(s) of MCP servers are available to the AI.", len(toolManager.Tools())))
+	return toolManager
+}
+
+// runToolCall runs a tool call of the AI once the user allows it, and returns the result to send back to the AI
+func runToolCall(ctx context.Context, out contracts_output.IOutput, tools contracts_agent.IToolSet, alwaysAllowed map[string]bool,
+	call provider_models.ToolCall, reader *bufio.Reader) provider_models.ToolResult {
+	description, ok := tools.Describe(call.Name)
+	if !ok {
+		// The tool set tells the AI the tool does not exist
+		return tools.CallTool(ctx, call)
+	}
+
+	out.Text("")
+	out.Info(fmt.Sprintf("🔧 The AI calls %s with: %s", description, call.Arguments))
+
+	if !tools.AutoApproved(call.Name) && !alwaysAllowed[call.Name] {
+		out.Prompt("tool", fmt.Sprintf("Allow the call of %s ?", description), []string{"y", "n", "a"})
+		switch utils.ToolCallPrompt(description, reader) {
+		case utils.ToolCallDeny:
+			out.Warning(fmt.Sprintf("Denied the call of %s.", description))
+			return provider_models.ToolResult{CallID: call.ID, Name: call.Name, Content: "The user denied this tool call.", IsError: true}
+		case utils.ToolCallAlways:
+			alwaysAllowed[call.Name] = true
+		}
+	}
+
+	stopCall := out.Progress(fmt.Sprintf("Calling %s...", description))
+	result := tools.CallTool(ctx, call)
+	stopCall()
+
+	if result.IsError {
+		out.Warning(fmt.Sprintf("The call of %s failed: %s", description, utils.TailLines(result.Content, 5)))
+	} else {
+		out.Success(fmt.Sprintf("✔️ %s returned %d characters.", description, len(result.Content)))
+	}
+	return result
+}
+
+// createCheckpoint commits the files of the applied changes to git, with the request of the user in the commit body
+func createCheckpoint(ctx context.Context, out contracts_output.IOutput, checkpoints *utils.GitCheckpoints, record *models.TransactionRecord, userRequest string) {
+	var paths []string
+	for _, operation := range record.Operations {
+		paths = append(paths, operation.RelativePath)
+	}
+
+	stopCheckpoint := out.Progress("Creating git checkpoint...")
+	checkpoint, err := checkpoints.Checkpoint(ctx, paths, userRequest)
+	stopCheckpoint()
+
+	if err != nil {
+		out.Error(fmt.Sprintf("Error creating git checkpoint: %v", err))
+		return
+	}
+	if checkpoint != nil {
+		out.Success(fmt.Sprintf("✔️ Git checkpoint %s: %s", checkpoint.Hash[:7], checkpoint.Subject))
+	}
+}
+
+// findCheckpointSubCommand handles the subcommands of git checkpoints and reports whether command was one of them
+func findCheckpointSubCommand(ctx context.Context, out contracts_output.IOutput, command string, checkpoints *utils.GitCheckpoints) bool {
+	switch command {
+	case "/checkpoints", "/squash-checkpoints", "/drop-checkpoints":
+	default:
+		return false
+	}
+
+	if checkpoints == nil {
+		out.Warning("Git checkpoints are not enabled, set 'git_checkpoints' to true to enable them.")
+		return true
+	}
+
+	switch command {
+	case "/checkpoints":
+		if len(checkpoints.Checkpoints()) == 0 {
+			out.Info("No git checkpoint commits in this session.")
+			return true
 		}
+		var builder strings.Builder
+		for i, checkpoint := range checkpoints.Checkpoints() {
+			builder.WriteString(fmt.Sprintf("%d. %s %s", i+1, checkpoint.Hash[:7], checkpoint.Subject))
+			if i < len(checkpoints.Checkpoints())-1 {
+				builder.WriteString("\n")
+			}
+		}
+		out.Box(builder.String())
+	case "/squash-checkpoints":
+		squashCheckpoints(ctx, out, checkpoints)
+	case "/drop-checkpoints":
+		dropCheckpoints(out, checkpoints)
+	}
+	return true
+}
+
+// finishCheckpoints lets the user keep, squash or drop the checkpoint commits of the session when it ends
+func finishCheckpoints(ctx context.Context, out contracts_output.IOutput, checkpoints *utils.GitCheckpoints, reader *bufio.Reader) {
+	if checkpoints == nil || len(checkpoints.Checkpoints()) == 0 {
+		return
+	}
+
+	out.Prompt("checkpoints", fmt.Sprintf("Keep, squash or drop the %d git checkpoint commit(s) of this session ?", len(checkpoints.Checkpoints())), []string{"k", "s", "d"})
+	switch utils.CheckpointsPrompt(len(checkpoints.Checkpoints()), reader) {
+	case utils.CheckpointsSquash:
+		squashCheckpoints(ctx, out, checkpoints)
+	case utils.CheckpointsDrop:
+		dropCheckpoints(out, checkpoints)
+	default:
+		out.Info(fmt.Sprintf("Kept %d git checkpoint commit(s).", len(checkpoints.Checkpoints())))
+	}
+}
+
+func squashCheckpoints(ctx context.Context, out contracts_output.IOutput, checkpoints *utils.GitCheckpoints) {
+	count := len(checkpoints.Checkpoints())
+
+	stopSquash := out.Progress("Squashing git checkpoints...")
+	err := checkpoints.Squash(ctx)
+	stopSquash()
+
+	if err != nil {
+		out.Error(fmt.Sprintf("Error squashing git checkpoints: %v", err))
+		return
 	}
+	out.Success(fmt.Sprintf("✔️ Squashed %d git checkpoint commit(s) into one commit.", count))
+}
+
+func dropCheckpoints(out contracts_output.IOutput, checkpoints *utils.GitCheckpoints) {
+	count := len(checkpoints.Checkpoints())
+	if err := checkpoints.Drop(); err != nil {
+		out.Error(fmt.Sprintf("Error dropping git checkpoints: %v", err))
+		return
+	}
+	out.Success(fmt.Sprintf("✔️ Dropped %d git checkpoint commit(s), their changes are kept staged.", count))
+}
+
+// maxVerifyOutputLines limits the verify command output shown and sent to the AI
+const maxVerifyOutputLines = 80
+
+// verifyChanges runs the verify command after changes were applied. When it fails and the fix iterations are not
+// exhausted, it offers the output to the AI and returns the fix request to send, if the user accepts.
+func verifyChanges(ctx context.Context, rootDependencies *RootDependencies, reader *bufio.Reader, fixIteration int) string {
+	command := rootDependencies.Config.VerifyCommand
+	out := rootDependencies.Output
+
+	stopVerify := out.Progress(fmt.Sprintf("Running verify command: %s", command))
+	output, err := utils.RunVerifyCommand(ctx, command, rootDependencies.Cwd)
+	stopVerify()
+
+	if err == nil {
+		if fixIteration > 0 {
+			out.Success(fmt.Sprintf("✔️ Verify command passed after %d fix iteration(s): %s", fixIteration, command))
+		} else {
+			out.Success(fmt.Sprintf("✔️ Verify command passed: %s", command))
+		}
+		return ""
+	}
+
+	output = utils.TailLines(output, maxVerifyOutputLines)
+	out.Error(fmt.Sprintf("❌ Verify command failed: %s (%v)", command, err))
+	out.Info(output)
+
+	if ctx.Err() != nil {
+		return ""
+	}
+
+	if fixIteration >= rootDependencies.Config.VerifyMaxIterations {
+		out.Warning(fmt.Sprintf("Stopped after %d fix iteration(s), the verify command still fails.", fixIteration))
+		return ""
+	}
+
+	out.Prompt("fix", fmt.Sprintf("Send the output to the AI for a fix (iteration %d/%d) ?", fixIteration+1, rootDependencies.Config.VerifyMaxIterations), []string{"y", "n"})
+	if !utils.ConfirmFixRequest(fixIteration+1, rootDependencies.Config.VerifyMaxIterations, reader) {
+		return ""
+	}
+
+	return fmt.Sprintf("After applying your changes, the verify command `%s` failed with this output:\n\n```\n%s\n```\n\n"+
+		"Fix the cause of the failure and send the corrected changes.", command, output)
+}
+
+// checkSyntax validates the content a file will have and, when it does not parse, lets the user apply it anyway,
+// reject it, or send the errors back to the AI. It reports whether the content should be applied and the feedback to send.
+func checkSyntax(rootDependencies *RootDependencies, path string, content string, reader *bufio.Reader) (bool, []string) {
+	syntaxErrors := rootDependencies.Analyzer.ValidateSyntax(path, []byte(content))
+	if len(syntaxErrors) == 0 {
+		return true, nil
+	}
+
+	var details []string
+	for _, syntaxError := range syntaxErrors {
+		details = append(details, "- "+syntaxError.String())
+	}
+	out := rootDependencies.Output
+	out.Warning(fmt.Sprintf("⚠️ %s does not parse after the change:\n%s", path, strings.Join(details, "\n")))
+
+	out.Prompt("syntax", fmt.Sprintf("Apply %s anyway, reject it, or send the errors to the AI ?", path), []string{"a", "r", "s"})
+	switch utils.SyntaxErrorPrompt(path, reader) {
+	case utils.SyntaxApplyAnyway:
+		return true, nil
+	case utils.SyntaxSendToAI:
+		out.Info(fmt.Sprintf("Syntax errors of %s will be sent back to the AI.", path))
+		return false, []string{fmt.Sprintf("### %s\n%s", path, strings.Join(details, "\n"))}
+	default:
+		out.ChangeResult(path, output_models.ChangeRejected, "❌ Changes rejected.")
+		return false, nil
+	}
+}
+
+// compactHistory replaces the older turns of the history with their summary when the request comes near the input
+// limit of the model, and reports the tokens it saved
+func compactHistory(ctx context.Context, out contracts_output.IOutput, rootDependencies *RootDependencies, prompt string, userInput string) {
+	history := rootDependencies.ChatHistory.GetHistory()
+	if !rootDependencies.HistoryCompactor.NeedsCompaction(prompt, history, userInput) {
+		return
+	}
+
+	stopCompacting := out.Thinking("Summarizing the older messages of the history...")
+	compacted, compaction, err := rootDependencies.HistoryCompactor.Compact(ctx, prompt, history, userInput)
+	stopCompacting()
+	if err != nil {
+		out.Warning(fmt.Sprintf("Could not compact the history, sending it in full: %v", err))
+		return
+	}
+	if !compaction.Compacted {
+		if len(history) == 0 {
+			return
+		}
+		out.Warning(fmt.Sprintf("The request of about %d tokens is near the input limit of %d tokens and the history cannot be compacted further, clear it with /clear-history.",
+			compaction.TokensBefore, compaction.MaxInputTokens))
+		return
+	}
+
+	rootDependencies.ChatHistory.ClearHistory()
+	rootDependencies.ChatHistory.AddMessages(compacted...)
+	out.Info(fmt.Sprintf("🗜️ Compacted the history: summarized %d older message(s), saving about %d tokens per request (%d → %d of the %d token limit).",
+		compaction.SummarizedMessages, compaction.SavedTokens(), compaction.TokensBefore, compaction.TokensAfter, compaction.MaxInputTokens))
 }
 
 func findCodeSubCommand(command string, rootDependencies *RootDependencies) (bool, bool) {
+	out := rootDependencies.Output
 	switch command {
 	case "/help":
-		helps := "/clear  Clear screen\n/exit  Exit from codai\n/token  Token information\n/live-token  Session token stats with details\n/clear-token  Clear token from session\n/clear-history  Clear history of chat from session\n/display-mode  Show current file display mode\n/set-display-mode <mode>  Set file display mode (info/relevant/full)"
-		styledHelps := lipgloss.BoxStyle.Render(helps)
-		fmt.Println(styledHelps)
+		helps := "/clear  Clear screen\n/exit  Exit from codai\n/token  Token information\n/live-token  Session token stats with details\n/clear-token  Clear token from session\n/clear-history  Clear history of chat from session\n/display-mode  Show current file display mode\n/set-display-mode <mode>  Set file display mode (info/relevant/full)\n/undo  Undo the last applied changes\n/redo  Redo the last undone changes\n/changes  List the changes applied in this session\n/checkpoints  List the git checkpoint commits of this session\n/squash-checkpoints  Squash the git checkpoint commits into one commit\n/drop-checkpoints  Drop the git checkpoint commits, keeping their changes staged\n/save  Save the session to resume it later\n/load <id>  Load a saved session in place of this one"
+		out.Box(helps)
 		return true, false
 	case "/clear":
-		fmt.Print("\033[2J\033[H")
+		if !out.Structured() {
+			fmt.Print("\033[2J\033[H")
+		}
 		return true, false
 	case "/exit":
 		return false, true
@@ -274,17 +839,27 @@ func findCodeSubCommand(command string, rootDependencies *RootDependencies) (boo
 		)
 		return true, false
 	case "/live-token":
-		// 显示实时token统计信息 
+		// 显示实时token统计信息
 		total, input, output := rootDependencies.TokenManagement.GetCurrentTokenUsage()
 		cost := rootDependencies.TokenManagement.CalculateCost(
 			rootDependencies.Config.AIProviderConfig.Provider,
 			rootDependencies.Config.AIProviderConfig.Model,
 			input, output,
 		)
-		fmt.Printf("📊 Session Token Stats:\n")
-		fmt.Printf("   Total: %d tokens (Input: %d, Output: %d)\n", total, input, output)
-		fmt.Printf("   Cost: $%.6f\n", cost)
-		fmt.Printf("   Model: %s\n", rootDependencies.Config.AIProviderConfig.Model)
+		var stats strings.Builder
+		stats.WriteString("📊 Session Token Stats:\n")
+		stats.WriteString(fmt.Sprintf("   Total: %d tokens (Input: %d, Output: %d)\n", total, input, output))
+		stats.WriteString(fmt.Sprintf("   Cost: $%.6f\n", cost))
+		stats.WriteString(fmt.Sprintf("   Model: %s", rootDependencies.Config.AIProviderConfig.Model))
+		if iterations, fixInput, fixOutput := rootDependencies.TokenManagement.GetFixTokenUsage(); iterations > 0 {
+			fixCost := rootDependencies.TokenManagement.CalculateCost(
+				rootDependencies.Config.AIProviderConfig.Provider,
+				rootDependencies.Config.AIProviderConfig.Model,
+				fixInput, fixOutput,
+			)
+			stats.WriteString(fmt.Sprintf("\n   Verify Fixes: %d iterations, %d tokens (Input: %d, Output: %d), Cost: $%.6f", iterations, fixInput+fixOutput, fixInput, fixOutput, fixCost))
+		}
+		out.Text(stats.String())
 		return true, false
 	case "/clear-token":
 		rootDependencies.TokenManagement.ClearToken()
@@ -292,12 +867,54 @@ func findCodeSubCommand(command string, rootDependencies *RootDependencies) (boo
 	case "/clear-history":
 		rootDependencies.ChatHistory.ClearHistory()
 		return true, false
+	case "/undo":
+		record, err := rootDependencies.ChangeJournal.Undo()
+		if err != nil {
+			out.Error(fmt.Sprintf("Error undoing changes: %v", err))
+			return true, false
+		}
+		out.Success(fmt.Sprintf("↩️ Undid changes to %d file(s).", len(record.Operations)))
+		return true, false
+	case "/redo":
+		record, err := rootDependencies.ChangeJournal.Redo()
+		if err != nil {
+			out.Error(fmt.Sprintf("Error redoing changes: %v", err))
+			return true, false
+		}
+		out.Success(fmt.Sprintf("↪️ Redid changes to %d file(s).", len(record.Operations)))
+		return true, false
+	case "/changes":
+		entries, err := rootDependencies.ChangeJournal.Changes()
+		if err != nil {
+			out.Error(fmt.Sprintf("Error reading changes: %v", err))
+			return true, false
+		}
+		if len(entries) == 0 {
+			out.Text("No changes applied in this session.")
+			return true, false
+		}
+		for i, entry := range entries {
+			header := fmt.Sprintf("#%d  %s", i+1, entry.Record.Timestamp.Format("15:04:05"))
+			if !entry.Applied {
+				header += "  (undone)"
+			}
+			out.Title(header)
+			for _, operation := range entry.Record.Operations {
+				line := fmt.Sprintf("   %-6s %s", operation.Action, operation.RelativePath)
+				if entry.Applied {
+					out.Text(line)
+				} else {
+					out.Info(line)
+				}
+			}
+		}
+		return true, false
 	case "/display-mode":
-		fmt.Printf("Current file display mode: %s\n", rootDependencies.Config.FileDisplayMode)
-		fmt.Println("Available modes:")
-		fmt.Println("  info     - Show only file directory, name, and line count")
-		fmt.Println("  relevant - Show relevant code parts (parsed or first 50 lines)")
-		fmt.Println("  full     - Show complete file content")
+		out.Text(fmt.Sprintf("Current file display mode: %s\n", rootDependencies.Config.FileDisplayMode) +
+			"Available modes:\n" +
+			"  info     - Show only file directory, name, and line count\n" +
+			"  relevant - Show relevant code parts (parsed or first 50 lines)\n" +
+			"  full     - Show complete file content")
 		return true, false
 	default:
 		// Handle set-display-mode command
@@ -307,14 +924,12 @@ func findCodeSubCommand(command string, rootDependencies *RootDependencies) (boo
 				mode := strings.TrimSpace(parts[1])
 				if mode == "info" || mode == "relevant" || mode == "full" {
 					rootDependencies.Config.FileDisplayMode = mode
-					fmt.Printf("File display mode set to: %s\n", mode)
-					fmt.Println("Note: Changes will take effect for new context loading.")
+					out.Text(fmt.Sprintf("File display mode set to: %s\nNote: Changes will take effect for new context loading.", mode))
 				} else {
-					fmt.Println("Invalid display mode. Use 'info', 'relevant', or 'full'.")
+					out.Text("Invalid display mode. Use 'info', 'relevant', or 'full'.")
 				}
 			} else {
-				fmt.Println("Usage: /set-display-mode <mode>")
-				fmt.Println("Available modes: info, relevant, full")
+				out.Text("Usage: /set-display-mode <mode>\nAvailable modes: info, relevant, full")
 			}
 			return true, false
 		}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/meysamhadeli/codai/constants/lipgloss"
+	"github.com/meysamhadeli/codai/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// maxCommitDiffLines limits the staged diff sent to the AI to generate the commit message
+const maxCommitDiffLines = 500
+
+// commitStyles are the supported values of the --style flag, with the instructions sent to the AI for them
+var commitStyles = map[string]string{
+	"": "",
+	"conventional": "Conventional Commits: the first line is '<type>(<optional scope>): <description>', where type is one of " +
+		"feat, fix, docs, style, refactor, perf, test, build, ci, chore or revert, and the description starts in lower case. " +
+		"Breaking changes add '!' after the type or scope and a 'BREAKING CHANGE:' footer.",
+}
+
+// commitCmd represents the commit command
+var commitCmd = &cobra.Command{
+	Use:   "commit",
+	Short: "Commit the staged changes with an AI generated commit message",
+	Long: `The 'commit' command generates a commit message for the staged changes with the AI, using the current branch
+and the recent commits as context. The message can be accepted, edited in $VISUAL or $EDITOR, or regenerated before
+committing. Use '--all' to stage every change first and '--yes' to commit without confirmation, e.g. in scripts.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		style, _ := cmd.Flags().GetString("style")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		return handleCommitCommand(cmd, all, style, yes)
+	},
+}
+
+func init() {
+	// Define command-specific flags
+	commitCmd.Flags().BoolP("all", "a", false, "Stage all changes of the current directory before committing")
+	commitCmd.Flags().String("style", "", "Style of the commit message (e.g., 'conventional')")
+	commitCmd.Flags().BoolP("yes", "y", false, "Commit with the generated message without confirmation")
+
+	// Add the commit command to the root command
+	rootCmd.AddCommand(commitCmd)
+}
+
+func handleCommitCommand(cmd *cobra.Command, all bool, style string, yes bool) error {
+	commitStyle, ok := commitStyles[strings.ToLower(style)]
+	if !ok {
+		return fmt.Errorf("unknown commit style '%s', supported styles are: conventional", style)
+	}
+
+	rootDependencies := handleRootCommand(cmd)
+	if rootDependencies == nil {
+		return fmt.Errorf("failed to initialize codai")
+	}
+	if rootDependencies.CurrentChatProvider == nil {
+		return fmt.Errorf("no AI provider is configured")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	git := utils.NewGitOperations(rootDependencies.Cwd)
+	if err := git.CheckGitRepo(); err != nil {
+		return err
+	}
+
+	if all {
+		if err := git.AddFiles(); err != nil {
+			return err
+		}
+	}
+
+	hasStagedChanges, err := git.HasStagedChanges()
+	if err != nil {
+		return err
+	}
+	if !hasStagedChanges {
+		return fmt.Errorf("no staged changes to commit, stage files with 'git add' or use '--all'")
+	}
+
+	stagedDiff, err := git.GetGitDiff()
+	if err != nil {
+		return err
+	}
+
+	branch, _ := git.GetBranchName()
+
+	// A repository without commits has no history, so the error is ignored
+	recentCommits, _ := git.GetRecentCommits(5)
+	var recentSubjects []string
+	for _, commit := range recentCommits {
+		if parts := strings.Split(commit, "|"); len(parts) >= 2 {
+			recentSubjects = append(recentSubjects, parts[1])
+		}
+	}
+
+	generator := utils.NewCommitMessageGenerator(rootDependencies.CurrentChatProvider)
+	request := utils.CommitMessageRequest{
+		StagedDiff:    utils.TruncateDiff(stagedDiff, maxCommitDiffLines),
+		WorkingDir:    rootDependencies.Cwd,
+		Branch:        branch,
+		RecentCommits: recentSubjects,
+		CommitStyle:   commitStyle,
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	spinner := pterm.DefaultSpinner.WithStyle(pterm.NewStyle(pterm.FgLightBlue)).WithSequence("⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏").WithDelay(100).WithRemoveWhenDone(true)
+
+	var message string
+	for generate := true; ; {
+		if generate {
+			spinnerGenerate, _ := spinner.Start("Generating commit message...")
+			message, err = generator.GenerateCommitMessage(ctx, request)
+			spinnerGenerate.Stop()
+			fmt.Print("\r")
+
+			if err != nil {
+				return err
+			}
+			if message == "" {
+				return fmt.Errorf("the AI returned an empty commit message")
+			}
+			generate = false
+		}
+
+		if yes {
+			break
+		}
+
+		fmt.Println(lipgloss.BoxStyle.Render(message))
+
+		decision := utils.CommitMessagePrompt(reader)
+		if decision == utils.CommitMessageAccept {
+			break
+		}
+
+		switch decision {
+		case utils.CommitMessageEdit:
+			edited, err := utils.EditInEditor("COMMIT_EDITMSG", message)
+			if err != nil {
+				fmt.Println(lipgloss.Red.Render(fmt.Sprintf("%v", err)))
+				continue
+			}
+			if strings.TrimSpace(edited) == "" {
+				fmt.Println(lipgloss.Yellow.Render("The edited commit message is empty, keeping the previous one."))
+				continue
+			}
+			message = strings.TrimSpace(edited)
+		case utils.CommitMessageRegenerate:
+			generate = true
+		case utils.CommitMessageAbort:
+			fmt.Println(lipgloss.Yellow.Render("Commit aborted, the changes are still staged."))
+			return nil
+		}
+	}
+
+	if err := git.Commit(message); err != nil {
+		return err
+	}
+
+	hash, _ := git.GetHead()
+	if len(hash) > 7 {
+		hash = hash[:7]
+	}
+	fmt.Println(lipgloss.Green.Render(fmt.Sprintf("✔️ Committed %s: %s", hash, strings.SplitN(message, "\n", 2)[0])))
+	return nil
+}
@@ -1,23 +1,30 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
-	contracts_provider "github.com/meysamhadeli/codai/providers/contracts"
+	safety_models "github.com/meysamhadeli/codai/command_safety/models"
+	"github.com/meysamhadeli/codai/constants/lipgloss"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/meysamhadeli/codai/utils"
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
-type ExecuteDependencies struct {
-	Provider contracts_provider.IChatAIProvider
-}
-
 var executeCmd = &cobra.Command{
 	Use:   "execute [command]",
 	Short: "Execute AI-suggested commands with user confirmation",
 	Long: `Execute AI-suggested commands with user confirmation.
 Parses AI responses for command suggestions and executes them safely.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return RunExecute(cmd, args)
 	},
@@ -28,27 +35,34 @@ func init() {
 }
 
 func RunExecute(cmd *cobra.Command, args []string) error {
-	deps := cmd.Context().Value("deps").(*RootDependencies)
-	
-	executeDeps := &ExecuteDependencies{
-		Provider: deps.CurrentChatProvider,
+	rootDependencies := handleRootCommand(cmd)
+	if rootDependencies == nil {
+		return fmt.Errorf("failed to initialize codai")
+	}
+	if rootDependencies.CurrentChatProvider == nil {
+		return fmt.Errorf("no AI provider is configured")
 	}
 
-	ctx := context.Background()
+	// Cancel the AI request or the running command on Ctrl+C
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	reader := bufio.NewReader(os.Stdin)
 
 	var userInput string
 	if len(args) > 0 {
 		userInput = strings.Join(args, " ")
 	} else {
 		fmt.Print("Enter command description: ")
-		fmt.Scanln(&userInput)
+		userInput, _ = reader.ReadString('\n')
 	}
+	userInput = strings.TrimSpace(userInput)
 
 	if userInput == "" {
 		return fmt.Errorf("command description cannot be empty")
 	}
 
-	prompt := fmt.Sprintf(`Analyze this command request: "%s"
+	prompt := `Analyze the command request of the user.
 Please provide the exact bash command to execute.
 
 Requirements:
@@ -59,53 +73,126 @@ Requirements:
 - Ensure the command is safe to execute
 
 Example format:
-sudo apt update && sudo apt upgrade -y`, userInput)
-
-	responseChan := executeDeps.Provider.ChatCompletionRequest(ctx, "", prompt)
-	
-	var messageBuilder strings.Builder
-	for response := range responseChan {
-		if response.Err != nil {
-			return fmt.Errorf("failed to get AI response: %w", response.Err)
-		}
-		messageBuilder.WriteString(response.Content)
-	}
-	
-	command := strings.TrimSpace(messageBuilder.String())
+sudo apt update && sudo apt upgrade -y`
+
+	spinner := pterm.DefaultSpinner.WithStyle(pterm.NewStyle(pterm.FgLightBlue)).WithSequence("⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏").WithDelay(100).WithRemoveWhenDone(true)
+	spinnerRequest, _ := spinner.Start("Asking the AI for a command...")
+
+	responseChan := rootDependencies.CurrentChatProvider.ChatCompletionRequest(ctx, provider_models.NewConversation(prompt, nil, userInput))
+
+	answer, err := provider_models.CollectAnswer(responseChan, nil)
+	spinnerRequest.Stop()
+	fmt.Print("\r")
+
+	if err != nil {
+		return fmt.Errorf("failed to get AI response: %w", err)
+	}
+	// A command cut off by a cancellation must not run
+	if !answer.Done {
+		return fmt.Errorf("the AI response was interrupted")
+	}
+
+	command := utils.StripCodeFence(answer.Content)
 	if command == "" {
 		return fmt.Errorf("no command returned from AI")
 	}
 
-	fmt.Printf("\n🤖 AI suggests this command:\n" + 
-		"────────────────────────────────────────\n" +
-		"%s\n" +
+	fmt.Printf("\n🤖 AI suggests this command:\n"+
+		"────────────────────────────────────────\n"+
+		"%s\n"+
 		"────────────────────────────────────────\n", command)
 
-	fmt.Print("\nExecute this command? [y/N]: ")
-	var confirmation string
-	fmt.Scanln(&confirmation)
+	executor := newCommandExecutor(rootDependencies)
+	assessment := executor.Assess(command)
+	printAssessment(assessment)
+
+	if err := executor.CheckCommand(command); err != nil {
+		return fmt.Errorf("the command is not allowed to run: %w", err)
+	}
 
-	if strings.ToLower(confirmation) != "y" {
+	if !utils.ConfirmCommand(assessment, reader) {
 		fmt.Println("Command execution cancelled.")
 		return nil
 	}
 
 	fmt.Println("\nExecuting command...")
-	
-	// Store the command in context for the bash tool to execute
-	ctx = context.WithValue(ctx, "command_to_execute", command)
-	
-	// Execute the command via bash tool
-	execErr := executeCommand(ctx, command)
-	if execErr != nil {
-		return fmt.Errorf("command execution failed: %v", execErr)
+
+	err = executor.ExecuteCommand(ctx, command)
+
+	var exitError *utils.CommandExitError
+	switch {
+	case err == nil:
+		fmt.Println(lipgloss.Green.Render("\n✔️ Command finished with exit code 0"))
+		return nil
+	case errors.Is(err, utils.ErrCommandTimeout):
+		fmt.Println(lipgloss.Red.Render(fmt.Sprintf("\n⏱️ %v", err)))
+		os.Exit(124)
+	case errors.Is(err, context.Canceled):
+		fmt.Println(lipgloss.Yellow.Render("\n🔄 Command canceled."))
+		os.Exit(130)
+	case errors.As(err, &exitError):
+		fmt.Println(lipgloss.Red.Render(fmt.Sprintf("\n❌ Command exited with code %d", exitError.ExitCode)))
+		os.Exit(exitError.ExitCode)
+	}
+
+	return err
+}
+
+// newCommandExecutor creates the executor of the AI-suggested commands, in the sandboxed execution mode when it
+// is configured
+func newCommandExecutor(rootDependencies *RootDependencies) *utils.CommandExecutor {
+	cfg := rootDependencies.Config
+	if !cfg.Sandbox {
+		return utils.NewCommandExecutorWithPolicy(rootDependencies.Cwd, cfg.CommandPolicy)
 	}
 
-	return nil
+	executor := utils.NewCommandExecutorWithSandbox(rootDependencies.Cwd, cfg.CommandPolicy, utils.SandboxOptions{
+		Timeout:        cfg.SandboxTimeout,
+		MaxOutputBytes: cfg.SandboxMaxOutput,
+		AllowEnv:       cfg.SandboxAllowEnv,
+		DenyNetwork:    cfg.SandboxDenyNetwork,
+	})
+
+	network := "allowed"
+	if cfg.SandboxDenyNetwork {
+		network = "denied"
+	}
+	fmt.Println(lipgloss.Gray.Render(fmt.Sprintf("\n🔒 Sandbox: jailed to %s, timeout %s, network %s", rootDependencies.Cwd, cfg.SandboxTimeout, network)))
+	if cfg.SandboxDenyNetwork && !executor.NetworkIsolated() {
+		fmt.Println(lipgloss.Yellow.Render("Network namespaces are not available, commands accessing the network are rejected instead."))
+	}
+
+	return executor
 }
 
-func executeCommand(ctx context.Context, command string) error {
-	// This function will be called by the bash tool
-	// The actual execution happens in the bash tool
-	return nil
-}
\ No newline at end of file
+// riskLevels lists the risk levels of an assessment, the highest first
+func riskLevels(assessment safety_models.CommandAssessment) string {
+	if len(assessment.Risks) == 0 {
+		return assessment.Risk.String()
+	}
+	levels := make([]string, 0, len(assessment.Risks))
+	for _, risk := range assessment.Risks {
+		levels = append(levels, risk.String())
+	}
+	return strings.Join(levels, ", ")
+}
+
+// printAssessment shows the risk level of a command and the reasons of it, colored by how risky it is
+func printAssessment(assessment safety_models.CommandAssessment) {
+	style := lipgloss.Green
+	switch {
+	case assessment.Denied || assessment.Risk == safety_models.RiskPrivileged:
+		style = lipgloss.Red
+	case assessment.Risk >= safety_models.RiskWritesOutsideProject:
+		style = lipgloss.Yellow
+	}
+
+	if assessment.Denied {
+		fmt.Println(style.Render("\n⛔ Risk: denied"))
+	} else {
+		fmt.Println(style.Render(fmt.Sprintf("\nRisk: %s", riskLevels(assessment))))
+	}
+	for _, reason := range assessment.Reasons {
+		fmt.Println(lipgloss.Gray.Render("  - " + reason))
+	}
+}
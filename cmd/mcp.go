@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/meysamhadeli/codai/mcp"
+	"github.com/spf13/cobra"
+)
+
+// mcpCmd represents the mcp command
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve codai as a Model Context Protocol server over stdio",
+	Long: `The 'mcp' command serves the project to agents and IDEs speaking the Model Context Protocol, with JSON-RPC
+messages on stdin and stdout. Its tools summarize the project ('project_summary'), read files ('read_files'), preview
+edits with syntax and path policy checks ('propose_edit') and write them in one transaction ('apply_edit'). Its
+resources are the tree-sitter outline of the symbols of the project ('codai://outline') and of each file
+('codai://outline/{path}'). Logs go to stderr.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Args:          cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return handleMCPCommand(cmd)
+	},
+}
+
+func init() {
+	// Add the mcp command to the root command
+	rootCmd.AddCommand(mcpCmd)
+}
+
+func handleMCPCommand(cmd *cobra.Command) error {
+	// Keep stdout for the messages of the protocol
+	rootDependencies := newRootDependencies(cmd, os.Stderr, false)
+	if rootDependencies == nil {
+		return fmt.Errorf("failed to initialize codai")
+	}
+
+	// The server stops when the client closes stdin, or with the default handling of the signals
+	mcpServer := mcp.NewServer(serverDependencies(rootDependencies))
+	if err := mcpServer.Serve(context.Background(), os.Stdin, os.Stdout); err != nil {
+		return fmt.Errorf("the MCP server failed: %w", err)
+	}
+	return nil
+}
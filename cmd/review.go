@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	charm_lipgloss "github.com/charmbracelet/lipgloss"
+	"github.com/meysamhadeli/codai/code_review"
+	review_models "github.com/meysamhadeli/codai/code_review/models"
+	"github.com/meysamhadeli/codai/config"
+	"github.com/meysamhadeli/codai/constants/lipgloss"
+	"github.com/meysamhadeli/codai/utils"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// maxReviewDiffLines limits the diff sent to the AI for a review
+const maxReviewDiffLines = 1500
+
+// reviewExitFindings is the exit code of a review with findings at or above the severity of --fail-on
+const reviewExitFindings = 1
+
+// The supported values of the --format flag of the review command
+const (
+	reviewFormatText  = "text"
+	reviewFormatJSON  = "json"
+	reviewFormatSARIF = "sarif"
+)
+
+// reviewCmd represents the review command
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Review the staged changes, a commit range or a branch with the AI",
+	Long: `The 'review' command asks the AI for a review of a diff, with the content of the changed files as context.
+By default it reviews the staged changes; use '--range' for a commit range like 'HEAD~3..HEAD', or '--base' for the
+changes of the current branch since it diverged from a base branch. The findings, with their file, line, severity and
+suggestion, are printed as text, JSON or SARIF for code scanning tools. With '--fail-on', the exit code is 1 when
+a finding has at least the given severity.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Args:          cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		revisions, _ := cmd.Flags().GetString("range")
+		base, _ := cmd.Flags().GetString("base")
+		format, _ := cmd.Flags().GetString("format")
+		failOn, _ := cmd.Flags().GetString("fail-on")
+
+		return handleReviewCommand(cmd, revisions, base, format, failOn)
+	},
+}
+
+func init() {
+	// Define command-specific flags
+	reviewCmd.Flags().String("range", "", "Review a commit range instead of the staged changes (e.g., 'HEAD~3..HEAD')")
+	reviewCmd.Flags().String("base", "", "Review the changes of the current branch since it diverged from this branch (e.g., 'main')")
+	reviewCmd.Flags().String("format", reviewFormatText, "Format of the review: 'text', 'json' or 'sarif'")
+	reviewCmd.Flags().String("fail-on", "", "Exit with code 1 when a finding has at least this severity: 'error', 'warning' or 'info'")
+	reviewCmd.MarkFlagsMutuallyExclusive("range", "base")
+
+	// Add the review command to the root command
+	rootCmd.AddCommand(reviewCmd)
+}
+
+func handleReviewCommand(cmd *cobra.Command, revisions string, base string, format string, failOn string) error {
+	format = strings.ToLower(format)
+	switch format {
+	case reviewFormatText, reviewFormatJSON, reviewFormatSARIF:
+	default:
+		return fmt.Errorf("unknown review format '%s', supported formats are: text, json, sarif", format)
+	}
+
+	var failSeverity review_models.Severity
+	if failOn != "" {
+		severity, ok := review_models.ParseSeverity(failOn)
+		if !ok {
+			return fmt.Errorf("unknown severity '%s', supported severities are: error, warning, info", failOn)
+		}
+		failSeverity = severity
+	}
+
+	// Keep stdout for the review when it is JSON or SARIF, the token usage goes to stderr
+	var textWriter io.Writer = os.Stdout
+	if format != reviewFormatText {
+		textWriter = os.Stderr
+	}
+	rootDependencies := newRootDependencies(cmd, textWriter, false)
+	if rootDependencies == nil {
+		return fmt.Errorf("failed to initialize codai")
+	}
+	if rootDependencies.CurrentChatProvider == nil {
+		return fmt.Errorf("no AI provider is configured")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	git := utils.NewGitOperations(rootDependencies.Cwd)
+	if err := git.CheckGitRepo(); err != nil {
+		return err
+	}
+
+	request, err := reviewRequest(git, revisions, base)
+	if err != nil {
+		return err
+	}
+
+	// The spinner writes to stderr, only show it to a user watching the terminal
+	var spinnerReview *pterm.SpinnerPrinter
+	if isTerminal(os.Stderr) {
+		spinner := pterm.DefaultSpinner.WithStyle(pterm.NewStyle(pterm.FgLightBlue)).WithSequence("⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏").WithDelay(100).WithRemoveWhenDone(true)
+		spinnerReview, _ = spinner.Start(fmt.Sprintf("Reviewing %s...", request.Description))
+	}
+
+	reviewer := code_review.NewCodeReviewer(rootDependencies.Cwd, rootDependencies.Analyzer, rootDependencies.CurrentChatProvider)
+	result, err := reviewer.Review(ctx, request)
+	if spinnerReview != nil {
+		_ = spinnerReview.Stop()
+	}
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case reviewFormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	case reviewFormatSARIF:
+		sarif, err := code_review.ToSARIF(result, config.DefaultConfig.Version)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(sarif))
+	default:
+		printReview(result)
+	}
+
+	if failSeverity != "" {
+		if count := result.CountAtLeast(failSeverity); count > 0 {
+			return &exitCodeError{code: reviewExitFindings, err: fmt.Errorf("the review found %d finding(s) with a severity of at least '%s'", count, failSeverity)}
+		}
+	}
+	return nil
+}
+
+// reviewRequest gets the diff to review: the commit range, the changes of the branch since base, or the staged changes
+func reviewRequest(git *utils.GitOperations, revisions string, base string) (review_models.ReviewRequest, error) {
+	var diff, description, revision string
+	var err error
+	switch {
+	case revisions != "":
+		description, revision = revisions, revisionTarget(revisions)
+		diff, err = git.GetRevisionDiff(revisions)
+	case base != "":
+		description, revision = base+"...HEAD", "HEAD"
+		diff, err = git.GetBranchDiff(base)
+	default:
+		description, revision = "staged changes", review_models.RevisionIndex
+		diff, err = git.GetGitDiff()
+	}
+	if err != nil {
+		return review_models.ReviewRequest{}, err
+	}
+
+	if strings.TrimSpace(diff) == "" {
+		if revisions == "" && base == "" {
+			return review_models.ReviewRequest{}, fmt.Errorf("no staged changes to review, stage files with 'git add' or use '--range' or '--base'")
+		}
+		return review_models.ReviewRequest{}, fmt.Errorf("no changes to review in %s", description)
+	}
+
+	return review_models.ReviewRequest{Diff: utils.TruncateDiff(diff, maxReviewDiffLines), Description: description, Revision: revision}, nil
+}
+
+// revisionTarget returns the revision of the new version of the files in the diff of revisions: the end of a range,
+// HEAD when the range leaves it out, or the working tree for a single revision
+func revisionTarget(revisions string) string {
+	for _, separator := range []string{"...", ".."} {
+		if _, to, ok := strings.Cut(revisions, separator); ok {
+			if to == "" {
+				return "HEAD"
+			}
+			return to
+		}
+	}
+	return ""
+}
+
+// printReview prints the findings of a review for the terminal
+func printReview(result *review_models.ReviewResult) {
+	fmt.Println(lipgloss.Gray.Render(fmt.Sprintf("Reviewed %d file(s) of %s.", len(result.Files), result.Description)))
+	if result.Summary != "" {
+		fmt.Println(lipgloss.BoxStyle.Render(result.Summary))
+	}
+
+	if len(result.Findings) == 0 {
+		fmt.Println(lipgloss.Green.Render("✔️ No findings."))
+		return
+	}
+
+	counts := make(map[review_models.Severity]int)
+	for _, finding := range result.Findings {
+		counts[finding.Severity]++
+
+		location := finding.File
+		if finding.Line > 0 {
+			location = fmt.Sprintf("%s:%d", finding.File, finding.Line)
+		}
+
+		fmt.Println()
+		fmt.Printf("%s %s %s\n", severityStyle(finding.Severity).Render(string(finding.Severity)), lipgloss.LightBlueB.Render(location), lipgloss.Gray.Render("["+finding.Category+"]"))
+		fmt.Println(lipgloss.BlueSky.Render(finding.Title))
+		if finding.Message != "" && finding.Message != finding.Title {
+			fmt.Println(finding.Message)
+		}
+		if finding.Suggestion != "" {
+			fmt.Println(lipgloss.Green.Render("Suggestion: ") + finding.Suggestion)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println(lipgloss.BoxStyle.Render(fmt.Sprintf("Findings: %d - Errors: %d - Warnings: %d - Info: %d", len(result.Findings),
+		counts[review_models.SeverityError], counts[review_models.SeverityWarning], counts[review_models.SeverityInfo])))
+}
+
+func severityStyle(severity review_models.Severity) charm_lipgloss.Style {
+	switch severity {
+	case review_models.SeverityError:
+		return lipgloss.Red
+	case review_models.SeverityWarning:
+		return lipgloss.Yellow
+	default:
+		return lipgloss.Info
+	}
+}
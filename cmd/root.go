@@ -1,19 +1,31 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"github.com/meysamhadeli/codai/change_journal"
+	contracts_journal "github.com/meysamhadeli/codai/change_journal/contracts"
 	"github.com/meysamhadeli/codai/chat_history"
 	contracts2 "github.com/meysamhadeli/codai/chat_history/contracts"
+	"github.com/meysamhadeli/codai/chat_session"
+	contracts_session "github.com/meysamhadeli/codai/chat_session/contracts"
 	"github.com/meysamhadeli/codai/code_analyzer"
 	contracts_analyzer "github.com/meysamhadeli/codai/code_analyzer/contracts"
 	"github.com/meysamhadeli/codai/config"
 	"github.com/meysamhadeli/codai/constants/lipgloss"
+	"github.com/meysamhadeli/codai/history_compaction"
+	contracts_compaction "github.com/meysamhadeli/codai/history_compaction/contracts"
+	"github.com/meysamhadeli/codai/output"
+	contracts_output "github.com/meysamhadeli/codai/output/contracts"
 	"github.com/meysamhadeli/codai/providers"
 	contracts_provider "github.com/meysamhadeli/codai/providers/contracts"
 	"github.com/meysamhadeli/codai/token_management"
 	"github.com/meysamhadeli/codai/token_management/contracts"
+	"github.com/meysamhadeli/codai/utils"
 	"github.com/spf13/cobra"
+	"io"
 	"os"
+	"path/filepath"
 )
 
 // RootDependencies holds the dependencies for the root command
@@ -24,6 +36,10 @@ type RootDependencies struct {
 	Config              *config.Config
 	ChatHistory         contracts2.IChatHistory
 	TokenManagement     contracts.ITokenManagement
+	ChangeJournal       contracts_journal.IChangeJournal
+	SessionStore        contracts_session.ISessionStore
+	HistoryCompactor    contracts_compaction.IHistoryCompactor
+	Output              contracts_output.IOutput
 }
 
 // RootCmd represents the 'context' command
@@ -51,6 +67,16 @@ var rootCmd = &cobra.Command{
 }
 
 func handleRootCommand(cmd *cobra.Command) *RootDependencies {
+	return newRootDependencies(cmd, os.Stdout, false)
+}
+
+// handleRootCommandWithOutput creates the dependencies of the commands supporting the structured output formats of
+// the 'output' option. The text format is written to textWriter, the structured formats to stdout.
+func handleRootCommandWithOutput(cmd *cobra.Command, textWriter io.Writer) *RootDependencies {
+	return newRootDependencies(cmd, textWriter, true)
+}
+
+func newRootDependencies(cmd *cobra.Command, textWriter io.Writer, structuredOutput bool) *RootDependencies {
 
 	var err error
 	var rootDependencies = &RootDependencies{}
@@ -58,35 +84,85 @@ func handleRootCommand(cmd *cobra.Command) *RootDependencies {
 	// Get current working directory
 	rootDependencies.Cwd, err = os.Getwd()
 	if err != nil || rootDependencies.Cwd == "" {
-		fmt.Println(lipgloss.Red.Render(fmt.Sprintf("error getting current directory")))
+		fmt.Fprintln(os.Stderr, lipgloss.Red.Render(fmt.Sprintf("error getting current directory")))
 		return nil
 	}
 
 	rootDependencies.Config = config.LoadConfigWithCache(cmd, rootDependencies.Cwd)
 
-	rootDependencies.TokenManagement = token_management.NewTokenManager()
+	outputFormat := output.FormatText
+	if structuredOutput {
+		outputFormat = rootDependencies.Config.Output
+	}
+	rootDependencies.Output, err = output.NewOutput(outputFormat, textWriter, os.Stdout, rootDependencies.Config.Theme)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, lipgloss.Red.Render(fmt.Sprintf("%v", err)))
+		return nil
+	}
+	if rootDependencies.Output.Structured() {
+		// Keep stdout for the events
+		utils.PromptOutput = os.Stderr
+	}
+
+	rootDependencies.TokenManagement = token_management.NewTokenManagerWithOutput(rootDependencies.Output)
 
 	rootDependencies.ChatHistory = chat_history.NewChatHistory()
 
-	rootDependencies.Analyzer = code_analyzer.NewCodeAnalyzer(rootDependencies.Cwd, rootDependencies.Config.EnableCache)
+	rootDependencies.Analyzer = code_analyzer.NewCodeAnalyzerWithProtectedPaths(rootDependencies.Cwd, rootDependencies.Config.EnableCache, rootDependencies.Config.ProtectedPaths)
+
+	rootDependencies.ChangeJournal = change_journal.NewChangeJournal(filepath.Join(rootDependencies.Cwd, ".codai", "journal"), rootDependencies.Analyzer)
+
+	rootDependencies.SessionStore = chat_session.NewSessionStore(filepath.Join(rootDependencies.Cwd, ".codai", "sessions"))
 
 	if err != nil {
-		fmt.Println(lipgloss.Red.Render(fmt.Sprintf("%v", err)))
+		fmt.Fprintln(os.Stderr, lipgloss.Red.Render(fmt.Sprintf("%v", err)))
 	}
 
 	rootDependencies.CurrentChatProvider, err = providers.ChatProviderFactory(rootDependencies.Config.AIProviderConfig, rootDependencies.TokenManagement)
 
 	if err != nil {
-		fmt.Println(lipgloss.Red.Render(fmt.Sprintf("%v", err)))
+		fmt.Fprintln(os.Stderr, lipgloss.Red.Render(fmt.Sprintf("%v", err)))
 	}
 
+	// Summarize the history with the compaction model of the provider when it is set, a cheaper one than the chat model
+	providerConfig := rootDependencies.Config.AIProviderConfig
+	summarizer := rootDependencies.CurrentChatProvider
+	if rootDependencies.Config.CompactionModel != "" {
+		summarizerConfig := *providerConfig
+		summarizerConfig.Model = rootDependencies.Config.CompactionModel
+		if summarizer, err = providers.ChatProviderFactory(&summarizerConfig, rootDependencies.TokenManagement); err != nil {
+			fmt.Fprintln(os.Stderr, lipgloss.Red.Render(fmt.Sprintf("%v", err)))
+		}
+	}
+	rootDependencies.HistoryCompactor = history_compaction.NewHistoryCompactor(summarizer,
+		rootDependencies.TokenManagement.MaxInputTokens(providerConfig.Provider, providerConfig.Model), rootDependencies.Config.CompactionThreshold)
+
 	return rootDependencies
 }
 
+// exitCodeError makes Execute exit with code instead of 1, for the commands used in shell scripts
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
+
+		var exitError *exitCodeError
+		if errors.As(err, &exitError) {
+			os.Exit(exitError.code)
+		}
 		os.Exit(1)
 	}
 }
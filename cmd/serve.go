@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/meysamhadeli/codai/constants/lipgloss"
+	"github.com/meysamhadeli/codai/providers"
+	contracts_provider "github.com/meysamhadeli/codai/providers/contracts"
+	"github.com/meysamhadeli/codai/server"
+	"github.com/meysamhadeli/codai/token_management/contracts"
+	"github.com/spf13/cobra"
+)
+
+// serveTokenEnv is the environment variable of the bearer token of the server, when '--token' is not set
+const serveTokenEnv = "CODAI_SERVE_TOKEN"
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the pipeline of codai as an HTTP API for editors and other tools",
+	Long: `The 'serve' command exposes the pipeline of 'code' as an HTTP API with JSON bodies: chat sessions streaming the
+answer of the AI as server-sent events, the context of the project, the extraction of the code changes of an answer,
+their application or dry run as a patch, the token usage of the sessions and the stats of the cache. Every session has
+its own chat history and token usage. The requests must send a bearer token: the one of '--token' or the
+CODAI_SERVE_TOKEN environment variable, or a random one printed at start. The server listens on localhost by default
+and refuses other addresses without a token of your own, unless '--insecure' disables the token.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Args:          cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _ := cmd.Flags().GetString("addr")
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv(serveTokenEnv)
+		}
+		insecure, _ := cmd.Flags().GetBool("insecure")
+		if insecure && token != "" {
+			return fmt.Errorf("'--insecure' cannot be used with a token")
+		}
+
+		return handleServeCommand(cmd, addr, token, insecure)
+	},
+}
+
+func init() {
+	// Define command-specific flags
+	serveCmd.Flags().String("addr", "127.0.0.1:7070", "Address the server listens on")
+	serveCmd.Flags().String("token", "", "Bearer token the requests must send in their 'Authorization' header, a random one when it is not set")
+	serveCmd.Flags().Bool("insecure", false, "Serve without a bearer token, letting every local process, or the network on another address, use the API")
+
+	// Add the serve command to the root command
+	rootCmd.AddCommand(serveCmd)
+}
+
+func handleServeCommand(cmd *cobra.Command, addr string, token string, insecure bool) error {
+	rootDependencies := handleRootCommand(cmd)
+	if rootDependencies == nil {
+		return fmt.Errorf("failed to initialize codai")
+	}
+	if rootDependencies.CurrentChatProvider == nil {
+		return fmt.Errorf("no AI provider is configured")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	// The clients on the network must be given a token of your own, the local ones can read a random one
+	generatedToken := false
+	if token == "" && !insecure {
+		if !isLoopback(listener.Addr()) {
+			listener.Close()
+			return fmt.Errorf("refusing to serve on %s without a token, set '--token' or %s, or '--insecure' to serve without one", listener.Addr(), serveTokenEnv)
+		}
+		if token, err = randomToken(); err != nil {
+			listener.Close()
+			return err
+		}
+		generatedToken = true
+	}
+
+	apiServer := server.NewServer(serverDependencies(rootDependencies), token)
+
+	fmt.Println(lipgloss.Green.Render(fmt.Sprintf("✔️ codai is serving %s on http://%s", rootDependencies.Cwd, listener.Addr())))
+	switch {
+	case generatedToken:
+		fmt.Printf("🔑 Send the header 'Authorization: Bearer %s', or choose the token with '--token'.\n", token)
+	case insecure && !isLoopback(listener.Addr()):
+		fmt.Println(lipgloss.Yellow.Render("⚠️ The server is reachable from the network without a token, anyone on it can change the project."))
+	case insecure:
+		fmt.Println(lipgloss.Yellow.Render("⚠️ The server accepts the requests without a token."))
+	}
+
+	if err := apiServer.Serve(ctx, listener); err != nil {
+		return fmt.Errorf("the server failed: %w", err)
+	}
+	fmt.Println(lipgloss.Gray.Render("The server stopped."))
+	return nil
+}
+
+// serverDependencies shares the dependencies of the commands with the servers, which create an AI provider per session
+func serverDependencies(rootDependencies *RootDependencies) *server.Dependencies {
+	providerConfig := rootDependencies.Config.AIProviderConfig
+	return &server.Dependencies{
+		Cwd:           rootDependencies.Cwd,
+		Config:        rootDependencies.Config,
+		Analyzer:      rootDependencies.Analyzer,
+		ChangeJournal: rootDependencies.ChangeJournal,
+		NewChatProvider: func(tokenManagement contracts.ITokenManagement) (contracts_provider.IChatAIProvider, error) {
+			return providers.ChatProviderFactory(providerConfig, tokenManagement)
+		},
+	}
+}
+
+// randomToken creates the bearer token of a server started without one
+func randomToken() (string, error) {
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to create the bearer token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// isLoopback reports whether the server only accepts connections from this machine
+func isLoopback(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && tcpAddr.IP.IsLoopback()
+}
@@ -0,0 +1,339 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meysamhadeli/codai/chat_session"
+	session_models "github.com/meysamhadeli/codai/chat_session/models"
+	contracts_output "github.com/meysamhadeli/codai/output/contracts"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/spf13/cobra"
+)
+
+// sessionsCmd represents the sessions command
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List, show and delete the saved chat sessions of the project",
+	Long: `The 'sessions' command manages the chat sessions of 'code' saved in the '.codai/sessions' directory of the
+project. Every session keeps its messages, the changes applied in it, its token usage and its model, and can be
+resumed with 'codai code --resume [id]'. An id can be shortened to any prefix matching a single session.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Args:          cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:           "list",
+	Short:         "List the saved sessions, the most recent first",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Args:          cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return handleSessionsCommand(cmd, func(rootDependencies *RootDependencies) error {
+			return listSessions(rootDependencies)
+		})
+	},
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:           "show <id>",
+	Short:         "Show the messages, changes and token usage of a session",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Args:          cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return handleSessionsCommand(cmd, func(rootDependencies *RootDependencies) error {
+			return showSession(rootDependencies, args[0])
+		})
+	},
+}
+
+var sessionsDeleteCmd = &cobra.Command{
+	Use:           "delete <id>...",
+	Short:         "Delete saved sessions",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Args:          cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return handleSessionsCommand(cmd, func(rootDependencies *RootDependencies) error {
+			for _, id := range args {
+				if err := rootDependencies.SessionStore.Delete(id); err != nil {
+					return err
+				}
+				rootDependencies.Output.Success(fmt.Sprintf("🗑️ Deleted session %s.", id))
+			}
+			return nil
+		})
+	},
+}
+
+func init() {
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsShowCmd, sessionsDeleteCmd)
+
+	// Add the sessions command to the root command
+	rootCmd.AddCommand(sessionsCmd)
+}
+
+func handleSessionsCommand(cmd *cobra.Command, run func(rootDependencies *RootDependencies) error) error {
+	rootDependencies := handleRootCommand(cmd)
+	if rootDependencies == nil {
+		return fmt.Errorf("failed to initialize codai")
+	}
+	defer rootDependencies.Output.Close()
+	return run(rootDependencies)
+}
+
+func listSessions(rootDependencies *RootDependencies) error {
+	out := rootDependencies.Output
+	sessions, warnings, err := rootDependencies.SessionStore.List()
+	if err != nil {
+		return err
+	}
+	warnSkippedSessions(out, warnings)
+	if len(sessions) == 0 {
+		out.Info("No saved sessions in this project.")
+		return nil
+	}
+
+	for _, session := range sessions {
+		out.Text(fmt.Sprintf("%s  %s  %-20s %3d messages  %s", session.ID, session.UpdatedAt.Local().Format("2006-01-02 15:04"),
+			session.Model, len(session.Messages), sessionTitle(session)))
+	}
+	return nil
+}
+
+// warnSkippedSessions warns about the session files that could not be read
+func warnSkippedSessions(out contracts_output.IOutput, warnings []error) {
+	for _, warning := range warnings {
+		out.Warning(fmt.Sprintf("Skipped a session: %v", warning))
+	}
+}
+
+func showSession(rootDependencies *RootDependencies, id string) error {
+	out := rootDependencies.Output
+	session, err := rootDependencies.SessionStore.Load(id)
+	if err != nil {
+		return err
+	}
+
+	out.Title(fmt.Sprintf("Session %s: %s", session.ID, sessionTitle(session)))
+	cost := rootDependencies.TokenManagement.CalculateCost(session.Provider, session.Model, session.Usage.InputTokens, session.Usage.OutputTokens)
+	out.Text(fmt.Sprintf("Created: %s\nUpdated: %s\nModel: %s (%s)\nTokens: %d (Input: %d, Output: %d), Cost: $%.6f",
+		session.CreatedAt.Local().Format(time.DateTime), session.UpdatedAt.Local().Format(time.DateTime), session.Model, session.Provider,
+		session.Usage.InputTokens+session.Usage.OutputTokens, session.Usage.InputTokens, session.Usage.OutputTokens, cost))
+
+	for _, message := range session.Messages {
+		switch message.Role {
+		case provider_models.RoleUser:
+			out.Title("> " + message.Text())
+		case provider_models.RoleTool:
+			out.Info(fmt.Sprintf("🔧 %s returned %d characters.", message.ToolName, len(message.Text())))
+		default:
+			if text := message.Text(); text != "" {
+				out.Text(text)
+			}
+			for _, call := range message.ToolCalls {
+				out.Info(fmt.Sprintf("🔧 The AI calls %s with: %s", call.Name, call.Arguments))
+			}
+		}
+	}
+
+	if len(session.Changes) > 0 {
+		out.Title("Changes")
+		for i, entry := range session.Changes {
+			if entry.Record == nil {
+				continue
+			}
+			header := fmt.Sprintf("#%d  %s", i+1, entry.Record.Timestamp.Local().Format("15:04:05"))
+			if !entry.Applied {
+				header += "  (undone)"
+			}
+			out.Text(header)
+			for _, operation := range entry.Record.Operations {
+				out.Text(fmt.Sprintf("   %-6s %s", operation.Action, operation.RelativePath))
+			}
+		}
+	}
+	return nil
+}
+
+// sessionTitle is the title of a session, or a placeholder when it has none
+func sessionTitle(session *session_models.Session) string {
+	if session.Title == "" {
+		return "(untitled)"
+	}
+	return session.Title
+}
+
+// maxSessionTitleLength limits the title of a session taken from its first request
+const maxSessionTitleLength = 80
+
+// codeSession saves the chat session of the 'code' command in the session store of the project: its messages, the
+// changes of the change journal and the token usage
+type codeSession struct {
+	rootDependencies *RootDependencies
+	session          *session_models.Session
+
+	mu            sync.Mutex
+	savedMessages int // Number of messages at the last save
+	savedTokens   int // Number of tokens at the last save
+}
+
+func newCodeSession(rootDependencies *RootDependencies) (*codeSession, error) {
+	providerConfig := rootDependencies.Config.AIProviderConfig
+	session, err := chat_session.NewSession(providerConfig.Provider, providerConfig.Model)
+	if err != nil {
+		return nil, err
+	}
+	return &codeSession{rootDependencies: rootDependencies, session: session}, nil
+}
+
+// ID returns the id of the current session
+func (codeSession *codeSession) ID() string {
+	codeSession.mu.Lock()
+	defer codeSession.mu.Unlock()
+	return codeSession.session.ID
+}
+
+// request titles the session with the first request of the user
+func (codeSession *codeSession) request(userRequest string) {
+	codeSession.mu.Lock()
+	defer codeSession.mu.Unlock()
+	if codeSession.session.Title != "" {
+		return
+	}
+	title := strings.Join(strings.Fields(userRequest), " ")
+	if len([]rune(title)) > maxSessionTitleLength {
+		title = string([]rune(title)[:maxSessionTitleLength-3]) + "..."
+	}
+	codeSession.session.Title = title
+}
+
+// save writes the current state of the session
+func (codeSession *codeSession) save() error {
+	codeSession.mu.Lock()
+	defer codeSession.mu.Unlock()
+	return codeSession.saveLocked()
+}
+
+// autoSave writes the session when it has new messages or token usage since the last save, sessions without messages
+// are not saved
+func (codeSession *codeSession) autoSave() error {
+	codeSession.mu.Lock()
+	defer codeSession.mu.Unlock()
+
+	history := codeSession.rootDependencies.ChatHistory.GetHistory()
+	total, _, _ := codeSession.rootDependencies.TokenManagement.GetCurrentTokenUsage()
+	if len(history) == 0 || (len(history) == codeSession.savedMessages && total == codeSession.savedTokens) {
+		return nil
+	}
+	return codeSession.saveLocked()
+}
+
+func (codeSession *codeSession) saveLocked() error {
+	rootDependencies := codeSession.rootDependencies
+	changes, err := rootDependencies.ChangeJournal.Changes()
+	if err != nil {
+		return err
+	}
+	total, input, output := rootDependencies.TokenManagement.GetCurrentTokenUsage()
+
+	session := codeSession.session
+	session.Messages = rootDependencies.ChatHistory.GetHistory()
+	session.Changes = changes
+	session.Usage = session_models.TokenUsage{InputTokens: input, OutputTokens: output}
+	if err := rootDependencies.SessionStore.Save(session); err != nil {
+		return err
+	}
+
+	codeSession.savedMessages, codeSession.savedTokens = len(session.Messages), total
+	return nil
+}
+
+// resume continues a saved session: its messages become the history, its changes the change journal and its usage
+// the token usage, and the next saves write to it
+func (codeSession *codeSession) resume(session *session_models.Session) error {
+	codeSession.mu.Lock()
+	defer codeSession.mu.Unlock()
+
+	rootDependencies := codeSession.rootDependencies
+	if err := rootDependencies.ChangeJournal.Restore(session.Changes); err != nil {
+		return err
+	}
+	rootDependencies.ChatHistory.ClearHistory()
+	rootDependencies.ChatHistory.AddMessages(session.Messages...)
+	rootDependencies.TokenManagement.ClearToken()
+	rootDependencies.TokenManagement.UsedTokens(session.Usage.InputTokens, session.Usage.OutputTokens)
+
+	// The session continues with the current model
+	providerConfig := rootDependencies.Config.AIProviderConfig
+	if session.Provider != providerConfig.Provider || session.Model != providerConfig.Model {
+		rootDependencies.Output.Warning(fmt.Sprintf("The session was created with %s (%s), it continues with %s (%s).",
+			session.Model, session.Provider, providerConfig.Model, providerConfig.Provider))
+		session.Provider, session.Model = providerConfig.Provider, providerConfig.Model
+	}
+
+	codeSession.session = session
+	codeSession.savedMessages = len(session.Messages)
+	codeSession.savedTokens = session.Usage.InputTokens + session.Usage.OutputTokens
+	rootDependencies.Output.Success(fmt.Sprintf("📂 Resumed session %s with %d message(s): %s", session.ID, len(session.Messages), sessionTitle(session)))
+	return nil
+}
+
+// resumeCodeSession resumes the session of the id, or the latest session for resumeLatest
+func resumeCodeSession(codeSession *codeSession, id string) error {
+	store := codeSession.rootDependencies.SessionStore
+	var session *session_models.Session
+	var err error
+	if id == resumeLatest {
+		var warnings []error
+		session, warnings, err = store.Latest()
+		warnSkippedSessions(codeSession.rootDependencies.Output, warnings)
+		if errors.Is(err, chat_session.ErrSessionNotFound) {
+			return fmt.Errorf("no saved session to resume in this project")
+		}
+	} else {
+		session, err = store.Load(id)
+	}
+	if err != nil {
+		return err
+	}
+	return codeSession.resume(session)
+}
+
+// findSessionSubCommand handles the subcommands saving and loading sessions and reports whether command was one of them
+func findSessionSubCommand(command string, codeSession *codeSession) bool {
+	out := codeSession.rootDependencies.Output
+	switch {
+	case command == "/save":
+		if err := codeSession.save(); err != nil {
+			out.Error(fmt.Sprintf("Error saving the session: %v", err))
+			return true
+		}
+		out.Success(fmt.Sprintf("💾 Saved session %s, resume it with 'codai code --resume %s'.", codeSession.ID(), codeSession.ID()))
+		return true
+	case command == "/load" || strings.HasPrefix(command, "/load "):
+		id := strings.TrimSpace(strings.TrimPrefix(command, "/load"))
+		if id == "" {
+			out.Text("Usage: /load <id>\nList the saved sessions with 'codai sessions list'.")
+			return true
+		}
+		// Keep the current session before replacing it
+		if err := codeSession.autoSave(); err != nil {
+			out.Error(fmt.Sprintf("Error saving the session: %v", err))
+			return true
+		}
+		if err := resumeCodeSession(codeSession, id); err != nil {
+			out.Error(fmt.Sprintf("Error loading the session: %v", err))
+		}
+		return true
+	}
+	return false
+}
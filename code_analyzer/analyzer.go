@@ -6,6 +6,7 @@ import (
 	"github.com/meysamhadeli/codai/code_analyzer/contracts"
 	"github.com/meysamhadeli/codai/code_analyzer/models"
 	"github.com/meysamhadeli/codai/embed_data"
+	"github.com/meysamhadeli/codai/patch"
 	"github.com/meysamhadeli/codai/utils"
 	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/smacker/go-tree-sitter/csharp"
@@ -26,13 +27,23 @@ import (
 
 // CodeAnalyzer handles the analysis of project files.
 type CodeAnalyzer struct {
-	Cwd          string
-	cacheManager *CacheManager
+	Cwd            string
+	cacheManager   *CacheManager
+	protectedPaths []string
 }
 
-func (analyzer *CodeAnalyzer) GeneratePrompt(codes []string, history []string, userInput string, requestedContext string) (string, string) {
+func (analyzer *CodeAnalyzer) GeneratePrompt(codes []string, userInput string, requestedContext string) (string, string) {
+	return analyzer.GeneratePromptWithEditFormat(codes, userInput, requestedContext, "diff")
+}
+
+// GeneratePromptWithEditFormat builds the system prompt with the template matching the edit format ('diff' or
+// 'search_replace'), and the user message. The history of the chat is sent as messages between them.
+func (analyzer *CodeAnalyzer) GeneratePromptWithEditFormat(codes []string, userInput string, requestedContext string, editFormat string) (string, string) {
 
 	promptTemplate := string(embed_data.SummarizeFullContextPrompt)
+	if editFormat == "search_replace" {
+		promptTemplate = string(embed_data.SearchReplacePrompt)
+	}
 
 	// Combine the relevant code into a single string
 	code := strings.Join(codes, "\n---------\n\n")
@@ -44,14 +55,17 @@ func (analyzer *CodeAnalyzer) GeneratePrompt(codes []string, history []string, u
 		prompt = prompt + fmt.Sprintf("## Here are the requsted full context files for using in your task\n\n%s______\n", requestedContext)
 	}
 
-	historyPrompt := "## Here is the history of chats\n\n" + strings.Join(history, "\n---------\n\n")
-	finalPrompt := fmt.Sprintf("%s\n\n______\n\n%s", historyPrompt, prompt)
-
-	return finalPrompt, userInputPrompt
+	return prompt, userInputPrompt
 }
 
 // NewCodeAnalyzer initializes a new CodeAnalyzer.
 func NewCodeAnalyzer(cwd string, enableCache bool) contracts.ICodeAnalyzer {
+	return NewCodeAnalyzerWithProtectedPaths(cwd, enableCache, nil)
+}
+
+// NewCodeAnalyzerWithProtectedPaths initializes a new CodeAnalyzer that refuses to change files inside the protected
+// paths, in addition to the .git and .codai directories which are always protected.
+func NewCodeAnalyzerWithProtectedPaths(cwd string, enableCache bool, protectedPaths []string) contracts.ICodeAnalyzer {
 	var cacheManager *CacheManager
 	
 	if enableCache {
@@ -72,11 +86,17 @@ func NewCodeAnalyzer(cwd string, enableCache bool) contracts.ICodeAnalyzer {
 	}
 
 	return &CodeAnalyzer{
-		Cwd:          cwd,
-		cacheManager: cacheManager,
+		Cwd:            cwd,
+		cacheManager:   cacheManager,
+		protectedPaths: protectedPaths,
 	}
 }
 
+// pathPolicy returns the policy resolving the paths of code changes against the project root.
+func (analyzer *CodeAnalyzer) pathPolicy() *pathPolicy {
+	return newPathPolicy(analyzer.Cwd, analyzer.protectedPaths)
+}
+
 func (analyzer *CodeAnalyzer) GetProjectFiles(rootDir string) (*models.FullContextData, error) {
 	return analyzer.GetProjectFilesWithDisplayMode(rootDir, "full")
 }
@@ -754,71 +774,147 @@ func (analyzer *CodeAnalyzer) ExtractCodeChanges(diff string) []models.CodeChang
 	return fileChanges
 }
 
+// ExtractSearchReplaceChanges extracts SEARCH/REPLACE edit blocks from the AI response and groups them per file.
+// Each block must follow a line naming the file, either "File: path" or the bare relative path.
+func (analyzer *CodeAnalyzer) ExtractSearchReplaceChanges(text string) []models.CodeChange {
+	filePathPattern := regexp.MustCompile("(?i)(?:\\d+\\.\\s*|File:\\s*)[`']?([^\\s*`']+?\\.[a-zA-Z0-9]+)[`']?\\b")
+	barePathPattern := regexp.MustCompile("^[`'*]*([\\w.\\-/\\\\]+\\.[a-zA-Z0-9]+)[`'*:]*$")
+
+	blocksByPath := make(map[string][]patch.SearchReplaceBlock)
+	var paths []string
+
+	var currentFilePath string
+	var searchLines, replaceLines []string
+	insideSearch, insideReplace := false, false
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmedLine := strings.TrimSpace(line)
+
+		switch {
+		case insideSearch:
+			if patch.IsDividerMarker(line) {
+				insideSearch, insideReplace = false, true
+				continue
+			}
+			searchLines = append(searchLines, line)
+		case insideReplace:
+			if patch.IsReplaceMarker(line) {
+				insideReplace = false
+				if _, exists := blocksByPath[currentFilePath]; !exists {
+					paths = append(paths, currentFilePath)
+				}
+				blocksByPath[currentFilePath] = append(blocksByPath[currentFilePath], patch.SearchReplaceBlock{
+					Search:  strings.Join(searchLines, "\n"),
+					Replace: strings.Join(replaceLines, "\n"),
+				})
+				continue
+			}
+			replaceLines = append(replaceLines, line)
+		case patch.IsSearchReplaceMarker(line):
+			// Blocks without a preceding file path cannot be applied
+			if currentFilePath != "" {
+				searchLines, replaceLines = nil, nil
+				insideSearch = true
+			}
+		case filePathPattern.MatchString(trimmedLine):
+			currentFilePath = filePathPattern.FindStringSubmatch(trimmedLine)[1]
+		case barePathPattern.MatchString(trimmedLine):
+			currentFilePath = barePathPattern.FindStringSubmatch(trimmedLine)[1]
+		}
+	}
+
+	var fileChanges []models.CodeChange
+	for _, path := range paths {
+		fileChanges = append(fileChanges, models.CodeChange{
+			RelativePath: path,
+			Code:         patch.FormatSearchReplace(blocksByPath[path]),
+		})
+	}
+
+	return fileChanges
+}
+
 func (analyzer *CodeAnalyzer) ApplyChanges(relativePath, diff string) error {
-	// Ensure the directory structure exists
-	dir := filepath.Dir(relativePath)
-	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+	// Resolve and write the change through a transaction, so a rejected hunk or failed write never leaves a partial file
+	transaction := analyzer.BeginTransaction()
+	if err := transaction.Stage(relativePath, diff); err != nil {
+		return err
 	}
 
-	// Process the diff content: handle additions and deletions
-	diffLines := strings.Split(diff, "\n")
-	var updatedContent []string
+	if len(transaction.StagedPaths()) == 0 {
+		fmt.Printf("File %s does not exist, so no deletion necessary.\n", relativePath)
+		return nil
+	}
 
-	for _, line := range diffLines {
-		trimmedLine := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmedLine, "-") {
-			// Ignore lines that start with "-", effectively deleting them
-			continue
-		} else if strings.HasPrefix(trimmedLine, "+") {
-			// Add lines that start with "+", but remove the "+" symbol
-			updatedContent = append(updatedContent, strings.ReplaceAll(trimmedLine, "+", " "))
-		} else {
-			// Keep all other lines as they are
-			updatedContent = append(updatedContent, line)
+	_, err := transaction.Commit()
+	return err
+}
+
+// resolveUpdatedContent computes the content a file will have once the code change is applied.
+// SEARCH/REPLACE blocks and unified diffs are applied against the current file; blocks using "+"/"-" markers are
+// only treated as a diff when their unchanged and removed lines can be found in the current file; anything else
+// is taken as the complete new content of the file.
+func resolveUpdatedContent(relativePath string, original []byte, code string) (string, error) {
+	if patch.IsSearchReplace(code) {
+		blocks, err := patch.ParseSearchReplace(code)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse edit blocks for %s: %w", relativePath, err)
+		}
+
+		result, err := patch.ApplySearchReplace(string(original), blocks)
+		if err != nil {
+			return "", fmt.Errorf("failed to apply edit blocks to %s: %w", relativePath, err)
 		}
+		return result.Content, nil
 	}
 
-	// Handle deletion if code is empty
-	if strings.TrimSpace(strings.Join(updatedContent, "\n")) == "" {
-		// Check if file exists, then delete if it does
-		if err := os.Remove(relativePath); err != nil {
-			if os.IsNotExist(err) {
-				fmt.Printf("File %s does not exist, so no deletion necessary.\n", relativePath)
-			} else {
-				return fmt.Errorf("failed to delete file: %w", err)
-			}
+	if patch.IsUnifiedDiff(code) {
+		filePatches, err := patch.Parse(code)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse diff for %s: %w", relativePath, err)
 		}
 
-		// After file deletion, check if the directory is empty and delete it if so
-		if err := removeEmptyDirectoryIfNeeded(dir); err != nil {
-			return err
+		var hunks []patch.Hunk
+		for _, filePatch := range filePatches {
+			hunks = append(hunks, filePatch.Hunks...)
 		}
-	} else {
-		// Write the updated content to the file
-		if err := ioutil.WriteFile(relativePath, []byte(strings.Join(updatedContent, "\n")), 0644); err != nil {
-			return fmt.Errorf("failed to write to file: %w", err)
+
+		result, err := patch.Apply(string(original), hunks)
+		if err != nil {
+			return "", fmt.Errorf("failed to apply diff to %s: %w", relativePath, err)
 		}
+		return result.Content, nil
 	}
 
-	return nil
+	if hunk, ok := patch.FromAnnotated(code); ok {
+		// A block where every line is added is a complete file, not an insertion
+		if hunk.IsPureAddition() {
+			return strings.Join(hunk.NewLinesText(), "\n"), nil
+		}
+		if result, err := patch.Apply(string(original), []patch.Hunk{hunk}); err == nil {
+			return result.Content, nil
+		}
+	}
+
+	return code, nil
 }
 
-// removeEmptyDirectoryIfNeeded checks if a directory is empty, and if so, deletes it
-func removeEmptyDirectoryIfNeeded(dir string) error {
+// removeEmptyDirectories checks if a directory is empty, and if so, deletes it, returning the removed directories
+func removeEmptyDirectories(dir string) ([]string, error) {
 	// Check if the directory is empty
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return fmt.Errorf("failed to read directory %s: %w", dir, err)
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
 	}
 
 	// If the directory is empty, remove it
 	if len(entries) == 0 {
 		if err := os.Remove(dir); err != nil {
-			return fmt.Errorf("failed to delete empty directory %s: %w", dir, err)
+			return nil, fmt.Errorf("failed to delete empty directory %s: %w", dir, err)
 		}
+		return []string{dir}, nil
 	}
-	return nil
+	return nil, nil
 }
 
 // extractRustStructure extracts basic Rust code structure using regex patterns
@@ -69,6 +69,9 @@ func TestRunInSequence(t *testing.T) {
 	t.Run("TestExtractCodeChangesWithStartPathWithNumberAndDot", TestExtractCodeChangesWithStartPathWithNumberAndDot)
 	t.Run("TestApplyChanges_AddLines", TestApplyChanges_AddLines)
 	t.Run("TestApplyChanges_RemoveLines", TestApplyChanges_UpdateLines)
+	t.Run("TestApplyChanges_UnifiedDiff", TestApplyChanges_UnifiedDiff)
+	t.Run("TestApplyChanges_RejectedHunkKeepsFile", TestApplyChanges_RejectedHunkKeepsFile)
+	t.Run("TestApplyChanges_KeepsLinesStartingWithDash", TestApplyChanges_KeepsLinesStartingWithDash)
 	t.Run("TestExtractCodeChangesWithAdditionalCharacters", TestExtractCodeChangesWithAdditionalCharacters)
 	t.Run("TestExtractCodeChangesWithDifferentFileLabelFormat", TestExtractCodeChangesWithDifferentFileLabelFormat)
 	t.Run("TestExtractCodeChangesWithSpecialFilePathFormat", TestExtractCodeChangesWithSpecialFilePathFormat)
@@ -79,6 +82,15 @@ func TestRunInSequence(t *testing.T) {
 	t.Run("TestExtractCodeChangesWithEmptyText", TestExtractCodeChangesWithEmptyText)
 	t.Run("TestExtractCodeChangesWithNonMatchingPatterns", TestExtractCodeChangesWithNonMatchingPatterns)
 	t.Run("TestExtractCodeChangesWithMultipleCodeBlocksSameFile", TestExtractCodeChangesWithMultipleCodeBlocksSameFile)
+	t.Run("TestExtractSearchReplaceChanges", TestExtractSearchReplaceChanges)
+	t.Run("TestApplyChanges_SearchReplace", TestApplyChanges_SearchReplace)
+	t.Run("TestTransaction_CommitAndRevert", TestTransaction_CommitAndRevert)
+	t.Run("TestTransaction_RollbackOnFailedRename", TestTransaction_RollbackOnFailedRename)
+	t.Run("TestTransaction_AbortsWhenFileChangedAfterStaging", TestTransaction_AbortsWhenFileChangedAfterStaging)
+	t.Run("TestRevertTransaction_Conflict", TestRevertTransaction_Conflict)
+	t.Run("TestTransaction_PreviewAndStageContent", TestTransaction_PreviewAndStageContent)
+	t.Run("TestApplyChanges_RejectsUnsafePaths", TestApplyChanges_RejectsUnsafePaths)
+	t.Run("TestValidateSyntax", TestValidateSyntax)
 	t.Run("TestTryGetInCompletedCodeBlock", TestTryGetInCompletedCodeBlock)
 	t.Run("TestTryGetInCompletedCodeBlockWithAdditionalCharacters", TestTryGetInCompletedCodeBlockWithAdditionalsCharacters)
 	t.Run("TestProcessRustFile", TestProcessRustFile)
@@ -91,17 +103,15 @@ func TestGeneratePrompt(t *testing.T) {
 	setup(t)
 
 	codes := []string{"code1", "code2"}
-	history := []string{"prev1", "prev2"}
 	requestedContext := "Requested context"
 	userInput := "User request"
 
-	finalPrompt, userInputPrompt := analyzer.GeneratePrompt(codes, history, userInput, requestedContext)
+	finalPrompt, userInputPrompt := analyzer.GeneratePrompt(codes, userInput, requestedContext)
 
 	// Assert that the outputs contain the expected mocked strings
 	assert.Contains(t, finalPrompt, "code1")
 	assert.Contains(t, finalPrompt, "code2")
-	assert.Contains(t, finalPrompt, "prev1")
-	assert.Contains(t, finalPrompt, "prev2")
+	assert.NotContains(t, finalPrompt, "history of chats")
 	assert.Contains(t, finalPrompt, "Requested context")
 	assert.Contains(t, userInputPrompt, "User request")
 }
@@ -111,11 +121,10 @@ func TestGeneratePrompt_ActualImplementation(t *testing.T) {
 
 	// Assuming boxStyle.Render and embed_data.CodeBlockTemplate are set up correctly
 	codes := []string{"code1", "code2"}
-	history := []string{"prev1", "prev2"}
 	userInput := "User request"
 	requestedContext := "Requested context"
 
-	finalPrompt, userInputPrompt := analyzer.GeneratePrompt(codes, history, userInput, requestedContext)
+	finalPrompt, userInputPrompt := analyzer.GeneratePrompt(codes, userInput, requestedContext)
 
 	// Check the content of the actual prompts here
 	// This will depend on how you set up boxStyle and embed_data
@@ -168,11 +177,12 @@ func TestApplyChanges_NewFile(t *testing.T) {
 	setup(t)
 
 	// Define the relative path for a new file and its content
-	filePath := filepath.Join(relativePathTestDir, "newfile.go")
+	fileName := "newfile.go"
+	filePath := filepath.Join(relativePathTestDir, fileName)
 	content := "package main\nfunc main() {}"
 
 	// Call ApplyChanges to create the new file
-	err := analyzer.ApplyChanges(filePath, content)
+	err := analyzer.ApplyChanges(fileName, content)
 	assert.NoError(t, err)
 
 	// Verify the file was created with the expected content
@@ -186,7 +196,8 @@ func TestApplyChanges_ModifyFile(t *testing.T) {
 	setup(t)
 
 	// Define the relative path and initial content for an existing file
-	filePath := filepath.Join(relativePathTestDir, "existingfile.go")
+	fileName := "existingfile.go"
+	filePath := filepath.Join(relativePathTestDir, fileName)
 	initialContent := "package main\nfunc main() {}"
 	modifiedContent := "package main\nfunc updatedMain() {}"
 
@@ -195,7 +206,7 @@ func TestApplyChanges_ModifyFile(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Use ApplyChanges to modify the content
-	err = analyzer.ApplyChanges(filePath, modifiedContent)
+	err = analyzer.ApplyChanges(fileName, modifiedContent)
 	assert.NoError(t, err)
 
 	// Verify that the file content was modified
@@ -209,7 +220,8 @@ func TestApplyChanges_DeletedFile(t *testing.T) {
 	setup(t)
 
 	// Define the relative path and content for the file
-	filePath := filepath.Join(relativePathTestDir, "deletedfile.go")
+	fileName := "deletedfile.go"
+	filePath := filepath.Join(relativePathTestDir, fileName)
 	content := "package main\nfunc deletedMain() {}"
 
 	// Initially create the file and verify its existence
@@ -223,7 +235,7 @@ func TestApplyChanges_DeletedFile(t *testing.T) {
 	assert.NoFileExists(t, filePath)
 
 	// Use ApplyChanges to recreate the file
-	err = analyzer.ApplyChanges(filePath, content)
+	err = analyzer.ApplyChanges(fileName, content)
 	assert.NoError(t, err)
 
 	// Verify the file was recreated with the correct content
@@ -315,7 +327,8 @@ func TestApplyChanges_AddLines(t *testing.T) {
 	setup(t)
 
 	// Define the relative path and initial content for the file
-	filePath := filepath.Join(relativePathTestDir, "addlines.go")
+	fileName := "addlines.go"
+	filePath := filepath.Join(relativePathTestDir, fileName)
 	initialContent := "package main\nfunc main() {}"
 	addedLinesDiff := "func newFunc() {}\nfunc main() {}"
 
@@ -324,7 +337,7 @@ func TestApplyChanges_AddLines(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Use ApplyChanges to add new lines
-	err = analyzer.ApplyChanges(filePath, addedLinesDiff)
+	err = analyzer.ApplyChanges(fileName, addedLinesDiff)
 	assert.NoError(t, err)
 
 	// Verify the new lines were added correctly
@@ -339,7 +352,8 @@ func TestApplyChanges_UpdateLines(t *testing.T) {
 	setup(t)
 
 	// Define the relative path and initial content for the file
-	filePath := filepath.Join(relativePathTestDir, "removelines.go")
+	fileName := "removelines.go"
+	filePath := filepath.Join(relativePathTestDir, fileName)
 	initialContent := "package main\nfunc toBeRemoved() {}\nfunc main() {}"
 	updatedLinesDiff := "func main() {}"
 
@@ -348,7 +362,7 @@ func TestApplyChanges_UpdateLines(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Use ApplyChanges to remove specific lines
-	err = analyzer.ApplyChanges(filePath, updatedLinesDiff)
+	err = analyzer.ApplyChanges(fileName, updatedLinesDiff)
 	assert.NoError(t, err)
 
 	// Verify the specified lines were updated
@@ -359,6 +373,337 @@ func TestApplyChanges_UpdateLines(t *testing.T) {
 }
 
 // Test for ExtractCodeChanges with additional characters around the file path
+// TestApplyChanges_UnifiedDiff tests if ApplyChanges applies unified diff hunks to the current file.
+func TestApplyChanges_UnifiedDiff(t *testing.T) {
+	setup(t)
+
+	fileName := "diff.go"
+	filePath := filepath.Join(relativePathTestDir, fileName)
+	initialContent := "package main\n\nfunc main() {\n\tprintln(\"a\")\n}\n"
+	diff := "@@ -3,3 +3,4 @@\n func main() {\n \tprintln(\"a\")\n+\tprintln(\"b\")\n }"
+
+	err := os.WriteFile(filePath, []byte(initialContent), 0644)
+	assert.NoError(t, err)
+
+	err = analyzer.ApplyChanges(fileName, diff)
+	assert.NoError(t, err)
+
+	savedContent, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {\n\tprintln(\"a\")\n\tprintln(\"b\")\n}\n", string(savedContent))
+}
+
+// TestApplyChanges_RejectedHunkKeepsFile tests if ApplyChanges leaves the file untouched when a hunk does not apply.
+func TestApplyChanges_RejectedHunkKeepsFile(t *testing.T) {
+	setup(t)
+
+	fileName := "rejected.go"
+	filePath := filepath.Join(relativePathTestDir, fileName)
+	initialContent := "package main\nfunc main() {}\n"
+	diff := "@@ -1,2 +1,2 @@\n package main\n-func other() {}\n+func renamed() {}"
+
+	err := os.WriteFile(filePath, []byte(initialContent), 0644)
+	assert.NoError(t, err)
+
+	err = analyzer.ApplyChanges(fileName, diff)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "hunk #1")
+
+	savedContent, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, initialContent, string(savedContent))
+}
+
+// TestTransaction_CommitAndRevert tests if a transaction modifies, creates and deletes files together and can be reverted and reapplied.
+func TestTransaction_CommitAndRevert(t *testing.T) {
+	setup(t)
+
+	modifiedName := "modified.go"
+	modifiedPath := filepath.Join(relativePathTestDir, modifiedName)
+	deletedName := filepath.Join("nested", "deleted.go")
+	deletedPath := filepath.Join(relativePathTestDir, deletedName)
+	createdName := filepath.Join("created", "dir", "created.go")
+	createdPath := filepath.Join(relativePathTestDir, createdName)
+
+	assert.NoError(t, os.WriteFile(modifiedPath, []byte("package main\nfunc a() {}\n"), 0600))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(deletedPath), 0755))
+	assert.NoError(t, os.WriteFile(deletedPath, []byte("package nested\n"), 0644))
+
+	transaction := analyzer.BeginTransaction()
+	assert.NoError(t, transaction.Stage(modifiedName, "@@ -1,2 +1,2 @@\n package main\n-func a() {}\n+func b() {}"))
+	assert.NoError(t, transaction.Stage(deletedName, ""))
+	assert.NoError(t, transaction.Stage(createdName, "package dir\n"))
+	assert.Equal(t, []string{modifiedName, deletedName, createdName}, transaction.StagedPaths())
+
+	record, err := transaction.Commit()
+	assert.NoError(t, err)
+	assert.Len(t, record.Operations, 3)
+
+	content, err := os.ReadFile(modifiedPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\nfunc b() {}\n", string(content))
+	info, err := os.Stat(modifiedPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.NoDirExists(t, filepath.Dir(deletedPath))
+	assert.FileExists(t, createdPath)
+
+	err = analyzer.RevertTransaction(record)
+	assert.NoError(t, err)
+
+	content, err = os.ReadFile(modifiedPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\nfunc a() {}\n", string(content))
+	content, err = os.ReadFile(deletedPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "package nested\n", string(content))
+	assert.NoDirExists(t, filepath.Join(relativePathTestDir, "created"))
+
+	err = analyzer.ReapplyTransaction(record)
+	assert.NoError(t, err)
+
+	content, err = os.ReadFile(modifiedPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\nfunc b() {}\n", string(content))
+	assert.NoDirExists(t, filepath.Dir(deletedPath))
+	assert.FileExists(t, createdPath)
+}
+
+// TestTransaction_RollbackOnFailedRename tests if files already written are restored when a later file fails.
+func TestTransaction_RollbackOnFailedRename(t *testing.T) {
+	setup(t)
+
+	firstName := "first.go"
+	firstPath := filepath.Join(relativePathTestDir, firstName)
+	secondName := "second.go"
+	secondPath := filepath.Join(relativePathTestDir, secondName)
+	assert.NoError(t, os.WriteFile(firstPath, []byte("first\n"), 0644))
+	assert.NoError(t, os.WriteFile(secondPath, []byte("second\n"), 0644))
+
+	renames := 0
+	renameFile = func(oldPath, newPath string) error {
+		renames++
+		if renames == 2 {
+			return fmt.Errorf("disk full")
+		}
+		return os.Rename(oldPath, newPath)
+	}
+	t.Cleanup(func() { renameFile = os.Rename })
+
+	transaction := analyzer.BeginTransaction()
+	assert.NoError(t, transaction.Stage(firstName, "first updated\n"))
+	assert.NoError(t, transaction.Stage(secondName, "second updated\n"))
+
+	_, err := transaction.Commit()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "rolled back")
+
+	content, err := os.ReadFile(firstPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "first\n", string(content))
+	content, err = os.ReadFile(secondPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "second\n", string(content))
+
+	tempFiles, err := filepath.Glob(filepath.Join(relativePathTestDir, ".*.codai-*"))
+	assert.NoError(t, err)
+	assert.Empty(t, tempFiles, "temporary files should be removed")
+}
+
+// TestTransaction_AbortsWhenFileChangedAfterStaging tests if nothing is written when a staged file changed on disk.
+func TestTransaction_AbortsWhenFileChangedAfterStaging(t *testing.T) {
+	setup(t)
+
+	otherName := "other.go"
+	otherPath := filepath.Join(relativePathTestDir, otherName)
+	changedName := "changed.go"
+	changedPath := filepath.Join(relativePathTestDir, changedName)
+	assert.NoError(t, os.WriteFile(changedPath, []byte("original\n"), 0644))
+
+	transaction := analyzer.BeginTransaction()
+	assert.NoError(t, transaction.Stage(otherName, "other\n"))
+	assert.NoError(t, transaction.Stage(changedName, "updated\n"))
+
+	assert.NoError(t, os.WriteFile(changedPath, []byte("edited by hand\n"), 0644))
+
+	_, err := transaction.Commit()
+	assert.Error(t, err)
+	assert.NoFileExists(t, otherPath)
+
+	content, err := os.ReadFile(changedPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "edited by hand\n", string(content))
+}
+
+// TestRevertTransaction_Conflict tests if a transaction is not reverted over later edits.
+func TestRevertTransaction_Conflict(t *testing.T) {
+	setup(t)
+
+	fileName := "conflict.go"
+	filePath := filepath.Join(relativePathTestDir, fileName)
+
+	transaction := analyzer.BeginTransaction()
+	assert.NoError(t, transaction.Stage(fileName, "generated\n"))
+	record, err := transaction.Commit()
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(filePath, []byte("edited by hand\n"), 0644))
+
+	err = analyzer.RevertTransaction(record)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), fileName)
+	assert.FileExists(t, filePath)
+}
+
+// TestTransaction_PreviewAndStageContent tests if a previewed change is resolved on top of content staged before.
+func TestTransaction_PreviewAndStageContent(t *testing.T) {
+	setup(t)
+
+	fileName := "preview.go"
+	filePath := filepath.Join(relativePathTestDir, fileName)
+	assert.NoError(t, os.WriteFile(filePath, []byte("package main\nfunc a() {}\n"), 0644))
+
+	transaction := analyzer.BeginTransaction()
+	before, after, err := transaction.Preview(fileName, "@@ -1,2 +1,2 @@\n package main\n-func a() {}\n+func b() {}")
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\nfunc a() {}\n", before)
+	assert.Equal(t, "package main\nfunc b() {}\n", after)
+
+	assert.NoError(t, transaction.StageContent(fileName, after))
+
+	before, _, err = transaction.Preview(fileName, "package main\n")
+	assert.NoError(t, err)
+	assert.Equal(t, after, before)
+
+	content, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\nfunc a() {}\n", string(content), "nothing is written before commit")
+
+	_, err = transaction.Commit()
+	assert.NoError(t, err)
+
+	content, err = os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, after, string(content))
+}
+
+// TestChangeStager tests if the changes are staged without the unparsable ones and their patch covers every file.
+func TestChangeStager(t *testing.T) {
+	setup(t)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(relativePathTestDir, "stage.go"), []byte("package main\nfunc a() {}\n"), 0644))
+
+	transaction := analyzer.BeginTransaction()
+	defer transaction.Discard()
+	stager := NewChangeStager(relativePathTestDir, analyzer, transaction)
+
+	hunks, err := stager.Stage("stage.go", "package main\nfunc b() {}\n")
+	assert.NoError(t, err)
+	assert.Len(t, hunks, 1)
+	_, err = stager.Stage("stage.go", "package main\nfunc c() {}\n")
+	assert.NoError(t, err)
+	_, err = stager.Stage("created.go", "package main\n")
+	assert.NoError(t, err)
+
+	hunks, err = stager.Stage("created.go", "package main\n")
+	assert.NoError(t, err)
+	assert.Empty(t, hunks, "a change of nothing is not staged")
+
+	var syntaxError *SyntaxCheckError
+	_, err = stager.Stage("broken.go", "package main\n\nfunc {")
+	assert.ErrorAs(t, err, &syntaxError)
+	assert.Contains(t, err.Error(), "does not parse")
+
+	var rejectedError *PathRejectedError
+	_, err = stager.Stage(filepath.Join(".git", "config"), "[core]\n")
+	assert.ErrorAs(t, err, &rejectedError)
+
+	assert.ElementsMatch(t, []string{"stage.go", "created.go"}, transaction.StagedPaths())
+	assert.Equal(t, map[string]string{"stage.go": "package main\nfunc c() {}\n", "created.go": "package main\n"}, stager.Staged())
+
+	// The patch goes from the files on disk to their last change
+	diff, files := stager.Patch()
+	assert.Equal(t, 2, files)
+	assert.Contains(t, diff, "--- a/stage.go\n+++ b/stage.go\n")
+	assert.Contains(t, diff, "-func a() {}\n+func c() {}\n")
+	assert.Contains(t, diff, "--- /dev/null\n+++ b/created.go\n")
+	assert.NotContains(t, diff, "broken.go")
+}
+
+// TestApplyChanges_RejectsUnsafePaths tests if paths outside the project root or inside protected directories are never written.
+func TestApplyChanges_RejectsUnsafePaths(t *testing.T) {
+	setup(t)
+
+	outsideDir := t.TempDir()
+	protectedAnalyzer := NewCodeAnalyzerWithProtectedPaths(relativePathTestDir, false, []string{"deploy/secrets"})
+
+	unsafePaths := []string{
+		filepath.Join("..", "outside.go"),
+		filepath.Join(outsideDir, "absolute.go"),
+		filepath.Join(".git", "config"),
+		filepath.Join("module", ".git", "HEAD"),
+		filepath.Join("deploy", "secrets", "key.pem"),
+		"",
+	}
+
+	// Creating symbolic links may need extra privileges on Windows
+	if err := os.Symlink(outsideDir, filepath.Join(relativePathTestDir, "link")); err == nil {
+		unsafePaths = append(unsafePaths, filepath.Join("link", "escaped.go"))
+	}
+
+	for _, unsafePath := range unsafePaths {
+		err := protectedAnalyzer.ApplyChanges(unsafePath, "package main\n")
+
+		var rejectedError *PathRejectedError
+		assert.ErrorAs(t, err, &rejectedError, unsafePath)
+	}
+
+	assert.NoFileExists(t, filepath.Join(relativePathTestDir, "..", "outside.go"))
+	assert.NoFileExists(t, filepath.Join(outsideDir, "absolute.go"))
+	assert.NoFileExists(t, filepath.Join(outsideDir, "escaped.go"))
+	assert.NoDirExists(t, filepath.Join(relativePathTestDir, ".git"))
+	assert.NoDirExists(t, filepath.Join(relativePathTestDir, "deploy"))
+
+	// Paths inside the project root are still allowed, even when absolute
+	absoluteRoot, err := filepath.Abs(relativePathTestDir)
+	assert.NoError(t, err)
+	assert.NoError(t, protectedAnalyzer.ApplyChanges(filepath.Join(absoluteRoot, "deploy", "app.yml"), "name: app\n"))
+	assert.FileExists(t, filepath.Join(relativePathTestDir, "deploy", "app.yml"))
+}
+
+// TestValidateSyntax tests if ERROR and MISSING nodes are reported with their line numbers.
+func TestValidateSyntax(t *testing.T) {
+	setup(t)
+
+	assert.Empty(t, analyzer.ValidateSyntax("valid.go", []byte("package main\n\nfunc main() {\n\tprintln(1)\n}\n")))
+	assert.Empty(t, analyzer.ValidateSyntax("notes.md", []byte("# {{ not code")))
+
+	syntaxErrors := analyzer.ValidateSyntax("broken.go", []byte("package main\n\nfunc main() {\n\tprintln(1\n}\n"))
+	assert.NotEmpty(t, syntaxErrors)
+	assert.Equal(t, 4, syntaxErrors[0].Line)
+
+	syntaxErrors = analyzer.ValidateSyntax("broken.py", []byte("def main():\n    return 1\n\nclass :\n    pass\n"))
+	assert.NotEmpty(t, syntaxErrors)
+	assert.Equal(t, 4, syntaxErrors[0].Line)
+	assert.Contains(t, syntaxErrors[0].String(), "line 4:")
+}
+
+// TestApplyChanges_KeepsLinesStartingWithDash tests if full content with "-" prefixed lines is written unchanged.
+func TestApplyChanges_KeepsLinesStartingWithDash(t *testing.T) {
+	setup(t)
+
+	fileName := "list.yml"
+	filePath := filepath.Join(relativePathTestDir, fileName)
+	content := "steps:\n- build\n- test\n-1"
+
+	err := analyzer.ApplyChanges(fileName, content)
+	assert.NoError(t, err)
+
+	savedContent, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(savedContent))
+}
+
 func TestExtractCodeChangesWithAdditionalCharacters(t *testing.T) {
 	setup(t)
 	text := "\n\n#####File: test.go#####\n```go\npackage main\n```\nFile: test2.go\n```go\npackage main\n```"
@@ -479,6 +824,40 @@ func TestExtractCodeChangesWithMultipleCodeBlocksSameFile(t *testing.T) {
 }
 
 // Test for TryGetInCompletedCodeBlock
+// Test for ExtractSearchReplaceChanges with blocks for several files
+func TestExtractSearchReplaceChanges(t *testing.T) {
+	setup(t)
+	text := "Here are the changes.\n\nFile: main.go\n```go\n<<<<<<< SEARCH\nfunc a() {}\n=======\nfunc b() {}\n>>>>>>> REPLACE\n```\n\nutils/helper.go\n<<<<<<< SEARCH\n=======\npackage utils\n>>>>>>> REPLACE\n\nFile: main.go\n<<<<<<< SEARCH\nfunc c() {}\n=======\n>>>>>>> REPLACE"
+
+	codeChanges := analyzer.ExtractSearchReplaceChanges(text)
+
+	assert.Len(t, codeChanges, 2)
+	assert.Equal(t, "main.go", codeChanges[0].RelativePath)
+	assert.Equal(t, "<<<<<<< SEARCH\nfunc a() {}\n=======\nfunc b() {}\n>>>>>>> REPLACE\n<<<<<<< SEARCH\nfunc c() {}\n=======\n>>>>>>> REPLACE", codeChanges[0].Code)
+	assert.Equal(t, "utils/helper.go", codeChanges[1].RelativePath)
+	assert.Equal(t, "<<<<<<< SEARCH\n=======\npackage utils\n>>>>>>> REPLACE", codeChanges[1].Code)
+}
+
+// TestApplyChanges_SearchReplace tests if ApplyChanges applies SEARCH/REPLACE blocks to the current file.
+func TestApplyChanges_SearchReplace(t *testing.T) {
+	setup(t)
+
+	fileName := "searchreplace.go"
+	filePath := filepath.Join(relativePathTestDir, fileName)
+	initialContent := "package main\n\nfunc main() {\n\tprintln(\"a\")\n}\n"
+	code := "<<<<<<< SEARCH\n\tprintln(\"a\")\n=======\n\tprintln(\"b\")\n>>>>>>> REPLACE"
+
+	err := os.WriteFile(filePath, []byte(initialContent), 0644)
+	assert.NoError(t, err)
+
+	err = analyzer.ApplyChanges(fileName, code)
+	assert.NoError(t, err)
+
+	savedContent, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {\n\tprintln(\"b\")\n}\n", string(savedContent))
+}
+
 func TestTryGetInCompletedCodeBlock(t *testing.T) {
 	setup(t) // setup before the first test runs
 
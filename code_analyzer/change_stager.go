@@ -0,0 +1,121 @@
+package code_analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/meysamhadeli/codai/code_analyzer/contracts"
+	"github.com/meysamhadeli/codai/code_analyzer/models"
+	"github.com/meysamhadeli/codai/patch"
+)
+
+// SyntaxCheckError is returned when a change would leave a file that does not parse
+type SyntaxCheckError struct {
+	Path   string
+	Errors []models.SyntaxError
+}
+
+func (e *SyntaxCheckError) Error() string {
+	var details []string
+	for _, syntaxError := range e.Errors {
+		details = append(details, syntaxError.String())
+	}
+	return "the file does not parse after the change: " + strings.Join(details, "; ")
+}
+
+// ChangeStager stages the changes of files in a transaction, refusing the changes that leave a file unparsable, and
+// keeps the content of the files before their first change and after their last one to write the patch of the
+// staged changes.
+type ChangeStager struct {
+	rootDir     string
+	analyzer    contracts.ICodeAnalyzer
+	transaction contracts.IChangeTransaction
+
+	// originals and finals are the content of the changed files before and after the staged changes, in paths order;
+	// the original of a file created by the changes is nil
+	originals map[string]*string
+	finals    map[string]string
+	paths     []string
+}
+
+// NewChangeStager creates a stager of the changes of the project in rootDir in transaction
+func NewChangeStager(rootDir string, analyzer contracts.ICodeAnalyzer, transaction contracts.IChangeTransaction) *ChangeStager {
+	return &ChangeStager{
+		rootDir:     rootDir,
+		analyzer:    analyzer,
+		transaction: transaction,
+		originals:   make(map[string]*string),
+		finals:      make(map[string]string),
+	}
+}
+
+// Stage stages the change of code to the file at relativePath and returns its hunks, none when it changes nothing.
+// It returns a *PathRejectedError when the path policy rejects the path and a *SyntaxCheckError when the file would
+// not parse after the change.
+func (stager *ChangeStager) Stage(relativePath string, code string) ([]patch.Hunk, error) {
+	before, after, err := stager.transaction.Preview(relativePath, code)
+	if err != nil {
+		return nil, err
+	}
+
+	hunks := patch.Diff(before, after, 3)
+	if len(hunks) == 0 {
+		return nil, nil
+	}
+
+	if after != "" {
+		if syntaxErrors := stager.analyzer.ValidateSyntax(relativePath, []byte(after)); len(syntaxErrors) > 0 {
+			return hunks, &SyntaxCheckError{Path: relativePath, Errors: syntaxErrors}
+		}
+	}
+
+	if err := stager.transaction.StageContent(relativePath, after); err != nil {
+		return hunks, err
+	}
+
+	path := filepath.ToSlash(filepath.Clean(relativePath))
+	if _, ok := stager.originals[path]; !ok {
+		// The first change of a file previews the file on disk
+		var original *string
+		if _, err := os.Stat(filepath.Join(stager.rootDir, path)); err == nil {
+			original = &before
+		}
+		stager.originals[path] = original
+		stager.paths = append(stager.paths, path)
+	}
+	stager.finals[path] = after
+	return hunks, nil
+}
+
+// Staged returns the content of the changed files after their last staged change, by their slash-separated relative
+// path; the content of a deleted file is empty
+func (stager *ChangeStager) Staged() map[string]string {
+	staged := make(map[string]string, len(stager.finals))
+	for path, content := range stager.finals {
+		staged[path] = content
+	}
+	return staged
+}
+
+// Patch returns the staged changes as a unified diff for 'git apply', with the number of files it changes
+func (stager *ChangeStager) Patch() (string, int) {
+	var builder strings.Builder
+	files := 0
+	for _, path := range stager.paths {
+		oldPath, newPath, before, after := path, path, "", stager.finals[path]
+		if original := stager.originals[path]; original != nil {
+			before = *original
+		} else {
+			oldPath = ""
+		}
+		if after == "" {
+			newPath = ""
+		}
+		if diff := patch.Unified(oldPath, newPath, before, after, 3); diff != "" {
+			builder.WriteString(diff)
+			files++
+		}
+	}
+	return builder.String(), files
+}
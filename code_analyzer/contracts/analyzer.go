@@ -9,9 +9,15 @@ type ICodeAnalyzer interface {
 	GetProjectFilesWithDisplayMode(rootDir string, displayMode string) (*models.FullContextData, error)
 	GetProjectFilesIncremental(rootDir string) (*models.FullContextData, bool, error)
 	ProcessFile(filePath string, sourceCode []byte) []string
-	GeneratePrompt(codes []string, history []string, userInput string, requestedContext string) (string, string)
+	ValidateSyntax(filePath string, sourceCode []byte) []models.SyntaxError
+	GeneratePrompt(codes []string, userInput string, requestedContext string) (string, string)
+	GeneratePromptWithEditFormat(codes []string, userInput string, requestedContext string, editFormat string) (string, string)
 	ExtractCodeChanges(text string) []models.CodeChange
+	ExtractSearchReplaceChanges(text string) []models.CodeChange
 	ApplyChanges(relativePath, code string) error
+	BeginTransaction() IChangeTransaction
+	RevertTransaction(record *models.TransactionRecord) error
+	ReapplyTransaction(record *models.TransactionRecord) error
 	TryGetInCompletedCodeBlocK(relativePaths string) (string, error)
 	ClearCache() error
 	GetCacheStats() (map[string]interface{}, error)
@@ -0,0 +1,15 @@
+package contracts
+
+import (
+	"github.com/meysamhadeli/codai/code_analyzer/models"
+)
+
+// IChangeTransaction applies a set of code changes to several files as a single unit.
+type IChangeTransaction interface {
+	Preview(relativePath, code string) (string, string, error)
+	Stage(relativePath, code string) error
+	StageContent(relativePath, content string) error
+	StagedPaths() []string
+	Commit() (*models.TransactionRecord, error)
+	Discard()
+}
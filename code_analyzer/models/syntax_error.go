@@ -0,0 +1,20 @@
+package models
+
+import (
+	"fmt"
+)
+
+// SyntaxError is an ERROR or MISSING node found by tree-sitter when parsing the content of a file.
+type SyntaxError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Missing bool   `json:"missing"`
+	Text    string `json:"text"`
+}
+
+func (e SyntaxError) String() string {
+	if e.Missing {
+		return fmt.Sprintf("line %d:%d: missing %q", e.Line, e.Column, e.Text)
+	}
+	return fmt.Sprintf("line %d:%d: syntax error near %q", e.Line, e.Column, e.Text)
+}
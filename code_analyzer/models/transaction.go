@@ -0,0 +1,35 @@
+package models
+
+import (
+	"os"
+	"time"
+)
+
+// FileAction describes what a transaction did to a file.
+type FileAction string
+
+const (
+	FileCreated  FileAction = "create"
+	FileModified FileAction = "modify"
+	FileDeleted  FileAction = "delete"
+)
+
+// FileOperation records a single file change made by a transaction, with enough data to revert it.
+// RelativePath is the path as requested by the change and Path the resolved file that was written.
+type FileOperation struct {
+	RelativePath    string      `json:"relative_path"`
+	Path            string      `json:"path"`
+	Action          FileAction  `json:"action"`
+	PreviousContent []byte      `json:"previous_content,omitempty"`
+	NewContent      []byte      `json:"new_content,omitempty"`
+	Mode            os.FileMode `json:"mode"`
+	CreatedDirs     []string    `json:"created_dirs,omitempty"`
+	RemovedDirs     []string    `json:"removed_dirs,omitempty"`
+}
+
+// TransactionRecord records every file operation committed together for one AI response.
+type TransactionRecord struct {
+	ID         string          `json:"id"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Operations []FileOperation `json:"operations"`
+}
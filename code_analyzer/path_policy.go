@@ -0,0 +1,132 @@
+package code_analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// alwaysProtectedPaths can never be written by a code change, whatever the configuration
+var alwaysProtectedPaths = []string{".git", ".codai"}
+
+// PathRejectedError is returned when a code change targets a path the path policy does not allow
+type PathRejectedError struct {
+	Path   string
+	Reason string
+}
+
+func (e *PathRejectedError) Error() string {
+	return fmt.Sprintf("refusing to write %s: %s", e.Path, e.Reason)
+}
+
+// pathPolicy resolves the paths of code changes against the project root and rejects unsafe targets
+type pathPolicy struct {
+	root           string
+	realRoot       string
+	protectedPaths []string
+}
+
+func newPathPolicy(cwd string, protectedPaths []string) *pathPolicy {
+	root, err := filepath.Abs(cwd)
+	if err != nil {
+		root = filepath.Clean(cwd)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		realRoot = root
+	}
+
+	policy := &pathPolicy{root: root, realRoot: realRoot}
+	for _, protectedPath := range append(append([]string{}, alwaysProtectedPaths...), protectedPaths...) {
+		protectedPath = strings.Trim(filepath.Clean(filepath.FromSlash(strings.TrimSpace(protectedPath))), string(filepath.Separator))
+		if protectedPath != "" && protectedPath != "." {
+			policy.protectedPaths = append(policy.protectedPaths, protectedPath)
+		}
+	}
+	return policy
+}
+
+// resolve returns the real path of a change target inside the project root, following symbolic links
+func (policy *pathPolicy) resolve(relativePath string) (string, error) {
+	if strings.TrimSpace(relativePath) == "" || strings.ContainsRune(relativePath, 0) {
+		return "", &PathRejectedError{Path: relativePath, Reason: "the path is empty or invalid"}
+	}
+
+	target := filepath.FromSlash(relativePath)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(policy.root, target)
+	}
+	target = filepath.Clean(target)
+
+	if reason := policy.check(policy.root, target); reason != "" {
+		return "", &PathRejectedError{Path: relativePath, Reason: reason}
+	}
+
+	// Follow symbolic links, so a link inside the project can't redirect the write outside of it
+	realTarget, err := evalExistingSymlinks(target)
+	if err != nil {
+		return "", &PathRejectedError{Path: relativePath, Reason: fmt.Sprintf("the path can't be resolved: %v", err)}
+	}
+
+	if reason := policy.check(policy.realRoot, realTarget); reason != "" {
+		return "", &PathRejectedError{Path: relativePath, Reason: "it is a symbolic link and " + reason}
+	}
+
+	return realTarget, nil
+}
+
+// check returns why target can't be written, or an empty string when it is allowed
+func (policy *pathPolicy) check(root, target string) string {
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "it is outside the project root"
+	}
+	if rel == "." {
+		return "it is the project root"
+	}
+
+	components := strings.Split(rel, string(filepath.Separator))
+	for _, protectedPath := range policy.protectedPaths {
+		protectedComponents := strings.Split(protectedPath, string(filepath.Separator))
+
+		// A single name like ".git" is protected at any depth, a longer path only from the project root
+		if len(protectedComponents) == 1 {
+			for _, component := range components {
+				if strings.EqualFold(component, protectedPath) {
+					return fmt.Sprintf("%s is a protected directory", protectedPath)
+				}
+			}
+			continue
+		}
+
+		if len(components) >= len(protectedComponents) && strings.EqualFold(filepath.Join(components[:len(protectedComponents)]...), protectedPath) {
+			return fmt.Sprintf("%s is a protected directory", protectedPath)
+		}
+	}
+
+	return ""
+}
+
+// evalExistingSymlinks resolves the symbolic links of the longest existing prefix of path
+func evalExistingSymlinks(path string) (string, error) {
+	var missing []string
+	current := path
+	for {
+		if _, err := os.Lstat(current); err == nil {
+			real, err := filepath.EvalSymlinks(current)
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(append([]string{real}, missing...)...), nil
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return path, nil
+		}
+		missing = append([]string{filepath.Base(current)}, missing...)
+		current = parent
+	}
+}
@@ -0,0 +1,101 @@
+package code_analyzer
+
+import (
+	"strings"
+
+	"github.com/meysamhadeli/codai/code_analyzer/models"
+	"github.com/meysamhadeli/codai/utils"
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/csharp"
+	"github.com/smacker/go-tree-sitter/golang"
+	"github.com/smacker/go-tree-sitter/java"
+	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/python"
+	"github.com/smacker/go-tree-sitter/typescript/typescript"
+)
+
+// maxSyntaxErrorText limits the source snippet reported for an ERROR node
+const maxSyntaxErrorText = 40
+
+// ValidateSyntax parses the content with the tree-sitter grammar of the file and returns its ERROR and MISSING nodes.
+// Files without a grammar are never reported as invalid.
+func (analyzer *CodeAnalyzer) ValidateSyntax(filePath string, sourceCode []byte) []models.SyntaxError {
+	lang := treeSitterLanguage(utils.GetSupportedLanguage(filePath))
+	if lang == nil || len(strings.TrimSpace(string(sourceCode))) == 0 {
+		return nil
+	}
+
+	parser := sitter.NewParser()
+	parser.SetLanguage(lang)
+
+	tree := parser.Parse(nil, sourceCode)
+	if tree == nil || !tree.RootNode().HasError() {
+		return nil
+	}
+
+	var syntaxErrors []models.SyntaxError
+	collectSyntaxErrors(tree.RootNode(), sourceCode, &syntaxErrors)
+	return syntaxErrors
+}
+
+// treeSitterLanguage returns the grammar for a language reported by utils.GetSupportedLanguage, or nil if there is none
+func treeSitterLanguage(language string) *sitter.Language {
+	switch language {
+	case "csharp":
+		return csharp.GetLanguage()
+	case "go":
+		return golang.GetLanguage()
+	case "python":
+		return python.GetLanguage()
+	case "java":
+		return java.GetLanguage()
+	case "javascript":
+		return javascript.GetLanguage()
+	case "typescript":
+		return typescript.GetLanguage()
+	default:
+		return nil
+	}
+}
+
+func collectSyntaxErrors(node *sitter.Node, sourceCode []byte, syntaxErrors *[]models.SyntaxError) {
+	if node == nil || !(node.HasError() || node.IsMissing()) {
+		return
+	}
+
+	position := node.StartPoint()
+	switch {
+	case node.IsMissing():
+		*syntaxErrors = append(*syntaxErrors, models.SyntaxError{
+			Line:    int(position.Row) + 1,
+			Column:  int(position.Column) + 1,
+			Missing: true,
+			Text:    node.Type(),
+		})
+		return
+	case node.IsError():
+		// Prefer the most specific errors inside, an ERROR node often wraps a large valid part of the file
+		found := len(*syntaxErrors)
+		for i := 0; i < int(node.ChildCount()); i++ {
+			collectSyntaxErrors(node.Child(i), sourceCode, syntaxErrors)
+		}
+		if len(*syntaxErrors) > found {
+			return
+		}
+
+		text := strings.TrimSpace(strings.SplitN(node.Content(sourceCode), "\n", 2)[0])
+		if len(text) > maxSyntaxErrorText {
+			text = text[:maxSyntaxErrorText] + "..."
+		}
+		*syntaxErrors = append(*syntaxErrors, models.SyntaxError{
+			Line:   int(position.Row) + 1,
+			Column: int(position.Column) + 1,
+			Text:   text,
+		})
+		return
+	}
+
+	for i := 0; i < int(node.ChildCount()); i++ {
+		collectSyntaxErrors(node.Child(i), sourceCode, syntaxErrors)
+	}
+}
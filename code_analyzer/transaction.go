@@ -0,0 +1,470 @@
+package code_analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/meysamhadeli/codai/code_analyzer/contracts"
+	"github.com/meysamhadeli/codai/code_analyzer/models"
+)
+
+// renameFile is used to move staged files into place, so tests can simulate a failing rename
+var renameFile = os.Rename
+
+// changeTransaction stages the accepted changes of one AI response and commits them all or none.
+type changeTransaction struct {
+	policy *pathPolicy
+	staged []*stagedChange
+}
+
+// stagedChange holds the resolved content of one file and the state it had when it was staged.
+type stagedChange struct {
+	relativePath string
+	path         string
+	existed      bool
+	previous     []byte
+	mode         os.FileMode
+	updated      string
+}
+
+func (change *stagedChange) isDeletion() bool {
+	return strings.TrimSpace(change.updated) == ""
+}
+
+// preparedChange is a staged change whose new content has been written to a temporary file.
+type preparedChange struct {
+	change      *stagedChange
+	tempPath    string
+	createdDirs []string
+}
+
+// BeginTransaction starts a new transaction for applying several code changes atomically.
+func (analyzer *CodeAnalyzer) BeginTransaction() contracts.IChangeTransaction {
+	return &changeTransaction{policy: analyzer.pathPolicy()}
+}
+
+// Preview resolves the change against the current file (or the content already staged for it) without touching disk,
+// returning the content before and after the change.
+func (transaction *changeTransaction) Preview(relativePath, code string) (string, string, error) {
+	path, err := transaction.policy.resolve(relativePath)
+	if err != nil {
+		return "", "", err
+	}
+
+	var current []byte
+	if change := transaction.find(path); change != nil {
+		current = []byte(change.updated)
+	} else {
+		previous, _, _, err := readCurrentFile(path)
+		if err != nil {
+			return "", "", err
+		}
+		current = previous
+	}
+
+	updated, err := resolveUpdatedContent(relativePath, current, code)
+	if err != nil {
+		return "", "", err
+	}
+	return string(current), updated, nil
+}
+
+// Stage resolves the change and stages the resulting content.
+func (transaction *changeTransaction) Stage(relativePath, code string) error {
+	_, updated, err := transaction.Preview(relativePath, code)
+	if err != nil {
+		return err
+	}
+	return transaction.StageContent(relativePath, updated)
+}
+
+// StageContent stages the complete new content of a file; empty content deletes the file.
+func (transaction *changeTransaction) StageContent(relativePath, content string) error {
+	path, err := transaction.policy.resolve(relativePath)
+	if err != nil {
+		return err
+	}
+
+	if change := transaction.find(path); change != nil {
+		change.updated = content
+		return nil
+	}
+
+	previous, existed, mode, err := readCurrentFile(path)
+	if err != nil {
+		return err
+	}
+
+	change := &stagedChange{relativePath: relativePath, path: path, existed: existed, previous: previous, mode: mode, updated: content}
+	if change.isDeletion() && !existed {
+		// Nothing to delete
+		return nil
+	}
+
+	transaction.staged = append(transaction.staged, change)
+	return nil
+}
+
+func (transaction *changeTransaction) find(path string) *stagedChange {
+	for _, change := range transaction.staged {
+		if change.path == path {
+			return change
+		}
+	}
+	return nil
+}
+
+// StagedPaths returns the relative paths of the staged changes in staging order.
+func (transaction *changeTransaction) StagedPaths() []string {
+	var paths []string
+	for _, change := range transaction.staged {
+		paths = append(paths, change.relativePath)
+	}
+	return paths
+}
+
+// Discard drops every staged change.
+func (transaction *changeTransaction) Discard() {
+	transaction.staged = nil
+}
+
+// Commit validates the staged changes, writes them to temporary files and renames them into place.
+// If any step fails every file is restored to its previous state.
+func (transaction *changeTransaction) Commit() (*models.TransactionRecord, error) {
+	record := &models.TransactionRecord{
+		ID:        fmt.Sprintf("%d", time.Now().UnixNano()),
+		Timestamp: time.Now(),
+	}
+
+	if len(transaction.staged) == 0 {
+		return record, nil
+	}
+
+	// Validate that nothing changed on disk since the changes were staged
+	for _, change := range transaction.staged {
+		current, existed, _, err := readCurrentFile(change.path)
+		if err != nil {
+			return nil, err
+		}
+		if existed != change.existed || !bytes.Equal(current, change.previous) {
+			return nil, fmt.Errorf("file %s changed on disk after the change was staged", change.relativePath)
+		}
+	}
+
+	// Write the new contents to temporary files next to their targets
+	var prepared []preparedChange
+	discardPrepared := func() {
+		for i := len(prepared) - 1; i >= 0; i-- {
+			if prepared[i].tempPath != "" {
+				_ = os.Remove(prepared[i].tempPath)
+			}
+			removeCreatedDirectories(prepared[i].createdDirs)
+		}
+	}
+
+	for _, change := range transaction.staged {
+		if change.isDeletion() {
+			prepared = append(prepared, preparedChange{change: change})
+			continue
+		}
+
+		createdDirs, err := createDirectories(filepath.Dir(change.path))
+		if err != nil {
+			discardPrepared()
+			return nil, err
+		}
+		prepared = append(prepared, preparedChange{change: change, createdDirs: createdDirs})
+
+		tempPath, err := writeTempFile(change.path, []byte(change.updated), change.mode)
+		if err != nil {
+			discardPrepared()
+			return nil, err
+		}
+		prepared[len(prepared)-1].tempPath = tempPath
+	}
+
+	// Move every file into place, rolling back what was already done on failure
+	for i, preparedChange := range prepared {
+		change := preparedChange.change
+		operation := models.FileOperation{
+			RelativePath: change.relativePath,
+			Path:         change.path,
+			Mode:         change.mode,
+			CreatedDirs:  preparedChange.createdDirs,
+		}
+		if change.existed {
+			operation.PreviousContent = change.previous
+		}
+
+		var err error
+		switch {
+		case change.isDeletion():
+			operation.Action = models.FileDeleted
+			if err = os.Remove(change.path); err == nil {
+				operation.RemovedDirs, err = removeEmptyDirectories(filepath.Dir(change.path))
+			}
+		default:
+			operation.Action = models.FileModified
+			if !change.existed {
+				operation.Action = models.FileCreated
+			}
+			operation.NewContent = []byte(change.updated)
+			err = renameFile(preparedChange.tempPath, change.path)
+		}
+
+		if err != nil {
+			rollbackErr := revertOperations(record.Operations)
+			for _, remaining := range prepared[i:] {
+				if remaining.tempPath != "" {
+					_ = os.Remove(remaining.tempPath)
+				}
+				removeCreatedDirectories(remaining.createdDirs)
+			}
+			if rollbackErr != nil {
+				return nil, fmt.Errorf("failed to apply %s: %v (rollback failed: %v)", change.relativePath, err, rollbackErr)
+			}
+			return nil, fmt.Errorf("failed to apply %s, all changes were rolled back: %w", change.relativePath, err)
+		}
+
+		record.Operations = append(record.Operations, operation)
+	}
+
+	transaction.staged = nil
+	return record, nil
+}
+
+// RevertTransaction restores every file touched by the transaction to its previous state.
+// It refuses to revert when a file was modified after the transaction was committed.
+func (analyzer *CodeAnalyzer) RevertTransaction(record *models.TransactionRecord) error {
+	if err := analyzer.checkOperations(record); err != nil {
+		return err
+	}
+
+	var conflicts []string
+	for _, operation := range record.Operations {
+		current, existed, _, err := readCurrentFile(operation.Path)
+		if err != nil {
+			return err
+		}
+		switch operation.Action {
+		case models.FileDeleted:
+			if existed {
+				conflicts = append(conflicts, operation.RelativePath)
+			}
+		default:
+			if !existed || !bytes.Equal(current, operation.NewContent) {
+				conflicts = append(conflicts, operation.RelativePath)
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("files changed since the transaction was applied: %s", strings.Join(conflicts, ", "))
+	}
+
+	return revertOperations(record.Operations)
+}
+
+// checkOperations verifies that a recorded transaction only touches paths the path policy still allows.
+func (analyzer *CodeAnalyzer) checkOperations(record *models.TransactionRecord) error {
+	policy := analyzer.pathPolicy()
+	for _, operation := range record.Operations {
+		path, err := policy.resolve(operation.RelativePath)
+		if err != nil {
+			return err
+		}
+		if path != operation.Path {
+			return &PathRejectedError{Path: operation.RelativePath, Reason: "it no longer resolves to the recorded file"}
+		}
+	}
+	return nil
+}
+
+// revertOperations undoes the operations in reverse order.
+func revertOperations(operations []models.FileOperation) error {
+	var errs []string
+	for i := len(operations) - 1; i >= 0; i-- {
+		if err := revertOperation(operations[i]); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func revertOperation(operation models.FileOperation) error {
+	switch operation.Action {
+	case models.FileCreated:
+		if err := os.Remove(operation.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", operation.RelativePath, err)
+		}
+		removeCreatedDirectories(operation.CreatedDirs)
+	case models.FileModified, models.FileDeleted:
+		for _, dir := range operation.RemovedDirs {
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				return fmt.Errorf("failed to recreate directory %s: %w", dir, err)
+			}
+		}
+		if err := replaceFile(operation.Path, operation.PreviousContent, operation.Mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReapplyTransaction applies a reverted transaction again.
+// It refuses to reapply when a file no longer matches the state the transaction started from.
+func (analyzer *CodeAnalyzer) ReapplyTransaction(record *models.TransactionRecord) error {
+	if err := analyzer.checkOperations(record); err != nil {
+		return err
+	}
+
+	var conflicts []string
+	for _, operation := range record.Operations {
+		current, existed, _, err := readCurrentFile(operation.Path)
+		if err != nil {
+			return err
+		}
+		switch operation.Action {
+		case models.FileCreated:
+			if existed {
+				conflicts = append(conflicts, operation.RelativePath)
+			}
+		default:
+			if !existed || !bytes.Equal(current, operation.PreviousContent) {
+				conflicts = append(conflicts, operation.RelativePath)
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("files changed since the transaction was reverted: %s", strings.Join(conflicts, ", "))
+	}
+
+	var applied []models.FileOperation
+	for _, operation := range record.Operations {
+		if err := reapplyOperation(operation); err != nil {
+			if rollbackErr := revertOperations(applied); rollbackErr != nil {
+				return fmt.Errorf("%v (rollback failed: %v)", err, rollbackErr)
+			}
+			return err
+		}
+		applied = append(applied, operation)
+	}
+	return nil
+}
+
+func reapplyOperation(operation models.FileOperation) error {
+	switch operation.Action {
+	case models.FileDeleted:
+		if err := os.Remove(operation.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete %s: %w", operation.RelativePath, err)
+		}
+		removeCreatedDirectories(operation.RemovedDirs)
+	default:
+		for _, dir := range operation.CreatedDirs {
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				return fmt.Errorf("failed to create directory %s: %w", dir, err)
+			}
+		}
+		if err := replaceFile(operation.Path, operation.NewContent, operation.Mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceFile atomically replaces the content of a file through a temporary file.
+func replaceFile(relativePath string, content []byte, mode os.FileMode) error {
+	tempPath, err := writeTempFile(relativePath, content, mode)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, relativePath); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write %s: %w", relativePath, err)
+	}
+	return nil
+}
+
+// readCurrentFile returns the content and mode of a file, reporting whether it exists.
+func readCurrentFile(relativePath string) ([]byte, bool, os.FileMode, error) {
+	info, err := os.Stat(relativePath)
+	if os.IsNotExist(err) {
+		return nil, false, 0644, nil
+	}
+	if err != nil {
+		return nil, false, 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, false, 0, fmt.Errorf("failed to read file: %s is a directory", relativePath)
+	}
+
+	content, err := os.ReadFile(relativePath)
+	if err != nil {
+		return nil, false, 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	return content, true, info.Mode().Perm(), nil
+}
+
+// writeTempFile writes content to a temporary file in the target's directory, so it can be renamed atomically.
+func writeTempFile(relativePath string, content []byte, mode os.FileMode) (string, error) {
+	tempFile, err := os.CreateTemp(filepath.Dir(relativePath), "."+filepath.Base(relativePath)+".codai-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file for %s: %w", relativePath, err)
+	}
+	tempPath := tempFile.Name()
+
+	if _, err := tempFile.Write(content); err != nil {
+		tempFile.Close()
+		_ = os.Remove(tempPath)
+		return "", fmt.Errorf("failed to write temporary file for %s: %w", relativePath, err)
+	}
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		_ = os.Remove(tempPath)
+		return "", fmt.Errorf("failed to sync temporary file for %s: %w", relativePath, err)
+	}
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return "", fmt.Errorf("failed to close temporary file for %s: %w", relativePath, err)
+	}
+	if err := os.Chmod(tempPath, mode); err != nil {
+		_ = os.Remove(tempPath)
+		return "", fmt.Errorf("failed to set mode of temporary file for %s: %w", relativePath, err)
+	}
+
+	return tempPath, nil
+}
+
+// createDirectories creates dir and its missing parents, returning the created directories from outermost to innermost.
+func createDirectories(dir string) ([]string, error) {
+	var missing []string
+	for current := dir; current != "." && current != string(filepath.Separator); current = filepath.Dir(current) {
+		if _, err := os.Stat(current); err == nil {
+			break
+		}
+		missing = append([]string{current}, missing...)
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	return missing, nil
+}
+
+// removeCreatedDirectories removes directories created by a transaction, innermost first, as long as they are empty.
+func removeCreatedDirectories(dirs []string) {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+}
@@ -0,0 +1,280 @@
+package code_review
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	contracts_analyzer "github.com/meysamhadeli/codai/code_analyzer/contracts"
+	"github.com/meysamhadeli/codai/code_review/contracts"
+	"github.com/meysamhadeli/codai/code_review/models"
+	"github.com/meysamhadeli/codai/embed_data"
+	"github.com/meysamhadeli/codai/patch"
+	contracts_provider "github.com/meysamhadeli/codai/providers/contracts"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/meysamhadeli/codai/utils"
+)
+
+// maxFileContextLines limits the content of a changed file sent to the AI as context of the diff
+const maxFileContextLines = 1000
+
+// defaultCategory is the category of the findings the AI did not categorize
+const defaultCategory = "general"
+
+// ErrNoChanges is returned when the diff to review has no changes
+var ErrNoChanges = errors.New("there are no changes to review")
+
+// codeReviewer asks the AI for a structured review of a diff, with the content of the changed files as context
+type codeReviewer struct {
+	rootDir  string
+	analyzer contracts_analyzer.ICodeAnalyzer
+	provider contracts_provider.IChatAIProvider
+}
+
+// NewCodeReviewer creates the reviewer of the diffs of the project in rootDir
+func NewCodeReviewer(rootDir string, analyzer contracts_analyzer.ICodeAnalyzer, provider contracts_provider.IChatAIProvider) contracts.ICodeReviewer {
+	return &codeReviewer{rootDir: rootDir, analyzer: analyzer, provider: provider}
+}
+
+func (reviewer *codeReviewer) Review(ctx context.Context, request models.ReviewRequest) (*models.ReviewResult, error) {
+	files, err := changedFiles(request.Diff)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := reviewer.fileContents(request.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	systemPrompt := fmt.Sprintf("## Here is the content of the changed files after the changes, with line numbers\n\n%s\n\n______\n%s",
+		fileContext(files, contents), string(embed_data.CodeReviewPrompt))
+	userPrompt := fmt.Sprintf("## Here are the changes to review (%s)\n\n```diff\n%s\n```", request.Description, request.Diff)
+
+	response, err := reviewer.complete(ctx, userPrompt, systemPrompt)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := parseReview(response, files)
+	if err != nil {
+		return nil, err
+	}
+	result.Description = request.Description
+	return result, nil
+}
+
+// complete sends the request to the AI and returns its whole answer
+func (reviewer *codeReviewer) complete(ctx context.Context, userPrompt string, systemPrompt string) (string, error) {
+	answer, err := provider_models.CollectAnswer(reviewer.provider.ChatCompletionRequest(ctx, provider_models.NewConversation(systemPrompt, nil, userPrompt)), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get AI response: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return answer.Content, nil
+}
+
+// fileContents returns a function reading the new version of a changed file in the revision of the diff: the files the
+// analyzer finds in the working tree for its changes, or the files of git for a commit or the index. Deleted and
+// ignored files are not found.
+func (reviewer *codeReviewer) fileContents(revision string) (func(path string) (string, bool), error) {
+	if revision != "" {
+		if revision == models.RevisionIndex {
+			revision = ""
+		}
+		git := utils.NewGitOperations(reviewer.rootDir)
+		return func(path string) (string, bool) {
+			content, err := git.GetFileAtRevision(revision, path)
+			return content, err == nil
+		}, nil
+	}
+
+	fullContext, err := reviewer.analyzer.GetProjectFilesWithDisplayMode(reviewer.rootDir, "full")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the context of the project: %w", err)
+	}
+	contents := make(map[string]string)
+	for _, fileData := range fullContext.FileData {
+		contents[fileData.RelativePath] = fileData.Code
+	}
+	return func(path string) (string, bool) {
+		content, ok := contents[path]
+		return content, ok
+	}, nil
+}
+
+// fileContext formats the content of the changed files, with line numbers so the AI can report the lines of its
+// findings. The files without content are left out.
+func fileContext(files []string, contents func(path string) (string, bool)) string {
+	var builder strings.Builder
+	for _, file := range files {
+		content, ok := contents(file)
+		if !ok {
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("### File: %s\n```\n", file))
+		lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+		for i, line := range lines {
+			if i == maxFileContextLines {
+				builder.WriteString(fmt.Sprintf("... (truncated %d more lines)\n", len(lines)-maxFileContextLines))
+				break
+			}
+			builder.WriteString(fmt.Sprintf("%5d | %s\n", i+1, line))
+		}
+		builder.WriteString("```\n\n")
+	}
+	return builder.String()
+}
+
+// changedFiles returns the paths of the files changed by a git diff, in the order of the diff
+func changedFiles(diff string) ([]string, error) {
+	if strings.TrimSpace(diff) == "" {
+		return nil, ErrNoChanges
+	}
+
+	filePatches, err := patch.Parse(diff)
+	if err != nil {
+		if errors.Is(err, patch.ErrNoHunks) {
+			return nil, ErrNoChanges
+		}
+		return nil, fmt.Errorf("failed to parse the diff: %w", err)
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, filePatch := range filePatches {
+		path := filePatch.NewPath
+		if path == "" {
+			path = filePatch.OldPath
+		}
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		files = append(files, path)
+	}
+	if len(files) == 0 {
+		return nil, ErrNoChanges
+	}
+	return files, nil
+}
+
+// reviewResponse is the JSON object the AI answers with
+type reviewResponse struct {
+	Summary  string `json:"summary"`
+	Findings []struct {
+		File       string      `json:"file"`
+		Line       flexibleInt `json:"line"`
+		EndLine    flexibleInt `json:"end_line"`
+		Severity   string      `json:"severity"`
+		Category   string      `json:"category"`
+		Title      string      `json:"title"`
+		Message    string      `json:"message"`
+		Suggestion string      `json:"suggestion"`
+	} `json:"findings"`
+}
+
+// flexibleInt accepts a line number as a JSON number or a string, as the AI answers with both
+type flexibleInt int
+
+func (value *flexibleInt) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), "\"")
+	if text == "" || text == "null" {
+		*value = 0
+		return nil
+	}
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return fmt.Errorf("invalid line number %s", string(data))
+	}
+	*value = flexibleInt(number)
+	return nil
+}
+
+// parseReview parses the answer of the AI, keeping the findings about the changed files sorted by file and line
+func parseReview(response string, files []string) (*models.ReviewResult, error) {
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("the AI did not answer with a review in JSON format")
+	}
+
+	var parsed reviewResponse
+	if err := json.Unmarshal([]byte(response[start:end+1]), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse the review of the AI: %w", err)
+	}
+
+	order := make(map[string]int)
+	for i, file := range files {
+		order[file] = i
+	}
+
+	result := &models.ReviewResult{Summary: strings.TrimSpace(parsed.Summary), Files: files, Findings: []models.Finding{}}
+	for _, finding := range parsed.Findings {
+		file, ok := diffPath(finding.File, order)
+		if !ok {
+			// The AI reported a file outside of the diff
+			continue
+		}
+
+		severity, ok := models.ParseSeverity(finding.Severity)
+		if !ok {
+			severity = models.SeverityWarning
+		}
+		category := strings.ToLower(strings.TrimSpace(finding.Category))
+		if category == "" {
+			category = defaultCategory
+		}
+		line, endLine := max(int(finding.Line), 0), int(finding.EndLine)
+		if endLine <= line {
+			endLine = 0
+		}
+		message := strings.TrimSpace(finding.Message)
+		title := strings.TrimSpace(finding.Title)
+		if title == "" {
+			title = strings.SplitN(message, "\n", 2)[0]
+		}
+		if title == "" {
+			continue
+		}
+
+		result.Findings = append(result.Findings, models.Finding{
+			File:       file,
+			Line:       line,
+			EndLine:    endLine,
+			Severity:   severity,
+			Category:   category,
+			Title:      title,
+			Message:    message,
+			Suggestion: strings.TrimSpace(finding.Suggestion),
+		})
+	}
+
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		left, right := result.Findings[i], result.Findings[j]
+		if left.File != right.File {
+			return order[left.File] < order[right.File]
+		}
+		return left.Line < right.Line
+	})
+	return result, nil
+}
+
+// diffPath finds the file of the diff a finding is about, stripping the prefixes of git diff paths the AI may keep
+func diffPath(path string, files map[string]int) (string, bool) {
+	path = strings.TrimSpace(strings.ReplaceAll(path, "\\", "/"))
+	if _, ok := files[path]; ok {
+		return path, true
+	}
+	for _, prefix := range []string{"a/", "b/", "./"} {
+		if _, ok := files[strings.TrimPrefix(path, prefix)]; ok {
+			return strings.TrimPrefix(path, prefix), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,174 @@
+package code_review
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meysamhadeli/codai/code_analyzer"
+	"github.com/meysamhadeli/codai/code_review/models"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
+
+ func main() {
++	panic("todo")
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package main
+`
+
+// fakeProvider answers every request with its response and records the prompts it received
+type fakeProvider struct {
+	response     string
+	userPrompt   string
+	systemPrompt string
+}
+
+func (provider *fakeProvider) ChatCompletionRequest(ctx context.Context, messages []provider_models.Message) <-chan provider_models.StreamResponse {
+	provider.systemPrompt, provider.userPrompt = messages[0].Text(), messages[len(messages)-1].Text()
+	responses := make(chan provider_models.StreamResponse, 3)
+	responses <- provider_models.StreamResponse{Content: provider.response}
+	responses <- provider_models.StreamResponse{Done: true}
+	responses <- provider_models.StreamResponse{Content: provider.response}
+	close(responses)
+	return responses
+}
+
+func TestChangedFiles(t *testing.T) {
+	files, err := changedFiles(testDiff)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"main.go", "old.go"}, files)
+
+	_, err = changedFiles("")
+	assert.ErrorIs(t, err, ErrNoChanges)
+}
+
+func TestParseReview(t *testing.T) {
+	response := "Here is my review:\n```json\n" + `{
+  "summary": "Adds a panic.",
+  "findings": [
+    {"file": "b/main.go", "line": "4", "severity": "critical", "category": "Bug", "title": "Panic in main", "message": "main always panics."},
+    {"file": "main.go", "line": 1, "end_line": 1, "severity": "whatever", "message": "Package comment is missing"},
+    {"file": "unknown.go", "line": 3, "severity": "error", "title": "Not in the diff"},
+    {"file": "old.go", "line": 0, "severity": "nit", "category": "", "title": "Deleted file", "suggestion": " Keep it "}
+  ]
+}` + "\n```"
+
+	result, err := parseReview(response, []string{"main.go", "old.go"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Adds a panic.", result.Summary)
+	assert.Equal(t, []models.Finding{
+		{File: "main.go", Line: 1, Severity: models.SeverityWarning, Category: defaultCategory, Title: "Package comment is missing", Message: "Package comment is missing"},
+		{File: "main.go", Line: 4, Severity: models.SeverityError, Category: "bug", Title: "Panic in main", Message: "main always panics."},
+		{File: "old.go", Severity: models.SeverityInfo, Category: defaultCategory, Title: "Deleted file", Suggestion: "Keep it"},
+	}, result.Findings)
+
+	assert.Equal(t, 1, result.CountAtLeast(models.SeverityError))
+	assert.Equal(t, 2, result.CountAtLeast(models.SeverityWarning))
+	assert.Equal(t, 3, result.CountAtLeast(models.SeverityInfo))
+
+	_, err = parseReview("The changes look good.", []string{"main.go"})
+	assert.Error(t, err)
+}
+
+func TestReview(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n\tpanic(\"todo\")\n}\n"), 0644))
+
+	provider := &fakeProvider{response: `{"summary": "ok", "findings": [{"file": "main.go", "line": 4, "severity": "error", "title": "Panic"}]}`}
+	reviewer := NewCodeReviewer(dir, code_analyzer.NewCodeAnalyzer(dir, false), provider)
+
+	result, err := reviewer.Review(context.Background(), models.ReviewRequest{Diff: testDiff, Description: "staged changes"})
+	assert.NoError(t, err)
+	assert.Equal(t, "staged changes", result.Description)
+	assert.Len(t, result.Findings, 1, "the content sent after Done is ignored")
+
+	assert.Contains(t, provider.systemPrompt, "### File: main.go")
+	assert.Contains(t, provider.systemPrompt, "    4 | \tpanic(\"todo\")")
+	assert.NotContains(t, provider.systemPrompt, "### File: old.go")
+	assert.Contains(t, provider.userPrompt, testDiff)
+
+	_, err = reviewer.Review(context.Background(), models.ReviewRequest{Diff: "\n"})
+	assert.ErrorIs(t, err, ErrNoChanges)
+}
+
+func TestReview_Revision(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=codai", "-c", "user.email=codai@example.com"}, args...)...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(output))
+	}
+	git("init", "-q")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n}\n"), 0644))
+	git("add", "main.go")
+	git("commit", "-q", "-m", "initial")
+
+	// The staged version differs from the working tree
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n\tpanic(\"todo\")\n}\n"), 0644))
+	git("add", "main.go")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\n// main is unfinished\nfunc main() {\n\tpanic(\"todo\")\n}\n"), 0644))
+
+	provider := &fakeProvider{response: `{"summary": "ok", "findings": []}`}
+	reviewer := NewCodeReviewer(dir, code_analyzer.NewCodeAnalyzer(dir, false), provider)
+
+	_, err := reviewer.Review(context.Background(), models.ReviewRequest{Diff: testDiff, Description: "staged changes", Revision: models.RevisionIndex})
+	assert.NoError(t, err)
+	assert.Contains(t, provider.systemPrompt, "    4 | \tpanic(\"todo\")")
+	assert.NotContains(t, provider.systemPrompt, "main is unfinished")
+
+	_, err = reviewer.Review(context.Background(), models.ReviewRequest{Diff: testDiff, Description: "HEAD~1..HEAD", Revision: "HEAD"})
+	assert.NoError(t, err)
+	assert.Contains(t, provider.systemPrompt, "    3 | func main() {")
+	assert.NotContains(t, provider.systemPrompt, "panic")
+}
+
+func TestToSARIF(t *testing.T) {
+	result := &models.ReviewResult{Findings: []models.Finding{
+		{File: "main.go", Line: 4, EndLine: 6, Severity: models.SeverityError, Category: "bug", Title: "Panic", Message: "main always panics.", Suggestion: "Return an error."},
+		{File: "old.go", Severity: models.SeverityInfo, Category: "maintainability", Title: "Deleted file"},
+	}}
+
+	data, err := ToSARIF(result, "1.0.0")
+	assert.NoError(t, err)
+
+	var log map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &log))
+	assert.Equal(t, "2.1.0", log["version"])
+
+	run := log["runs"].([]interface{})[0].(map[string]interface{})
+	driver := run["tool"].(map[string]interface{})["driver"].(map[string]interface{})
+	assert.Equal(t, "codai", driver["name"])
+	assert.Len(t, driver["rules"], 2)
+
+	results := run["results"].([]interface{})
+	assert.Len(t, results, 2)
+
+	first := results[0].(map[string]interface{})
+	assert.Equal(t, "codai/bug", first["ruleId"])
+	assert.Equal(t, "error", first["level"])
+	assert.True(t, strings.HasSuffix(first["message"].(map[string]interface{})["text"].(string), "Suggestion: Return an error."))
+	region := first["locations"].([]interface{})[0].(map[string]interface{})["physicalLocation"].(map[string]interface{})["region"].(map[string]interface{})
+	assert.Equal(t, float64(4), region["startLine"])
+	assert.Equal(t, float64(6), region["endLine"])
+
+	second := results[1].(map[string]interface{})
+	assert.Equal(t, "note", second["level"])
+	assert.NotContains(t, second["locations"].([]interface{})[0].(map[string]interface{})["physicalLocation"], "region")
+}
@@ -0,0 +1,11 @@
+package contracts
+
+import (
+	"context"
+
+	"github.com/meysamhadeli/codai/code_review/models"
+)
+
+type ICodeReviewer interface {
+	Review(ctx context.Context, request models.ReviewRequest) (*models.ReviewResult, error)
+}
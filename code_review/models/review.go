@@ -0,0 +1,45 @@
+package models
+
+// RevisionIndex is the revision of the staged changes, whose new version of the files is in the git index
+const RevisionIndex = ":"
+
+// ReviewRequest is a diff to review, with a description of what it covers like 'staged changes' or 'main...HEAD'
+type ReviewRequest struct {
+	Diff        string
+	Description string
+	// Revision holds the new version of the files of the diff: a commit, RevisionIndex for the staged changes, or
+	// empty for the changes of the working tree
+	Revision string
+}
+
+// Finding is one problem found in the reviewed changes. Line and EndLine are in the new version of the file, 0 when
+// the finding is about the whole file.
+type Finding struct {
+	File       string   `json:"file"`
+	Line       int      `json:"line"`
+	EndLine    int      `json:"end_line,omitempty"`
+	Severity   Severity `json:"severity"`
+	Category   string   `json:"category"`
+	Title      string   `json:"title"`
+	Message    string   `json:"message"`
+	Suggestion string   `json:"suggestion,omitempty"`
+}
+
+// ReviewResult is the review of a diff
+type ReviewResult struct {
+	Description string    `json:"description"`
+	Summary     string    `json:"summary"`
+	Files       []string  `json:"files"`
+	Findings    []Finding `json:"findings"`
+}
+
+// CountAtLeast counts the findings with a severity of at least severity
+func (result *ReviewResult) CountAtLeast(severity Severity) int {
+	count := 0
+	for _, finding := range result.Findings {
+		if finding.Severity.Rank() >= severity.Rank() {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,41 @@
+package models
+
+import "strings"
+
+// Severity is how important a review finding is, from the least to the most important
+type Severity string
+
+const (
+	// SeverityInfo is a suggestion that would improve the code
+	SeverityInfo Severity = "info"
+	// SeverityWarning is a problem that should be fixed
+	SeverityWarning Severity = "warning"
+	// SeverityError is a bug or a security issue that must be fixed before merging
+	SeverityError Severity = "error"
+)
+
+// Rank orders the severities, higher is more important
+func (severity Severity) Rank() int {
+	switch severity {
+	case SeverityError:
+		return 2
+	case SeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ParseSeverity parses a severity, accepting the usual synonyms the AI may answer with
+func ParseSeverity(text string) (Severity, bool) {
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "error", "critical", "high", "blocker", "major":
+		return SeverityError, true
+	case "warning", "warn", "medium", "moderate":
+		return SeverityWarning, true
+	case "info", "note", "low", "minor", "suggestion", "nit":
+		return SeverityInfo, true
+	default:
+		return "", false
+	}
+}
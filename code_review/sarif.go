@@ -0,0 +1,142 @@
+package code_review
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/meysamhadeli/codai/code_review/models"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// ToSARIF converts a review to a SARIF 2.1.0 log, the format of code scanning tools, with one rule per category
+func ToSARIF(result *models.ReviewResult, version string) ([]byte, error) {
+	categories := make(map[string]bool)
+	results := []sarifResult{}
+	for _, finding := range result.Findings {
+		categories[finding.Category] = true
+
+		text := finding.Title
+		if finding.Message != "" && finding.Message != finding.Title {
+			text += "\n\n" + finding.Message
+		}
+		if finding.Suggestion != "" {
+			text += "\n\nSuggestion: " + finding.Suggestion
+		}
+
+		location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: finding.File}}}
+		if finding.Line > 0 {
+			location.PhysicalLocation.Region = &sarifRegion{StartLine: finding.Line, EndLine: finding.EndLine}
+		}
+
+		results = append(results, sarifResult{
+			RuleID:    sarifRuleID(finding.Category),
+			Level:     sarifLevel(finding.Severity),
+			Message:   sarifMessage{Text: text},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	rules := []sarifRule{}
+	for category := range categories {
+		rules = append(rules, sarifRule{
+			ID:               sarifRuleID(category),
+			Name:             category,
+			ShortDescription: sarifMessage{Text: fmt.Sprintf("AI code review: %s", category)},
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "codai",
+				Version:        version,
+				InformationURI: "https://github.com/meysamhadeli/codai",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+	return json.MarshalIndent(log, "", "  ")
+}
+
+func sarifRuleID(category string) string {
+	return "codai/" + strings.ReplaceAll(category, " ", "-")
+}
+
+// sarifLevel maps a severity to the SARIF levels
+func sarifLevel(severity models.Severity) string {
+	switch severity {
+	case models.SeverityError:
+		return "error"
+	case models.SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
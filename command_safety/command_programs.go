@@ -0,0 +1,874 @@
+package command_safety
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/meysamhadeli/codai/command_safety/models"
+)
+
+func set(values ...string) map[string]bool {
+	result := make(map[string]bool, len(values))
+	for _, value := range values {
+		result[value] = true
+	}
+	return result
+}
+
+// readOnlyPrograms only read files or print information, apart from their redirections
+var readOnlyPrograms = set(
+	"ls", "dir", "cat", "bat", "less", "more", "head", "tail", "grep", "egrep", "fgrep", "zgrep", "rg", "ag", "ack",
+	"wc", "echo", "printf", "pwd", "whoami", "id", "groups", "date", "cal", "uname", "which", "whereis", "type", "file",
+	"stat", "du", "df", "tree", "uniq", "cut", "tr", "diff", "cmp", "comm", "basename", "dirname", "realpath",
+	"readlink", "printenv", "ps", "pgrep", "top", "htop", "free", "uptime", "true", "false", "test", "[", "[[", "jq",
+	"yq", "awk", "gawk", "mawk", "column", "nl", "od", "xxd", "hexdump", "strings", "md5sum", "sha1sum", "sha224sum",
+	"sha256sum", "sha512sum", "shasum", "cksum", "b2sum", "base64", "seq", "yes", "tac", "rev", "fold", "fmt", "paste",
+	"join", "expr", "bc", "sleep", "man", "help", "whatis", "apropos", "tldr", "history", "alias", "export", "set",
+	"unset", "read", "local", "declare", "typeset", "shift", "exit", "return", "wait", "jobs", ":", "lsof", "netstat",
+	"ss", "nproc", "arch", "lscpu", "lsblk", "locale", "tput", "clear", "zcat", "xzcat", "bzcat", "journalctl",
+	"umask", "ulimit", "trap", "getconf", "env", "popd",
+)
+
+// networkPrograms access the network
+var networkPrograms = set(
+	"curl", "wget", "ssh", "scp", "sftp", "ftp", "telnet", "nc", "ncat", "netcat", "socat", "ping", "ping6",
+	"traceroute", "dig", "nslookup", "host", "whois", "http", "https", "xh", "aria2c", "kubectl", "helm", "terraform",
+	"tofu", "gh", "glab", "aws", "gcloud", "gsutil", "az", "heroku", "vercel", "netlify", "fly", "flyctl", "ansible",
+	"ansible-playbook", "npx", "pnpx", "bunx", "yt-dlp", "mosh", "rclone", "svn", "hg",
+)
+
+// privilegedPrograms change the system; readOnlySubcommands lists their invocations that only read,
+// by first argument, where an empty string stands for no argument
+var privilegedPrograms = set(
+	"apt", "apt-get", "aptitude", "dpkg", "rpm", "yum", "dnf", "apk", "pacman", "zypper", "snap", "flatpak", "emerge",
+	"port", "systemctl", "service", "mount", "umount", "modprobe", "insmod", "rmmod", "iptables", "ip6tables", "nft",
+	"ufw", "firewall-cmd", "useradd", "userdel", "usermod", "groupadd", "groupdel", "passwd", "chpasswd", "chroot",
+	"visudo", "sysctl", "launchctl", "hostname", "hostnamectl", "timedatectl", "swapon", "swapoff", "crontab",
+)
+
+var readOnlySubcommands = map[string]map[string]bool{
+	"apt":         set("list", "search", "show", "policy", "showpkg", "depends", "rdepends"),
+	"apt-get":     set("check", "changelog"),
+	"aptitude":    set("search", "show"),
+	"dpkg":        set("-l", "-L", "-s", "-S", "--list", "--listfiles", "--status", "--search"),
+	"rpm":         set("-q", "-qa", "-qi", "-ql", "-qf"),
+	"yum":         set("list", "search", "info", "repolist", "provides"),
+	"dnf":         set("list", "search", "info", "repolist", "provides"),
+	"apk":         set("info", "search", "list", "policy"),
+	"pacman":      set("-Q", "-Qi", "-Ql", "-Qs", "-Qe", "-Ss", "-Si"),
+	"snap":        set("list", "info", "find"),
+	"flatpak":     set("list", "info", "search"),
+	"systemctl":   set("", "status", "is-active", "is-enabled", "is-failed", "list-units", "list-unit-files", "list-timers", "show", "cat"),
+	"service":     set("--status-all"),
+	"mount":       set(""),
+	"iptables":    set("-L", "-S", "--list"),
+	"ufw":         set("status"),
+	"launchctl":   set("list", "print"),
+	"hostname":    set("", "-f", "-s", "-i", "-I", "-d", "-A", "--fqdn"),
+	"hostnamectl": set("", "status"),
+	"timedatectl": set("", "status"),
+	"crontab":     set("-l"),
+	"sysctl":      set("", "-a", "-n"),
+}
+
+// shellPrograms run a command line given with -c, a script file, or the script they read from their input
+var shellPrograms = set("sh", "bash", "zsh", "dash", "ksh", "ash", "mksh", "fish")
+
+// interpreterPrograms run inline code, a script file, or the script they read from their input
+var interpreterPrograms = set("python", "python2", "python3", "node", "nodejs", "perl", "ruby", "php", "lua", "rscript", "pwsh", "powershell", "deno")
+
+// Sub-commands of the development tools, by risk. Sub-commands not listed access the network.
+var (
+	goReadOnly      = set("version", "env", "list", "doc", "help", "vet")
+	goWritesProject = set("build", "test", "run", "generate", "fmt", "fix", "clean", "tool", "work")
+	goNetwork       = set("get", "install", "mod")
+
+	nodeReadOnly      = set("ls", "list", "help", "version", "--version", "-v", "why", "explain", "config")
+	nodeWritesProject = set("run", "run-script", "test", "t", "start", "build", "restart", "stop", "pack", "exec", "x")
+
+	pythonReadOnly      = set("list", "show", "info", "freeze", "check", "help", "--version", "-V", "-v", "config", "env")
+	pythonWritesProject = set("run", "exec", "build", "shell")
+
+	cargoReadOnly      = set("version", "--version", "-V", "help", "tree", "metadata", "locate-project", "pkgid", "verify-project")
+	cargoWritesProject = set("build", "b", "check", "c", "test", "t", "run", "r", "fmt", "clippy", "doc", "d", "clean", "bench", "fix", "new", "init", "package")
+
+	dockerReadOnly = set("ps", "images", "version", "info", "inspect", "logs", "stats", "top", "history", "events", "port", "diff", "--version", "help")
+	dockerNetwork  = set("pull", "push", "login", "logout", "search", "build")
+	brewReadOnly   = set("list", "ls", "info", "--version", "config", "doctor", "leaves", "deps", "uses")
+
+	gitReadOnly = set("status", "log", "diff", "show", "blame", "grep", "ls-files", "ls-tree", "rev-parse", "rev-list",
+		"describe", "shortlog", "reflog", "cat-file", "help", "version", "--version", "whatchanged", "show-ref",
+		"for-each-ref", "count-objects", "check-ignore", "name-rev", "merge-base", "var")
+	gitNetwork = set("clone", "fetch", "pull", "push", "ls-remote", "submodule")
+)
+
+var (
+	nodePackageManagers   = set("npm", "yarn", "pnpm", "bun")
+	pythonPackageManagers = set("pip", "pip3", "pipx", "poetry", "uv", "pdm", "conda", "mamba", "gem", "bundle", "bundler", "composer")
+	containerPrograms     = set("docker", "podman", "nerdctl")
+)
+
+// Options taking a value, for the programs whose operands are analyzed
+var (
+	sudoOptionsWithValue = set("-u", "-g", "-C", "-D", "-h", "-p", "-r", "-t", "-U", "-T", "--user", "--group", "--chdir", "--host", "--prompt")
+	envOptionsWithValue  = set("-u", "--unset", "-C", "--chdir", "-S", "--split-string")
+	wrapperOptions       = map[string]map[string]bool{
+		"nohup":   {},
+		"time":    {},
+		"builtin": {},
+		"exec":    set("-a"),
+		"nice":    set("-n", "--adjustment"),
+		"ionice":  set("-c", "-n", "-p", "--class", "--classdata"),
+		"timeout": set("-s", "-k", "--signal", "--kill-after"),
+		"stdbuf":  set("-i", "-o", "-e"),
+		"setsid":  {},
+		"command": {},
+		"xargs":   set("-I", "-n", "-P", "-L", "-l", "-d", "-E", "-e", "-s", "-a", "--arg-file", "--delimiter", "--max-args", "--max-procs", "--max-lines", "--replace"),
+		"watch":   set("-n", "--interval", "-d"),
+	}
+	gitOptionsWithValue      = set("-C", "-c", "--git-dir", "--work-tree", "--namespace", "--exec-path")
+	gitCloneOptionsWithValue = set("-b", "--branch", "-o", "--origin", "-c", "--config", "--depth", "--reference",
+		"--separate-git-dir", "-u", "--upload-pack", "-j", "--jobs", "--filter", "--shallow-since", "--shallow-exclude")
+	scpOptionsWithValue = set("-P", "-i", "-F", "-o", "-c", "-l", "-S", "-J")
+	writerOptions       = map[string]map[string]bool{
+		"touch":    set("-d", "-t", "-r", "--date", "--reference"),
+		"mkdir":    set("-m", "--mode"),
+		"mkfifo":   set("-m", "--mode"),
+		"truncate": set("-s", "-r", "--size", "--reference"),
+		"shred":    set("-n", "-s", "--iterations", "--size"),
+		"mv":       set("-t", "-S", "--target-directory", "--suffix"),
+		"cp":       set("-t", "-S", "--target-directory", "--suffix"),
+		"ln":       set("-t", "-S", "--target-directory", "--suffix"),
+		"install":  set("-m", "-o", "-g", "-t", "-S", "--mode", "--owner", "--group", "--target-directory", "--suffix"),
+		"rsync":    set("-e", "--rsh"),
+		"sed":      set("-e", "-f", "-l", "--expression", "--file"),
+		"gzip":     set("-S", "--suffix"),
+		"unzip":    set("-d", "-x"),
+	}
+)
+
+// classify analyzes a program with its arguments, once the wrappers around it are unwrapped
+func (safety *commandSafety) classify(a *analysis, name string, args []word, dir string) commandInfo {
+	info := commandInfo{dir: dir}
+
+	switch {
+	case name == "sudo" || name == "doas" || name == "pkexec" || name == "run0":
+		a.raise(models.RiskPrivileged, "%s runs the command with elevated privileges", name)
+		if rest := afterOptions(args, sudoOptionsWithValue); len(rest) > 0 {
+			inner := safety.analyzeSimpleCommand(a, rest, dir)
+			info.network, info.runsInput = inner.network, inner.runsInput
+		}
+		return info
+
+	case name == "su":
+		a.raise(models.RiskPrivileged, "su runs the command with elevated privileges")
+		for i, arg := range args {
+			if (arg.value == "-c" || arg.value == "--command") && i+1 < len(args) {
+				inner := safety.analyzeCommandString(a, name, args[i+1], dir)
+				info.network = inner.network
+			}
+		}
+		return info
+
+	case name == "env":
+		runDir := dir
+		for i, arg := range args {
+			if (arg.value == "-C" || arg.value == "--chdir") && i+1 < len(args) {
+				if path, ok := safety.resolvePath(args[i+1], dir); ok {
+					runDir = path
+				} else {
+					runDir = ""
+				}
+			}
+			if (arg.value == "-S" || arg.value == "--split-string") && i+1 < len(args) {
+				return safety.analyzeCommandString(a, name, args[i+1], runDir)
+			}
+		}
+		rest := afterOptions(args, envOptionsWithValue)
+		for len(rest) > 0 && isAssignment(rest[0]) {
+			rest = rest[1:]
+		}
+		if len(rest) > 0 {
+			inner := safety.analyzeSimpleCommand(a, rest, runDir)
+			info.network, info.runsInput = inner.network, inner.runsInput
+		}
+		return info
+
+	case name == "command" && hasAnyArg(args, "-v", "-V"):
+		return info
+
+	case wrapperOptions[name] != nil:
+		rest := afterOptions(args, wrapperOptions[name])
+		if name == "timeout" && len(rest) > 0 {
+			// The duration
+			rest = rest[1:]
+		}
+		if name == "watch" && len(rest) == 1 {
+			return safety.analyzeCommandString(a, name, rest[0], dir)
+		}
+		if name == "xargs" && len(rest) > 0 {
+			// The remaining arguments are read from the input, so they are only known when the command runs
+			rest = append(rest[:len(rest):len(rest)], word{value: "{}", expanded: true})
+		}
+		if len(rest) > 0 {
+			inner := safety.analyzeSimpleCommand(a, rest, dir)
+			info.network, info.runsInput = inner.network, inner.runsInput
+		}
+		return info
+
+	case name == "eval":
+		values := make([]string, len(args))
+		expanded := false
+		for i, arg := range args {
+			values[i], expanded = arg.value, expanded || arg.expanded
+		}
+		if expanded {
+			a.raise(models.RiskWritesOutsideProject, "eval runs a command that is only known when it runs")
+			return info
+		}
+		return safety.analyzeCommandString(a, name, word{value: strings.Join(values, " ")}, dir)
+
+	case shellPrograms[name]:
+		return safety.classifyShell(a, name, args, dir)
+
+	case interpreterPrograms[name]:
+		return safety.classifyInterpreter(a, name, args, dir)
+
+	case name == "source" || name == ".":
+		a.raise(models.RiskWritesProject, "%s runs a script in the current shell", name)
+		return info
+
+	case name == "cd" || name == "pushd":
+		info.dir = safety.changeDir(args, dir)
+		return info
+
+	case name == "git":
+		safety.classifyGit(a, args, dir, &info)
+		return info
+
+	case name == "find":
+		safety.classifyFind(a, args, dir)
+		return info
+
+	case readOnlyPrograms[name]:
+		return info
+
+	case name == "rsync" && (hasArgContaining(args, "::") || hasArgPrefix(args, "rsync://") || hasRemoteOperand(args)):
+		a.raise(models.RiskNetwork, "rsync copies files over the network")
+		for _, target := range downloadTargets(name, args) {
+			safety.writesPath(a, name, target, dir)
+		}
+		info.network = true
+		return info
+
+	case networkPrograms[name]:
+		a.raise(models.RiskNetwork, "%s accesses the network", name)
+		for _, target := range downloadTargets(name, args) {
+			safety.writesPath(a, name, target, dir)
+		}
+		info.network = true
+		return info
+
+	case privilegedPrograms[name]:
+		first := ""
+		if len(args) > 0 {
+			first = args[0].value
+		}
+		readOnly := readOnlySubcommands[name][first]
+		if name == "sysctl" && !hasAnyArg(args, "-w", "--write") && !hasArgContaining(args, "=") {
+			readOnly = true
+		}
+		if !readOnly {
+			a.raise(models.RiskPrivileged, "%s changes the system", name)
+		}
+		return info
+
+	case name == "kill" || name == "killall" || name == "pkill":
+		a.raise(models.RiskWritesOutsideProject, "%s stops processes", name)
+		return info
+	}
+
+	if targets, ok := safety.writeTargets(name, args); ok {
+		for _, target := range targets {
+			safety.writesPath(a, name, target, dir)
+		}
+		return info
+	}
+
+	subcommand := firstOperand(args, nil)
+	switch {
+	case name == "go":
+		safety.classifySubcommand(a, &info, name, subcommand, dir, goReadOnly, goWritesProject, goNetwork)
+	case nodePackageManagers[name]:
+		if name == "yarn" && subcommand == "" {
+			subcommand = "install"
+		}
+		safety.classifySubcommand(a, &info, name, subcommand, dir, nodeReadOnly, nodeWritesProject, nil)
+	case pythonPackageManagers[name]:
+		safety.classifySubcommand(a, &info, name, subcommand, dir, pythonReadOnly, pythonWritesProject, nil)
+	case name == "cargo":
+		safety.classifySubcommand(a, &info, name, subcommand, dir, cargoReadOnly, cargoWritesProject, nil)
+	case name == "brew":
+		safety.classifySubcommand(a, &info, name, subcommand, dir, brewReadOnly, nil, nil)
+	case containerPrograms[name]:
+		if subcommand == "compose" && len(args) > 1 {
+			subcommand = firstOperand(args[1:], nil)
+		}
+		switch {
+		case dockerReadOnly[subcommand] || subcommand == "config" || subcommand == "ls":
+		case dockerNetwork[subcommand] || subcommand == "up" || subcommand == "run" || subcommand == "create":
+			a.raise(models.RiskNetwork, "%s %s may download images from the network", name, subcommand)
+			a.raise(models.RiskPrivileged, "%s %s runs containers with the privileges of the container engine", name, subcommand)
+			info.network = true
+		default:
+			a.raise(models.RiskPrivileged, "%s %s controls containers with the privileges of the container engine", name, subcommand)
+		}
+	default:
+		a.raise(models.RiskWritesProject, "%s is not known to be read-only", name)
+	}
+
+	return info
+}
+
+// classifySubcommand classifies a development tool by its sub-command. Sub-commands in none of the sets access
+// the network when network is nil, and are not known to be read-only otherwise.
+func (safety *commandSafety) classifySubcommand(a *analysis, info *commandInfo, name, subcommand, dir string, readOnly, writesProject, network map[string]bool) {
+	switch {
+	case readOnly[subcommand]:
+	case writesProject[subcommand]:
+		safety.writesPath(a, name+" "+subcommand, word{value: "."}, dir)
+	case network == nil || network[subcommand]:
+		a.raise(models.RiskNetwork, "%s %s accesses the network", name, subcommand)
+		info.network = true
+	default:
+		a.raise(models.RiskWritesProject, "%s %s is not known to be read-only", name, subcommand)
+	}
+}
+
+func (safety *commandSafety) classifyShell(a *analysis, name string, args []word, dir string) commandInfo {
+	info := commandInfo{dir: dir}
+	for i := 0; i < len(args); i++ {
+		value := args[i].value
+		switch {
+		case value == "--":
+			i = len(args)
+		case strings.HasPrefix(value, "-") && !strings.HasPrefix(value, "--") && strings.Contains(value, "c"):
+			if i+1 < len(args) {
+				return safety.analyzeCommandString(a, name, args[i+1], dir)
+			}
+			return info
+		case value == "-o" || value == "+o":
+			i++
+		case strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+"):
+		default:
+			if safety.analyzeSubstitutions(a, args[i], dir) {
+				a.deny("%s runs a script downloaded from the network", name)
+			} else {
+				a.raise(models.RiskWritesProject, "%s runs the script %s", name, value)
+			}
+			return info
+		}
+	}
+
+	a.raise(models.RiskWritesProject, "%s runs the commands it reads from its input", name)
+	info.runsInput = true
+	return info
+}
+
+func (safety *commandSafety) classifyInterpreter(a *analysis, name string, args []word, dir string) commandInfo {
+	info := commandInfo{dir: dir}
+	if len(args) == 1 && hasAnyArg(args, "--version", "-V", "-v", "version") {
+		return info
+	}
+
+	for _, arg := range args {
+		switch value := arg.value; {
+		case value == "-c" || value == "-e" || value == "--eval" || value == "-r" || value == "-m" || strings.EqualFold(value, "-Command"):
+			a.raise(models.RiskWritesProject, "%s runs inline code", name)
+			return info
+		case value == "-":
+			a.raise(models.RiskWritesProject, "%s runs the code it reads from its input", name)
+			info.runsInput = true
+			return info
+		case strings.HasPrefix(value, "-"):
+		default:
+			if safety.analyzeSubstitutions(a, arg, dir) {
+				a.deny("%s runs a script downloaded from the network", name)
+			} else if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+				a.raise(models.RiskNetwork, "%s runs a script from %s", name, value)
+				info.network = true
+			} else {
+				a.raise(models.RiskWritesProject, "%s runs the script %s", name, value)
+			}
+			return info
+		}
+	}
+
+	a.raise(models.RiskWritesProject, "%s runs the code it reads from its input", name)
+	info.runsInput = true
+	return info
+}
+
+func (safety *commandSafety) classifyGit(a *analysis, args []word, dir string, info *commandInfo) {
+	workDir := dir
+	for i := 0; i < len(args); i++ {
+		if args[i].value == "-C" && i+1 < len(args) {
+			if path, ok := safety.resolvePath(args[i+1], workDir); ok {
+				workDir = path
+			} else {
+				workDir = ""
+			}
+		}
+	}
+
+	rest := afterOptions(args, gitOptionsWithValue)
+	if len(rest) == 0 {
+		return
+	}
+	subcommand := rest[0].value
+	operands := operandsOf(rest[1:], nil)
+
+	readOnly := gitReadOnly[subcommand]
+	switch subcommand {
+	case "branch", "tag":
+		readOnly = len(operands) == 0 && !hasAnyArg(rest, "-d", "-D", "-m", "-M", "-c", "-C", "--delete", "--move", "--copy", "-u", "--set-upstream-to", "--unset-upstream")
+	case "remote":
+		readOnly = len(operands) == 0 || operands[0].value == "show" || operands[0].value == "get-url"
+		if len(operands) > 0 && operands[0].value == "update" {
+			subcommand = "fetch"
+		}
+	case "stash":
+		readOnly = len(operands) > 0 && (operands[0].value == "list" || operands[0].value == "show")
+	case "config":
+		readOnly = hasAnyArg(rest, "--get", "--get-all", "--get-regexp", "--list", "-l")
+		if !readOnly && hasAnyArg(rest, "--global", "--system") {
+			a.raise(models.RiskWritesOutsideProject, "git config changes the configuration outside of the project")
+			return
+		}
+	}
+
+	switch {
+	case readOnly:
+	case gitNetwork[subcommand]:
+		a.raise(models.RiskNetwork, "git %s accesses the network", subcommand)
+		info.network = true
+		switch subcommand {
+		case "clone":
+			// The repository is cloned to the directory of the second operand, or to a new directory of the working
+			// directory
+			target := word{value: "."}
+			if operands := operandsOf(rest[1:], gitCloneOptionsWithValue); len(operands) > 1 {
+				target = operands[1]
+			}
+			safety.writesPath(a, "git clone", target, workDir)
+		case "pull", "submodule":
+			safety.writesPath(a, "git "+subcommand, word{value: "."}, workDir)
+		}
+	default:
+		safety.writesPath(a, "git "+subcommand, word{value: "."}, workDir)
+	}
+}
+
+func (safety *commandSafety) classifyFind(a *analysis, args []word, dir string) {
+	var paths []word
+	i := 0
+	for ; i < len(args); i++ {
+		value := args[i].value
+		if strings.HasPrefix(value, "-") || value == "(" || value == "!" {
+			break
+		}
+		paths = append(paths, args[i])
+	}
+	if len(paths) == 0 {
+		paths = []word{{value: "."}}
+	}
+
+	for ; i < len(args); i++ {
+		switch args[i].value {
+		case "-delete":
+			for _, path := range paths {
+				safety.writesPath(a, "find -delete", path, dir)
+			}
+		case "-fprint", "-fprint0", "-fprintf", "-fls":
+			if i+1 < len(args) {
+				safety.writesPath(a, "find "+args[i].value, args[i+1], dir)
+			}
+		case "-exec", "-execdir", "-ok", "-okdir":
+			var command []word
+			for i++; i < len(args) && args[i].value != ";" && args[i].value != "+"; i++ {
+				arg := args[i]
+				if arg.value == "{}" {
+					arg = paths[0]
+				}
+				command = append(command, arg)
+			}
+			safety.analyzeSimpleCommand(a, command, dir)
+		}
+	}
+}
+
+// writeTargets returns the files changed by the programs which write to their operands
+func (safety *commandSafety) writeTargets(name string, args []word) ([]word, bool) {
+	operands := operandsOf(args, writerOptions[name])
+	targetDirectory := optionValue(args, "-t", "--target-directory")
+
+	switch name {
+	case "rm", "rmdir", "unlink", "shred", "touch", "mkdir", "mkfifo", "truncate", "tee":
+		return operands, true
+	case "mv":
+		if targetDirectory != nil {
+			operands = append(operands, *targetDirectory)
+		}
+		return operands, true
+	case "cp", "ln", "install", "rsync":
+		if targetDirectory != nil {
+			return []word{*targetDirectory}, true
+		}
+		if name == "install" && hasAnyArg(args, "-d", "--directory") {
+			return operands, true
+		}
+		if len(operands) == 0 {
+			return nil, true
+		}
+		return operands[len(operands)-1:], true
+	case "chmod", "chown", "chgrp":
+		if optionValue(args, "--reference") != nil || hasArgPrefix(args, "--reference=") || len(operands) == 0 {
+			return operands, true
+		}
+		return operands[1:], true
+	case "sed":
+		if !hasArgPrefix(args, "-i") && !hasArgPrefix(args, "--in-place") && !hasClusteredFlag(args, 'i') {
+			return nil, true
+		}
+		if optionValue(args, "-e", "--expression", "-f", "--file") == nil && len(operands) > 0 {
+			operands = operands[1:]
+		}
+		return operands, true
+	case "sort":
+		if output := optionValue(args, "-o", "--output"); output != nil {
+			return []word{*output}, true
+		}
+		return nil, true
+	case "dd":
+		for _, arg := range args {
+			if strings.HasPrefix(arg.value, "of=") {
+				return []word{{value: strings.TrimPrefix(arg.value, "of="), expanded: arg.expanded}}, true
+			}
+		}
+		return nil, true
+	case "gzip", "gunzip", "xz", "unxz", "bzip2", "bunzip2", "zstd", "unzstd", "lz4":
+		if hasAnyArg(args, "-c", "--stdout", "-l", "--list", "-t", "--test") {
+			return nil, true
+		}
+		return operands, true
+	case "tar":
+		return tarTargets(args), true
+	case "unzip":
+		if hasAnyArg(args, "-l", "-t", "-v", "-Z") {
+			return nil, true
+		}
+		if destination := optionValue(args, "-d"); destination != nil {
+			return []word{*destination}, true
+		}
+		return []word{{value: "."}}, true
+	case "zip":
+		if len(operands) == 0 {
+			return nil, true
+		}
+		return operands[:1], true
+	case "patch", "make", "cmake", "ninja":
+		return []word{{value: "."}}, true
+	}
+	return nil, false
+}
+
+// tarTargets returns the archive written by 'tar -c' or the directory 'tar -x' extracts to
+// downloadOptions are the options naming the files or the directories the network programs write to
+var downloadOptions = map[string]map[string]bool{
+	"curl": set("-o", "--output", "--output-dir", "-D", "--dump-header", "-c", "--cookie-jar", "--trace", "--trace-ascii", "--stderr"),
+	"wget": set("-O", "--output-document", "-P", "--directory-prefix", "-o", "--output-file", "-a", "--append-output"),
+}
+
+// downloadTargets returns the paths a network program writes to, the working directory standing for the files it
+// names after their URL
+func downloadTargets(name string, args []word) []word {
+	switch name {
+	case "scp", "rsync":
+		withValue := scpOptionsWithValue
+		if name == "rsync" {
+			withValue = writerOptions["rsync"]
+		}
+		operands := operandsOf(args, withValue)
+		if len(operands) < 2 || hasRemoteOperand(operands[len(operands)-1:]) {
+			return nil
+		}
+		return operands[len(operands)-1:]
+	case "curl", "wget":
+	default:
+		return nil
+	}
+
+	options := downloadOptions[name]
+	var targets []word
+	found := make(map[string]bool)
+	add := func(option string, target word) {
+		found[option] = true
+		// '-' is the standard output
+		if target.value != "-" {
+			targets = append(targets, target)
+		}
+	}
+	for i := 0; i < len(args); i++ {
+		value := args[i].value
+		switch {
+		case value == "--":
+			i = len(args)
+		case strings.HasPrefix(value, "--"):
+			if option, inline, ok := strings.Cut(value, "="); ok && options[option] {
+				add(option, word{value: inline, expanded: args[i].expanded})
+			} else if options[value] && i+1 < len(args) {
+				add(value, args[i+1])
+				i++
+			}
+		case strings.HasPrefix(value, "-"):
+			// Short options may be clustered, the one taking a value ends the cluster: '-sSLo file' or '-ofile'
+			for j := 1; j < len(value); j++ {
+				option := "-" + value[j:j+1]
+				if !options[option] {
+					continue
+				}
+				if j+1 < len(value) {
+					add(option, word{value: value[j+1:], expanded: args[i].expanded})
+				} else if i+1 < len(args) {
+					add(option, args[i+1])
+					i++
+				}
+				break
+			}
+		}
+	}
+
+	switch {
+	case name == "curl" && (hasAnyArg(args, "--remote-name", "--remote-name-all") || hasClusteredFlag(args, 'O')) && !found["--output-dir"]:
+		targets = append(targets, word{value: "."})
+	case name == "wget" && !found["-O"] && !found["--output-document"] && !found["-P"] && !found["--directory-prefix"] && !hasAnyArg(args, "--spider"):
+		targets = append(targets, word{value: "."})
+	}
+	return targets
+}
+
+func tarTargets(args []word) []word {
+	var flags string
+	for i, arg := range args {
+		if strings.HasPrefix(arg.value, "-") && !strings.HasPrefix(arg.value, "--") {
+			flags += arg.value[1:]
+		} else if i == 0 && !strings.HasPrefix(arg.value, "-") {
+			flags += arg.value
+		}
+	}
+
+	switch {
+	case strings.ContainsAny(flags, "x") || hasAnyArg(args, "--extract", "--get"):
+		if directory := optionValue(args, "-C", "--directory"); directory != nil {
+			return []word{*directory}
+		}
+		return []word{{value: "."}}
+	case strings.ContainsAny(flags, "cru") || hasAnyArg(args, "--create", "--append", "--update"):
+		for i, arg := range args {
+			if (strings.HasSuffix(arg.value, "f") && !strings.HasPrefix(arg.value, "--") || arg.value == "--file") && i+1 < len(args) {
+				return []word{args[i+1]}
+			}
+		}
+		return []word{{value: "."}}
+	}
+	return nil
+}
+
+// builtinDenial returns why a command is never allowed, or an empty string
+func (safety *commandSafety) builtinDenial(name string, args []word, dir string) string {
+	switch {
+	case strings.HasPrefix(name, "mkfs"), name == "fdisk", name == "sfdisk", name == "cfdisk", name == "gdisk",
+		name == "parted", name == "wipefs", name == "mkswap":
+		return "it can erase disks"
+	case name == "shutdown", name == "reboot", name == "halt", name == "poweroff":
+		return "it stops the system"
+	case (name == "init" || name == "telinit") && hasAnyArg(args, "0", "6"):
+		return "it stops the system"
+	case name == "systemctl" && len(args) > 0 && set("poweroff", "reboot", "halt", "kexec")[args[0].value]:
+		return "it stops the system"
+	}
+
+	switch name {
+	case "rm":
+		if hasAnyArg(args, "--no-preserve-root") {
+			return "it disables the protection of the root directory"
+		}
+		if !hasAnyArg(args, "--recursive") && !hasClusteredFlag(args, 'r') && !hasClusteredFlag(args, 'R') {
+			return ""
+		}
+		for _, operand := range operandsOf(args, nil) {
+			if path, critical := safety.criticalTarget(operand, dir); critical {
+				return "it deletes " + path + " recursively"
+			}
+		}
+	case "chmod", "chown", "chgrp":
+		if !hasAnyArg(args, "-R", "--recursive") && !hasClusteredFlag(args, 'R') {
+			return ""
+		}
+		for _, operand := range operandsOf(args, nil) {
+			if path, critical := safety.criticalTarget(operand, dir); critical {
+				return "it changes the permissions of " + path + " recursively"
+			}
+		}
+	case "mv":
+		operands := operandsOf(args, nil)
+		for i, operand := range operands {
+			if i == len(operands)-1 && optionValue(args, "-t", "--target-directory") == nil {
+				break
+			}
+			if path, critical := safety.criticalTarget(operand, dir); critical {
+				return "it moves " + path
+			}
+		}
+	case "find":
+		if !hasAnyArg(args, "-delete") {
+			return ""
+		}
+		for _, arg := range args {
+			if strings.HasPrefix(arg.value, "-") || arg.value == "(" || arg.value == "!" {
+				break
+			}
+			if path, critical := safety.criticalTarget(arg, dir); critical {
+				return "it deletes everything under " + path
+			}
+		}
+	case "dd":
+		for _, arg := range args {
+			if path, ok := safety.resolvePath(word{value: strings.TrimPrefix(arg.value, "of="), expanded: arg.expanded}, dir); ok && strings.HasPrefix(arg.value, "of=") && isDevicePath(path) {
+				return "it writes to the device " + path
+			}
+		}
+	}
+	return ""
+}
+
+// criticalTarget reports whether an operand is, or matches every file of, a critical path
+func (safety *commandSafety) criticalTarget(operand word, dir string) (string, bool) {
+	path, ok := safety.resolvePath(operand, dir)
+	if !ok {
+		return "", false
+	}
+	if strings.ContainsAny(filepath.Base(path), "*?") {
+		path = filepath.Dir(path)
+	}
+	return path, safety.isCriticalPath(path)
+}
+
+// afterOptions returns the arguments from the first operand on, which is the command run by a wrapper like 'sudo'
+func afterOptions(args []word, withValue map[string]bool) []word {
+	for i := 0; i < len(args); i++ {
+		value := args[i].value
+		switch {
+		case value == "--":
+			return args[i+1:]
+		case withValue[value]:
+			i++
+		case strings.HasPrefix(value, "-") && len(value) > 1:
+		default:
+			return args[i:]
+		}
+	}
+	return nil
+}
+
+// operandsOf returns the arguments which are not options nor option values
+func operandsOf(args []word, withValue map[string]bool) []word {
+	var operands []word
+	for i := 0; i < len(args); i++ {
+		value := args[i].value
+		switch {
+		case value == "--":
+			return append(operands, args[i+1:]...)
+		case withValue[value]:
+			i++
+		case strings.HasPrefix(value, "-") && len(value) > 1:
+		default:
+			operands = append(operands, args[i])
+		}
+	}
+	return operands
+}
+
+func firstOperand(args []word, withValue map[string]bool) string {
+	if operands := operandsOf(args, withValue); len(operands) > 0 {
+		return operands[0].value
+	}
+	return ""
+}
+
+// optionValue returns the value of the first of the options given, as '-o value' or '--option=value'
+func optionValue(args []word, names ...string) *word {
+	for i, arg := range args {
+		for _, name := range names {
+			if arg.value == name && i+1 < len(args) {
+				return &args[i+1]
+			}
+			if strings.HasPrefix(name, "--") && strings.HasPrefix(arg.value, name+"=") {
+				return &word{value: strings.TrimPrefix(arg.value, name+"="), expanded: arg.expanded}
+			}
+		}
+	}
+	return nil
+}
+
+func hasAnyArg(args []word, values ...string) bool {
+	for _, arg := range args {
+		for _, value := range values {
+			if arg.value == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasArgPrefix(args []word, prefix string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg.value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasArgContaining(args []word, text string) bool {
+	for _, arg := range args {
+		if strings.Contains(arg.value, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasClusteredFlag reports whether a short flag is given, alone or clustered like '-rf'
+func hasClusteredFlag(args []word, flag rune) bool {
+	for _, arg := range args {
+		if arg.value == "--" {
+			return false
+		}
+		if strings.HasPrefix(arg.value, "-") && !strings.HasPrefix(arg.value, "--") && strings.ContainsRune(arg.value[1:], flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRemoteOperand reports whether an operand is a remote path like 'host:path'
+func hasRemoteOperand(args []word) bool {
+	for _, operand := range operandsOf(args, writerOptions["rsync"]) {
+		if colon := strings.Index(operand.value, ":"); colon > 0 && !strings.Contains(operand.value[:colon], "/") {
+			return true
+		}
+	}
+	return false
+}
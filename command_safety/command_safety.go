@@ -0,0 +1,430 @@
+package command_safety
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/meysamhadeli/codai/command_safety/contracts"
+	"github.com/meysamhadeli/codai/command_safety/models"
+)
+
+// commandSafety analyzes command lines suggested by the AI before they run in the project root
+type commandSafety struct {
+	root   string
+	home   string
+	policy *models.CommandPolicy
+}
+
+// NewCommandSafety creates the safety analyzer of the commands run in root, applying the rules of policy on top of
+// the built-in ones. Built-in denials, like deleting the home directory, can't be allowed by the policy.
+func NewCommandSafety(root string, policy *models.CommandPolicy) contracts.ICommandSafety {
+	if absRoot, err := filepath.Abs(root); err == nil {
+		root = absRoot
+	}
+	home, _ := os.UserHomeDir()
+	if policy == nil {
+		policy = &models.CommandPolicy{}
+	}
+	return &commandSafety{root: filepath.Clean(root), home: home, policy: policy}
+}
+
+// analysis collects the risk of every command of a command line
+type analysis struct {
+	result  models.CommandAssessment
+	reasons []riskReason
+	seen    map[string]bool
+
+	// functions are the shell functions being defined, to detect the ones calling themselves
+	functions []string
+}
+
+type riskReason struct {
+	risk   models.RiskLevel
+	denied bool
+	text   string
+}
+
+// commandInfo is what the analysis of one command tells the pipeline and the list around it
+type commandInfo struct {
+	// dir is the working directory after the command, changed by 'cd'; empty when it is unknown
+	dir string
+	// network reports that the command reads from the network
+	network bool
+	// runsInput reports that the command runs the script it reads from its input, like 'sh'
+	runsInput bool
+}
+
+// Assess parses a command line and classifies what it can affect
+func (safety *commandSafety) Assess(command string) models.CommandAssessment {
+	a := &analysis{result: models.CommandAssessment{Command: command, Risk: models.RiskReadOnly}, seen: map[string]bool{}}
+
+	list, err := parseCommandLine(command)
+	if err != nil {
+		a.deny("the command can't be analyzed: %v", err)
+	} else {
+		safety.analyzeList(a, list, safety.root)
+	}
+
+	// Denials first, then the highest risks
+	sort.SliceStable(a.reasons, func(i, j int) bool {
+		if a.reasons[i].denied != a.reasons[j].denied {
+			return a.reasons[i].denied
+		}
+		return a.reasons[i].risk > a.reasons[j].risk
+	})
+	for _, reason := range a.reasons {
+		a.result.Reasons = append(a.result.Reasons, reason.text)
+		if !reason.denied && !a.result.HasRisk(reason.risk) {
+			a.result.Risks = append(a.result.Risks, reason.risk)
+		}
+	}
+
+	return a.result
+}
+
+func (a *analysis) raise(risk models.RiskLevel, format string, args ...any) {
+	if risk > a.result.Risk {
+		a.result.Risk = risk
+	}
+	if risk == models.RiskReadOnly {
+		return
+	}
+	text := fmt.Sprintf(format, args...)
+	if !a.seen[text] {
+		a.seen[text] = true
+		a.reasons = append(a.reasons, riskReason{risk: risk, text: text})
+	}
+}
+
+func (a *analysis) deny(format string, args ...any) {
+	a.result.Denied = true
+	text := fmt.Sprintf(format, args...)
+	if !a.seen[text] {
+		a.seen[text] = true
+		a.reasons = append(a.reasons, riskReason{denied: true, text: text})
+	}
+}
+
+// analyzeList analyzes the pipelines of a list in order, following the changes of the working directory
+func (safety *commandSafety) analyzeList(a *analysis, list *commandList, dir string) commandInfo {
+	info := commandInfo{dir: dir}
+	for _, pipe := range list.pipelines {
+		pipeInfo := safety.analyzePipeline(a, pipe, info.dir)
+		info.dir = pipeInfo.dir
+		info.network = info.network || pipeInfo.network
+	}
+	return info
+}
+
+func (safety *commandSafety) analyzePipeline(a *analysis, pipe *pipeline, dir string) commandInfo {
+	info := commandInfo{dir: dir}
+	for i, command := range pipe.commands {
+		commandInfo := safety.analyzeCommand(a, command, dir)
+
+		if i > 0 && commandInfo.runsInput && info.network {
+			a.deny("it pipes content downloaded from the network into a program that runs it")
+		}
+		info.network = info.network || commandInfo.network
+
+		// Every command of a longer pipeline runs in a subshell, so only a single command can change the directory
+		if len(pipe.commands) == 1 {
+			info.dir = commandInfo.dir
+		}
+	}
+	return info
+}
+
+func (safety *commandSafety) analyzeCommand(a *analysis, command *shellCommand, dir string) commandInfo {
+	info := commandInfo{dir: dir}
+
+	for _, w := range command.words {
+		info.network = safety.analyzeSubstitutions(a, w, dir) || info.network
+	}
+
+	for _, redirect := range command.redirects {
+		info.network = safety.analyzeSubstitutions(a, redirect.target, dir) || info.network
+		safety.analyzeRedirect(a, redirect, dir)
+	}
+
+	switch {
+	case command.function != "":
+		a.functions = append(a.functions, command.function)
+		bodyInfo := safety.analyzeList(a, command.body, dir)
+		a.functions = a.functions[:len(a.functions)-1]
+		info.network = info.network || bodyInfo.network
+		return info
+	case command.body != nil:
+		bodyInfo := safety.analyzeList(a, command.body, dir)
+		if command.group {
+			info.dir = bodyInfo.dir
+		}
+		info.network = info.network || bodyInfo.network
+		return info
+	}
+
+	simpleInfo := safety.analyzeSimpleCommand(a, command.words, dir)
+	simpleInfo.network = simpleInfo.network || info.network
+	return simpleInfo
+}
+
+// analyzeSubstitutions analyzes the commands substituted in a word and reports whether they read from the network
+func (safety *commandSafety) analyzeSubstitutions(a *analysis, w word, dir string) bool {
+	network := false
+	for _, substitution := range w.substitutions {
+		network = safety.analyzeList(a, substitution, dir).network || network
+	}
+	return network
+}
+
+func (safety *commandSafety) analyzeRedirect(a *analysis, redirect redirect, dir string) {
+	op := strings.TrimLeft(redirect.op, "0123456789")
+	if !strings.ContainsAny(op, ">") || strings.HasPrefix(op, "<<") {
+		return
+	}
+
+	// '2>&1' duplicates a file descriptor and '>&-' closes one
+	if (op == ">&" || op == "<&") && (isDigits(redirect.target.value) || redirect.target.value == "-") {
+		return
+	}
+
+	if path, ok := safety.resolvePath(redirect.target, dir); ok && isDevicePath(path) {
+		if strings.HasPrefix(path, "/dev/tcp/") || strings.HasPrefix(path, "/dev/udp/") {
+			a.raise(models.RiskNetwork, "the redirection connects to %s", strings.TrimPrefix(path, "/dev/"))
+			return
+		}
+		a.deny("it writes to the device %s", path)
+		return
+	}
+	safety.writesPath(a, "the redirection", redirect.target, dir)
+}
+
+// analyzeSimpleCommand analyzes a command made of words, unwrapping the programs that run another command like 'sudo'
+func (safety *commandSafety) analyzeSimpleCommand(a *analysis, words []word, dir string) commandInfo {
+	info := commandInfo{dir: dir}
+
+	// Skip the variable assignments in front of the command
+	for len(words) > 0 && isAssignment(words[0]) {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return info
+	}
+
+	if words[0].expanded {
+		a.raise(models.RiskWritesOutsideProject, "the program %s is only known when the command runs", words[0].value)
+		return info
+	}
+
+	name := programName(words[0].value)
+	args := words[1:]
+
+	for _, function := range a.functions {
+		if function == name {
+			a.deny("the function %s calls itself, which can exhaust the system (fork bomb)", name)
+		}
+	}
+
+	if rule, ok := matchRule(safety.policy.Deny, name, args); ok {
+		reason := rule.Reason
+		if reason == "" {
+			reason = "a deny rule of the command policy matches it"
+		}
+		a.deny("%s: %s", name, reason)
+		return info
+	}
+
+	if reason := safety.builtinDenial(name, args, dir); reason != "" {
+		a.deny("%s: %s", name, reason)
+		return info
+	}
+
+	if _, ok := matchRule(safety.policy.Allow, name, args); ok {
+		if name == "cd" || name == "pushd" {
+			info.dir = safety.changeDir(args, dir)
+		}
+		return info
+	}
+
+	return safety.classify(a, name, args, dir)
+}
+
+// analyzeCommandString parses and analyzes a command line passed as an argument, like the one of 'sh -c'
+func (safety *commandSafety) analyzeCommandString(a *analysis, program string, w word, dir string) commandInfo {
+	info := commandInfo{dir: dir}
+	if safety.analyzeSubstitutions(a, w, dir) {
+		a.deny("%s runs a command downloaded from the network", program)
+		return info
+	}
+	if w.expanded {
+		a.raise(models.RiskWritesOutsideProject, "%s runs a command that is only known when it runs", program)
+		return info
+	}
+
+	list, err := parseCommandLine(w.value)
+	if err != nil {
+		a.deny("the command run by %s can't be analyzed: %v", program, err)
+		return info
+	}
+	inner := safety.analyzeList(a, list, dir)
+	info.network = inner.network
+	return info
+}
+
+// matchRule returns the first rule matching the program and its arguments
+func matchRule(rules []models.CommandRule, name string, args []word) (models.CommandRule, bool) {
+	values := make([]string, len(args))
+	for i, arg := range args {
+		values[i] = arg.value
+	}
+	joined := strings.Join(values, " ")
+
+	for _, rule := range rules {
+		if rule.Program == "" || !wildcardMatch(strings.ToLower(rule.Program), name) {
+			continue
+		}
+		if rule.Args == "" || wildcardMatch(rule.Args, joined) {
+			return rule, true
+		}
+	}
+	return models.CommandRule{}, false
+}
+
+// wildcardMatch matches text against a pattern where '*' matches any sequence of characters, spaces included
+func wildcardMatch(pattern string, text string) bool {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	matched, _ := regexp.MatchString("^"+strings.Join(parts, ".*")+"$", text)
+	return matched
+}
+
+// resolvePath returns the absolute path an argument refers to, or false when it is only known when the command runs
+func (safety *commandSafety) resolvePath(w word, dir string) (string, bool) {
+	value := w.value
+
+	for _, prefix := range []string{"${HOME}", "$HOME"} {
+		if w.expanded && strings.HasPrefix(value, prefix) && (len(value) == len(prefix) || value[len(prefix)] == '/') {
+			if safety.home == "" {
+				return "", false
+			}
+			value = safety.home + value[len(prefix):]
+			w.expanded = strings.ContainsAny(value, "$`")
+		}
+	}
+	if w.expanded {
+		return "", false
+	}
+
+	if value == "~" || strings.HasPrefix(value, "~/") {
+		if safety.home == "" {
+			return "", false
+		}
+		value = safety.home + value[1:]
+	} else if strings.HasPrefix(value, "~") {
+		return "", false
+	}
+
+	if !filepath.IsAbs(value) {
+		if dir == "" {
+			return "", false
+		}
+		value = filepath.Join(dir, value)
+	}
+	return filepath.Clean(value), true
+}
+
+// writesPath raises the risk of a program writing to the path of an argument
+func (safety *commandSafety) writesPath(a *analysis, program string, w word, dir string) {
+	path, ok := safety.resolvePath(w, dir)
+	if !ok {
+		a.raise(models.RiskWritesOutsideProject, "%s writes to %s, which is only known when the command runs", program, w.value)
+		return
+	}
+
+	switch path {
+	case "/dev/null", "/dev/stdout", "/dev/stderr", "/dev/tty":
+		return
+	}
+
+	if isInside(safety.root, path) {
+		a.raise(models.RiskWritesProject, "%s changes %s", program, safety.displayPath(path))
+		return
+	}
+	a.raise(models.RiskWritesOutsideProject, "%s changes %s, outside of the project", program, path)
+}
+
+// changeDir returns the working directory after 'cd', or an empty string when it is unknown
+func (safety *commandSafety) changeDir(args []word, dir string) string {
+	operands := operandsOf(args, nil)
+	if len(operands) == 0 {
+		return safety.home
+	}
+	if operands[0].value == "-" {
+		return ""
+	}
+	path, ok := safety.resolvePath(operands[0], dir)
+	if !ok {
+		return ""
+	}
+	return path
+}
+
+// isCriticalPath reports whether deleting path recursively would destroy the system, the home directory or the project
+func (safety *commandSafety) isCriticalPath(path string) bool {
+	if path == filepath.Dir(path) || filepath.Dir(path) == filepath.Dir(filepath.Dir(path)) {
+		// The root of the file system or one of its top level directories, like /etc
+		return true
+	}
+	if isInside(path, safety.root) {
+		return true
+	}
+	return safety.home != "" && isInside(path, safety.home)
+}
+
+func (safety *commandSafety) displayPath(path string) string {
+	if rel, err := filepath.Rel(safety.root, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// isInside reports whether path is root or inside of it
+func isInside(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func isDevicePath(path string) bool {
+	if !strings.HasPrefix(path, "/dev/") {
+		return false
+	}
+	switch path {
+	case "/dev/null", "/dev/stdout", "/dev/stderr", "/dev/tty", "/dev/zero":
+		return false
+	}
+	return !strings.HasPrefix(path, "/dev/fd/")
+}
+
+func isAssignment(w word) bool {
+	name, _, found := strings.Cut(w.value, "=")
+	if !found || name == "" {
+		return false
+	}
+	for i, c := range name {
+		if !isNameRune(c) || (i == 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// programName returns the name of a program without its directory and extension, like 'rm' for '/bin/rm'
+func programName(program string) string {
+	name := strings.ToLower(filepath.Base(strings.ReplaceAll(program, "\\", "/")))
+	return strings.TrimSuffix(name, ".exe")
+}
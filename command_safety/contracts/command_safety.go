@@ -0,0 +1,7 @@
+package contracts
+
+import "github.com/meysamhadeli/codai/command_safety/models"
+
+type ICommandSafety interface {
+	Assess(command string) models.CommandAssessment
+}
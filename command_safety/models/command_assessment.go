@@ -0,0 +1,28 @@
+package models
+
+// CommandAssessment is the result of the safety analysis of a command line
+type CommandAssessment struct {
+	Command string
+	// Risk is the highest risk level of every command of the command line
+	Risk RiskLevel
+	// Risks are the risk levels found in the command line, the highest first, without RiskReadOnly. The levels are
+	// not all ordered by danger, e.g. a command accessing the network may also write outside of the project.
+	Risks []RiskLevel
+	// Denied reports that a rule forbids the command line, it must not be run
+	Denied bool
+	// Reasons explain the risk level, or why the command line is denied
+	Reasons []string
+}
+
+// HasRisk reports whether a command of the command line has the risk level
+func (assessment CommandAssessment) HasRisk(risk RiskLevel) bool {
+	if risk == RiskReadOnly {
+		return assessment.Risk == RiskReadOnly
+	}
+	for _, found := range assessment.Risks {
+		if found == risk {
+			return true
+		}
+	}
+	return false
+}
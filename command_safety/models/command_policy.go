@@ -0,0 +1,18 @@
+package models
+
+// CommandRule matches the commands of a program, optionally only with some arguments
+type CommandRule struct {
+	// Program is the name of the program, without its directory; '*' matches any sequence of characters
+	Program string `mapstructure:"program"`
+	// Args is matched against the arguments joined by spaces; '*' matches any sequence of characters and an empty pattern matches any arguments
+	Args string `mapstructure:"args"`
+	// Reason is shown to the user when the rule denies a command
+	Reason string `mapstructure:"reason"`
+}
+
+// CommandPolicy holds the configured rules for the commands suggested by the AI.
+// Denied commands are never run, allowed commands are treated as read-only. Deny rules take precedence.
+type CommandPolicy struct {
+	Allow []CommandRule `mapstructure:"allow"`
+	Deny  []CommandRule `mapstructure:"deny"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// RiskLevel classifies what a command can affect, from the least to the most dangerous
+type RiskLevel int
+
+const (
+	// RiskReadOnly commands only read files or print information
+	RiskReadOnly RiskLevel = iota
+	// RiskWritesProject commands may change files inside the project
+	RiskWritesProject
+	// RiskWritesOutsideProject commands may change files outside the project
+	RiskWritesOutsideProject
+	// RiskNetwork commands access the network, e.g. to download or upload data
+	RiskNetwork
+	// RiskPrivileged commands run with elevated privileges or change the system
+	RiskPrivileged
+)
+
+func (risk RiskLevel) String() string {
+	switch risk {
+	case RiskReadOnly:
+		return "read-only"
+	case RiskWritesProject:
+		return "writes project"
+	case RiskWritesOutsideProject:
+		return "writes outside project"
+	case RiskNetwork:
+		return "network"
+	case RiskPrivileged:
+		return "privileged"
+	default:
+		return "unknown"
+	}
+}
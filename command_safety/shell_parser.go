@@ -0,0 +1,638 @@
+package command_safety
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commandList is a sequence of pipelines, separated by ';', '&', '&&', '||' or newlines
+type commandList struct {
+	pipelines []*pipeline
+}
+
+// pipeline is a sequence of commands connected by '|'
+type pipeline struct {
+	commands []*shellCommand
+}
+
+// shellCommand is a simple command, a subshell '( ... )', a group '{ ...; }' or a function definition
+type shellCommand struct {
+	words     []word
+	redirects []redirect
+
+	// body holds the commands of a subshell or a group; a group runs in the current shell
+	body  *commandList
+	group bool
+
+	// function is the name of a defined function, whose body is in body
+	function string
+}
+
+// word is a shell word with its quotes removed
+type word struct {
+	value string
+
+	// expanded reports that the word contains a parameter expansion or a command substitution,
+	// so its value is only known when the command runs
+	expanded bool
+
+	// substitutions are the commands run by '$(...)', '`...`', '<(...)' or '>(...)' inside the word
+	substitutions []*commandList
+}
+
+// redirect is a redirection like '> file' or '2>&1'
+type redirect struct {
+	op     string
+	target word
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenOperator
+	tokenRedirect
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	word word
+}
+
+// reservedWords start a compound command; they are skipped, so the commands inside are analyzed
+var reservedWords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"while": true, "until": true, "do": true, "done": true, "!": true,
+}
+
+// headerWords start a compound command whose header, up to the next separator, runs no command
+var headerWords = map[string]bool{"for": true, "select": true}
+
+// parseCommandLine parses a command line with the syntax of a POSIX shell
+func parseCommandLine(commandLine string) (*commandList, error) {
+	tokens, err := tokenize(commandLine)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &shellParser{tokens: tokens}
+	list, err := parser.parseList("")
+	if err != nil {
+		return nil, err
+	}
+	if next := parser.peek(); next.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected '%s'", next.text)
+	}
+	return list, nil
+}
+
+type shellParser struct {
+	tokens []token
+	pos    int
+}
+
+func (parser *shellParser) peek() token {
+	if parser.pos >= len(parser.tokens) {
+		return token{kind: tokenEOF}
+	}
+	return parser.tokens[parser.pos]
+}
+
+func (parser *shellParser) next() token {
+	current := parser.peek()
+	if parser.pos < len(parser.tokens) {
+		parser.pos++
+	}
+	return current
+}
+
+func isSeparator(current token) bool {
+	if current.kind != tokenOperator {
+		return false
+	}
+	switch current.text {
+	case ";", "&", "&&", "||", "\n":
+		return true
+	}
+	return false
+}
+
+// parseList parses pipelines until the end of the input, or until the closing ')' or '}' of a subshell or group
+func (parser *shellParser) parseList(closing string) (*commandList, error) {
+	list := &commandList{}
+	for {
+		current := parser.peek()
+		switch {
+		case current.kind == tokenEOF:
+			return list, nil
+		case isSeparator(current):
+			parser.next()
+			continue
+		case closing == ")" && current.kind == tokenOperator && current.text == ")":
+			return list, nil
+		case closing == "}" && current.kind == tokenWord && current.text == "}":
+			return list, nil
+		case current.kind == tokenOperator && current.text == ")":
+			return nil, fmt.Errorf("unexpected ')'")
+		}
+
+		pipe, err := parser.parsePipeline()
+		if err != nil {
+			return nil, err
+		}
+		list.pipelines = append(list.pipelines, pipe)
+	}
+}
+
+func (parser *shellParser) parsePipeline() (*pipeline, error) {
+	pipe := &pipeline{}
+	for {
+		command, err := parser.parseCommand()
+		if err != nil {
+			return nil, err
+		}
+		pipe.commands = append(pipe.commands, command)
+
+		if current := parser.peek(); current.kind != tokenOperator || current.text != "|" {
+			return pipe, nil
+		}
+		parser.next()
+	}
+}
+
+func (parser *shellParser) parseCommand() (*shellCommand, error) {
+	command := &shellCommand{}
+
+	// Subshell
+	if current := parser.peek(); current.kind == tokenOperator && current.text == "(" {
+		parser.next()
+		body, err := parser.parseList(")")
+		if err != nil {
+			return nil, err
+		}
+		if closing := parser.next(); closing.kind != tokenOperator || closing.text != ")" {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		command.body = body
+		return command, parser.parseRedirects(command)
+	}
+
+	for {
+		current := parser.peek()
+		switch current.kind {
+		case tokenEOF:
+			return command, nil
+		case tokenRedirect:
+			if err := parser.parseRedirect(command); err != nil {
+				return nil, err
+			}
+		case tokenOperator:
+			if current.text == "(" && len(command.words) == 1 && len(command.redirects) == 0 {
+				return parser.parseFunction(command.words[0].value)
+			}
+			if current.text == "(" {
+				return nil, fmt.Errorf("unexpected '('")
+			}
+			return command, nil
+		case tokenWord:
+			if len(command.words) == 0 && len(command.redirects) == 0 {
+				switch {
+				case current.text == "{":
+					parser.next()
+					return parser.parseGroup(command)
+				case current.text == "case":
+					return nil, fmt.Errorf("case statements are not supported")
+				case reservedWords[current.text]:
+					parser.next()
+					continue
+				case headerWords[current.text]:
+					for next := parser.peek(); next.kind != tokenEOF && !isSeparator(next); next = parser.peek() {
+						parser.next()
+					}
+					return command, nil
+				}
+			}
+			parser.next()
+			command.words = append(command.words, current.word)
+		}
+	}
+}
+
+// parseGroup parses the commands of '{ ...; }' after the opening brace
+func (parser *shellParser) parseGroup(command *shellCommand) (*shellCommand, error) {
+	body, err := parser.parseList("}")
+	if err != nil {
+		return nil, err
+	}
+	if closing := parser.next(); closing.kind != tokenWord || closing.text != "}" {
+		return nil, fmt.Errorf("missing '}'")
+	}
+	command.body, command.group = body, true
+	return command, parser.parseRedirects(command)
+}
+
+// parseFunction parses the definition 'name() body' after its name
+func (parser *shellParser) parseFunction(name string) (*shellCommand, error) {
+	parser.next()
+	if closing := parser.next(); closing.kind != tokenOperator || closing.text != ")" {
+		return nil, fmt.Errorf("missing ')' in the definition of %s", name)
+	}
+	for isSeparator(parser.peek()) && parser.peek().text == "\n" {
+		parser.next()
+	}
+
+	body, err := parser.parseCommand()
+	if err != nil {
+		return nil, err
+	}
+	return &shellCommand{function: name, body: &commandList{pipelines: []*pipeline{{commands: []*shellCommand{body}}}}}, nil
+}
+
+func (parser *shellParser) parseRedirects(command *shellCommand) error {
+	for parser.peek().kind == tokenRedirect {
+		if err := parser.parseRedirect(command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (parser *shellParser) parseRedirect(command *shellCommand) error {
+	op := parser.next()
+	target := parser.next()
+	if target.kind != tokenWord {
+		return fmt.Errorf("missing target of the redirection '%s'", op.text)
+	}
+	command.redirects = append(command.redirects, redirect{op: op.text, target: target.word})
+	return nil
+}
+
+// shellLexer splits a command line into words, operators and redirections
+type shellLexer struct {
+	input []rune
+	pos   int
+
+	// heredocs are the delimiters of the here-documents whose content starts after the next newline
+	heredocs        []heredoc
+	expectHeredocOf string
+}
+
+type heredoc struct {
+	delimiter string
+	stripTabs bool
+}
+
+func tokenize(commandLine string) ([]token, error) {
+	lexer := &shellLexer{input: []rune(commandLine)}
+
+	var tokens []token
+	for {
+		current, err := lexer.nextToken()
+		if err != nil {
+			return nil, err
+		}
+		if current.kind == tokenEOF {
+			return tokens, nil
+		}
+		tokens = append(tokens, current)
+	}
+}
+
+func (lexer *shellLexer) peekAt(offset int) rune {
+	if lexer.pos+offset >= len(lexer.input) {
+		return 0
+	}
+	return lexer.input[lexer.pos+offset]
+}
+
+func (lexer *shellLexer) hasPrefix(prefix string) bool {
+	for i, r := range []rune(prefix) {
+		if lexer.peekAt(i) != r {
+			return false
+		}
+	}
+	return true
+}
+
+func (lexer *shellLexer) nextToken() (token, error) {
+	// Skip blanks and line continuations
+	for lexer.pos < len(lexer.input) {
+		if c := lexer.input[lexer.pos]; c == ' ' || c == '\t' || c == '\r' {
+			lexer.pos++
+		} else if lexer.hasPrefix("\\\n") {
+			lexer.pos += 2
+		} else {
+			break
+		}
+	}
+
+	if lexer.pos >= len(lexer.input) {
+		return token{kind: tokenEOF}, nil
+	}
+
+	c := lexer.input[lexer.pos]
+	switch {
+	case c == '#':
+		for lexer.pos < len(lexer.input) && lexer.input[lexer.pos] != '\n' {
+			lexer.pos++
+		}
+		return lexer.nextToken()
+	case c == '\n':
+		lexer.pos++
+		if err := lexer.skipHeredocs(); err != nil {
+			return token{}, err
+		}
+		return token{kind: tokenOperator, text: "\n"}, nil
+	case (c == '<' || c == '>') && lexer.peekAt(1) == '(':
+		return lexer.readWord()
+	case c == '<' || c == '>' || (c == '&' && lexer.peekAt(1) == '>'):
+		return lexer.readRedirect(""), nil
+	}
+
+	for _, operator := range []string{"&&", "||", "|&", ";;", "|", "&", ";", "(", ")"} {
+		if lexer.hasPrefix(operator) {
+			lexer.pos += len(operator)
+			switch operator {
+			case "|&":
+				operator = "|"
+			case ";;":
+				operator = ";"
+			}
+			return token{kind: tokenOperator, text: operator}, nil
+		}
+	}
+
+	return lexer.readWord()
+}
+
+// readRedirect reads a redirection operator, prefixed by the file descriptor fd when there is one
+func (lexer *shellLexer) readRedirect(fd string) token {
+	for _, operator := range []string{"&>>", "&>", "<<<", "<<-", "<<", "<&", "<>", "<", ">>", ">&", ">|", ">"} {
+		if lexer.hasPrefix(operator) {
+			lexer.pos += len(operator)
+			if operator == "<<" || operator == "<<-" {
+				lexer.expectHeredocOf = operator
+			}
+			return token{kind: tokenRedirect, text: fd + operator}
+		}
+	}
+	return token{kind: tokenRedirect, text: fd}
+}
+
+// skipHeredocs skips the content of the here-documents started on the line that just ended
+func (lexer *shellLexer) skipHeredocs() error {
+	for _, document := range lexer.heredocs {
+		for {
+			if lexer.pos >= len(lexer.input) {
+				return fmt.Errorf("missing end of the here-document '%s'", document.delimiter)
+			}
+			end := lexer.pos
+			for end < len(lexer.input) && lexer.input[end] != '\n' {
+				end++
+			}
+			line := string(lexer.input[lexer.pos:end])
+			lexer.pos = end
+			if lexer.pos < len(lexer.input) {
+				lexer.pos++
+			}
+			if document.stripTabs {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == document.delimiter {
+				break
+			}
+		}
+	}
+	lexer.heredocs = nil
+	return nil
+}
+
+func isWordBreak(c rune) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', ';', '&', '|', '(', ')', '<', '>':
+		return true
+	}
+	return false
+}
+
+func (lexer *shellLexer) readWord() (token, error) {
+	var value strings.Builder
+	result := word{}
+
+	for lexer.pos < len(lexer.input) {
+		c := lexer.input[lexer.pos]
+
+		switch {
+		case (c == '<' || c == '>') && lexer.peekAt(1) == '(':
+			// Process substitution
+			lexer.pos++
+			inner, err := lexer.readBalanced('(', ')')
+			if err != nil {
+				return token{}, err
+			}
+			if err := result.addSubstitution(inner); err != nil {
+				return token{}, err
+			}
+			value.WriteString(string(c) + "(" + inner + ")")
+			continue
+		case isWordBreak(c):
+			// A word of digits right before a redirection is its file descriptor
+			if (c == '<' || c == '>') && value.Len() > 0 && isDigits(value.String()) && !result.expanded {
+				return lexer.readRedirect(value.String()), nil
+			}
+			return lexer.finishWord(value.String(), result), nil
+		case c == '\\':
+			lexer.pos++
+			if lexer.pos < len(lexer.input) {
+				if lexer.input[lexer.pos] != '\n' {
+					value.WriteRune(lexer.input[lexer.pos])
+				}
+				lexer.pos++
+			}
+		case c == '\'':
+			lexer.pos++
+			start := lexer.pos
+			for lexer.pos < len(lexer.input) && lexer.input[lexer.pos] != '\'' {
+				lexer.pos++
+			}
+			if lexer.pos >= len(lexer.input) {
+				return token{}, fmt.Errorf("unterminated single quote")
+			}
+			value.WriteString(string(lexer.input[start:lexer.pos]))
+			lexer.pos++
+		case c == '"':
+			lexer.pos++
+			if err := lexer.readDoubleQuoted(&value, &result); err != nil {
+				return token{}, err
+			}
+		case c == '$' || c == '`':
+			if err := lexer.readExpansion(&value, &result); err != nil {
+				return token{}, err
+			}
+		default:
+			value.WriteRune(c)
+			lexer.pos++
+		}
+	}
+
+	return lexer.finishWord(value.String(), result), nil
+}
+
+func (lexer *shellLexer) finishWord(value string, result word) token {
+	result.value = value
+	if lexer.expectHeredocOf != "" {
+		lexer.heredocs = append(lexer.heredocs, heredoc{delimiter: value, stripTabs: lexer.expectHeredocOf == "<<-"})
+		lexer.expectHeredocOf = ""
+	}
+	return token{kind: tokenWord, text: value, word: result}
+}
+
+func (lexer *shellLexer) readDoubleQuoted(value *strings.Builder, result *word) error {
+	for lexer.pos < len(lexer.input) {
+		c := lexer.input[lexer.pos]
+		switch {
+		case c == '"':
+			lexer.pos++
+			return nil
+		case c == '\\' && strings.ContainsRune("$`\"\\\n", lexer.peekAt(1)):
+			if lexer.peekAt(1) != '\n' {
+				value.WriteRune(lexer.peekAt(1))
+			}
+			lexer.pos += 2
+		case c == '$' || c == '`':
+			if err := lexer.readExpansion(value, result); err != nil {
+				return err
+			}
+		default:
+			value.WriteRune(c)
+			lexer.pos++
+		}
+	}
+	return fmt.Errorf("unterminated double quote")
+}
+
+// readExpansion reads a parameter expansion, an arithmetic expansion or a command substitution.
+// The raw text is kept in the value, so '$HOME' can still be recognized in paths.
+func (lexer *shellLexer) readExpansion(value *strings.Builder, result *word) error {
+	start := lexer.pos
+
+	switch {
+	case lexer.hasPrefix("`"):
+		lexer.pos++
+		begin := lexer.pos
+		for lexer.pos < len(lexer.input) && lexer.input[lexer.pos] != '`' {
+			if lexer.input[lexer.pos] == '\\' {
+				lexer.pos++
+			}
+			lexer.pos++
+		}
+		if lexer.pos >= len(lexer.input) {
+			return fmt.Errorf("unterminated backquote")
+		}
+		inner := string(lexer.input[begin:lexer.pos])
+		lexer.pos++
+		if err := result.addSubstitution(inner); err != nil {
+			return err
+		}
+	case lexer.hasPrefix("$(("):
+		lexer.pos += 2
+		if _, err := lexer.readBalanced('(', ')'); err != nil {
+			return err
+		}
+		if lexer.peekAt(0) == ')' {
+			lexer.pos++
+		}
+	case lexer.hasPrefix("$("):
+		lexer.pos++
+		inner, err := lexer.readBalanced('(', ')')
+		if err != nil {
+			return err
+		}
+		if err := result.addSubstitution(inner); err != nil {
+			return err
+		}
+	case lexer.hasPrefix("${"):
+		lexer.pos++
+		if _, err := lexer.readBalanced('{', '}'); err != nil {
+			return err
+		}
+	default:
+		lexer.pos++
+		if next := lexer.peekAt(0); strings.ContainsRune("@*#?$!-0123456789", next) && next != 0 {
+			lexer.pos++
+		} else {
+			for lexer.pos < len(lexer.input) && isNameRune(lexer.input[lexer.pos]) {
+				lexer.pos++
+			}
+		}
+		if lexer.pos == start+1 {
+			// A lone '$' is literal
+			value.WriteRune('$')
+			return nil
+		}
+	}
+
+	result.expanded = true
+	value.WriteString(string(lexer.input[start:lexer.pos]))
+	return nil
+}
+
+// readBalanced reads from an opening bracket to its matching closing bracket, skipping quoted text,
+// and returns the text between them
+func (lexer *shellLexer) readBalanced(opening, closing rune) (string, error) {
+	lexer.pos++
+	begin := lexer.pos
+	depth := 1
+	for lexer.pos < len(lexer.input) {
+		c := lexer.input[lexer.pos]
+		switch c {
+		case '\\':
+			lexer.pos++
+		case '\'':
+			for lexer.pos++; lexer.pos < len(lexer.input) && lexer.input[lexer.pos] != '\''; lexer.pos++ {
+			}
+		case '"':
+			for lexer.pos++; lexer.pos < len(lexer.input) && lexer.input[lexer.pos] != '"'; lexer.pos++ {
+				if lexer.input[lexer.pos] == '\\' {
+					lexer.pos++
+				}
+			}
+		case opening:
+			depth++
+		case closing:
+			depth--
+			if depth == 0 {
+				inner := string(lexer.input[begin:lexer.pos])
+				lexer.pos++
+				return inner, nil
+			}
+		}
+		lexer.pos++
+	}
+	return "", fmt.Errorf("missing '%c'", closing)
+}
+
+func (result *word) addSubstitution(commandLine string) error {
+	list, err := parseCommandLine(commandLine)
+	if err != nil {
+		return err
+	}
+	result.expanded = true
+	result.substitutions = append(result.substitutions, list)
+	return nil
+}
+
+func isNameRune(c rune) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isDigits(text string) bool {
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return text != ""
+}
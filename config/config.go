@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	safety_models "github.com/meysamhadeli/codai/command_safety/models"
 	"github.com/meysamhadeli/codai/constants/lipgloss"
+	mcp_models "github.com/meysamhadeli/codai/mcp/models"
 	"github.com/meysamhadeli/codai/providers"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -26,19 +28,42 @@ var (
 
 // Config represents the structure of the configuration file
 type Config struct {
-	Version          string                      `mapstructure:"version"`
-	Theme            string                      `mapstructure:"theme"`
-	FileDisplayMode  string                      `mapstructure:"file_display_mode"`
-	EnableCache      bool                        `mapstructure:"enable_cache"`
-	AIProviderConfig *providers.AIProviderConfig `mapstructure:"ai_provider_config"`
+	Version             string                             `mapstructure:"version"`
+	Theme               string                             `mapstructure:"theme"`
+	FileDisplayMode     string                             `mapstructure:"file_display_mode"`
+	EnableCache         bool                               `mapstructure:"enable_cache"`
+	EditFormat          string                             `mapstructure:"edit_format"`
+	Output              string                             `mapstructure:"output"`
+	ProtectedPaths      []string                           `mapstructure:"protected_paths"`
+	VerifyCommand       string                             `mapstructure:"verify_command"`
+	VerifyMaxIterations int                                `mapstructure:"verify_max_iterations"`
+	GitCheckpoints      bool                               `mapstructure:"git_checkpoints"`
+	CommandPolicy       *safety_models.CommandPolicy       `mapstructure:"command_policy"`
+	Sandbox             bool                               `mapstructure:"sandbox"`
+	SandboxTimeout      time.Duration                      `mapstructure:"sandbox_timeout"`
+	SandboxMaxOutput    int                                `mapstructure:"sandbox_max_output"`
+	SandboxAllowEnv     []string                           `mapstructure:"sandbox_allow_env"`
+	SandboxDenyNetwork  bool                               `mapstructure:"sandbox_deny_network"`
+	ToolCalling         bool                               `mapstructure:"tool_calling"`
+	MCPServers          map[string]mcp_models.ServerConfig `mapstructure:"mcp_servers"`
+	CompactionThreshold float64                            `mapstructure:"compaction_threshold"`
+	CompactionModel     string                             `mapstructure:"compaction_model"`
+	AIProviderConfig    *providers.AIProviderConfig        `mapstructure:"ai_provider_config"`
 }
 
 // DefaultConfig values
 var DefaultConfig = Config{
-	Version:         "1.8.4",
-	Theme:           "dracula",
-	FileDisplayMode: "info",
-	EnableCache:     true, // 默认启用缓存
+	Version:             "1.8.4",
+	Theme:               "dracula",
+	FileDisplayMode:     "info",
+	EnableCache:         true, // 默认启用缓存
+	EditFormat:          "diff",
+	Output:              "text",
+	VerifyMaxIterations: 3,               // 修复验证失败的最大次数
+	SandboxTimeout:      5 * time.Minute, // 沙箱中命令的最长运行时间
+	SandboxMaxOutput:    1 << 20,         // 沙箱中命令的最大输出字节数
+	ToolCalling:         true,            // 让AI调用工具读取项目文件
+	CompactionThreshold: 0.8,             // 请求接近模型输入上限的80%时压缩历史
 	AIProviderConfig: &providers.AIProviderConfig{
 		Provider:        "openai",
 		BaseURL:         "https://api.openai.com/v1",
@@ -84,7 +109,7 @@ func LoadConfigs(rootCmd *cobra.Command, cwd string) *Config {
 			viper.SetConfigType("json")
 			if err := viper.ReadInConfig(); err != nil {
 				// If both fail, we'll continue with defaults
-				fmt.Println(lipgloss.Yellow.Render("No configuration file found, using defaults"))
+				fmt.Fprintln(os.Stderr, lipgloss.Yellow.Render("No configuration file found, using defaults"))
 			}
 		}
 	}
@@ -92,7 +117,7 @@ func LoadConfigs(rootCmd *cobra.Command, cwd string) *Config {
 	// Read the explicitly specified config file (if any)
 	if cfgFile != "" {
 		if err := viper.ReadInConfig(); err != nil {
-			fmt.Println(lipgloss.Red.Render(fmt.Sprintf("Error reading config file: %v", err)))
+			fmt.Fprintln(os.Stderr, lipgloss.Red.Render(fmt.Sprintf("Error reading config file: %v", err)))
 			os.Exit(1)
 		}
 	}
@@ -102,7 +127,7 @@ func LoadConfigs(rootCmd *cobra.Command, cwd string) *Config {
 
 	// Unmarshal the configuration into the Config struct
 	if err := viper.Unmarshal(&config); err != nil {
-		fmt.Println(lipgloss.Red.Render(fmt.Sprintf("Unable to decode into struct: %v", err)))
+		fmt.Fprintln(os.Stderr, lipgloss.Red.Render(fmt.Sprintf("Unable to decode into struct: %v", err)))
 		os.Exit(1)
 	}
 
@@ -115,6 +140,20 @@ func setDefaults() {
 	viper.SetDefault("theme", DefaultConfig.Theme)
 	viper.SetDefault("file_display_mode", DefaultConfig.FileDisplayMode)
 	viper.SetDefault("enable_cache", DefaultConfig.EnableCache)
+	viper.SetDefault("edit_format", DefaultConfig.EditFormat)
+	viper.SetDefault("output", DefaultConfig.Output)
+	viper.SetDefault("protected_paths", DefaultConfig.ProtectedPaths)
+	viper.SetDefault("verify_command", DefaultConfig.VerifyCommand)
+	viper.SetDefault("verify_max_iterations", DefaultConfig.VerifyMaxIterations)
+	viper.SetDefault("git_checkpoints", DefaultConfig.GitCheckpoints)
+	viper.SetDefault("sandbox", DefaultConfig.Sandbox)
+	viper.SetDefault("sandbox_timeout", DefaultConfig.SandboxTimeout)
+	viper.SetDefault("sandbox_max_output", DefaultConfig.SandboxMaxOutput)
+	viper.SetDefault("sandbox_allow_env", DefaultConfig.SandboxAllowEnv)
+	viper.SetDefault("sandbox_deny_network", DefaultConfig.SandboxDenyNetwork)
+	viper.SetDefault("tool_calling", DefaultConfig.ToolCalling)
+	viper.SetDefault("compaction_threshold", DefaultConfig.CompactionThreshold)
+	viper.SetDefault("compaction_model", DefaultConfig.CompactionModel)
 	viper.SetDefault("ai_provider_config.provider", DefaultConfig.AIProviderConfig.Provider)
 	viper.SetDefault("ai_provider_config.base_url", DefaultConfig.AIProviderConfig.BaseURL)
 	viper.SetDefault("ai_provider_config.model", DefaultConfig.AIProviderConfig.Model)
@@ -131,6 +170,20 @@ func bindEnv() {
 	_ = viper.BindEnv("theme", "THEME")
 	_ = viper.BindEnv("file_display_mode", "FILE_DISPLAY_MODE")
 	_ = viper.BindEnv("enable_cache", "ENABLE_CACHE")
+	_ = viper.BindEnv("edit_format", "EDIT_FORMAT")
+	_ = viper.BindEnv("output", "OUTPUT_FORMAT")
+	_ = viper.BindEnv("protected_paths", "PROTECTED_PATHS")
+	_ = viper.BindEnv("verify_command", "VERIFY_COMMAND")
+	_ = viper.BindEnv("verify_max_iterations", "VERIFY_MAX_ITERATIONS")
+	_ = viper.BindEnv("git_checkpoints", "GIT_CHECKPOINTS")
+	_ = viper.BindEnv("sandbox", "SANDBOX")
+	_ = viper.BindEnv("sandbox_timeout", "SANDBOX_TIMEOUT")
+	_ = viper.BindEnv("sandbox_max_output", "SANDBOX_MAX_OUTPUT")
+	_ = viper.BindEnv("sandbox_allow_env", "SANDBOX_ALLOW_ENV")
+	_ = viper.BindEnv("sandbox_deny_network", "SANDBOX_DENY_NETWORK")
+	_ = viper.BindEnv("tool_calling", "TOOL_CALLING")
+	_ = viper.BindEnv("compaction_threshold", "COMPACTION_THRESHOLD")
+	_ = viper.BindEnv("compaction_model", "COMPACTION_MODEL")
 	_ = viper.BindEnv("ai_provider_config.provider", "PROVIDER")
 	_ = viper.BindEnv("ai_provider_config.base_url", "BASE_URL")
 	_ = viper.BindEnv("ai_provider_config.model", "MODEL")
@@ -140,18 +193,33 @@ func bindEnv() {
 	_ = viper.BindEnv("ai_provider_config.api_version", "API_VERSION")
 }
 
-// bindFlags binds the CLI flags to configuration values.
+// bindFlags binds the CLI flags to configuration values. The flags are looked up in Flags() of the running
+// command, which includes the persistent flags inherited from the root command.
 func bindFlags(rootCmd *cobra.Command) {
-	_ = viper.BindPFlag("theme", rootCmd.PersistentFlags().Lookup("theme"))
-	_ = viper.BindPFlag("file_display_mode", rootCmd.PersistentFlags().Lookup("file_display_mode"))
-	_ = viper.BindPFlag("enable_cache", rootCmd.PersistentFlags().Lookup("enable_cache"))
-	_ = viper.BindPFlag("ai_provider_config.provider", rootCmd.PersistentFlags().Lookup("provider"))
-	_ = viper.BindPFlag("ai_provider_config.base_url", rootCmd.PersistentFlags().Lookup("base_url"))
-	_ = viper.BindPFlag("ai_provider_config.model", rootCmd.PersistentFlags().Lookup("model"))
-	_ = viper.BindPFlag("ai_provider_config.temperature", rootCmd.PersistentFlags().Lookup("temperature"))
-	_ = viper.BindPFlag("ai_provider_config.reasoning_effort", rootCmd.PersistentFlags().Lookup("reasoning_effort"))
-	_ = viper.BindPFlag("ai_provider_config.api_key", rootCmd.PersistentFlags().Lookup("api_key"))
-	_ = viper.BindPFlag("ai_provider_config.api_version", rootCmd.PersistentFlags().Lookup("api_version"))
+	_ = viper.BindPFlag("theme", rootCmd.Flags().Lookup("theme"))
+	_ = viper.BindPFlag("file_display_mode", rootCmd.Flags().Lookup("file_display_mode"))
+	_ = viper.BindPFlag("enable_cache", rootCmd.Flags().Lookup("enable_cache"))
+	_ = viper.BindPFlag("edit_format", rootCmd.Flags().Lookup("edit_format"))
+	_ = viper.BindPFlag("output", rootCmd.Flags().Lookup("output"))
+	_ = viper.BindPFlag("protected_paths", rootCmd.Flags().Lookup("protected_paths"))
+	_ = viper.BindPFlag("verify_command", rootCmd.Flags().Lookup("verify_command"))
+	_ = viper.BindPFlag("verify_max_iterations", rootCmd.Flags().Lookup("verify_max_iterations"))
+	_ = viper.BindPFlag("git_checkpoints", rootCmd.Flags().Lookup("git_checkpoints"))
+	_ = viper.BindPFlag("sandbox", rootCmd.Flags().Lookup("sandbox"))
+	_ = viper.BindPFlag("sandbox_timeout", rootCmd.Flags().Lookup("sandbox_timeout"))
+	_ = viper.BindPFlag("sandbox_max_output", rootCmd.Flags().Lookup("sandbox_max_output"))
+	_ = viper.BindPFlag("sandbox_allow_env", rootCmd.Flags().Lookup("sandbox_allow_env"))
+	_ = viper.BindPFlag("sandbox_deny_network", rootCmd.Flags().Lookup("sandbox_deny_network"))
+	_ = viper.BindPFlag("tool_calling", rootCmd.Flags().Lookup("tool_calling"))
+	_ = viper.BindPFlag("compaction_threshold", rootCmd.Flags().Lookup("compaction_threshold"))
+	_ = viper.BindPFlag("compaction_model", rootCmd.Flags().Lookup("compaction_model"))
+	_ = viper.BindPFlag("ai_provider_config.provider", rootCmd.Flags().Lookup("provider"))
+	_ = viper.BindPFlag("ai_provider_config.base_url", rootCmd.Flags().Lookup("base_url"))
+	_ = viper.BindPFlag("ai_provider_config.model", rootCmd.Flags().Lookup("model"))
+	_ = viper.BindPFlag("ai_provider_config.temperature", rootCmd.Flags().Lookup("temperature"))
+	_ = viper.BindPFlag("ai_provider_config.reasoning_effort", rootCmd.Flags().Lookup("reasoning_effort"))
+	_ = viper.BindPFlag("ai_provider_config.api_key", rootCmd.Flags().Lookup("api_key"))
+	_ = viper.BindPFlag("ai_provider_config.api_version", rootCmd.Flags().Lookup("api_version"))
 }
 
 // InitFlags initializes the flags for the root command.
@@ -161,13 +229,43 @@ func InitFlags(rootCmd *cobra.Command) {
 
 	// Theme configuration
 	rootCmd.PersistentFlags().String("theme", DefaultConfig.Theme, "Set customize theme for buffering response from ai. (e.g., 'dracula', 'light', 'dark')")
-	
+
 	// File display mode configuration
 	rootCmd.PersistentFlags().String("file_display_mode", DefaultConfig.FileDisplayMode, "Set file display mode: 'info' (file info only), 'relevant' (relevant code parts), 'full' (complete file content)")
-	
+
 	// Cache configuration
 	rootCmd.PersistentFlags().Bool("enable_cache", DefaultConfig.EnableCache, "Enable or disable file caching for improved performance")
 
+	// Edit format configuration
+	rootCmd.PersistentFlags().String("edit_format", DefaultConfig.EditFormat, "Set the edit protocol the AI uses for code changes: 'diff' (unified diff) or 'search_replace' (SEARCH/REPLACE blocks)")
+
+	// Output format configuration
+	rootCmd.PersistentFlags().String("output", DefaultConfig.Output, "Output format of the 'code' and 'ask' commands: 'text' for the terminal, 'json' for one JSON array of events at exit, or 'ndjson' for one JSON event per line")
+
+	// Protected paths configuration
+	rootCmd.PersistentFlags().StringSlice("protected_paths", DefaultConfig.ProtectedPaths, "Directories the AI is never allowed to change, in addition to '.git' and '.codai' (e.g., 'vendor,deploy/secrets')")
+
+	// Verify configuration
+	rootCmd.PersistentFlags().String("verify_command", DefaultConfig.VerifyCommand, "Command run after changes are applied to verify them (e.g., 'go test ./...'); its failures are offered to the AI for a fix")
+	rootCmd.PersistentFlags().Int("verify_max_iterations", DefaultConfig.VerifyMaxIterations, "Maximum number of fix requests sent to the AI for one failing verify command")
+
+	// Git checkpoints configuration
+	rootCmd.PersistentFlags().Bool("git_checkpoints", DefaultConfig.GitCheckpoints, "Create a git commit for each accepted AI change set, to squash or drop at the end of the session")
+
+	// Sandbox configuration
+	rootCmd.PersistentFlags().Bool("sandbox", DefaultConfig.Sandbox, "Run the AI-suggested commands in a sandbox: jailed to the project, with a timeout, an output cap and an environment without secrets")
+	rootCmd.PersistentFlags().Duration("sandbox_timeout", DefaultConfig.SandboxTimeout, "Maximum wall-clock time of a sandboxed command (e.g., '30s', '5m'); 0 for no limit")
+	rootCmd.PersistentFlags().Int("sandbox_max_output", DefaultConfig.SandboxMaxOutput, "Maximum output of a sandboxed command in bytes; 0 for no limit")
+	rootCmd.PersistentFlags().StringSlice("sandbox_allow_env", DefaultConfig.SandboxAllowEnv, "Environment variables passed to sandboxed commands in addition to the defaults, a trailing '*' matches a prefix (e.g., 'GITHUB_*,NPM_CONFIG_REGISTRY')")
+	rootCmd.PersistentFlags().Bool("sandbox_deny_network", DefaultConfig.SandboxDenyNetwork, "Deny the network to sandboxed commands, with Linux namespaces when available or by rejecting the commands accessing the network")
+
+	// Tool calling configuration
+	rootCmd.PersistentFlags().Bool("tool_calling", DefaultConfig.ToolCalling, "Let the AI call tools to read, search and change the project files, with providers supporting tool calling")
+
+	// History compaction configuration
+	rootCmd.PersistentFlags().Float64("compaction_threshold", DefaultConfig.CompactionThreshold, "Fraction of the input limit of the model at which the older turns of the history are summarized (e.g., 0.8); 0 to never compact")
+	rootCmd.PersistentFlags().String("compaction_model", DefaultConfig.CompactionModel, "Model of the AI provider summarizing the history, a cheaper one than the chat model (e.g., 'gpt-4o-mini'); the chat model when empty")
+
 	// Version flag
 	rootCmd.Flags().BoolP("version", "v", false, "Specifies the version of the application.")
 
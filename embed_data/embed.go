@@ -5,6 +5,18 @@ import _ "embed"
 //go:embed prompts/summarize_full_context_prompt.tmpl
 var SummarizeFullContextPrompt []byte
 
+//go:embed prompts/search_replace_prompt.tmpl
+var SearchReplacePrompt []byte
+
+//go:embed prompts/code_review_prompt.tmpl
+var CodeReviewPrompt []byte
+
+//go:embed prompts/tool_calling_prompt.tmpl
+var ToolCallingPrompt []byte
+
+//go:embed prompts/summarize_history_prompt.tmpl
+var SummarizeHistoryPrompt []byte
+
 //go:embed models_details/model_details.tmpl
 var ModelDetails []byte
 
@@ -0,0 +1,15 @@
+package contracts
+
+import (
+	"context"
+
+	"github.com/meysamhadeli/codai/history_compaction/models"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+)
+
+// IHistoryCompactor keeps the requests of a chat session under the input limit of the model, by replacing the older
+// turns of its history with their summary
+type IHistoryCompactor interface {
+	NeedsCompaction(prompt string, history []provider_models.Message, userInput string) bool
+	Compact(ctx context.Context, prompt string, history []provider_models.Message, userInput string) ([]provider_models.Message, models.Compaction, error)
+}
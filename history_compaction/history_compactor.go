@@ -0,0 +1,177 @@
+package history_compaction
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/meysamhadeli/codai/embed_data"
+	"github.com/meysamhadeli/codai/history_compaction/contracts"
+	"github.com/meysamhadeli/codai/history_compaction/models"
+	contracts_provider "github.com/meysamhadeli/codai/providers/contracts"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+)
+
+const (
+	// charsPerToken is the average number of characters of a token, to estimate the size of a request without the
+	// tokenizer of the model
+	charsPerToken = 4
+	// messageOverheadTokens is the estimated number of tokens of the role and framing of a message
+	messageOverheadTokens = 4
+	// keepRecentTurns is the number of most recent turns kept verbatim after the summary
+	keepRecentTurns = 2
+	// maxToolResultLength limits the result of a tool call in the transcript sent to be summarized
+	maxToolResultLength = 2000
+
+	summaryIntroduction    = "Here is the summary of our earlier conversation:\n\n"
+	summaryAcknowledgement = "Understood, I will continue from this summary."
+)
+
+// historyCompactor summarizes the older turns of a history with the model when a request comes near its input limit
+type historyCompactor struct {
+	summarizer     contracts_provider.IChatAIProvider
+	maxInputTokens int
+	threshold      float64
+}
+
+// NeedsCompaction reports whether the request of the prompt, the history and the user input reaches the threshold of
+// the input limit, to announce the summary before asking for it
+func (compactor *historyCompactor) NeedsCompaction(prompt string, history []provider_models.Message, userInput string) bool {
+	return compactor.exceeds(EstimateTokens(provider_models.NewConversation(prompt, history, userInput)...))
+}
+
+// Compact returns the history to send with the request of the prompt and the user input. When the estimated size of
+// the request reaches the threshold of the input limit, the turns before the most recent ones are replaced with their
+// summary; otherwise the history is returned unchanged.
+func (compactor *historyCompactor) Compact(ctx context.Context, prompt string, history []provider_models.Message, userInput string) ([]provider_models.Message, models.Compaction, error) {
+	compaction := models.Compaction{MaxInputTokens: compactor.maxInputTokens}
+	compaction.TokensBefore = EstimateTokens(provider_models.NewConversation(prompt, history, userInput)...)
+	compaction.TokensAfter = compaction.TokensBefore
+	if !compactor.exceeds(compaction.TokensBefore) {
+		return history, compaction, nil
+	}
+
+	// A turn starts with a user message and goes on with the answers and tool calls of the model
+	var turns []int
+	for i, message := range history {
+		if message.Role == provider_models.RoleUser {
+			turns = append(turns, i)
+		}
+	}
+	keep := min(keepRecentTurns, len(turns)-1)
+	if keep < 1 {
+		return history, compaction, nil
+	}
+	older, recent := history[:turns[len(turns)-keep]], history[turns[len(turns)-keep]:]
+	// The summary of a previous compaction is not summarized again on its own
+	if len(turns) == keep+1 && isSummary(older[turns[0]]) {
+		return history, compaction, nil
+	}
+
+	summary, err := compactor.summarize(ctx, older)
+	if err != nil {
+		return history, compaction, fmt.Errorf("failed to summarize the history: %w", err)
+	}
+
+	compacted := append(summaryMessages(summary), recent...)
+	tokensAfter := EstimateTokens(provider_models.NewConversation(prompt, compacted, userInput)...)
+	if tokensAfter >= compaction.TokensBefore {
+		return history, compaction, nil
+	}
+
+	compaction.Compacted = true
+	compaction.SummarizedMessages = len(older)
+	compaction.TokensAfter = tokensAfter
+	return compacted, compaction, nil
+}
+
+// exceeds reports whether a request of the estimated tokens reaches the threshold of the input limit, never when the
+// limit of the model is unknown
+func (compactor *historyCompactor) exceeds(tokens int) bool {
+	if compactor.summarizer == nil || compactor.maxInputTokens <= 0 || compactor.threshold <= 0 {
+		return false
+	}
+	return float64(tokens) >= compactor.threshold*float64(compactor.maxInputTokens)
+}
+
+// summarize asks the model for the summary of the messages
+func (compactor *historyCompactor) summarize(ctx context.Context, messages []provider_models.Message) (string, error) {
+	request := []provider_models.Message{
+		provider_models.NewTextMessage(provider_models.RoleSystem, string(embed_data.SummarizeHistoryPrompt)),
+		provider_models.NewTextMessage(provider_models.RoleUser, transcript(messages)),
+	}
+
+	answer, err := provider_models.CollectAnswer(compactor.summarizer.ChatCompletionRequest(ctx, request), nil)
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	summary := strings.TrimSpace(answer.Content)
+	if summary == "" {
+		return "", fmt.Errorf("the summary is empty")
+	}
+	return summary, nil
+}
+
+// transcript writes the messages as a text to summarize
+func transcript(messages []provider_models.Message) string {
+	var builder strings.Builder
+	for _, message := range messages {
+		switch message.Role {
+		case provider_models.RoleUser:
+			fmt.Fprintf(&builder, "## Developer\n%s\n\n", message.Text())
+		case provider_models.RoleAssistant:
+			fmt.Fprintf(&builder, "## Assistant\n%s\n", message.Text())
+			for _, call := range message.ToolCalls {
+				fmt.Fprintf(&builder, "(called %s with %s)\n", call.Name, call.Arguments)
+			}
+			builder.WriteString("\n")
+		case provider_models.RoleTool:
+			result := message.Text()
+			if len(result) > maxToolResultLength {
+				result = result[:maxToolResultLength] + "\n... (truncated)"
+			}
+			fmt.Fprintf(&builder, "## Result of %s\n%s\n\n", message.ToolName, result)
+		}
+	}
+	return builder.String()
+}
+
+// summaryMessages creates the turn replacing the older turns of the history: the summary as a user message, so the
+// user and assistant messages keep alternating, and its acknowledgement
+func summaryMessages(summary string) []provider_models.Message {
+	message := provider_models.NewTextMessage(provider_models.RoleUser, summaryIntroduction+summary)
+	message.Metadata = map[string]string{provider_models.MetadataSummary: "true"}
+	return []provider_models.Message{
+		message,
+		provider_models.NewTextMessage(provider_models.RoleAssistant, summaryAcknowledgement),
+	}
+}
+
+// isSummary reports whether the message is the summary of a compaction
+func isSummary(message provider_models.Message) bool {
+	_, ok := message.Metadata[provider_models.MetadataSummary]
+	return ok
+}
+
+// EstimateTokens estimates the number of tokens of the messages from their length
+func EstimateTokens(messages ...provider_models.Message) int {
+	tokens := 0
+	for _, message := range messages {
+		length := len(message.Text())
+		for _, call := range message.ToolCalls {
+			length += len(call.Name) + len(call.Arguments)
+		}
+		tokens += messageOverheadTokens + (length+charsPerToken-1)/charsPerToken
+	}
+	return tokens
+}
+
+// NewHistoryCompactor creates a history compactor summarizing with summarizer once a request reaches the threshold,
+// a fraction of maxInputTokens. It never compacts when maxInputTokens or threshold is 0.
+func NewHistoryCompactor(summarizer contracts_provider.IChatAIProvider, maxInputTokens int, threshold float64) contracts.IHistoryCompactor {
+	return &historyCompactor{summarizer: summarizer, maxInputTokens: maxInputTokens, threshold: threshold}
+}
@@ -0,0 +1,125 @@
+package history_compaction
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSummarizer answers every request with its summary, or fails with its error
+type fakeSummarizer struct {
+	summary  string
+	err      error
+	requests [][]provider_models.Message
+}
+
+func (summarizer *fakeSummarizer) ChatCompletionRequest(ctx context.Context, messages []provider_models.Message) <-chan provider_models.StreamResponse {
+	summarizer.requests = append(summarizer.requests, messages)
+	responses := make(chan provider_models.StreamResponse, 3)
+	if summarizer.err != nil {
+		responses <- provider_models.StreamResponse{Err: summarizer.err}
+	} else {
+		responses <- provider_models.StreamResponse{Content: summarizer.summary}
+		responses <- provider_models.StreamResponse{Done: true}
+		responses <- provider_models.StreamResponse{Content: summarizer.summary}
+	}
+	close(responses)
+	return responses
+}
+
+// longHistory creates a history of turns with long user requests, the second one calling a tool
+func longHistory(turns int) []provider_models.Message {
+	var history []provider_models.Message
+	for i := 0; i < turns; i++ {
+		history = append(history, provider_models.NewTextMessage(provider_models.RoleUser, strings.Repeat("request ", 100)))
+		if i == 1 {
+			call := provider_models.ToolCall{ID: "call_1", Name: "read_file", Arguments: `{"path": "main.go"}`}
+			history = append(history,
+				provider_models.NewAssistantMessage("", []provider_models.ToolCall{call}),
+				provider_models.NewToolMessage(provider_models.ToolResult{CallID: "call_1", Name: "read_file", Content: strings.Repeat("x", 5000)}))
+		}
+		history = append(history, provider_models.NewTextMessage(provider_models.RoleAssistant, strings.Repeat("answer ", 100)))
+	}
+	return history
+}
+
+func TestHistoryCompactor_Compact(t *testing.T) {
+	summarizer := &fakeSummarizer{summary: "- The developer asked for requests."}
+	compactor := NewHistoryCompactor(summarizer, 2000, 0.5)
+	history := longHistory(4)
+
+	assert.True(t, compactor.NeedsCompaction("prompt", history, "input"))
+	compacted, compaction, err := compactor.Compact(context.Background(), "prompt", history, "input")
+	assert.NoError(t, err)
+	assert.True(t, compaction.Compacted)
+	assert.Equal(t, 6, compaction.SummarizedMessages)
+	assert.Greater(t, compaction.SavedTokens(), 0)
+	assert.Equal(t, EstimateTokens(provider_models.NewConversation("prompt", compacted, "input")...), compaction.TokensAfter)
+
+	// The summary replaces the older turns and the last two turns are kept verbatim
+	assert.Len(t, compacted, 6)
+	assert.Equal(t, provider_models.RoleUser, compacted[0].Role)
+	assert.Contains(t, compacted[0].Text(), summarizer.summary)
+	assert.Equal(t, "true", compacted[0].Metadata[provider_models.MetadataSummary])
+	assert.Equal(t, provider_models.RoleAssistant, compacted[1].Role)
+	assert.Equal(t, history[6:], compacted[2:])
+
+	// The older turns are sent to be summarized, with the tool results truncated
+	assert.Len(t, summarizer.requests, 1)
+	transcript := summarizer.requests[0][1].Text()
+	assert.Contains(t, transcript, "(called read_file with")
+	assert.Contains(t, transcript, "... (truncated)")
+	assert.NotContains(t, transcript, strings.Repeat("x", maxToolResultLength+1))
+
+	// The summary is only summarized again with other older turns
+	_, compaction, err = compactor.Compact(context.Background(), "prompt", compacted, "input")
+	assert.NoError(t, err)
+	assert.False(t, compaction.Compacted)
+	assert.Len(t, summarizer.requests, 1)
+
+	compacted, compaction, err = compactor.Compact(context.Background(), "prompt", append(compacted, longHistory(1)...), "input")
+	assert.NoError(t, err)
+	assert.True(t, compaction.Compacted)
+	assert.Equal(t, 4, compaction.SummarizedMessages)
+	assert.Len(t, summarizer.requests, 2)
+}
+
+func TestHistoryCompactor_NoCompaction(t *testing.T) {
+	summarizer := &fakeSummarizer{summary: "summary"}
+	history := longHistory(4)
+
+	// Under the threshold
+	compactor := NewHistoryCompactor(summarizer, 1000000, 0.8)
+	assert.False(t, compactor.NeedsCompaction("prompt", history, "input"))
+	compacted, compaction, err := compactor.Compact(context.Background(), "prompt", history, "input")
+	assert.NoError(t, err)
+	assert.False(t, compaction.Compacted)
+	assert.Equal(t, history, compacted)
+
+	// Unknown input limit or compaction disabled
+	assert.False(t, NewHistoryCompactor(summarizer, 0, 0.8).NeedsCompaction("prompt", history, "input"))
+	assert.False(t, NewHistoryCompactor(summarizer, 2000, 0).NeedsCompaction("prompt", history, "input"))
+
+	// A single turn is kept verbatim
+	compacted, compaction, err = NewHistoryCompactor(summarizer, 100, 0.5).Compact(context.Background(), "prompt", longHistory(1), "input")
+	assert.NoError(t, err)
+	assert.False(t, compaction.Compacted)
+	assert.Len(t, compacted, 2)
+	assert.Empty(t, summarizer.requests)
+}
+
+func TestHistoryCompactor_SummaryError(t *testing.T) {
+	history := longHistory(4)
+
+	compacted, compaction, err := NewHistoryCompactor(&fakeSummarizer{err: errors.New("rate limited")}, 2000, 0.5).Compact(context.Background(), "prompt", history, "input")
+	assert.ErrorContains(t, err, "rate limited")
+	assert.False(t, compaction.Compacted)
+	assert.Equal(t, history, compacted)
+
+	_, _, err = NewHistoryCompactor(&fakeSummarizer{summary: "  "}, 2000, 0.5).Compact(context.Background(), "prompt", history, "input")
+	assert.ErrorContains(t, err, "empty")
+}
@@ -0,0 +1,15 @@
+package models
+
+// Compaction is the outcome of the compaction of a history
+type Compaction struct {
+	Compacted          bool // Whether older turns were replaced with their summary
+	SummarizedMessages int  // Number of messages replaced with the summary
+	TokensBefore       int  // Estimated tokens of the request before the compaction
+	TokensAfter        int  // Estimated tokens of the request after the compaction
+	MaxInputTokens     int  // Input limit of the model
+}
+
+// SavedTokens returns the estimated number of tokens the compaction saved on every following request
+func (compaction Compaction) SavedTokens() int {
+	return compaction.TokensBefore - compaction.TokensAfter
+}
@@ -0,0 +1,319 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/meysamhadeli/codai/config"
+	"github.com/meysamhadeli/codai/mcp/models"
+)
+
+// Timeouts of the servers started by codai
+const (
+	initializeTimeout = 30 * time.Second
+	closeTimeout      = 5 * time.Second
+)
+
+// maxStderrBytes is the end of the stderr of a server kept to explain its failures
+const maxStderrBytes = 4096
+
+// ErrClientClosed is returned by the calls to a server that stopped
+var ErrClientClosed = errors.New("the MCP server stopped")
+
+// incomingMessage is a message of a server: a response to a call, or a request or a notification of the server
+type incomingMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *models.Error   `json:"error,omitempty"`
+}
+
+// Client calls a server of the Model Context Protocol with newline-delimited JSON-RPC messages
+type Client struct {
+	name       string
+	writer     io.WriteCloser
+	writerMu   sync.Mutex
+	nextID     atomic.Int64
+	pendingMu  sync.Mutex
+	pending    map[string]chan *incomingMessage
+	closed     chan struct{}
+	closeOnce  sync.Once
+	ServerInfo models.Implementation
+
+	// process and stderr are set for the servers started by StartClient
+	process *exec.Cmd
+	stderr  *tailBuffer
+}
+
+// NewClient creates the client of the server named name, reading its messages from reader and writing to writer
+func NewClient(name string, reader io.Reader, writer io.WriteCloser) *Client {
+	client := &Client{
+		name:    name,
+		writer:  writer,
+		pending: make(map[string]chan *incomingMessage),
+		closed:  make(chan struct{}),
+	}
+	go client.readMessages(reader)
+	return client
+}
+
+// StartClient starts the server of serverConfig in dir and initializes the session with it
+func StartClient(ctx context.Context, name string, dir string, serverConfig models.ServerConfig) (*Client, error) {
+	if strings.TrimSpace(serverConfig.Command) == "" {
+		return nil, fmt.Errorf("the MCP server '%s' has no command", name)
+	}
+
+	process := exec.Command(serverConfig.Command, serverConfig.Args...)
+	process.Dir = dir
+	process.Env = append(os.Environ(), serverConfig.Env...)
+	stderr := &tailBuffer{}
+	process.Stderr = stderr
+
+	stdin, err := process.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := process.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := process.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the MCP server '%s': %w", name, err)
+	}
+
+	client := NewClient(name, stdout, stdin)
+	client.process, client.stderr = process, stderr
+
+	initializeCtx, cancel := context.WithTimeout(ctx, initializeTimeout)
+	defer cancel()
+	if err := client.Initialize(initializeCtx); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to initialize the MCP server '%s': %w", name, client.withStderr(err))
+	}
+	return client, nil
+}
+
+// Name is the name of the server in the configuration
+func (client *Client) Name() string {
+	return client.name
+}
+
+// Initialize negotiates the protocol version with the server
+func (client *Client) Initialize(ctx context.Context) error {
+	var result models.InitializeResult
+	params := models.InitializeParams{
+		ProtocolVersion: models.ProtocolVersions[0],
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      models.Implementation{Name: "codai", Version: config.DefaultConfig.Version},
+	}
+	if err := client.call(ctx, "initialize", params, &result); err != nil {
+		return err
+	}
+
+	supported := false
+	for _, version := range models.ProtocolVersions {
+		supported = supported || version == result.ProtocolVersion
+	}
+	if !supported {
+		return fmt.Errorf("the server speaks the unsupported protocol version '%s'", result.ProtocolVersion)
+	}
+	client.ServerInfo = result.ServerInfo
+
+	return client.notify("notifications/initialized", nil)
+}
+
+// ListTools returns all the tools of the server
+func (client *Client) ListTools(ctx context.Context) ([]models.Tool, error) {
+	var tools []models.Tool
+	cursor := ""
+	for {
+		params := map[string]string{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var result models.ListToolsResult
+		if err := client.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" || result.NextCursor == cursor {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// CallTool calls a tool of the server with its arguments in JSON
+func (client *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*models.CallToolResult, error) {
+	var result models.CallToolResult
+	if err := client.call(ctx, "tools/call", models.CallToolParams{Name: name, Arguments: arguments}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close stops the session, and the server when it was started by StartClient
+func (client *Client) Close() error {
+	err := client.writer.Close()
+	if client.process == nil {
+		return err
+	}
+
+	// The server should exit when its stdin is closed, it is killed otherwise
+	exited := make(chan error, 1)
+	go func() {
+		exited <- client.process.Wait()
+	}()
+	select {
+	case <-exited:
+	case <-time.After(closeTimeout):
+		_ = client.process.Process.Kill()
+		<-exited
+	}
+	return nil
+}
+
+// call sends a request and decodes the result of its response
+func (client *Client) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	id := client.nextID.Add(1)
+	key := strconv.FormatInt(id, 10)
+	responses := make(chan *incomingMessage, 1)
+
+	client.pendingMu.Lock()
+	client.pending[key] = responses
+	client.pendingMu.Unlock()
+	defer func() {
+		client.pendingMu.Lock()
+		delete(client.pending, key)
+		client.pendingMu.Unlock()
+	}()
+
+	if err := client.write(map[string]interface{}{"jsonrpc": models.JSONRPCVersion, "id": id, "method": method, "params": params}); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		_ = client.notify("notifications/cancelled", map[string]interface{}{"requestId": id, "reason": ctx.Err().Error()})
+		return ctx.Err()
+	case <-client.closed:
+		return client.withStderr(ErrClientClosed)
+	case response := <-responses:
+		if response.Error != nil {
+			return response.Error
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("invalid result of '%s': %w", method, err)
+		}
+		return nil
+	}
+}
+
+func (client *Client) notify(method string, params interface{}) error {
+	message := map[string]interface{}{"jsonrpc": models.JSONRPCVersion, "method": method}
+	if params != nil {
+		message["params"] = params
+	}
+	return client.write(message)
+}
+
+func (client *Client) write(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	client.writerMu.Lock()
+	defer client.writerMu.Unlock()
+	if _, err := client.writer.Write(append(data, '\n')); err != nil {
+		return client.withStderr(fmt.Errorf("%w: %v", ErrClientClosed, err))
+	}
+	return nil
+}
+
+// readMessages routes the responses of the server to their calls and answers its requests, until it stops
+func (client *Client) readMessages(reader io.Reader) {
+	defer client.closeOnce.Do(func() { close(client.closed) })
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageBytes)
+	for scanner.Scan() {
+		var message incomingMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			// Servers may log to stdout by mistake, their other messages are still valid
+			continue
+		}
+
+		if message.Method != "" {
+			if len(message.ID) > 0 {
+				client.answer(message)
+			}
+			continue
+		}
+
+		client.pendingMu.Lock()
+		responses, ok := client.pending[string(message.ID)]
+		client.pendingMu.Unlock()
+		if ok {
+			responses <- &message
+		}
+	}
+}
+
+// answer answers the requests of the server: codai only answers pings
+func (client *Client) answer(request incomingMessage) {
+	response := map[string]interface{}{"jsonrpc": models.JSONRPCVersion, "id": request.ID}
+	if request.Method == "ping" {
+		response["result"] = struct{}{}
+	} else {
+		response["error"] = models.Error{Code: models.CodeMethodNotFound, Message: fmt.Sprintf("method '%s' not supported by codai", request.Method)}
+	}
+	_ = client.write(response)
+}
+
+// withStderr adds the end of the stderr of the server to an error
+func (client *Client) withStderr(err error) error {
+	if client.stderr == nil {
+		return err
+	}
+	if tail := strings.TrimSpace(client.stderr.String()); tail != "" {
+		return fmt.Errorf("%w\n%s", err, tail)
+	}
+	return err
+}
+
+// tailBuffer keeps the end of what is written to it
+type tailBuffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (buffer *tailBuffer) Write(data []byte) (int, error) {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	buffer.data = append(buffer.data, data...)
+	if len(buffer.data) > maxStderrBytes {
+		buffer.data = buffer.data[len(buffer.data)-maxStderrBytes:]
+	}
+	return len(data), nil
+}
+
+func (buffer *tailBuffer) String() string {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return string(buffer.data)
+}
@@ -0,0 +1,155 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meysamhadeli/codai/code_analyzer"
+	"github.com/meysamhadeli/codai/config"
+	"github.com/meysamhadeli/codai/mcp/models"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/meysamhadeli/codai/server"
+	"github.com/stretchr/testify/assert"
+)
+
+// newConnectedClient connects a client to a server of a project over pipes
+func newConnectedClient(t *testing.T) *Client {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n}\n"), 0644))
+
+	mcpServer := NewServer(&server.Dependencies{
+		Cwd:      dir,
+		Config:   &config.Config{FileDisplayMode: "info", EditFormat: "diff"},
+		Analyzer: code_analyzer.NewCodeAnalyzer(dir, false),
+	})
+
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := mcpServer.Serve(context.Background(), serverReader, serverWriter)
+		serverWriter.Close()
+		done <- err
+	}()
+
+	client := NewClient("codai", clientReader, clientWriter)
+	t.Cleanup(func() {
+		assert.NoError(t, client.Close())
+		assert.NoError(t, <-done)
+	})
+
+	assert.NoError(t, client.Initialize(context.Background()))
+	return client
+}
+
+func TestClient(t *testing.T) {
+	client := newConnectedClient(t)
+	ctx := context.Background()
+
+	tools, err := client.ListTools(ctx)
+	assert.NoError(t, err)
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	assert.Equal(t, []string{"project_summary", "read_files", "propose_edit", "apply_edit"}, names)
+
+	result, err := client.CallTool(ctx, "read_files", json.RawMessage(`{"paths": ["main.go"]}`))
+	assert.NoError(t, err)
+	assert.False(t, result.IsError)
+	assert.Contains(t, ToolResultText(result), "func main()")
+
+	_, err = client.CallTool(ctx, "unknown", json.RawMessage(`{}`))
+	var protocolError *models.Error
+	assert.ErrorAs(t, err, &protocolError)
+	assert.Equal(t, models.CodeInvalidParams, protocolError.Code)
+}
+
+func TestClient_Closed(t *testing.T) {
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	client := NewClient("broken", clientReader, clientWriter)
+	defer client.Close()
+
+	// The server exits without answering
+	go func() {
+		_, _ = bufio.NewReader(serverReader).ReadString('\n')
+		serverWriter.Close()
+		_, _ = io.Copy(io.Discard, serverReader)
+	}()
+	err := client.Initialize(context.Background())
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
+
+func TestToolManager(t *testing.T) {
+	client := newConnectedClient(t)
+	ctx := context.Background()
+
+	tools, err := client.ListTools(ctx)
+	assert.NoError(t, err)
+	manager := &toolManager{tools: make(map[string]managedTool)}
+	assert.Empty(t, manager.add(client, tools, []string{"read_files"}))
+
+	definitions := manager.Tools()
+	assert.Len(t, definitions, 4)
+	assert.Equal(t, "codai__project_summary", definitions[0].Name)
+	assert.True(t, json.Valid(definitions[0].Parameters))
+
+	description, ok := manager.Describe("codai__read_files")
+	assert.True(t, ok)
+	assert.Equal(t, "read_files of MCP server codai", description)
+	assert.True(t, manager.AutoApproved("codai__read_files"))
+	assert.False(t, manager.AutoApproved("codai__apply_edit"))
+
+	result := manager.CallTool(ctx, provider_models.ToolCall{ID: "call_1", Name: "codai__project_summary"})
+	assert.False(t, result.IsError)
+	assert.Equal(t, "call_1", result.CallID)
+	assert.Contains(t, result.Content, "main.go")
+
+	result = manager.CallTool(ctx, provider_models.ToolCall{ID: "call_2", Name: "codai__read_files", Arguments: `{"paths": [`})
+	assert.True(t, result.IsError)
+
+	result = manager.CallTool(ctx, provider_models.ToolCall{ID: "call_3", Name: "other__tool"})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.Content, "Unknown tool")
+}
+
+func TestToolName(t *testing.T) {
+	assert.Equal(t, "my_server__read_file", toolName("my server", "read.file"))
+	assert.Len(t, toolName("server", strings.Repeat("a", 100)), maxToolNameLength)
+
+	// The long names sharing their beginning stay apart
+	first, second := toolName("server", strings.Repeat("a", 100)+"_read"), toolName("server", strings.Repeat("a", 100)+"_write")
+	assert.Len(t, second, maxToolNameLength)
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, first, toolName("server", strings.Repeat("a", 100)+"_read"))
+}
+
+func TestToolManager_Collisions(t *testing.T) {
+	client := newConnectedClient(t)
+	tools := []models.Tool{{Name: "read.file"}, {Name: "read_file"}, {Name: "write_file"}}
+
+	manager := &toolManager{tools: make(map[string]managedTool)}
+	errs := manager.add(client, tools, nil)
+
+	assert.Len(t, manager.Tools(), 2)
+	assert.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "the tool 'read_file' of the MCP server 'codai' was left out")
+}
+
+func TestStartToolManager_FailedServer(t *testing.T) {
+	manager, errs := StartToolManager(context.Background(), t.TempDir(), map[string]models.ServerConfig{
+		"missing": {Command: "codai-mcp-server-that-does-not-exist"},
+	})
+	defer manager.Close()
+
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "missing")
+	assert.Empty(t, manager.Tools())
+}
@@ -0,0 +1,11 @@
+package contracts
+
+import (
+	contracts_agent "github.com/meysamhadeli/codai/agent/contracts"
+)
+
+// IToolManager gives the model the tools of the MCP servers of the configuration and runs its calls
+type IToolManager interface {
+	contracts_agent.IToolSet
+	Close()
+}
@@ -0,0 +1,11 @@
+package models
+
+// ServerConfig is an MCP server started by codai over stdio, declared in the 'mcp_servers' option
+type ServerConfig struct {
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+	// Env are the 'KEY=value' variables added to the environment of the server
+	Env []string `mapstructure:"env"`
+	// AutoApprove are the tools of the server called without asking the user
+	AutoApprove []string `mapstructure:"auto_approve"`
+}
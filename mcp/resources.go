@@ -0,0 +1,95 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	analyzer_models "github.com/meysamhadeli/codai/code_analyzer/models"
+	"github.com/meysamhadeli/codai/mcp/models"
+	"github.com/meysamhadeli/codai/utils"
+)
+
+// The URIs of the outline of the symbols of the project, and of one of its files
+const (
+	outlineURI       = "codai://outline"
+	outlineURIPrefix = outlineURI + "/"
+)
+
+// outlineFiles returns the files of the project with the tree-sitter outline of their symbols
+func (mcpServer *Server) outlineFiles() ([]analyzer_models.FileData, error) {
+	fullContext, err := mcpServer.dependencies.Analyzer.GetProjectFilesWithDisplayMode(mcpServer.dependencies.Cwd, "full")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the context of the project: %w", err)
+	}
+
+	var files []analyzer_models.FileData
+	for _, fileData := range fullContext.FileData {
+		if utils.GetSupportedLanguage(fileData.RelativePath) != "" && strings.TrimSpace(fileData.TreeSitterCode) != "" {
+			files = append(files, fileData)
+		}
+	}
+	return files, nil
+}
+
+func (mcpServer *Server) listResources(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	files, err := mcpServer.outlineFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	result := models.ListResourcesResult{Resources: []models.Resource{{
+		URI:         outlineURI,
+		Name:        "Project outline",
+		Description: "The symbols of the files of the project, parsed with tree-sitter",
+		MimeType:    "text/markdown",
+	}}}
+	for _, file := range files {
+		result.Resources = append(result.Resources, models.Resource{
+			URI:      outlineURIPrefix + filepath.ToSlash(file.RelativePath),
+			Name:     "Outline of " + filepath.ToSlash(file.RelativePath),
+			MimeType: "text/plain",
+		})
+	}
+	return result, nil
+}
+
+func (mcpServer *Server) listResourceTemplates(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return models.ListResourceTemplatesResult{ResourceTemplates: []models.ResourceTemplate{{
+		URITemplate: outlineURIPrefix + "{path}",
+		Name:        "File outline",
+		Description: "The symbols of a file of the project, parsed with tree-sitter",
+		MimeType:    "text/plain",
+	}}}, nil
+}
+
+func (mcpServer *Server) readResource(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var readParams models.ReadResourceParams
+	if err := decodeParams(params, &readParams); err != nil {
+		return nil, err
+	}
+
+	files, err := mcpServer.outlineFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	if readParams.URI == outlineURI {
+		var builder strings.Builder
+		for _, file := range files {
+			builder.WriteString(fmt.Sprintf("## %s\n\n%s\n\n", filepath.ToSlash(file.RelativePath), strings.TrimSpace(file.TreeSitterCode)))
+		}
+		return models.ReadResourceResult{Contents: []models.ResourceContents{{URI: outlineURI, MimeType: "text/markdown", Text: builder.String()}}}, nil
+	}
+
+	if path, ok := strings.CutPrefix(readParams.URI, outlineURIPrefix); ok {
+		for _, file := range files {
+			if filepath.ToSlash(file.RelativePath) == path {
+				return models.ReadResourceResult{Contents: []models.ResourceContents{{URI: readParams.URI, MimeType: "text/plain", Text: strings.TrimSpace(file.TreeSitterCode)}}}, nil
+			}
+		}
+	}
+	return nil, &models.Error{Code: models.CodeResourceNotFound, Message: fmt.Sprintf("resource '%s' not found", readParams.URI)}
+}
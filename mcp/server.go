@@ -0,0 +1,169 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/meysamhadeli/codai/config"
+	"github.com/meysamhadeli/codai/mcp/models"
+	"github.com/meysamhadeli/codai/server"
+)
+
+// maxMessageBytes limits the size of a message read from the client
+const maxMessageBytes = 32 << 20
+
+// methodHandler answers a request with its result, or with a *models.Error
+type methodHandler func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// Server serves the tools and resources of codai to a client of the Model Context Protocol, with newline-delimited
+// JSON-RPC messages like the stdio transport of the protocol
+type Server struct {
+	dependencies *server.Dependencies
+	// api runs the steps of the pipeline shared with the HTTP server
+	api      *server.Server
+	methods  map[string]methodHandler
+	tools    []tool
+	writerMu sync.Mutex
+}
+
+// NewServer creates the server of the project of dependencies
+func NewServer(dependencies *server.Dependencies) *Server {
+	mcpServer := &Server{dependencies: dependencies, api: server.NewServer(dependencies, "")}
+	mcpServer.tools = mcpServer.newTools()
+	mcpServer.methods = map[string]methodHandler{
+		"initialize":               mcpServer.initialize,
+		"ping":                     mcpServer.ping,
+		"tools/list":               mcpServer.listTools,
+		"tools/call":               mcpServer.callTool,
+		"resources/list":           mcpServer.listResources,
+		"resources/templates/list": mcpServer.listResourceTemplates,
+		"resources/read":           mcpServer.readResource,
+	}
+	return mcpServer
+}
+
+// Serve answers the messages read from reader on writer until reader is closed or ctx is done
+func (mcpServer *Server) Serve(ctx context.Context, reader io.Reader, writer io.Writer) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageBytes)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if response := mcpServer.handle(ctx, []byte(line)); response != nil {
+			if err := mcpServer.write(writer, response); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// handle answers a message, or returns nil for a notification
+func (mcpServer *Server) handle(ctx context.Context, message []byte) *models.Response {
+	var request models.Request
+	if err := json.Unmarshal(message, &request); err != nil {
+		return errorResponse(nil, &models.Error{Code: models.CodeParseError, Message: fmt.Sprintf("invalid JSON: %v", err)})
+	}
+	if request.JSONRPC != models.JSONRPCVersion || request.Method == "" {
+		if request.IsNotification() {
+			return nil
+		}
+		return errorResponse(request.ID, &models.Error{Code: models.CodeInvalidRequest, Message: "the message is not a JSON-RPC 2.0 request"})
+	}
+
+	// Notifications, like 'notifications/initialized', need no answer
+	if request.IsNotification() {
+		return nil
+	}
+
+	handler, ok := mcpServer.methods[request.Method]
+	if !ok {
+		return errorResponse(request.ID, &models.Error{Code: models.CodeMethodNotFound, Message: fmt.Sprintf("method '%s' not found", request.Method)})
+	}
+
+	result, err := handler(ctx, request.Params)
+	if err != nil {
+		var protocolError *models.Error
+		if !errors.As(err, &protocolError) {
+			protocolError = &models.Error{Code: models.CodeInternalError, Message: err.Error()}
+		}
+		return errorResponse(request.ID, protocolError)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(request.ID, &models.Error{Code: models.CodeInternalError, Message: err.Error()})
+	}
+	return &models.Response{JSONRPC: models.JSONRPCVersion, ID: request.ID, Result: data}
+}
+
+func (mcpServer *Server) write(writer io.Writer, response *models.Response) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	mcpServer.writerMu.Lock()
+	defer mcpServer.writerMu.Unlock()
+	_, err = writer.Write(append(data, '\n'))
+	return err
+}
+
+func errorResponse(id json.RawMessage, protocolError *models.Error) *models.Response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &models.Response{JSONRPC: models.JSONRPCVersion, ID: id, Error: protocolError}
+}
+
+// decodeParams decodes the parameters of a request, answering invalid params when they don't match
+func decodeParams(params json.RawMessage, value interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, value); err != nil {
+		return &models.Error{Code: models.CodeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+	return nil
+}
+
+func (mcpServer *Server) initialize(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var initializeParams models.InitializeParams
+	if err := decodeParams(params, &initializeParams); err != nil {
+		return nil, err
+	}
+
+	// Answer with the version of the client when it is supported, with the newest one otherwise
+	version := models.ProtocolVersions[0]
+	for _, supported := range models.ProtocolVersions {
+		if supported == initializeParams.ProtocolVersion {
+			version = supported
+		}
+	}
+
+	return models.InitializeResult{
+		ProtocolVersion: version,
+		Capabilities: models.ServerCapabilities{
+			Tools:     &models.ListChangedCapability{},
+			Resources: &models.ListChangedCapability{},
+		},
+		ServerInfo: models.Implementation{Name: "codai", Version: config.DefaultConfig.Version},
+		Instructions: fmt.Sprintf("codai gives access to the project in %s: summarize it with 'project_summary', read files with "+
+			"'read_files', preview edits with 'propose_edit' and write them with 'apply_edit'. The outline of the symbols of the "+
+			"files is in the 'codai://outline' resources.", mcpServer.dependencies.Cwd),
+	}, nil
+}
+
+func (mcpServer *Server) ping(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return struct{}{}, nil
+}
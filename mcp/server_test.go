@@ -0,0 +1,234 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/meysamhadeli/codai/code_analyzer"
+	"github.com/meysamhadeli/codai/config"
+	"github.com/meysamhadeli/codai/mcp/models"
+	output_models "github.com/meysamhadeli/codai/output/models"
+	"github.com/meysamhadeli/codai/server"
+	server_models "github.com/meysamhadeli/codai/server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// testClient drives a server over pipes, like a client of the stdio transport
+type testClient struct {
+	t       *testing.T
+	writer  io.WriteCloser
+	scanner *bufio.Scanner
+	nextID  int
+	done    chan error
+}
+
+func newTestClient(t *testing.T) (*testClient, string) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n}\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("some notes\n"), 0644))
+
+	mcpServer := NewServer(&server.Dependencies{
+		Cwd:      dir,
+		Config:   &config.Config{FileDisplayMode: "info", EditFormat: "diff"},
+		Analyzer: code_analyzer.NewCodeAnalyzer(dir, false),
+	})
+
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	client := &testClient{t: t, writer: clientWriter, scanner: bufio.NewScanner(clientReader), done: make(chan error, 1)}
+	client.scanner.Buffer(make([]byte, 0, 64*1024), maxMessageBytes)
+
+	go func() {
+		err := mcpServer.Serve(context.Background(), serverReader, serverWriter)
+		serverWriter.Close()
+		client.done <- err
+	}()
+	t.Cleanup(func() {
+		clientWriter.Close()
+		assert.NoError(t, <-client.done)
+	})
+	return client, dir
+}
+
+func (client *testClient) send(message string) {
+	_, err := client.writer.Write([]byte(message + "\n"))
+	assert.NoError(client.t, err)
+}
+
+func (client *testClient) receive() models.Response {
+	assert.True(client.t, client.scanner.Scan(), "the server answers")
+	var response models.Response
+	assert.NoError(client.t, json.Unmarshal(client.scanner.Bytes(), &response))
+	assert.Equal(client.t, models.JSONRPCVersion, response.JSONRPC)
+	return response
+}
+
+// call sends a request and decodes its result, failing on an error response
+func (client *testClient) call(method string, params interface{}, result interface{}) {
+	response := client.request(method, params)
+	assert.Nil(client.t, response.Error)
+	if result != nil {
+		assert.NoError(client.t, json.Unmarshal(response.Result, result))
+	}
+}
+
+func (client *testClient) request(method string, params interface{}) models.Response {
+	client.nextID++
+	data, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": client.nextID, "method": method, "params": params})
+	assert.NoError(client.t, err)
+	client.send(string(data))
+
+	response := client.receive()
+	assert.Equal(client.t, json.RawMessage(mustMarshal(client.nextID)), response.ID)
+	return response
+}
+
+func (client *testClient) callTool(name string, arguments interface{}) models.CallToolResult {
+	var result models.CallToolResult
+	client.call("tools/call", map[string]interface{}{"name": name, "arguments": arguments}, &result)
+	assert.Len(client.t, result.Content, 1)
+	return result
+}
+
+func mustMarshal(value interface{}) []byte {
+	data, _ := json.Marshal(value)
+	return data
+}
+
+func TestInitialize(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	var result models.InitializeResult
+	client.call("initialize", models.InitializeParams{ProtocolVersion: "2025-03-26", ClientInfo: models.Implementation{Name: "test", Version: "1"}}, &result)
+	assert.Equal(t, "2025-03-26", result.ProtocolVersion)
+	assert.Equal(t, "codai", result.ServerInfo.Name)
+	assert.NotNil(t, result.Capabilities.Tools)
+	assert.NotNil(t, result.Capabilities.Resources)
+
+	// Notifications are not answered, the next message is the answer to the ping
+	client.send(`{"jsonrpc": "2.0", "method": "notifications/initialized"}`)
+	client.call("ping", nil, nil)
+
+	client.call("initialize", models.InitializeParams{ProtocolVersion: "1999-01-01"}, &result)
+	assert.Equal(t, models.ProtocolVersions[0], result.ProtocolVersion, "an unknown version gets the newest one")
+}
+
+func TestErrors(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	client.send(`{"jsonrpc": "2.0", "id": 1, "method": `)
+	response := client.receive()
+	assert.Equal(t, json.RawMessage("null"), response.ID)
+	assert.Equal(t, models.CodeParseError, response.Error.Code)
+
+	client.send(`{"jsonrpc": "1.0", "id": "a", "method": "ping"}`)
+	response = client.receive()
+	assert.Equal(t, json.RawMessage(`"a"`), response.ID)
+	assert.Equal(t, models.CodeInvalidRequest, response.Error.Code)
+
+	assert.Equal(t, models.CodeMethodNotFound, client.request("prompts/list", nil).Error.Code)
+	assert.Equal(t, models.CodeInvalidParams, client.request("tools/call", map[string]interface{}{"name": "unknown"}).Error.Code)
+	assert.Equal(t, models.CodeInvalidParams, client.request("tools/call", []int{1}).Error.Code)
+	assert.Equal(t, models.CodeResourceNotFound, client.request("resources/read", models.ReadResourceParams{URI: "codai://outline/unknown.go"}).Error.Code)
+}
+
+func TestTools(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	var tools models.ListToolsResult
+	client.call("tools/list", nil, &tools)
+	var names []string
+	for _, tool := range tools.Tools {
+		names = append(names, tool.Name)
+		assert.True(t, json.Valid(tool.InputSchema), tool.Name)
+	}
+	assert.Equal(t, []string{"project_summary", "read_files", "propose_edit", "apply_edit"}, names)
+
+	summary := client.callTool("project_summary", map[string]string{"mode": "info"})
+	assert.False(t, summary.IsError)
+	assert.Contains(t, summary.Content[0].Text, "**File: main.go**")
+	assert.Contains(t, summary.Content[0].Text, "**File: notes.txt**")
+
+	summary = client.callTool("project_summary", map[string]string{"mode": "everything"})
+	assert.True(t, summary.IsError)
+}
+
+func TestReadFiles(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	files := client.callTool("read_files", map[string][]string{"paths": {"notes.txt", "./main.go", "missing.go"}})
+	assert.False(t, files.IsError)
+	assert.Contains(t, files.Content[0].Text, "**File: notes.txt**\n\nsome notes\n")
+	assert.Contains(t, files.Content[0].Text, "**File: main.go**\n\npackage main\n")
+	assert.Contains(t, files.Content[0].Text, "These files are not in the project: missing.go")
+
+	outside := client.callTool("read_files", map[string][]string{"paths": {"../../etc/passwd"}})
+	assert.True(t, outside.IsError, "files outside of the project are not read")
+	assert.NotContains(t, outside.Content[0].Text, "root:")
+}
+
+func TestEdits(t *testing.T) {
+	client, dir := newTestClient(t)
+	changes := []output_models.CodeChange{
+		{Path: "main.go", Code: "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"},
+		{Path: "../outside.go", Code: "package main\n"},
+	}
+
+	proposed := client.callTool("propose_edit", map[string]interface{}{"changes": changes})
+	assert.True(t, proposed.IsError, "a change was rejected")
+	var proposal server_models.ApplyResponse
+	assert.NoError(t, json.Unmarshal([]byte(proposed.Content[0].Text), &proposal))
+	assert.Equal(t, output_models.ChangeAccepted, proposal.Results[0].Status)
+	assert.Equal(t, output_models.ChangeRejected, proposal.Results[1].Status)
+	assert.Contains(t, proposal.Patch, "+\tprintln(\"hello\")\n")
+
+	content, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	assert.Equal(t, "package main\n\nfunc main() {\n}\n", string(content), "a proposal leaves the files as they are")
+
+	applied := client.callTool("apply_edit", map[string]interface{}{"text": "File: main.go\n```go\n" + changes[0].Code + "```\n"})
+	assert.False(t, applied.IsError)
+	var application server_models.ApplyResponse
+	assert.NoError(t, json.Unmarshal([]byte(applied.Content[0].Text), &application))
+	assert.Equal(t, output_models.ChangeApplied, application.Results[0].Status)
+
+	content, _ = os.ReadFile(filepath.Join(dir, "main.go"))
+	assert.Contains(t, string(content), "println(\"hello\")")
+
+	broken := client.callTool("apply_edit", map[string]interface{}{"changes": []output_models.CodeChange{{Path: "broken.go", Code: "package main\n\nfunc {"}}})
+	assert.True(t, broken.IsError)
+	_, err := os.Stat(filepath.Join(dir, "broken.go"))
+	assert.True(t, os.IsNotExist(err))
+
+	empty := client.callTool("apply_edit", map[string]interface{}{})
+	assert.True(t, empty.IsError)
+}
+
+func TestResources(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	var resources models.ListResourcesResult
+	client.call("resources/list", nil, &resources)
+	var uris []string
+	for _, resource := range resources.Resources {
+		uris = append(uris, resource.URI)
+	}
+	assert.Equal(t, []string{"codai://outline", "codai://outline/main.go"}, uris, "only the files tree-sitter parses have an outline")
+
+	var templates models.ListResourceTemplatesResult
+	client.call("resources/templates/list", nil, &templates)
+	assert.Equal(t, "codai://outline/{path}", templates.ResourceTemplates[0].URITemplate)
+
+	var outline models.ReadResourceResult
+	client.call("resources/read", models.ReadResourceParams{URI: "codai://outline"}, &outline)
+	assert.Contains(t, outline.Contents[0].Text, "## main.go\n\n")
+	assert.Contains(t, outline.Contents[0].Text, "function: main")
+
+	client.call("resources/read", models.ReadResourceParams{URI: "codai://outline/main.go"}, &outline)
+	assert.Equal(t, "codai://outline/main.go", outline.Contents[0].URI)
+	assert.Contains(t, outline.Contents[0].Text, "function: main")
+}
@@ -0,0 +1,185 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/meysamhadeli/codai/mcp/contracts"
+	"github.com/meysamhadeli/codai/mcp/models"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+)
+
+// toolCallTimeout limits the time of a tool call
+const toolCallTimeout = 5 * time.Minute
+
+// maxToolNameLength is the longest tool name the APIs of the providers accept
+const maxToolNameLength = 64
+
+// invalidToolNameCharacters are the characters the APIs of the providers reject in tool names
+var invalidToolNameCharacters = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// managedTool is a tool of a server, under the name the model sees
+type managedTool struct {
+	client       *Client
+	name         string
+	autoApproved bool
+}
+
+// toolManager routes the tool calls of the model to the MCP servers
+type toolManager struct {
+	clients     []*Client
+	tools       map[string]managedTool
+	definitions []provider_models.ToolDefinition
+}
+
+// StartToolManager starts the MCP servers in dir and lists their tools. The servers that fail to start and the tools
+// whose names collide are left out, with their errors.
+func StartToolManager(ctx context.Context, dir string, servers map[string]models.ServerConfig) (contracts.IToolManager, []error) {
+	manager := &toolManager{tools: make(map[string]managedTool)}
+
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		serverConfig := servers[name]
+		client, err := StartClient(ctx, name, dir, serverConfig)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		listCtx, cancel := context.WithTimeout(ctx, initializeTimeout)
+		tools, err := client.ListTools(listCtx)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list the tools of the MCP server '%s': %w", name, err))
+			_ = client.Close()
+			continue
+		}
+		errs = append(errs, manager.add(client, tools, serverConfig.AutoApprove)...)
+	}
+	return manager, errs
+}
+
+// add registers the tools of a server as '<server>__<tool>', so the tools of different servers don't collide, and
+// returns the errors of the tools left out as their names still collide once they are made valid
+func (manager *toolManager) add(client *Client, tools []models.Tool, autoApprove []string) []error {
+	manager.clients = append(manager.clients, client)
+
+	approved := make(map[string]bool)
+	for _, name := range autoApprove {
+		approved[name] = true
+	}
+
+	var errs []error
+	for _, tool := range tools {
+		name := toolName(client.Name(), tool.Name)
+		if existing, exists := manager.tools[name]; exists {
+			errs = append(errs, fmt.Errorf("the tool '%s' of the MCP server '%s' was left out, its name '%s' is taken by the tool '%s' of the MCP server '%s'",
+				tool.Name, client.Name(), name, existing.name, existing.client.Name()))
+			continue
+		}
+		manager.tools[name] = managedTool{client: client, name: tool.Name, autoApproved: approved[tool.Name] || approved["*"]}
+
+		parameters := tool.InputSchema
+		if len(parameters) == 0 || !json.Valid(parameters) {
+			parameters = json.RawMessage(`{"type": "object"}`)
+		}
+		manager.definitions = append(manager.definitions, provider_models.ToolDefinition{
+			Name:        name,
+			Description: tool.Description,
+			Parameters:  parameters,
+		})
+	}
+	return errs
+}
+
+// toolName returns the name of the tool of a server the model sees. The names too long for the providers are cut,
+// with a hash of the whole name so the names sharing their beginning stay apart.
+func toolName(server string, tool string) string {
+	name := server + "__" + tool
+	valid := invalidToolNameCharacters.ReplaceAllString(name, "_")
+	if len(valid) <= maxToolNameLength {
+		return valid
+	}
+	hash := sha256.Sum256([]byte(name))
+	suffix := "_" + hex.EncodeToString(hash[:4])
+	return valid[:maxToolNameLength-len(suffix)] + suffix
+}
+
+func (manager *toolManager) Tools() []provider_models.ToolDefinition {
+	return manager.definitions
+}
+
+func (manager *toolManager) Describe(name string) (string, bool) {
+	tool, ok := manager.tools[name]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%s of MCP server %s", tool.name, tool.client.Name()), true
+}
+
+func (manager *toolManager) AutoApproved(name string) bool {
+	return manager.tools[name].autoApproved
+}
+
+// CallTool calls a tool and returns its text content, or the error the model should see
+func (manager *toolManager) CallTool(ctx context.Context, call provider_models.ToolCall) provider_models.ToolResult {
+	result := provider_models.ToolResult{CallID: call.ID, Name: call.Name}
+
+	tool, ok := manager.tools[call.Name]
+	if !ok {
+		result.Content, result.IsError = fmt.Sprintf("Unknown tool '%s'.", call.Name), true
+		return result
+	}
+
+	arguments := json.RawMessage(strings.TrimSpace(call.Arguments))
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	if !json.Valid(arguments) {
+		result.Content, result.IsError = "The arguments of the call are not valid JSON.", true
+		return result
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, toolCallTimeout)
+	defer cancel()
+	toolResult, err := tool.client.CallTool(callCtx, tool.name, arguments)
+	if err != nil {
+		result.Content, result.IsError = fmt.Sprintf("The call failed: %v", err), true
+		return result
+	}
+
+	result.Content, result.IsError = ToolResultText(toolResult), toolResult.IsError
+	return result
+}
+
+// ToolResultText joins the text content of a tool result, noting the content the model can't see as text
+func ToolResultText(result *models.CallToolResult) string {
+	var parts []string
+	for _, content := range result.Content {
+		if content.Type == "text" {
+			parts = append(parts, content.Text)
+		} else {
+			parts = append(parts, fmt.Sprintf("[%s content omitted]", content.Type))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (manager *toolManager) Close() {
+	for _, client := range manager.clients {
+		_ = client.Close()
+	}
+}
@@ -0,0 +1,240 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/meysamhadeli/codai/mcp/models"
+	output_models "github.com/meysamhadeli/codai/output/models"
+)
+
+// tool is a tool of the server with the function running it
+type tool struct {
+	definition models.Tool
+	run        func(ctx context.Context, arguments json.RawMessage) (*models.CallToolResult, error)
+}
+
+// editArguments are the arguments of the tools changing files: the changes, or an answer of an AI to extract them from
+type editArguments struct {
+	Changes []output_models.CodeChange `json:"changes"`
+	Text    string                     `json:"text"`
+}
+
+const editSchema = `{
+  "type": "object",
+  "properties": {
+    "changes": {
+      "type": "array",
+      "description": "The changes: the full new content of a file, a unified diff or SEARCH/REPLACE blocks. An empty code deletes the file.",
+      "items": {
+        "type": "object",
+        "properties": {
+          "path": {"type": "string", "description": "Path of the file relative to the project root"},
+          "code": {"type": "string"}
+        },
+        "required": ["path", "code"]
+      }
+    },
+    "text": {"type": "string", "description": "An answer in the edit format of codai to extract the changes from, when 'changes' is empty"}
+  }
+}`
+
+func (mcpServer *Server) newTools() []tool {
+	return []tool{
+		{
+			definition: models.Tool{
+				Name:        "project_summary",
+				Description: "Summarize the files of the project: their paths and sizes ('info'), their relevant code ('relevant') or their full code ('full').",
+				InputSchema: json.RawMessage(`{"type": "object", "properties": {"mode": {"type": "string", "enum": ["info", "relevant", "full"], "description": "The display mode, the configured mode by default"}}}`),
+			},
+			run: mcpServer.projectSummary,
+		},
+		{
+			definition: models.Tool{
+				Name:        "read_files",
+				Description: "Read the full content of files of the project.",
+				InputSchema: json.RawMessage(`{"type": "object", "properties": {"paths": {"type": "array", "items": {"type": "string"}, "description": "Paths relative to the project root"}}, "required": ["paths"]}`),
+			},
+			run: mcpServer.readFiles,
+		},
+		{
+			definition: models.Tool{
+				Name:        "propose_edit",
+				Description: "Preview changes of files without writing them: the result of every change, with syntax and path policy checks, and the unified diff of the accepted ones.",
+				InputSchema: json.RawMessage(editSchema),
+			},
+			run: func(ctx context.Context, arguments json.RawMessage) (*models.CallToolResult, error) {
+				return mcpServer.edit(arguments, true)
+			},
+		},
+		{
+			definition: models.Tool{
+				Name:        "apply_edit",
+				Description: "Write changes of files to the project in one transaction, skipping the changes rejected by the path policy or leaving a file unparsable. The changes can be undone with '/undo' in 'codai code'.",
+				InputSchema: json.RawMessage(editSchema),
+			},
+			run: func(ctx context.Context, arguments json.RawMessage) (*models.CallToolResult, error) {
+				return mcpServer.edit(arguments, false)
+			},
+		},
+	}
+}
+
+func (mcpServer *Server) listTools(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	result := models.ListToolsResult{Tools: []models.Tool{}}
+	for _, tool := range mcpServer.tools {
+		result.Tools = append(result.Tools, tool.definition)
+	}
+	return result, nil
+}
+
+func (mcpServer *Server) callTool(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var callParams models.CallToolParams
+	if err := decodeParams(params, &callParams); err != nil {
+		return nil, err
+	}
+
+	for _, tool := range mcpServer.tools {
+		if tool.definition.Name != callParams.Name {
+			continue
+		}
+		result, err := tool.run(ctx, callParams.Arguments)
+		if err != nil {
+			// The model sees the errors of the tools to correct its call
+			return toolError(err.Error()), nil
+		}
+		return result, nil
+	}
+	return nil, &models.Error{Code: models.CodeInvalidParams, Message: fmt.Sprintf("unknown tool '%s'", callParams.Name)}
+}
+
+func toolText(text string) *models.CallToolResult {
+	return &models.CallToolResult{Content: []models.Content{models.TextContent(text)}}
+}
+
+func toolError(message string) *models.CallToolResult {
+	return &models.CallToolResult{Content: []models.Content{models.TextContent(message)}, IsError: true}
+}
+
+// decodeArguments decodes the arguments of a tool
+func decodeArguments(arguments json.RawMessage, value interface{}) error {
+	if len(arguments) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(arguments, value); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	return nil
+}
+
+func (mcpServer *Server) projectSummary(ctx context.Context, arguments json.RawMessage) (*models.CallToolResult, error) {
+	var summaryArguments struct {
+		Mode string `json:"mode"`
+	}
+	if err := decodeArguments(arguments, &summaryArguments); err != nil {
+		return nil, err
+	}
+
+	projectContext, err := mcpServer.api.ProjectContext(summaryArguments.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if len(projectContext.Files) == 0 {
+		return toolText("The project has no files."), nil
+	}
+
+	var codes []string
+	for _, file := range projectContext.Files {
+		codes = append(codes, file.Content)
+	}
+	return toolText(strings.Join(codes, "\n\n")), nil
+}
+
+// readFiles reads the files of the project like the files the AI asks for in 'code'. Only the files of the project
+// are read, not the ignored files or the files outside of it.
+func (mcpServer *Server) readFiles(ctx context.Context, arguments json.RawMessage) (*models.CallToolResult, error) {
+	var readArguments struct {
+		Paths []string `json:"paths"`
+	}
+	if err := decodeArguments(arguments, &readArguments); err != nil {
+		return nil, err
+	}
+	if len(readArguments.Paths) == 0 {
+		return nil, fmt.Errorf("no paths to read")
+	}
+
+	projectContext, err := mcpServer.api.ProjectContext("info")
+	if err != nil {
+		return nil, err
+	}
+	projectFiles := make(map[string]bool)
+	for _, file := range projectContext.Files {
+		projectFiles[filepath.ToSlash(file.Path)] = true
+	}
+
+	var paths, unknown []string
+	for _, path := range readArguments.Paths {
+		path = filepath.ToSlash(filepath.Clean(strings.TrimSpace(path)))
+		if projectFiles[path] {
+			paths = append(paths, path)
+		} else {
+			unknown = append(unknown, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("the files are not in the project: %s", strings.Join(unknown, ", "))
+	}
+
+	request, err := json.Marshal(map[string][]string{"files": paths})
+	if err != nil {
+		return nil, err
+	}
+	codes, err := mcpServer.dependencies.Analyzer.TryGetInCompletedCodeBlocK(string(request))
+	if err != nil {
+		return nil, err
+	}
+	if len(unknown) > 0 {
+		codes += fmt.Sprintf("\n---------\n\nThese files are not in the project: %s", strings.Join(unknown, ", "))
+	}
+	return toolText(codes), nil
+}
+
+// edit previews or applies the changes of a tool call. The result is the JSON of the results and of the patch, and
+// an error when a change was rejected or failed.
+func (mcpServer *Server) edit(arguments json.RawMessage, dryRun bool) (*models.CallToolResult, error) {
+	var editArguments editArguments
+	if err := decodeArguments(arguments, &editArguments); err != nil {
+		return nil, err
+	}
+
+	changes := editArguments.Changes
+	if len(changes) == 0 && strings.TrimSpace(editArguments.Text) != "" {
+		extracted, err := mcpServer.api.ExtractChanges(editArguments.Text, "")
+		if err != nil {
+			return nil, err
+		}
+		if changes = extracted; len(changes) == 0 {
+			return nil, fmt.Errorf("no code changes found in the text")
+		}
+	}
+
+	response, err := mcpServer.api.ApplyChanges(changes, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	result := toolText(string(data))
+	for _, changeResult := range response.Results {
+		if changeResult.Status == output_models.ChangeFailed || changeResult.Status == output_models.ChangeRejected {
+			result.IsError = true
+		}
+	}
+	return result, nil
+}
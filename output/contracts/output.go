@@ -0,0 +1,36 @@
+package contracts
+
+import (
+	"context"
+
+	analyzer_models "github.com/meysamhadeli/codai/code_analyzer/models"
+	"github.com/meysamhadeli/codai/output/models"
+	"github.com/meysamhadeli/codai/patch"
+)
+
+// IOutput renders what codai shows to the user, as styled text for the terminal or as structured events
+type IOutput interface {
+	// Structured reports whether the output is structured events rather than text
+	Structured() bool
+	Chunk(ctx context.Context, content string) error
+	// Progress shows a long running operation until the returned function is called
+	Progress(text string) func()
+	// Thinking shows that the AI is working on a request until the returned function is called
+	Thinking(text string) func()
+	Info(message string)
+	Success(message string)
+	Warning(message string)
+	Error(message string)
+	Title(text string)
+	Box(text string)
+	Text(text string)
+	CodeChanges(changes []analyzer_models.CodeChange)
+	DiffHeader(path string, hunks []patch.Hunk)
+	Hunk(path string, index int, total int, hunk patch.Hunk)
+	ChangeResult(path string, status models.ChangeStatus, message string)
+	TokenUsage(usage models.TokenUsage)
+	// Prompt announces a question whose answer is read from stdin
+	Prompt(kind string, message string, options []string)
+	// Close writes what is still buffered
+	Close() error
+}
@@ -0,0 +1,164 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	analyzer_models "github.com/meysamhadeli/codai/code_analyzer/models"
+	"github.com/meysamhadeli/codai/constants/lipgloss"
+	"github.com/meysamhadeli/codai/output/contracts"
+	"github.com/meysamhadeli/codai/output/models"
+	"github.com/meysamhadeli/codai/patch"
+	"github.com/meysamhadeli/codai/utils"
+	"github.com/pterm/pterm"
+)
+
+// humanOutput renders the output as styled text for the terminal
+type humanOutput struct {
+	writer io.Writer
+	theme  string
+}
+
+// NewHumanOutput creates the output rendering styled text to writer, highlighting the code of the AI responses with theme
+func NewHumanOutput(writer io.Writer, theme string) contracts.IOutput {
+	return &humanOutput{writer: writer, theme: theme}
+}
+
+func (output *humanOutput) Structured() bool {
+	return false
+}
+
+func (output *humanOutput) Chunk(ctx context.Context, content string) error {
+	language := utils.DetectLanguageFromCodeBlock(content)
+	return utils.RenderAndPrintMarkdownWithContext(ctx, output.writer, content, language, output.theme)
+}
+
+// spinnerVisible reports whether the spinners, which pterm writes to stderr, are watched in a terminal. In a pipe
+// or a CI log every frame would be printed on its own line.
+func spinnerVisible() bool {
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (output *humanOutput) Progress(text string) func() {
+	if !spinnerVisible() {
+		return func() {}
+	}
+	spinner := pterm.DefaultSpinner.WithStyle(pterm.NewStyle(pterm.FgLightBlue)).WithSequence("⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏").WithDelay(100).WithRemoveWhenDone(true)
+	spinnerProgress, _ := spinner.Start(text)
+	return func() {
+		spinnerProgress.Stop()
+		fmt.Fprint(output.writer, "\r")
+	}
+}
+
+func (output *humanOutput) Thinking(text string) func() {
+	if !spinnerVisible() {
+		return func() {}
+	}
+	aiSpinner := pterm.DefaultSpinner.
+		WithStyle(pterm.NewStyle(pterm.FgCyan)).
+		WithSequence("🤔", "🧠", "💭", "✨", "🚀", "💡").
+		WithDelay(1000).
+		WithRemoveWhenDone(true)
+	spinnerAI, _ := aiSpinner.Start(text)
+	return func() {
+		spinnerAI.Stop()
+	}
+}
+
+func (output *humanOutput) Info(message string) {
+	fmt.Fprintln(output.writer, lipgloss.Gray.Render(message))
+}
+
+func (output *humanOutput) Success(message string) {
+	fmt.Fprintln(output.writer, lipgloss.Green.Render(message))
+}
+
+func (output *humanOutput) Warning(message string) {
+	fmt.Fprintln(output.writer, lipgloss.Yellow.Render(message))
+}
+
+func (output *humanOutput) Error(message string) {
+	fmt.Fprintln(output.writer, lipgloss.Red.Render(message))
+}
+
+func (output *humanOutput) Title(text string) {
+	fmt.Fprintln(output.writer, lipgloss.BlueSky.Render(text))
+}
+
+func (output *humanOutput) Box(text string) {
+	fmt.Fprintln(output.writer, lipgloss.BoxStyle.Render(text))
+}
+
+func (output *humanOutput) Text(text string) {
+	fmt.Fprintln(output.writer, text)
+}
+
+// CodeChanges renders nothing, the changes are part of the rendered AI response
+func (output *humanOutput) CodeChanges(changes []analyzer_models.CodeChange) {}
+
+// DiffHeader prints the file path of a reviewed change with its number of added and removed lines
+func (output *humanOutput) DiffHeader(path string, hunks []patch.Hunk) {
+	added, removed := diffStats(hunks)
+
+	fmt.Fprint(output.writer, "\r")
+	fmt.Fprintf(output.writer, "%s %s %s\n", lipgloss.LightBlueB.Render(path), lipgloss.Green.Render(fmt.Sprintf("+%d", added)), lipgloss.Red.Render(fmt.Sprintf("-%d", removed)))
+}
+
+// Hunk prints a hunk with added lines in green and removed lines in red
+func (output *humanOutput) Hunk(path string, index int, total int, hunk patch.Hunk) {
+	var builder strings.Builder
+	builder.WriteString(lipgloss.Info.Render(hunk.Header()) + "\n")
+	for _, line := range hunk.Lines {
+		switch line.Kind {
+		case patch.Addition:
+			builder.WriteString(lipgloss.Green.Render("+"+line.Text) + "\n")
+		case patch.Deletion:
+			builder.WriteString(lipgloss.Red.Render("-"+line.Text) + "\n")
+		default:
+			builder.WriteString(" " + line.Text + "\n")
+		}
+	}
+	fmt.Fprint(output.writer, builder.String())
+}
+
+func (output *humanOutput) ChangeResult(path string, status models.ChangeStatus, message string) {
+	switch status {
+	case models.ChangeAccepted, models.ChangeApplied:
+		output.Success(message)
+	case models.ChangeUnchanged:
+		output.Info(message)
+	default:
+		output.Error(message)
+	}
+}
+
+func (output *humanOutput) TokenUsage(usage models.TokenUsage) {
+	switch usage.Scope {
+	case models.ScopeRequest:
+		line := fmt.Sprintf("\r[Tokens: +%d input / +%d output = +%d total]  ", usage.InputTokens, usage.OutputTokens, usage.TotalTokens)
+		if usage.Cost > 0 {
+			line += fmt.Sprintf("[Cost: +$%.6f]  ", usage.Cost)
+		}
+		fmt.Fprintf(output.writer, "\n%s\n", line)
+	case models.ScopeLive:
+		fmt.Fprintf(output.writer, "\rToken Used: %d - Cost: $%.6f - Model: %s", usage.TotalTokens, usage.Cost, usage.Model)
+	default:
+		tokenInfo := fmt.Sprintf("Token Used: %d - Cost: %.6f $ - Chat Model: %s", usage.TotalTokens, usage.Cost, usage.Model)
+		if usage.FixIterations > 0 {
+			tokenInfo += fmt.Sprintf("\nVerify Fixes: %d - Token Used: %d - Cost: %.6f $", usage.FixIterations, usage.FixTokens, usage.FixCost)
+		}
+		output.Box(tokenInfo)
+	}
+}
+
+// Prompt renders nothing, the prompts of utils show their question themselves
+func (output *humanOutput) Prompt(kind string, message string, options []string) {}
+
+func (output *humanOutput) Close() error {
+	return nil
+}
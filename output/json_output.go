@@ -0,0 +1,144 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+
+	analyzer_models "github.com/meysamhadeli/codai/code_analyzer/models"
+	"github.com/meysamhadeli/codai/output/contracts"
+	"github.com/meysamhadeli/codai/output/models"
+	"github.com/meysamhadeli/codai/patch"
+)
+
+// jsonOutput writes the output as structured events, for CI and editor plugins
+type jsonOutput struct {
+	mu     sync.Mutex
+	writer io.Writer
+	ndjson bool
+	events []models.Event
+}
+
+// NewJSONOutput creates the output writing events to writer, one JSON object per line as they happen when ndjson
+// is set, and otherwise one JSON array of all the events when it is closed
+func NewJSONOutput(writer io.Writer, ndjson bool) contracts.IOutput {
+	return &jsonOutput{writer: writer, ndjson: ndjson, events: []models.Event{}}
+}
+
+// emit writes or buffers an event. Providers report their token usage from their own goroutine, so it is synchronized.
+func (output *jsonOutput) emit(event models.Event) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	if !output.ndjson {
+		output.events = append(output.events, event)
+		return
+	}
+	_ = json.NewEncoder(output.writer).Encode(event)
+}
+
+// message emits a status message, without the blank lines and the carriage returns used for spacing in the terminal
+func (output *jsonOutput) message(level models.MessageLevel, message string) {
+	output.emit(models.Event{Type: models.EventMessage, Level: level, Message: strings.TrimSpace(message)})
+}
+
+func (output *jsonOutput) Structured() bool {
+	return true
+}
+
+func (output *jsonOutput) Chunk(ctx context.Context, content string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	output.emit(models.Event{Type: models.EventChunk, Content: content})
+	return nil
+}
+
+func (output *jsonOutput) Progress(text string) func() {
+	output.message(models.LevelProgress, text)
+	return func() {}
+}
+
+func (output *jsonOutput) Thinking(text string) func() {
+	output.message(models.LevelProgress, text)
+	return func() {}
+}
+
+func (output *jsonOutput) Info(message string) {
+	output.message(models.LevelInfo, message)
+}
+
+func (output *jsonOutput) Success(message string) {
+	output.message(models.LevelSuccess, message)
+}
+
+func (output *jsonOutput) Warning(message string) {
+	output.message(models.LevelWarning, message)
+}
+
+func (output *jsonOutput) Error(message string) {
+	output.message(models.LevelError, message)
+}
+
+func (output *jsonOutput) Title(text string) {
+	output.message(models.LevelInfo, text)
+}
+
+func (output *jsonOutput) Box(text string) {
+	output.message(models.LevelInfo, text)
+}
+
+// Text emits the text as an info message, skipping the blank lines used for spacing in the terminal
+func (output *jsonOutput) Text(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	output.message(models.LevelInfo, text)
+}
+
+func (output *jsonOutput) CodeChanges(changes []analyzer_models.CodeChange) {
+	event := models.Event{Type: models.EventCodeChanges}
+	for _, change := range changes {
+		event.Changes = append(event.Changes, models.CodeChange{Path: change.RelativePath, Code: change.Code})
+	}
+	output.emit(event)
+}
+
+func (output *jsonOutput) DiffHeader(path string, hunks []patch.Hunk) {
+	added, removed := diffStats(hunks)
+	output.emit(models.Event{Type: models.EventDiff, Path: path, Diff: &models.DiffSummary{Added: added, Removed: removed, Hunks: len(hunks)}})
+}
+
+func (output *jsonOutput) Hunk(path string, index int, total int, hunk patch.Hunk) {
+	output.emit(models.Event{Type: models.EventHunk, Path: path, Hunk: &models.Hunk{Index: index, Total: total, Diff: unifiedHunk(hunk)}})
+}
+
+func (output *jsonOutput) ChangeResult(path string, status models.ChangeStatus, message string) {
+	output.emit(models.Event{Type: models.EventChangeResult, Path: path, Status: status, Message: message})
+}
+
+func (output *jsonOutput) TokenUsage(usage models.TokenUsage) {
+	output.emit(models.Event{Type: models.EventTokenUsage, Usage: &usage})
+}
+
+func (output *jsonOutput) Prompt(kind string, message string, options []string) {
+	output.emit(models.Event{Type: models.EventPrompt, Prompt: &models.Prompt{Kind: kind, Message: message, Options: options}})
+}
+
+// Close writes the buffered events as one JSON array, which is empty when nothing happened
+func (output *jsonOutput) Close() error {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	if output.ndjson || output.events == nil {
+		return nil
+	}
+	events := output.events
+	output.events = nil
+
+	encoder := json.NewEncoder(output.writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(events)
+}
@@ -0,0 +1,76 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	analyzer_models "github.com/meysamhadeli/codai/code_analyzer/models"
+	"github.com/meysamhadeli/codai/output/models"
+	"github.com/meysamhadeli/codai/patch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONOutput_NDJSON(t *testing.T) {
+	var buffer bytes.Buffer
+	out := NewJSONOutput(&buffer, true)
+
+	assert.NoError(t, out.Chunk(context.Background(), "Hello"))
+	out.Text("\n")
+	out.Warning("\n🔄 Exiting...")
+	out.CodeChanges([]analyzer_models.CodeChange{{RelativePath: "main.go", Code: "package main\n"}})
+	out.ChangeResult("main.go", models.ChangeApplied, "✔️ Applied changes to main.go.")
+	out.TokenUsage(models.TokenUsage{Scope: models.ScopeRequest, InputTokens: 10, OutputTokens: 5, TotalTokens: 15})
+	assert.NoError(t, out.Close())
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Len(t, lines, 5)
+
+	var events []models.Event
+	for _, line := range lines {
+		var event models.Event
+		assert.NoError(t, json.Unmarshal([]byte(line), &event))
+		events = append(events, event)
+	}
+
+	assert.Equal(t, models.EventChunk, events[0].Type)
+	assert.Equal(t, "Hello", events[0].Content)
+	assert.Equal(t, models.LevelWarning, events[1].Level)
+	assert.Equal(t, "🔄 Exiting...", events[1].Message)
+	assert.Equal(t, []models.CodeChange{{Path: "main.go", Code: "package main\n"}}, events[2].Changes)
+	assert.Equal(t, models.ChangeApplied, events[3].Status)
+	assert.Equal(t, "main.go", events[3].Path)
+	assert.Equal(t, 15, events[4].Usage.TotalTokens)
+}
+
+func TestJSONOutput_Array(t *testing.T) {
+	var buffer bytes.Buffer
+	out := NewJSONOutput(&buffer, false)
+
+	hunks := patch.Diff("a\nb\n", "a\nc\n", 3)
+	out.DiffHeader("file.txt", hunks)
+	out.Hunk("file.txt", 1, len(hunks), hunks[0])
+	assert.Empty(t, buffer.String(), "events are written when the output is closed")
+
+	assert.NoError(t, out.Close())
+
+	var events []models.Event
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &events))
+	assert.Len(t, events, 2)
+	assert.Equal(t, &models.DiffSummary{Added: 1, Removed: 1, Hunks: 1}, events[0].Diff)
+	assert.Contains(t, events[1].Hunk.Diff, "-b\n+c\n")
+}
+
+func TestJSONOutput_EmptyArray(t *testing.T) {
+	var buffer bytes.Buffer
+	out := NewJSONOutput(&buffer, false)
+	assert.NoError(t, out.Close())
+	assert.Equal(t, "[]\n", buffer.String())
+}
+
+func TestNewOutput_UnknownFormat(t *testing.T) {
+	_, err := NewOutput("xml", &bytes.Buffer{}, &bytes.Buffer{}, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package models
+
+// EventType is the type of a structured output event
+type EventType string
+
+const (
+	// EventChunk is a streamed chunk of the AI response
+	EventChunk EventType = "chunk"
+	// EventMessage is a status message, with its level
+	EventMessage EventType = "message"
+	// EventCodeChanges are the code changes extracted from the AI response
+	EventCodeChanges EventType = "code_changes"
+	// EventDiff is the diff summary of a change under review, followed by its hunks
+	EventDiff EventType = "diff"
+	// EventHunk is one hunk of a change under review
+	EventHunk EventType = "hunk"
+	// EventChangeResult is the result of the review or the application of a change
+	EventChangeResult EventType = "change_result"
+	// EventTokenUsage is the token usage and cost of a request or of the session
+	EventTokenUsage EventType = "token_usage"
+	// EventPrompt is emitted when codai waits for an answer on stdin
+	EventPrompt EventType = "prompt"
+)
+
+// MessageLevel is the level of a status message
+type MessageLevel string
+
+const (
+	LevelProgress MessageLevel = "progress"
+	LevelInfo     MessageLevel = "info"
+	LevelSuccess  MessageLevel = "success"
+	LevelWarning  MessageLevel = "warning"
+	LevelError    MessageLevel = "error"
+)
+
+// ChangeStatus is the result of a reviewed change
+type ChangeStatus string
+
+const (
+	// ChangeAccepted is a change accepted in review, written with the other accepted changes
+	ChangeAccepted ChangeStatus = "accepted"
+	// ChangeRejected is a change rejected in review or by the path policy
+	ChangeRejected ChangeStatus = "rejected"
+	// ChangeUnchanged is a change leaving the file as it is
+	ChangeUnchanged ChangeStatus = "unchanged"
+	// ChangeApplied is a change written to disk
+	ChangeApplied ChangeStatus = "applied"
+	// ChangeFailed is a change that could not be previewed or written
+	ChangeFailed ChangeStatus = "failed"
+)
+
+// Event is one structured output event. Only the fields of its type are set.
+type Event struct {
+	Type    EventType    `json:"type"`
+	Level   MessageLevel `json:"level,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Content string       `json:"content,omitempty"`
+	Path    string       `json:"path,omitempty"`
+	Status  ChangeStatus `json:"status,omitempty"`
+	Changes []CodeChange `json:"changes,omitempty"`
+	Diff    *DiffSummary `json:"diff,omitempty"`
+	Hunk    *Hunk        `json:"hunk,omitempty"`
+	Usage   *TokenUsage  `json:"usage,omitempty"`
+	Prompt  *Prompt      `json:"prompt,omitempty"`
+}
+
+// CodeChange is a file change extracted from the AI response
+type CodeChange struct {
+	Path string `json:"path"`
+	Code string `json:"code"`
+}
+
+// DiffSummary counts the changed lines of a file
+type DiffSummary struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Hunks   int `json:"hunks"`
+}
+
+// Hunk is a hunk of a change in unified diff format
+type Hunk struct {
+	Index int    `json:"index"`
+	Total int    `json:"total"`
+	Diff  string `json:"diff"`
+}
+
+// TokenUsageScope tells what a token usage covers
+type TokenUsageScope string
+
+const (
+	// ScopeRequest is the usage of one AI request
+	ScopeRequest TokenUsageScope = "request"
+	// ScopeSession is the usage of the whole session
+	ScopeSession TokenUsageScope = "session"
+	// ScopeLive is the usage of the session while a request is running
+	ScopeLive TokenUsageScope = "live"
+)
+
+// TokenUsage is the token usage and cost of a request or of the session
+type TokenUsage struct {
+	Scope        TokenUsageScope `json:"scope"`
+	Provider     string          `json:"provider"`
+	Model        string          `json:"model"`
+	InputTokens  int             `json:"input_tokens"`
+	OutputTokens int             `json:"output_tokens"`
+	TotalTokens  int             `json:"total_tokens"`
+	Cost         float64         `json:"cost"`
+
+	// The part of the session usage spent on fixing failed verify commands
+	FixIterations int     `json:"fix_iterations,omitempty"`
+	FixTokens     int     `json:"fix_tokens,omitempty"`
+	FixCost       float64 `json:"fix_cost,omitempty"`
+}
+
+// Prompt is a question waiting for an answer on stdin
+type Prompt struct {
+	Kind    string   `json:"kind"`
+	Message string   `json:"message"`
+	Options []string `json:"options,omitempty"`
+}
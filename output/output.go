@@ -0,0 +1,65 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/meysamhadeli/codai/output/contracts"
+	"github.com/meysamhadeli/codai/patch"
+)
+
+// The supported output formats
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// NewOutput creates the output of a format: styled text written to textWriter, or structured events written to
+// eventWriter as one JSON array when the command ends ('json') or as one JSON object per line ('ndjson')
+func NewOutput(format string, textWriter io.Writer, eventWriter io.Writer, theme string) (contracts.IOutput, error) {
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return NewHumanOutput(textWriter, theme), nil
+	case FormatJSON:
+		return NewJSONOutput(eventWriter, false), nil
+	case FormatNDJSON:
+		return NewJSONOutput(eventWriter, true), nil
+	default:
+		return nil, fmt.Errorf("unknown output format '%s', supported formats are: text, json, ndjson", format)
+	}
+}
+
+// diffStats counts the added and removed lines of hunks
+func diffStats(hunks []patch.Hunk) (int, int) {
+	added, removed := 0, 0
+	for _, hunk := range hunks {
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case patch.Addition:
+				added++
+			case patch.Deletion:
+				removed++
+			}
+		}
+	}
+	return added, removed
+}
+
+// unifiedHunk formats a hunk in unified diff format
+func unifiedHunk(hunk patch.Hunk) string {
+	var builder strings.Builder
+	builder.WriteString(hunk.Header() + "\n")
+	for _, line := range hunk.Lines {
+		switch line.Kind {
+		case patch.Addition:
+			builder.WriteString("+" + line.Text + "\n")
+		case patch.Deletion:
+			builder.WriteString("-" + line.Text + "\n")
+		default:
+			builder.WriteString(" " + line.Text + "\n")
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,358 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxFuzz is the maximum number of leading and trailing context lines that may be ignored when locating a hunk.
+const MaxFuzz = 2
+
+// matchMode controls how strictly hunk lines are compared with file lines.
+type matchMode int
+
+const (
+	matchExact matchMode = iota
+	matchIgnoreTrailingSpace
+	matchIgnoreSpace
+)
+
+// HunkResult describes where a hunk was applied or why it was rejected.
+type HunkResult struct {
+	Index  int // 0-based index of the hunk in the patch
+	Header string
+	Line   int   // 1-based line in the working copy where the hunk matched
+	Offset int   // distance in lines between the expected and the actual position
+	Fuzz   int   // number of context lines ignored on each side
+	Err    error // non-nil when the hunk was rejected
+}
+
+// Result is the outcome of applying a set of hunks to a file.
+type Result struct {
+	Content string
+	Hunks   []HunkResult
+}
+
+// Rejected returns the results of the hunks that failed to apply.
+func (result *Result) Rejected() []HunkResult {
+	var rejected []HunkResult
+	for _, hunk := range result.Hunks {
+		if hunk.Err != nil {
+			rejected = append(rejected, hunk)
+		}
+	}
+	return rejected
+}
+
+// RejectError is returned when one or more hunks could not be applied.
+type RejectError struct {
+	Total    int
+	Rejected []HunkResult
+	Unit     string // "hunks" unless set otherwise
+}
+
+func (e *RejectError) Error() string {
+	unit := e.Unit
+	if unit == "" {
+		unit = "hunks"
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("%d of %d %s failed to apply", len(e.Rejected), e.Total, unit))
+	for _, rejected := range e.Rejected {
+		builder.WriteString(fmt.Sprintf("\n  %s #%d %s: %v", strings.TrimSuffix(unit, "s"), rejected.Index+1, rejected.Header, rejected.Err))
+	}
+	return builder.String()
+}
+
+// Apply applies the hunks to the original content. Each hunk is located near its expected position,
+// tolerating line offsets, whitespace differences and up to MaxFuzz missing context lines.
+// When any hunk is rejected the returned error is a *RejectError and Result.Content is the unchanged original.
+func Apply(original string, hunks []Hunk) (*Result, error) {
+	lines, lineEnding, trailingNewline := splitLines(original)
+	if original == "" && len(hunks) > 0 {
+		trailingNewline = !hunks[len(hunks)-1].NoNewlineAtEOF
+	}
+
+	result := &Result{Content: original}
+	delta := 0
+
+	for i, hunk := range hunks {
+		hunkResult := HunkResult{Index: i, Header: hunk.Header()}
+
+		expected := hunk.OldStart - 1 + delta
+		if hunk.OldStart == 0 {
+			expected = 0
+		}
+
+		updated, position, fuzz, err := applyHunk(lines, hunk, expected)
+		if err != nil {
+			hunkResult.Err = err
+			result.Hunks = append(result.Hunks, hunkResult)
+			continue
+		}
+
+		hunkResult.Line = position + 1
+		hunkResult.Offset = position - expected
+		hunkResult.Fuzz = fuzz
+		result.Hunks = append(result.Hunks, hunkResult)
+
+		delta += len(updated) - len(lines)
+		lines = updated
+	}
+
+	if rejected := result.Rejected(); len(rejected) > 0 {
+		return result, &RejectError{Total: len(hunks), Rejected: rejected}
+	}
+
+	content := strings.Join(lines, lineEnding)
+	if trailingNewline && len(lines) > 0 {
+		content += lineEnding
+	}
+	result.Content = content
+
+	return result, nil
+}
+
+// applyHunk locates the hunk in lines and returns the updated lines, the matched position and the fuzz used.
+func applyHunk(lines []string, hunk Hunk, expected int) ([]string, int, int, error) {
+	if len(hunk.OldLinesText()) == 0 {
+		// Pure additions have nothing to match, so insert at the expected position (or the end for unknown positions)
+		position := expected
+		if hunk.OldStart == 0 && hunk.NewStart == 0 {
+			position = len(lines)
+		} else if hunk.OldStart > 0 && hunk.OldLines == 0 {
+			// "@@ -5,0 +6,2 @@" inserts after line 5
+			position++
+		}
+		position = clamp(position, 0, len(lines))
+		return splice(lines, position, 0, hunk.NewLinesText()), position, 0, nil
+	}
+
+	for fuzz := 0; fuzz <= MaxFuzz; fuzz++ {
+		trimmed, skipped, ok := trimContext(hunk.Lines, fuzz)
+		if !ok {
+			break
+		}
+		for _, mode := range []matchMode{matchExact, matchIgnoreTrailingSpace, matchIgnoreSpace} {
+			position, found := locate(lines, trimmed, expected+skipped, mode)
+			if !found {
+				continue
+			}
+			replacement := buildReplacement(lines[position:], trimmed, mode)
+			oldCount := countOld(trimmed)
+			return splice(lines, position, oldCount, replacement), position, fuzz, nil
+		}
+	}
+
+	return nil, 0, 0, fmt.Errorf("context not found")
+}
+
+// trimContext drops up to fuzz context lines from each end of the hunk, never touching changed lines.
+// It also returns how many leading lines were dropped.
+func trimContext(hunkLines []Line, fuzz int) ([]Line, int, bool) {
+	if fuzz == 0 {
+		return hunkLines, 0, true
+	}
+
+	start, end := 0, len(hunkLines)
+	for i := 0; i < fuzz && start < end && hunkLines[start].Kind == Context; i++ {
+		start++
+	}
+	for i := 0; i < fuzz && end > start && hunkLines[end-1].Kind == Context; i++ {
+		end--
+	}
+
+	trimmed := hunkLines[start:end]
+	if len(trimmed) == len(hunkLines) || countOld(trimmed) == 0 {
+		// Nothing left to trim, or nothing left to anchor the hunk on
+		return nil, 0, false
+	}
+	return trimmed, start, true
+}
+
+// locate searches for the old side of the hunk, starting at the expected position and moving outwards.
+func locate(lines []string, hunkLines []Line, expected int, mode matchMode) (int, bool) {
+	var old []string
+	for _, line := range hunkLines {
+		if line.Kind != Addition {
+			old = append(old, line.Text)
+		}
+	}
+
+	last := len(lines) - len(old)
+	if last < 0 {
+		return 0, false
+	}
+	expected = clamp(expected, 0, last)
+
+	for distance := 0; distance <= last; distance++ {
+		if candidate := expected - distance; candidate >= 0 && matchesAt(lines, old, candidate, mode) {
+			return candidate, true
+		}
+		if candidate := expected + distance; distance > 0 && candidate <= last && matchesAt(lines, old, candidate, mode) {
+			return candidate, true
+		}
+	}
+
+	return 0, false
+}
+
+func matchesAt(lines []string, old []string, position int, mode matchMode) bool {
+	for i, expectedLine := range old {
+		if !linesEqual(lines[position+i], expectedLine, mode) {
+			return false
+		}
+	}
+	return true
+}
+
+func linesEqual(a, b string, mode matchMode) bool {
+	switch mode {
+	case matchIgnoreTrailingSpace:
+		return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t")
+	case matchIgnoreSpace:
+		return strings.TrimSpace(a) == strings.TrimSpace(b)
+	default:
+		return a == b
+	}
+}
+
+// buildReplacement produces the new lines for a matched hunk. Context lines keep the file's own text, and when
+// the match ignored indentation, added lines are re-indented to follow the file.
+func buildReplacement(fileLines []string, hunkLines []Line, mode matchMode) []string {
+	var indents indentMap
+	if mode == matchIgnoreSpace {
+		indents = newIndentMap(fileLines, hunkLines)
+	}
+
+	var replacement []string
+	position := 0
+
+	for _, line := range hunkLines {
+		switch line.Kind {
+		case Context:
+			replacement = append(replacement, fileLines[position])
+			position++
+		case Deletion:
+			position++
+		case Addition:
+			text := line.Text
+			if indents != nil && strings.TrimSpace(text) != "" {
+				text = indents.translate(leadingSpace(text)) + strings.TrimLeft(text, " \t")
+			}
+			replacement = append(replacement, text)
+		}
+	}
+
+	return replacement
+}
+
+// indentMap translates the indentation used by a hunk into the indentation used by the file,
+// learned from the lines that matched.
+type indentMap map[string]string
+
+func newIndentMap(fileLines []string, hunkLines []Line) indentMap {
+	indents := make(indentMap)
+	position := 0
+	for _, line := range hunkLines {
+		if line.Kind == Addition {
+			continue
+		}
+		if strings.TrimSpace(line.Text) != "" {
+			if _, exists := indents[leadingSpace(line.Text)]; !exists {
+				indents[leadingSpace(line.Text)] = leadingSpace(fileLines[position])
+			}
+		}
+		position++
+	}
+	return indents
+}
+
+// translate maps a hunk indentation to the file indentation. Unknown depths reuse the closest known shallower
+// depth and convert the remaining indentation with the unit inferred from two known depths.
+func (indents indentMap) translate(indent string) string {
+	if mapped, exists := indents[indent]; exists {
+		return mapped
+	}
+
+	base := ""
+	for known := range indents {
+		if strings.HasPrefix(indent, known) && len(known) > len(base) {
+			base = known
+		}
+	}
+	extra := indent[len(base):]
+
+	hunkUnit, fileUnit := indents.unit()
+	if hunkUnit != "" && len(extra)%len(hunkUnit) == 0 && extra == strings.Repeat(hunkUnit, len(extra)/len(hunkUnit)) {
+		extra = strings.Repeat(fileUnit, len(extra)/len(hunkUnit))
+	}
+
+	return indents[base] + extra
+}
+
+// unit infers one indentation level in the hunk and in the file from two nested known depths.
+func (indents indentMap) unit() (string, string) {
+	for hunkOuter, fileOuter := range indents {
+		for hunkInner, fileInner := range indents {
+			if len(hunkInner) > len(hunkOuter) && strings.HasPrefix(hunkInner, hunkOuter) && len(fileInner) > len(fileOuter) && strings.HasPrefix(fileInner, fileOuter) {
+				hunkExtra, fileExtra := hunkInner[len(hunkOuter):], fileInner[len(fileOuter):]
+				// Only trust a single level difference, so the unit is not a multiple of the real one
+				if len(hunkExtra) <= 4 && len(fileExtra) <= 4 {
+					return hunkExtra, fileExtra
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+func countOld(hunkLines []Line) int {
+	count := 0
+	for _, line := range hunkLines {
+		if line.Kind != Addition {
+			count++
+		}
+	}
+	return count
+}
+
+func splice(lines []string, position int, remove int, insert []string) []string {
+	updated := make([]string, 0, len(lines)-remove+len(insert))
+	updated = append(updated, lines[:position]...)
+	updated = append(updated, insert...)
+	updated = append(updated, lines[position+remove:]...)
+	return updated
+}
+
+// splitLines splits content into lines, reporting the line ending in use and whether the content ends with one.
+func splitLines(content string) ([]string, string, bool) {
+	if content == "" {
+		return []string{}, "\n", false
+	}
+
+	lineEnding := "\n"
+	if strings.Contains(content, "\r\n") {
+		lineEnding = "\r\n"
+	}
+
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(strings.ReplaceAll(content, "\r\n", "\n"), "\n"), "\n")
+
+	return lines, lineEnding, trailingNewline
+}
+
+func leadingSpace(text string) string {
+	return text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+}
+
+func clamp(value, low, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
@@ -0,0 +1,239 @@
+package patch
+
+import (
+	"strings"
+)
+
+// editOp is one step of a line edit script. OldIndex and NewIndex are the positions in the old and new lines;
+// for an addition OldIndex is where the line is inserted, for a deletion NewIndex is where the line was.
+type editOp struct {
+	kind     LineKind
+	oldIndex int
+	newIndex int
+}
+
+// Diff compares two texts line by line and returns their differences as unified diff hunks surrounded by up to
+// contextLines unchanged lines. Line endings are part of the comparison, so a changed line ending is a change too.
+func Diff(before, after string, contextLines int) []Hunk {
+	oldLines := splitKeepingNewlines(before)
+	newLines := splitKeepingNewlines(after)
+
+	return groupHunks(diffLines(oldLines, newLines), oldLines, newLines, contextLines)
+}
+
+// Merge rebuilds the text resulting from applying only the accepted hunks, which must come from Diff(before, after).
+func Merge(before, after string, hunks []Hunk, accepted []bool) string {
+	oldLines := splitKeepingNewlines(before)
+	newLines := splitKeepingNewlines(after)
+
+	var builder strings.Builder
+	cursor := 0
+	for i, hunk := range hunks {
+		oldIndex := startIndex(hunk.OldStart, hunk.OldLines)
+		newIndex := startIndex(hunk.NewStart, hunk.NewLines)
+
+		builder.WriteString(strings.Join(oldLines[cursor:oldIndex], ""))
+		if i < len(accepted) && accepted[i] {
+			builder.WriteString(strings.Join(newLines[newIndex:newIndex+hunk.NewLines], ""))
+		} else {
+			builder.WriteString(strings.Join(oldLines[oldIndex:oldIndex+hunk.OldLines], ""))
+		}
+		cursor = oldIndex + hunk.OldLines
+	}
+	builder.WriteString(strings.Join(oldLines[cursor:], ""))
+
+	return builder.String()
+}
+
+// startIndex converts a unified diff start line into a zero based index; an empty range starts after the line.
+func startIndex(start, count int) int {
+	if count == 0 {
+		return start
+	}
+	return start - 1
+}
+
+// splitKeepingNewlines splits text into lines that keep their line ending.
+func splitKeepingNewlines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes the shortest edit script between a and b, skipping their common prefix and suffix first.
+func diffLines(a, b []string) []editOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []editOp
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, editOp{kind: Context, oldIndex: i, newIndex: i})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+	for i := suffix; i > 0; i-- {
+		ops = append(ops, editOp{kind: Context, oldIndex: len(a) - i, newIndex: len(b) - i})
+	}
+	return ops
+}
+
+// myers implements the Myers O(ND) difference algorithm, offsetting the indices of the returned operations.
+func myers(a, b []string, oldOffset, newOffset int) []editOp {
+	n, m := len(a), len(b)
+	maxEdits := n + m
+	if maxEdits == 0 {
+		return nil
+	}
+
+	center := maxEdits + 1
+	v := make([]int, 2*maxEdits+3)
+	var trace [][]int
+
+search:
+	for d := 0; d <= maxEdits; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[center+k-1] < v[center+k+1]) {
+				x = v[center+k+1]
+			} else {
+				x = v[center+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[center+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk back through the saved states to recover the edit script in reverse
+	var reversed []editOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		previous := trace[d]
+		k := x - y
+
+		var previousK int
+		if k == -d || (k != d && previous[center+k-1] < previous[center+k+1]) {
+			previousK = k + 1
+		} else {
+			previousK = k - 1
+		}
+		previousX := previous[center+previousK]
+		previousY := previousX - previousK
+
+		for x > previousX && y > previousY {
+			x--
+			y--
+			reversed = append(reversed, editOp{kind: Context, oldIndex: oldOffset + x, newIndex: newOffset + y})
+		}
+
+		if d > 0 {
+			if x == previousX {
+				y--
+				reversed = append(reversed, editOp{kind: Addition, oldIndex: oldOffset + x, newIndex: newOffset + y})
+			} else {
+				x--
+				reversed = append(reversed, editOp{kind: Deletion, oldIndex: oldOffset + x, newIndex: newOffset + y})
+			}
+		}
+	}
+
+	ops := make([]editOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// groupHunks groups the changes of an edit script into hunks, merging changes separated by few unchanged lines.
+func groupHunks(ops []editOp, oldLines, newLines []string, contextLines int) []Hunk {
+	var hunks []Hunk
+
+	i := 0
+	for i < len(ops) {
+		if ops[i].kind == Context {
+			i++
+			continue
+		}
+
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk while the next change is close enough to share its context
+		end := i
+		for {
+			for end < len(ops) && ops[end].kind != Context {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == Context {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*contextLines {
+				end = next
+				continue
+			}
+			end += contextLines
+			if end > len(ops) {
+				end = len(ops)
+			}
+			break
+		}
+
+		hunks = append(hunks, buildHunk(ops[start:end], oldLines, newLines))
+		i = end
+	}
+
+	return hunks
+}
+
+func buildHunk(ops []editOp, oldLines, newLines []string) Hunk {
+	hunk := Hunk{}
+	for _, op := range ops {
+		switch op.kind {
+		case Addition:
+			hunk.NewLines++
+			hunk.Lines = append(hunk.Lines, Line{Kind: Addition, Text: trimLineEnding(newLines[op.newIndex])})
+		case Deletion:
+			hunk.OldLines++
+			hunk.Lines = append(hunk.Lines, Line{Kind: Deletion, Text: trimLineEnding(oldLines[op.oldIndex])})
+		default:
+			hunk.OldLines++
+			hunk.NewLines++
+			hunk.Lines = append(hunk.Lines, Line{Kind: Context, Text: trimLineEnding(oldLines[op.oldIndex])})
+		}
+	}
+
+	hunk.OldStart = ops[0].oldIndex + 1
+	if hunk.OldLines == 0 {
+		hunk.OldStart = ops[0].oldIndex
+	}
+	hunk.NewStart = ops[0].newIndex + 1
+	if hunk.NewLines == 0 {
+		hunk.NewStart = ops[0].newIndex
+	}
+
+	return hunk
+}
+
+func trimLineEnding(line string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+}
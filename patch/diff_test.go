@@ -0,0 +1,63 @@
+package patch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff_Hunks(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+
+	hunks := Diff(before, after, 3)
+
+	assert.Len(t, hunks, 2)
+	assert.Equal(t, "@@ -1,5 +1,5 @@", hunks[0].Header())
+	assert.Equal(t, []Line{
+		{Kind: Context, Text: "a"},
+		{Kind: Deletion, Text: "b"},
+		{Kind: Addition, Text: "B"},
+		{Kind: Context, Text: "c"},
+		{Kind: Context, Text: "d"},
+		{Kind: Context, Text: "e"},
+	}, hunks[0].Lines)
+	assert.Equal(t, "@@ -10,3 +10,4 @@", hunks[1].Header())
+}
+
+func TestDiff_AppliesBackToAfter(t *testing.T) {
+	before := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"a\")\n}\n"
+	after := "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc main() {\n\tfmt.Println(\"b\")\n\tos.Exit(0)\n}\n"
+
+	hunks := Diff(before, after, 1)
+
+	result, err := Apply(before, hunks)
+	assert.NoError(t, err)
+	assert.Equal(t, after, result.Content)
+}
+
+func TestDiff_NewAndDeletedFile(t *testing.T) {
+	created := Diff("", "one\ntwo\n", 3)
+	assert.Len(t, created, 1)
+	assert.Equal(t, "@@ -0,0 +1,2 @@", created[0].Header())
+
+	deleted := Diff("one\ntwo\n", "", 3)
+	assert.Len(t, deleted, 1)
+	assert.Equal(t, "@@ -1,2 +0,0 @@", deleted[0].Header())
+
+	assert.Empty(t, Diff("same\n", "same\n", 3))
+}
+
+func TestMerge_SelectedHunks(t *testing.T) {
+	before := strings.Repeat("x\n", 10) + "end"
+	after := "first\n" + strings.Repeat("x\n", 10) + "end\n"
+
+	hunks := Diff(before, after, 2)
+	assert.Len(t, hunks, 2)
+
+	assert.Equal(t, before, Merge(before, after, hunks, []bool{false, false}))
+	assert.Equal(t, after, Merge(before, after, hunks, []bool{true, true}))
+	assert.Equal(t, "first\n"+strings.Repeat("x\n", 10)+"end", Merge(before, after, hunks, []bool{true, false}))
+	assert.Equal(t, strings.Repeat("x\n", 10)+"end\n", Merge(before, after, hunks, []bool{false, true}))
+}
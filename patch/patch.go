@@ -0,0 +1,263 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LineKind identifies the role of a line inside a hunk.
+type LineKind int
+
+const (
+	Context LineKind = iota
+	Addition
+	Deletion
+)
+
+// Line is a single line of a hunk without its diff marker.
+type Line struct {
+	Kind LineKind
+	Text string
+}
+
+// Hunk represents one "@@ ... @@" section of a unified diff.
+// OldStart is 1-based; a value of 0 means the position is unknown (or the hunk targets an empty file).
+type Hunk struct {
+	OldStart       int
+	OldLines       int
+	NewStart       int
+	NewLines       int
+	Section        string
+	Lines          []Line
+	NoNewlineAtEOF bool
+}
+
+// FilePatch holds all hunks that target one file.
+type FilePatch struct {
+	OldPath string
+	NewPath string
+	Hunks   []Hunk
+}
+
+// ErrNoHunks is returned when the text does not contain any hunk.
+var ErrNoHunks = errors.New("no hunks found in patch")
+
+var (
+	hunkHeaderRegex     = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+	bareHunkHeaderRegex = regexp.MustCompile(`^@@(?:\s.*)?$`)
+)
+
+// IsUnifiedDiff reports whether the text contains at least one unified diff hunk header.
+func IsUnifiedDiff(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if isHunkHeader(strings.TrimRight(line, "\r")) {
+			return true
+		}
+	}
+	return false
+}
+
+func isHunkHeader(line string) bool {
+	return hunkHeaderRegex.MatchString(line) || bareHunkHeaderRegex.MatchString(line)
+}
+
+// Parse parses unified diff text into file patches. File headers ("--- a/x", "+++ b/x") are optional,
+// so a bare list of hunks is returned as a single FilePatch with empty paths.
+func Parse(text string) ([]FilePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	var filePatches []FilePatch
+	var currentFile *FilePatch
+	var currentHunk *Hunk
+
+	flushHunk := func() {
+		if currentHunk == nil {
+			return
+		}
+		// Blank lines at the end of a hunk are usually the end of the code block, not context
+		for len(currentHunk.Lines) > 0 {
+			last := currentHunk.Lines[len(currentHunk.Lines)-1]
+			if last.Kind != Context || last.Text != "" {
+				break
+			}
+			currentHunk.Lines = currentHunk.Lines[:len(currentHunk.Lines)-1]
+		}
+		if currentFile == nil {
+			filePatches = append(filePatches, FilePatch{})
+			currentFile = &filePatches[len(filePatches)-1]
+		}
+		currentFile.Hunks = append(currentFile.Hunks, *currentHunk)
+		currentHunk = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		// A "--- " line is a file header only when followed by "+++ ", otherwise it is a deleted line
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			flushHunk()
+			filePatches = append(filePatches, FilePatch{
+				OldPath: parseHeaderPath(strings.TrimPrefix(line, "--- ")),
+				NewPath: parseHeaderPath(strings.TrimPrefix(lines[i+1], "+++ ")),
+			})
+			currentFile = &filePatches[len(filePatches)-1]
+			i++
+			continue
+		}
+
+		if isHunkHeader(line) {
+			flushHunk()
+			hunk, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			currentHunk = hunk
+			continue
+		}
+
+		if currentHunk == nil {
+			// Skip "diff --git", "index" and any other preamble
+			continue
+		}
+
+		switch {
+		case line == "":
+			currentHunk.Lines = append(currentHunk.Lines, Line{Kind: Context})
+		case line[0] == ' ':
+			currentHunk.Lines = append(currentHunk.Lines, Line{Kind: Context, Text: line[1:]})
+		case line[0] == '+':
+			currentHunk.Lines = append(currentHunk.Lines, Line{Kind: Addition, Text: line[1:]})
+		case line[0] == '-':
+			currentHunk.Lines = append(currentHunk.Lines, Line{Kind: Deletion, Text: line[1:]})
+		case line[0] == '\\':
+			// "\ No newline at end of file"
+			currentHunk.NoNewlineAtEOF = true
+		default:
+			// Models frequently forget the leading space on context lines
+			currentHunk.Lines = append(currentHunk.Lines, Line{Kind: Context, Text: line})
+		}
+	}
+	flushHunk()
+
+	hunkCount := 0
+	for _, filePatch := range filePatches {
+		hunkCount += len(filePatch.Hunks)
+	}
+	if hunkCount == 0 {
+		return nil, ErrNoHunks
+	}
+
+	return filePatches, nil
+}
+
+// parseHunkHeader parses "@@ -l,s +l,s @@ section". A bare "@@" header yields a hunk with unknown position.
+func parseHunkHeader(line string) (*Hunk, error) {
+	matches := hunkHeaderRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return &Hunk{}, nil
+	}
+
+	hunk := &Hunk{Section: strings.TrimSpace(matches[5])}
+	var err error
+	if hunk.OldStart, err = strconv.Atoi(matches[1]); err != nil {
+		return nil, fmt.Errorf("invalid hunk header %q: %w", line, err)
+	}
+	hunk.OldLines = 1
+	if matches[2] != "" {
+		if hunk.OldLines, err = strconv.Atoi(matches[2]); err != nil {
+			return nil, fmt.Errorf("invalid hunk header %q: %w", line, err)
+		}
+	}
+	if hunk.NewStart, err = strconv.Atoi(matches[3]); err != nil {
+		return nil, fmt.Errorf("invalid hunk header %q: %w", line, err)
+	}
+	hunk.NewLines = 1
+	if matches[4] != "" {
+		if hunk.NewLines, err = strconv.Atoi(matches[4]); err != nil {
+			return nil, fmt.Errorf("invalid hunk header %q: %w", line, err)
+		}
+	}
+
+	return hunk, nil
+}
+
+// parseHeaderPath strips the "a/" or "b/" prefix and any trailing timestamp from a file header path.
+func parseHeaderPath(path string) string {
+	if idx := strings.Index(path, "\t"); idx >= 0 {
+		path = path[:idx]
+	}
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		path = path[2:]
+	}
+	return path
+}
+
+// FromAnnotated converts a code block where changed lines carry a "+" or "-" marker in the first
+// column (and unchanged lines carry none) into a single unanchored hunk.
+// It returns false when the block has no markers at all.
+func FromAnnotated(code string) (Hunk, bool) {
+	var hunk Hunk
+	hasMarkers := false
+
+	for _, line := range strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			hasMarkers = true
+			hunk.Lines = append(hunk.Lines, Line{Kind: Addition, Text: line[1:]})
+		case strings.HasPrefix(line, "-"):
+			hasMarkers = true
+			hunk.Lines = append(hunk.Lines, Line{Kind: Deletion, Text: line[1:]})
+		default:
+			hunk.Lines = append(hunk.Lines, Line{Kind: Context, Text: line})
+		}
+	}
+
+	return hunk, hasMarkers
+}
+
+// OldLinesText returns the lines the hunk expects to find in the original file.
+func (hunk Hunk) OldLinesText() []string {
+	var lines []string
+	for _, line := range hunk.Lines {
+		if line.Kind != Addition {
+			lines = append(lines, line.Text)
+		}
+	}
+	return lines
+}
+
+// NewLinesText returns the lines the hunk produces.
+func (hunk Hunk) NewLinesText() []string {
+	var lines []string
+	for _, line := range hunk.Lines {
+		if line.Kind != Deletion {
+			lines = append(lines, line.Text)
+		}
+	}
+	return lines
+}
+
+// IsPureAddition reports whether the hunk only adds lines.
+func (hunk Hunk) IsPureAddition() bool {
+	for _, line := range hunk.Lines {
+		if line.Kind != Addition {
+			return false
+		}
+	}
+	return len(hunk.Lines) > 0
+}
+
+// Header renders the hunk header, used when reporting rejected hunks.
+func (hunk Hunk) Header() string {
+	if hunk.OldStart == 0 && hunk.NewStart == 0 && hunk.OldLines == 0 && hunk.NewLines == 0 {
+		return "@@"
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+}
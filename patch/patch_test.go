@@ -0,0 +1,149 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse_WithFileHeaders(t *testing.T) {
+	text := "diff --git a/main.go b/main.go\nindex 83db48f..bf269f4 100644\n--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,4 @@ package main\n package main\n \n+import \"fmt\"\n func main() {}\n"
+
+	filePatches, err := Parse(text)
+
+	assert.NoError(t, err)
+	assert.Len(t, filePatches, 1)
+	assert.Equal(t, "main.go", filePatches[0].OldPath)
+	assert.Equal(t, "main.go", filePatches[0].NewPath)
+	assert.Len(t, filePatches[0].Hunks, 1)
+
+	hunk := filePatches[0].Hunks[0]
+	assert.Equal(t, 1, hunk.OldStart)
+	assert.Equal(t, 3, hunk.OldLines)
+	assert.Equal(t, 4, hunk.NewLines)
+	assert.Equal(t, "package main", hunk.Section)
+	assert.Equal(t, []string{"package main", "", "func main() {}"}, hunk.OldLinesText())
+	assert.Equal(t, []string{"package main", "", "import \"fmt\"", "func main() {}"}, hunk.NewLinesText())
+}
+
+func TestParse_DeletedLineThatLooksLikeHeader(t *testing.T) {
+	text := "@@ -1,2 +1,1 @@\n--- a/notes.md\n keep\n"
+
+	filePatches, err := Parse(text)
+
+	assert.NoError(t, err)
+	assert.Len(t, filePatches, 1)
+	assert.Equal(t, []Line{{Kind: Deletion, Text: "-- a/notes.md"}, {Kind: Context, Text: "keep"}}, filePatches[0].Hunks[0].Lines)
+}
+
+func TestParse_NoHunks(t *testing.T) {
+	_, err := Parse("package main\n")
+
+	assert.ErrorIs(t, err, ErrNoHunks)
+}
+
+func TestIsUnifiedDiff(t *testing.T) {
+	assert.True(t, IsUnifiedDiff("@@ -1 +1 @@\n-a\n+b"))
+	assert.True(t, IsUnifiedDiff("@@\n-a\n+b"))
+	assert.False(t, IsUnifiedDiff("- item one\n- item two"))
+	assert.False(t, IsUnifiedDiff("x := -1\ny := +2"))
+}
+
+func TestApply_WithOffset(t *testing.T) {
+	original := "a\nb\nc\nd\ne\nf\ng\n"
+	filePatches, err := Parse("@@ -2,3 +2,3 @@\n d\n-e\n+E\n f\n")
+	assert.NoError(t, err)
+
+	result, err := Apply(original, filePatches[0].Hunks)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb\nc\nd\nE\nf\ng\n", result.Content)
+	assert.Equal(t, 2, result.Hunks[0].Offset)
+}
+
+func TestApply_MultipleHunks(t *testing.T) {
+	original := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\n"
+	filePatches, err := Parse("@@ -1,2 +1,3 @@\n one\n+one and a half\n two\n@@ -7,2 +8,1 @@\n seven\n-eight\n")
+	assert.NoError(t, err)
+
+	result, err := Apply(original, filePatches[0].Hunks)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "one\none and a half\ntwo\nthree\nfour\nfive\nsix\nseven\n", result.Content)
+}
+
+func TestApply_KeepsLinesStartingWithMarkers(t *testing.T) {
+	original := "items:\n  - first\n  - second\nvalue: -1\n"
+	filePatches, err := Parse("@@ -2,3 +2,4 @@\n   - first\n   - second\n+  - third\n value: -1\n")
+	assert.NoError(t, err)
+
+	result, err := Apply(original, filePatches[0].Hunks)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "items:\n  - first\n  - second\n  - third\nvalue: -1\n", result.Content)
+}
+
+func TestApply_WhitespaceTolerantMatchReindents(t *testing.T) {
+	original := "func main() {\n\tfmt.Println(\"a\")\n\tfmt.Println(\"b\")\n}\n"
+	hunk, ok := FromAnnotated("    fmt.Println(\"a\")\n-   fmt.Println(\"b\")\n+    fmt.Println(\"c\")")
+	assert.True(t, ok)
+
+	result, err := Apply(original, []Hunk{hunk})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "func main() {\n\tfmt.Println(\"a\")\n\tfmt.Println(\"c\")\n}\n", result.Content)
+}
+
+func TestApply_WithFuzz(t *testing.T) {
+	original := "alpha\nbeta\ngamma\ndelta\n"
+	filePatches, err := Parse("@@ -1,4 +1,4 @@\n changed header\n beta\n-gamma\n+GAMMA\n delta\n")
+	assert.NoError(t, err)
+
+	result, err := Apply(original, filePatches[0].Hunks)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "alpha\nbeta\nGAMMA\ndelta\n", result.Content)
+	assert.Equal(t, 1, result.Hunks[0].Fuzz)
+}
+
+func TestApply_RejectedHunkLeavesContentUnchanged(t *testing.T) {
+	original := "one\ntwo\nthree\n"
+	filePatches, err := Parse("@@ -1,2 +1,2 @@\n one\n-two\n+TWO\n@@ -10,2 +10,2 @@\n missing\n-line\n+LINE\n")
+	assert.NoError(t, err)
+
+	result, err := Apply(original, filePatches[0].Hunks)
+
+	var rejectError *RejectError
+	assert.ErrorAs(t, err, &rejectError)
+	assert.Len(t, rejectError.Rejected, 1)
+	assert.Equal(t, 1, rejectError.Rejected[0].Index)
+	assert.Equal(t, original, result.Content)
+}
+
+func TestApply_NewFile(t *testing.T) {
+	filePatches, err := Parse("--- /dev/null\n+++ b/hello.txt\n@@ -0,0 +1,2 @@\n+hello\n+world\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "", filePatches[0].OldPath)
+
+	result, err := Apply("", filePatches[0].Hunks)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\nworld\n", result.Content)
+}
+
+func TestApply_PreservesCRLF(t *testing.T) {
+	original := "one\r\ntwo\r\nthree\r\n"
+	filePatches, err := Parse("@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n")
+	assert.NoError(t, err)
+
+	result, err := Apply(original, filePatches[0].Hunks)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "one\r\n2\r\nthree\r\n", result.Content)
+}
+
+func TestFromAnnotated_NoMarkers(t *testing.T) {
+	_, ok := FromAnnotated("package main\n\nfunc main() {}")
+
+	assert.False(t, ok)
+}
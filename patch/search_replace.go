@@ -0,0 +1,166 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SearchReplaceBlock replaces the lines of Search with the lines of Replace. An empty Search creates the file
+// when it does not exist yet, or appends Replace to the end of it.
+type SearchReplaceBlock struct {
+	Search  string
+	Replace string
+}
+
+// ErrNoBlocks is returned when the text does not contain any complete SEARCH/REPLACE block.
+var ErrNoBlocks = errors.New("no SEARCH/REPLACE blocks found")
+
+var (
+	searchMarkerRegex  = regexp.MustCompile(`^<{5,9} ?SEARCH\s*$`)
+	dividerMarkerRegex = regexp.MustCompile(`^={5,9}\s*$`)
+	replaceMarkerRegex = regexp.MustCompile(`^>{5,9} ?REPLACE\s*$`)
+)
+
+// IsSearchReplaceMarker reports whether the line opens a SEARCH section.
+func IsSearchReplaceMarker(line string) bool {
+	return searchMarkerRegex.MatchString(strings.TrimSpace(line))
+}
+
+// IsDividerMarker reports whether the line separates the SEARCH and REPLACE sections.
+func IsDividerMarker(line string) bool {
+	return dividerMarkerRegex.MatchString(strings.TrimSpace(line))
+}
+
+// IsReplaceMarker reports whether the line closes a SEARCH/REPLACE block.
+func IsReplaceMarker(line string) bool {
+	return replaceMarkerRegex.MatchString(strings.TrimSpace(line))
+}
+
+// IsSearchReplace reports whether the text contains at least one SEARCH marker.
+func IsSearchReplace(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if IsSearchReplaceMarker(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSearchReplace parses every complete SEARCH/REPLACE block in the text, ignoring anything around them.
+func ParseSearchReplace(text string) ([]SearchReplaceBlock, error) {
+	const (
+		outside = iota
+		inSearch
+		inReplace
+	)
+
+	var blocks []SearchReplaceBlock
+	var searchLines, replaceLines []string
+	state := outside
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		switch state {
+		case outside:
+			if IsSearchReplaceMarker(line) {
+				searchLines, replaceLines = nil, nil
+				state = inSearch
+			}
+		case inSearch:
+			if IsDividerMarker(line) {
+				state = inReplace
+				continue
+			}
+			searchLines = append(searchLines, line)
+		case inReplace:
+			if IsReplaceMarker(line) {
+				blocks = append(blocks, SearchReplaceBlock{
+					Search:  strings.Join(searchLines, "\n"),
+					Replace: strings.Join(replaceLines, "\n"),
+				})
+				state = outside
+				continue
+			}
+			replaceLines = append(replaceLines, line)
+		}
+	}
+
+	if state != outside {
+		return nil, fmt.Errorf("unterminated SEARCH/REPLACE block")
+	}
+	if len(blocks) == 0 {
+		return nil, ErrNoBlocks
+	}
+
+	return blocks, nil
+}
+
+// FormatSearchReplace renders blocks back into the SEARCH/REPLACE text format.
+func FormatSearchReplace(blocks []SearchReplaceBlock) string {
+	var builder strings.Builder
+	for i, block := range blocks {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString("<<<<<<< SEARCH\n")
+		if block.Search != "" {
+			builder.WriteString(block.Search + "\n")
+		}
+		builder.WriteString("=======\n")
+		if block.Replace != "" {
+			builder.WriteString(block.Replace + "\n")
+		}
+		builder.WriteString(">>>>>>> REPLACE")
+	}
+	return builder.String()
+}
+
+// ApplySearchReplace applies the blocks in order. Every SEARCH section is matched exactly first and then
+// ignoring whitespace, in which case the REPLACE lines are re-indented to follow the file.
+// When any block does not match the returned error is a *RejectError and Result.Content is the unchanged original.
+func ApplySearchReplace(original string, blocks []SearchReplaceBlock) (*Result, error) {
+	hunks := make([]Hunk, 0, len(blocks))
+	for _, block := range blocks {
+		hunks = append(hunks, block.toHunk())
+	}
+
+	result, err := Apply(original, hunks)
+
+	// Describe rejected blocks by their first searched line rather than by a hunk header
+	for i := range result.Hunks {
+		result.Hunks[i].Header = blocks[result.Hunks[i].Index].describe()
+	}
+	var rejectError *RejectError
+	if errors.As(err, &rejectError) {
+		rejectError.Unit = "blocks"
+		rejectError.Rejected = result.Rejected()
+	}
+
+	return result, err
+}
+
+// toHunk expresses the block as an unanchored hunk that deletes the searched lines and adds the replacement.
+func (block SearchReplaceBlock) toHunk() Hunk {
+	var hunk Hunk
+	if block.Search != "" {
+		for _, line := range strings.Split(block.Search, "\n") {
+			hunk.Lines = append(hunk.Lines, Line{Kind: Deletion, Text: line})
+		}
+	}
+	if block.Replace != "" {
+		for _, line := range strings.Split(block.Replace, "\n") {
+			hunk.Lines = append(hunk.Lines, Line{Kind: Addition, Text: line})
+		}
+	}
+	return hunk
+}
+
+func (block SearchReplaceBlock) describe() string {
+	for _, line := range strings.Split(block.Search, "\n") {
+		if strings.TrimSpace(line) != "" {
+			return fmt.Sprintf("(SEARCH %q)", strings.TrimSpace(line))
+		}
+	}
+	return "(SEARCH empty)"
+}
@@ -0,0 +1,77 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSearchReplace(t *testing.T) {
+	text := "some explanation\n```go\n<<<<<<< SEARCH\nfunc a() {}\n=======\nfunc b() {}\n>>>>>>> REPLACE\n```\n<<<<<<< SEARCH\n=======\nnew\n>>>>>>> REPLACE"
+
+	blocks, err := ParseSearchReplace(text)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []SearchReplaceBlock{
+		{Search: "func a() {}", Replace: "func b() {}"},
+		{Search: "", Replace: "new"},
+	}, blocks)
+}
+
+func TestParseSearchReplace_Unterminated(t *testing.T) {
+	_, err := ParseSearchReplace("<<<<<<< SEARCH\nfunc a() {}\n=======\nfunc b() {}")
+
+	assert.Error(t, err)
+}
+
+func TestFormatSearchReplace_RoundTrip(t *testing.T) {
+	blocks := []SearchReplaceBlock{
+		{Search: "a\nb", Replace: "c"},
+		{Search: "", Replace: "d"},
+	}
+
+	parsed, err := ParseSearchReplace(FormatSearchReplace(blocks))
+
+	assert.NoError(t, err)
+	assert.Equal(t, blocks, parsed)
+}
+
+func TestApplySearchReplace_Exact(t *testing.T) {
+	original := "package main\n\nfunc a() {\n\treturn\n}\n"
+
+	result, err := ApplySearchReplace(original, []SearchReplaceBlock{{Search: "func a() {\n\treturn\n}", Replace: "func b() {\n\treturn\n}"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc b() {\n\treturn\n}\n", result.Content)
+}
+
+func TestApplySearchReplace_WhitespaceTolerant(t *testing.T) {
+	original := "func main() {\n\tif ok {\n\t\trun()\n\t}\n}\n"
+
+	result, err := ApplySearchReplace(original, []SearchReplaceBlock{{Search: "  if ok {\n    run()\n  }", Replace: "  if ok {\n    run()\n    stop()\n  }"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "func main() {\n\tif ok {\n\t\trun()\n\t\tstop()\n\t}\n}\n", result.Content)
+}
+
+func TestApplySearchReplace_NewFile(t *testing.T) {
+	result, err := ApplySearchReplace("", []SearchReplaceBlock{{Replace: "package main\n\nfunc main() {}"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {}\n", result.Content)
+}
+
+func TestApplySearchReplace_MissingSearchRejectsAll(t *testing.T) {
+	original := "one\ntwo\n"
+
+	result, err := ApplySearchReplace(original, []SearchReplaceBlock{
+		{Search: "one", Replace: "1"},
+		{Search: "three", Replace: "3"},
+	})
+
+	var rejectError *RejectError
+	assert.ErrorAs(t, err, &rejectError)
+	assert.Contains(t, err.Error(), "1 of 2 blocks failed to apply")
+	assert.Contains(t, err.Error(), `(SEARCH "three")`)
+	assert.Equal(t, original, result.Content)
+}
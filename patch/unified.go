@@ -0,0 +1,59 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Unified formats the differences between two versions of a file as a unified diff with file headers, which
+// 'git apply' and 'patch -p1' accept. An empty oldPath is a created file and an empty newPath a deleted one.
+// It returns an empty string when the versions are equal.
+func Unified(oldPath, newPath, before, after string, contextLines int) string {
+	hunks := Diff(before, after, contextLines)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	oldName, newName := "/dev/null", "/dev/null"
+	if oldPath != "" {
+		oldName = "a/" + oldPath
+	}
+	if newPath != "" {
+		newName = "b/" + newPath
+	}
+
+	oldCount, newCount := len(splitKeepingNewlines(before)), len(splitKeepingNewlines(after))
+	oldMissingNewline := before != "" && !strings.HasSuffix(before, "\n")
+	newMissingNewline := after != "" && !strings.HasSuffix(after, "\n")
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName))
+	for _, hunk := range hunks {
+		builder.WriteString(hunk.Header() + "\n")
+
+		oldIndex, newIndex := startIndex(hunk.OldStart, hunk.OldLines), startIndex(hunk.NewStart, hunk.NewLines)
+		for _, line := range hunk.Lines {
+			// The last line of a file without a final newline is followed by a marker
+			missingNewline := false
+			switch line.Kind {
+			case Addition:
+				builder.WriteString("+" + line.Text + "\n")
+				missingNewline = newMissingNewline && newIndex == newCount-1
+				newIndex++
+			case Deletion:
+				builder.WriteString("-" + line.Text + "\n")
+				missingNewline = oldMissingNewline && oldIndex == oldCount-1
+				oldIndex++
+			default:
+				builder.WriteString(" " + line.Text + "\n")
+				missingNewline = oldMissingNewline && oldIndex == oldCount-1
+				oldIndex++
+				newIndex++
+			}
+			if missingNewline {
+				builder.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+	return builder.String()
+}
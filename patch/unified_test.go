@@ -0,0 +1,27 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnified_ChangedFile(t *testing.T) {
+	before := "a\nb\nc\n"
+	after := "a\nB\nc\n"
+
+	assert.Equal(t, "--- a/file.txt\n+++ b/file.txt\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n", Unified("file.txt", "file.txt", before, after, 3))
+	assert.Equal(t, "", Unified("file.txt", "file.txt", before, before, 3))
+}
+
+func TestUnified_NewAndDeletedFile(t *testing.T) {
+	assert.Equal(t, "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n", Unified("", "new.txt", "", "a\nb\n", 3))
+	assert.Equal(t, "--- a/old.txt\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-a\n-b\n", Unified("old.txt", "", "a\nb\n", "", 3))
+}
+
+func TestUnified_NoNewlineAtEndOfFile(t *testing.T) {
+	assert.Equal(t, "--- a/file.txt\n+++ b/file.txt\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		Unified("file.txt", "file.txt", "a\nb", "a\nb\n", 3))
+	assert.Equal(t, "--- a/file.txt\n+++ b/file.txt\n@@ -1,2 +1,3 @@\n-a\n+A\n b\n+c\n\\ No newline at end of file\n",
+		Unified("file.txt", "file.txt", "a\nb\n", "A\nb\nc", 3))
+}
@@ -52,23 +52,97 @@ func NewAnthropicMessageProvider(config *AnthropicConfig) contracts.IChatAIProvi
 	}
 }
 
-func (anthropicProvider *AnthropicConfig) ChatCompletionRequest(ctx context.Context, userInput string, prompt string) <-chan general_models.StreamResponse {
+func (anthropicProvider *AnthropicConfig) ChatCompletionRequest(ctx context.Context, messages []general_models.Message) <-chan general_models.StreamResponse {
+	system, anthropicMessages := toAnthropicMessages(messages)
+	return anthropicProvider.streamMessages(ctx, system, anthropicMessages, nil)
+}
+
+// ChatCompletionRequestWithTools sends the tools to the model with the messages
+func (anthropicProvider *AnthropicConfig) ChatCompletionRequestWithTools(ctx context.Context, messages []general_models.Message, tools []general_models.ToolDefinition) <-chan general_models.StreamResponse {
+	var anthropicTools []models.Tool
+	for _, tool := range tools {
+		anthropicTools = append(anthropicTools, models.Tool{Name: tool.Name, Description: tool.Description, InputSchema: tool.Parameters})
+	}
+
+	system, anthropicMessages := toAnthropicMessages(messages)
+	return anthropicProvider.streamMessages(ctx, system, anthropicMessages, anthropicTools)
+}
+
+// toAnthropicMessages converts the messages to the system prompt and the messages of the messages API. The system
+// prompt is cached, the tool calls are tool use blocks of the assistant messages and the tool messages are tool
+// result blocks of a user message.
+func toAnthropicMessages(messages []general_models.Message) ([]models.ContentBlock, []models.Message) {
+	var system []models.ContentBlock
+	prompt, messages := general_models.SplitSystemPrompt(messages)
+	if prompt != "" {
+		system = []models.ContentBlock{{Type: "text", Text: prompt, CacheControl: &models.CacheControl{Type: "ephemeral"}}}
+	}
+
+	var anthropicMessages []models.Message
+	for _, message := range messages {
+		switch message.Role {
+		case general_models.RoleAssistant:
+			if len(message.ToolCalls) == 0 {
+				// Anthropic rejects empty contents
+				if text := message.Text(); text != "" {
+					anthropicMessages = append(anthropicMessages, models.Message{Role: "assistant", Content: text})
+				}
+				continue
+			}
+			var blocks []models.ContentBlock
+			if text := message.Text(); text != "" {
+				blocks = append(blocks, models.ContentBlock{Type: "text", Text: text})
+			}
+			for _, call := range message.ToolCalls {
+				blocks = append(blocks, models.ContentBlock{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Name,
+					Input: general_models.ArgumentsObject(call.Arguments),
+				})
+			}
+			anthropicMessages = append(anthropicMessages, models.Message{Role: "assistant", Content: blocks})
+		case general_models.RoleTool:
+			block := models.ContentBlock{
+				Type:      "tool_result",
+				ToolUseID: message.ToolCallID,
+				Content:   message.Text(),
+				IsError:   message.IsError,
+			}
+			// The results of the calls of an assistant message are sent together
+			if last := len(anthropicMessages) - 1; last >= 0 && anthropicMessages[last].Role == "user" {
+				if blocks, ok := anthropicMessages[last].Content.([]models.ContentBlock); ok {
+					anthropicMessages[last].Content = append(blocks, block)
+					continue
+				}
+			}
+			anthropicMessages = append(anthropicMessages, models.Message{Role: "user", Content: []models.ContentBlock{block}})
+		default:
+			anthropicMessages = append(anthropicMessages, models.Message{Role: "user", Content: message.Text()})
+		}
+	}
+	return system, anthropicMessages
+}
+
+// streamMessages sends the system prompt and the messages to the messages API and streams the answer
+func (anthropicProvider *AnthropicConfig) streamMessages(ctx context.Context, system []models.ContentBlock, messages []models.Message, tools []models.Tool) <-chan general_models.StreamResponse {
 	responseChan := make(chan general_models.StreamResponse)
-	var markdownBuffer strings.Builder // Accumulate content for streaming responses
-	var usage models.Usage             // To track token usage
+	var markdownBuffer strings.Builder      // Accumulate content for streaming responses
+	var usage models.Usage                  // To track token usage
+	var toolCalls []general_models.ToolCall // Tool uses of the answer
+	toolCallIndexes := make(map[int]int)    // Index of the tool use of each tool use block
 
 	go func() {
 		defer close(responseChan)
 
 		// Prepare the request body
 		reqBody := models.AnthropicMessageRequest{
-			Messages: []models.Message{
-				{Role: "system", Content: prompt},
-				{Role: "user", Content: userInput},
-			},
+			System:      system,
+			Messages:    messages,
 			Model:       anthropicProvider.Model,
 			Temperature: anthropicProvider.Temperature,
 			Stream:      true,
+			Tools:       tools,
 		}
 
 		jsonData, err := json.Marshal(reqBody)
@@ -142,7 +216,17 @@ func (anthropicProvider *AnthropicConfig) ChatCompletionRequest(ctx context.Cont
 
 				// Handle content and final message updates
 				switch response.Type {
+				case "content_block_start":
+					if response.ContentBlock != nil && response.ContentBlock.Type == "tool_use" {
+						toolCallIndexes[response.Index] = len(toolCalls)
+						toolCalls = append(toolCalls, general_models.ToolCall{ID: response.ContentBlock.ID, Name: response.ContentBlock.Name})
+					}
 				case "content_block_delta":
+					if response.Delta.Type == "input_json_delta" {
+						if index, ok := toolCallIndexes[response.Index]; ok {
+							toolCalls[index].Arguments += response.Delta.PartialJSON
+						}
+					}
 					if response.Delta.Type == "text_delta" {
 						markdownBuffer.WriteString(response.Delta.Text)
 						if strings.Contains(response.Delta.Text, "\n") {
@@ -156,11 +240,15 @@ func (anthropicProvider *AnthropicConfig) ChatCompletionRequest(ctx context.Cont
 						// 注意：不在这里调用UsedTokens，避免重复计算，只在最后调用一次
 					}
 				case "message_stop":
-					responseChan <- general_models.StreamResponse{Content: markdownBuffer.String(), Done: true}
+					responseChan <- general_models.StreamResponse{Content: markdownBuffer.String()}
+					markdownBuffer.Reset()
+					if len(toolCalls) > 0 {
+						responseChan <- general_models.StreamResponse{ToolCalls: toolCalls}
+					}
+					responseChan <- general_models.StreamResponse{Done: true}
 					if usage.TotalTokens > 0 {
 						anthropicProvider.TokenManagement.UsedTokens(usage.InputTokens, usage.OutputTokens)
 						// 显示本次使用的token统计
-						fmt.Print("\n")
 						anthropicProvider.TokenManagement.DisplayTokenUsage(
 							"anthropic",
 							anthropicProvider.Model,
@@ -0,0 +1,60 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/meysamhadeli/codai/providers/anthropic/models"
+	general_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToAnthropicMessages(t *testing.T) {
+	readCall := general_models.ToolCall{ID: "call_1", Name: "read_file", Arguments: `{"path":"main.go"}`}
+	listCall := general_models.ToolCall{ID: "call_2", Name: "list_files", Arguments: "not json"}
+
+	tests := []struct {
+		name       string
+		messages   []general_models.Message
+		wantSystem []models.ContentBlock
+		want       []models.Message
+	}{
+		{
+			name:       "text messages",
+			messages:   general_models.NewConversation("prompt", []general_models.Message{general_models.NewTextMessage(general_models.RoleAssistant, "")}, "hi"),
+			wantSystem: []models.ContentBlock{{Type: "text", Text: "prompt", CacheControl: &models.CacheControl{Type: "ephemeral"}}},
+			want:       []models.Message{{Role: "user", Content: "hi"}},
+		},
+		{
+			name: "tool results of a message merged",
+			messages: []general_models.Message{
+				general_models.NewTextMessage(general_models.RoleUser, "read main.go"),
+				general_models.NewAssistantMessage("Let me look.", []general_models.ToolCall{readCall, listCall}),
+				general_models.NewToolMessage(general_models.ToolResult{CallID: "call_1", Name: "read_file", Content: "package main"}),
+				general_models.NewToolMessage(general_models.ToolResult{CallID: "call_2", Name: "list_files", Content: "denied", IsError: true}),
+				general_models.NewAssistantMessage("It is the main package.", nil),
+			},
+			want: []models.Message{
+				{Role: "user", Content: "read main.go"},
+				{Role: "assistant", Content: []models.ContentBlock{
+					{Type: "text", Text: "Let me look."},
+					{Type: "tool_use", ID: "call_1", Name: "read_file", Input: json.RawMessage(`{"path":"main.go"}`)},
+					{Type: "tool_use", ID: "call_2", Name: "list_files", Input: json.RawMessage(`{}`)},
+				}},
+				{Role: "user", Content: []models.ContentBlock{
+					{Type: "tool_result", ToolUseID: "call_1", Content: "package main"},
+					{Type: "tool_result", ToolUseID: "call_2", Content: "denied", IsError: true},
+				}},
+				{Role: "assistant", Content: "It is the main package."},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			system, messages := toAnthropicMessages(test.messages)
+			assert.Equal(t, test.wantSystem, system)
+			assert.Equal(t, test.want, messages)
+		})
+	}
+}
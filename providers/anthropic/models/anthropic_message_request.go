@@ -1,15 +1,44 @@
 package models
 
+import "encoding/json"
+
 // AnthropicMessageRequest represents the request body for Anthropic message.
 type AnthropicMessageRequest struct {
-	Model       string    `json:"model"`                 // Model ID, e.g., "claude-3-5-sonnet-latest"
-	Messages    []Message `json:"messages"`              // Array of message history
-	Temperature *float32  `json:"temperature,omitempty"` // Sampling temperature (0.0-1.0)
-	Stream      bool      `json:"stream,omitempty"`      // Enable/disable streaming
+	Model       string         `json:"model"`                 // Model ID, e.g., "claude-3-5-sonnet-latest"
+	System      []ContentBlock `json:"system,omitempty"`      // System prompt, apart from the messages
+	Messages    []Message      `json:"messages"`              // Array of message history
+	Temperature *float32       `json:"temperature,omitempty"` // Sampling temperature (0.0-1.0)
+	Stream      bool           `json:"stream,omitempty"`      // Enable/disable streaming
+	Tools       []Tool         `json:"tools,omitempty"`       // Tools the model may call
 }
 
 // Message Define the request body structure
 type Message struct {
-	Role    string `json:"role"`    // Valid roles: "system", "user", "assistant"
-	Content string `json:"content"` // The text content for this message
+	Role    string      `json:"role"`    // Valid roles: "user", "assistant"
+	Content interface{} `json:"content"` // The text content for this message, or its content blocks
+}
+
+// ContentBlock is a block of the content of a message: text, a tool use of the assistant or a tool result
+type ContentBlock struct {
+	Type         string          `json:"type"`                    // "text", "tool_use" or "tool_result"
+	Text         string          `json:"text,omitempty"`          // Text of a text block
+	ID           string          `json:"id,omitempty"`            // Identifier of a tool use
+	Name         string          `json:"name,omitempty"`          // Tool of a tool use
+	Input        json.RawMessage `json:"input,omitempty"`         // Arguments of a tool use as a JSON object
+	ToolUseID    string          `json:"tool_use_id,omitempty"`   // Tool use answered by a tool result
+	Content      string          `json:"content,omitempty"`       // Content of a tool result
+	IsError      bool            `json:"is_error,omitempty"`      // Whether a tool result is an error
+	CacheControl *CacheControl   `json:"cache_control,omitempty"` // Caches the prompt up to this block
+}
+
+// CacheControl marks the end of a cached prefix of the prompt
+type CacheControl struct {
+	Type string `json:"type"` // "ephemeral"
+}
+
+// Tool is a tool the model may use, with the JSON schema of its input
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
 }
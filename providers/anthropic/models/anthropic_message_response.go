@@ -6,6 +6,9 @@ type AnthropicMessageResponse struct {
 	Choices []Choice `json:"choices,omitempty"` // Array of choices for response content
 	Usage   *Usage   `json:"usage,omitempty"`   // Optional token usage details (appears in certain chunks)
 	Delta   *Delta   `json:"delta,omitempty"`   // Optional content updates or deltas
+	Index   int      `json:"index"`             // Index of the content block of a block event
+
+	ContentBlock *ContentBlock `json:"content_block,omitempty"` // Block started by a content_block_start event
 }
 
 // Choice represents an individual choice in the response.
@@ -15,9 +18,10 @@ type Choice struct {
 
 // Delta represents the streamed content or updates.
 type Delta struct {
-	Type       string `json:"type,omitempty"`        // Type of delta, e.g., "text_delta"
-	Text       string `json:"text,omitempty"`        // Text content streamed in chunks
-	StopReason string `json:"stop_reason,omitempty"` // Reason for stopping (e.g., "end_turn")
+	Type        string `json:"type,omitempty"`         // Type of delta, e.g., "text_delta"
+	Text        string `json:"text,omitempty"`         // Text content streamed in chunks
+	StopReason  string `json:"stop_reason,omitempty"`  // Reason for stopping (e.g., "end_turn")
+	PartialJSON string `json:"partial_json,omitempty"` // Chunk of the input of a tool use, for "input_json_delta"
 }
 
 // Usage represents token usage details for Anthropic responses.
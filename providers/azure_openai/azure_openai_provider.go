@@ -45,21 +45,55 @@ func NewAzureOpenAIChatProvider(config *AzureOpenAIConfig) contracts.IChatAIProv
 	}
 }
 
-func (azureOpenAIProvider *AzureOpenAIConfig) ChatCompletionRequest(ctx context.Context, userInput string, prompt string) <-chan models.StreamResponse {
+func (azureOpenAIProvider *AzureOpenAIConfig) ChatCompletionRequest(ctx context.Context, messages []models.Message) <-chan models.StreamResponse {
+	return azureOpenAIProvider.streamChatCompletion(ctx, toAzureMessages(messages), nil)
+}
+
+// ChatCompletionRequestWithTools sends the tools to the model with the messages
+func (azureOpenAIProvider *AzureOpenAIConfig) ChatCompletionRequestWithTools(ctx context.Context, messages []models.Message, tools []models.ToolDefinition) <-chan models.StreamResponse {
+	var azureTools []azure_openai_models.Tool
+	for _, tool := range tools {
+		azureTools = append(azureTools, azure_openai_models.Tool{
+			Type:     "function",
+			Function: azure_openai_models.FunctionDefinition{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters},
+		})
+	}
+
+	return azureOpenAIProvider.streamChatCompletion(ctx, toAzureMessages(messages), azureTools)
+}
+
+// toAzureMessages converts the messages to the chat completion API, with the tool calls of the assistant messages
+func toAzureMessages(messages []models.Message) []azure_openai_models.Message {
+	converted := make([]azure_openai_models.Message, 0, len(messages))
+	for _, message := range messages {
+		convertedMessage := azure_openai_models.Message{Role: string(message.Role), Content: message.Text(), ToolCallID: message.ToolCallID}
+		for _, call := range message.ToolCalls {
+			convertedMessage.ToolCalls = append(convertedMessage.ToolCalls, azure_openai_models.ToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: azure_openai_models.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		converted = append(converted, convertedMessage)
+	}
+	return converted
+}
+
+// streamChatCompletion sends the messages to the chat completion API and streams the answer
+func (azureOpenAIProvider *AzureOpenAIConfig) streamChatCompletion(ctx context.Context, messages []azure_openai_models.Message, tools []azure_openai_models.Tool) <-chan models.StreamResponse {
 	responseChan := make(chan models.StreamResponse)
 	var markdownBuffer strings.Builder  // Buffer to accumulate content until newline
 	var usage azure_openai_models.Usage // Variable to hold usage data
+	var toolCalls []models.ToolCall     // Tool calls assembled from their chunks, by index
 
 	go func() {
 		defer close(responseChan)
 
 		// Prepare the request body
 		reqBody := azure_openai_models.OpenAIChatCompletionRequest{
-			Model: azureOpenAIProvider.Model,
-			Messages: []azure_openai_models.Message{
-				{Role: "system", Content: prompt},
-				{Role: "user", Content: userInput},
-			},
+			Model:           azureOpenAIProvider.Model,
+			Messages:        messages,
+			Tools:           tools,
 			Stream:          true,
 			Temperature:     azureOpenAIProvider.Temperature,
 			ReasoningEffort: azureOpenAIProvider.ReasoningEffort,
@@ -129,6 +163,11 @@ func (azureOpenAIProvider *AzureOpenAIConfig) ChatCompletionRequest(ctx context.
 			if line == "data: [DONE]\n" {
 				// Send the final content
 				responseChan <- models.StreamResponse{Content: markdownBuffer.String()}
+				markdownBuffer.Reset()
+
+				if len(toolCalls) > 0 {
+					responseChan <- models.StreamResponse{ToolCalls: toolCalls}
+				}
 
 				responseChan <- models.StreamResponse{Done: true}
 
@@ -136,7 +175,6 @@ func (azureOpenAIProvider *AzureOpenAIConfig) ChatCompletionRequest(ctx context.
 				if usage.TotalTokens > 0 {
 					azureOpenAIProvider.TokenManagement.UsedTokens(usage.PromptTokens, usage.CompletionTokens)
 					// 显示本次使用的token统计
-					fmt.Print("\n")
 					azureOpenAIProvider.TokenManagement.DisplayTokenUsage(
 						"azure-openai",
 						azureOpenAIProvider.Model,
@@ -163,6 +201,13 @@ func (azureOpenAIProvider *AzureOpenAIConfig) ChatCompletionRequest(ctx context.
 					usage = response.Usage // Capture the usage data for later use
 				}
 
+				// Accumulate the chunks of the tool calls
+				if len(response.Choices) > 0 {
+					for _, delta := range response.Choices[0].Delta.ToolCalls {
+						toolCalls = models.AppendToolCallDelta(toolCalls, delta.Index, delta.ID, delta.Function.Name, delta.Function.Arguments)
+					}
+				}
+
 				// Accumulate and send response content
 				if len(response.Choices) > 0 {
 					content := response.Choices[0].Delta.Content
@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 // OpenAIChatCompletionRequest Define the request body structure
 type OpenAIChatCompletionRequest struct {
 	Model           string        `json:"model"`
@@ -8,12 +10,41 @@ type OpenAIChatCompletionRequest struct {
 	ReasoningEffort *string       `json:"reasoning_effort,omitempty"` // Optional field (pointer to string)
 	Stream          bool          `json:"stream"`
 	StreamOptions   StreamOptions `json:"stream_options"`
+	Tools           []Tool        `json:"tools,omitempty"` // Tools the model may call
 }
 
 // Message Define the request body structure
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // Calls of an assistant message
+	ToolCallID string     `json:"tool_call_id,omitempty"` // Call answered by a tool message
+}
+
+// Tool is a function the model may call
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition describes a function with the JSON schema of its arguments
+type FunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolCall is a call of a function by the model
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall is the name of a called function with its arguments in JSON
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 // StreamOptions includes configurations for streaming behavior
@@ -13,7 +13,16 @@ type Choice struct {
 
 // Delta represents the delta object in each choice containing the content.
 type Delta struct {
-	Content string `json:"content"`
+	Content   string          `json:"content"`
+	ToolCalls []ToolCallDelta `json:"tool_calls"`
+}
+
+// ToolCallDelta is a chunk of a tool call, the chunks of a call share its index
+type ToolCallDelta struct {
+	Index    int          `json:"index"`
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
 }
 
 // Usage defines the token usage information for the response.
@@ -5,6 +5,16 @@ import (
 	"github.com/meysamhadeli/codai/providers/models"
 )
 
+// IChatAIProvider sends a conversation to the model and streams its answer. The messages start with the system
+// prompt, followed by the alternating user and assistant messages of the history and the new user message.
 type IChatAIProvider interface {
-	ChatCompletionRequest(ctx context.Context, userInput string, prompt string) <-chan models.StreamResponse
+	ChatCompletionRequest(ctx context.Context, messages []models.Message) <-chan models.StreamResponse
+}
+
+// IToolCallingProvider is implemented by the providers whose API lets the model call tools. The model sees the tools
+// and its previous calls as assistant messages answered by tool messages, and its new calls come in the ToolCalls of
+// a response before Done.
+type IToolCallingProvider interface {
+	IChatAIProvider
+	ChatCompletionRequestWithTools(ctx context.Context, messages []models.Message, tools []models.ToolDefinition) <-chan models.StreamResponse
 }
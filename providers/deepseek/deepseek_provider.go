@@ -53,7 +53,7 @@ func NewDeepSeekChatProvider(config *DeepSeekConfig) contracts.IChatAIProvider {
 		TokenManagement: config.TokenManagement,
 	}
 }
-func (deepSeekProvider *DeepSeekConfig) ChatCompletionRequest(ctx context.Context, userInput string, prompt string) <-chan models.StreamResponse {
+func (deepSeekProvider *DeepSeekConfig) ChatCompletionRequest(ctx context.Context, messages []models.Message) <-chan models.StreamResponse {
 	responseChan := make(chan models.StreamResponse)
 	var markdownBuffer strings.Builder // Buffer to accumulate content until newline
 	var usage deepseek_models.Usage    // Variable to hold usage data
@@ -63,11 +63,8 @@ func (deepSeekProvider *DeepSeekConfig) ChatCompletionRequest(ctx context.Contex
 
 		// Prepare the request body
 		reqBody := deepseek_models.DeepSeekChatCompletionRequest{
-			Model: deepSeekProvider.Model,
-			Messages: []deepseek_models.Message{
-				{Role: "system", Content: prompt},
-				{Role: "user", Content: userInput},
-			},
+			Model:           deepSeekProvider.Model,
+			Messages:        models.ToTextMessages(messages),
 			Stream:          true,
 			Temperature:     deepSeekProvider.Temperature,
 			ReasoningEffort: deepSeekProvider.ReasoningEffort,
@@ -123,11 +120,11 @@ func (deepSeekProvider *DeepSeekConfig) ChatCompletionRequest(ctx context.Contex
 		for {
 			line, err := reader.ReadString('\n')
 			if err != nil {
-				markdownBuffer.Reset()
 				if err == io.EOF {
 					// Stream ended, send any remaining content
 					if markdownBuffer.Len() > 0 {
 						responseChan <- models.StreamResponse{Content: markdownBuffer.String()}
+						markdownBuffer.Reset()
 					}
 
 					// Notify that the stream is done
@@ -137,7 +134,6 @@ func (deepSeekProvider *DeepSeekConfig) ChatCompletionRequest(ctx context.Contex
 					if usage.TotalTokens > 0 {
 						deepSeekProvider.TokenManagement.UsedTokens(usage.PromptTokens, usage.CompletionTokens)
 						// 显示本次使用的token统计
-						fmt.Print("\n")
 						deepSeekProvider.TokenManagement.DisplayTokenUsage(
 							"deepseek",
 							deepSeekProvider.Model,
@@ -148,6 +144,7 @@ func (deepSeekProvider *DeepSeekConfig) ChatCompletionRequest(ctx context.Contex
 
 					break
 				}
+				markdownBuffer.Reset()
 				responseChan <- models.StreamResponse{Err: fmt.Errorf("error reading stream: %v", err)}
 				return
 			}
@@ -170,13 +167,13 @@ func (deepSeekProvider *DeepSeekConfig) ChatCompletionRequest(ctx context.Contex
 				if len(response.Choices) > 0 && response.Choices[0].FinishReason != "" {
 					// Stream completed for this choice
 					responseChan <- models.StreamResponse{Content: markdownBuffer.String()}
+					markdownBuffer.Reset()
 					responseChan <- models.StreamResponse{Done: true}
 
 					// Count total tokens usage
 					if usage.TotalTokens > 0 {
 						deepSeekProvider.TokenManagement.UsedTokens(usage.PromptTokens, usage.CompletionTokens)
 						// 显示本次使用的token统计
-						fmt.Print("\n")
 						deepSeekProvider.TokenManagement.DisplayTokenUsage(
 							"deepseek",
 							deepSeekProvider.Model,
@@ -1,16 +1,12 @@
 package models
 
+import general_models "github.com/meysamhadeli/codai/providers/models"
+
 // DeepSeekChatCompletionRequest Define the request body structure
 type DeepSeekChatCompletionRequest struct {
-	Model           string    `json:"model"`
-	Messages        []Message `json:"messages"`
-	Temperature     *float32  `json:"temperature,omitempty"`      // Optional field (pointer to float32)
-	ReasoningEffort *string   `json:"reasoning_effort,omitempty"` // Optional field (pointer to string)
-	Stream          bool      `json:"stream"`
-}
-
-// Message Define the request body structure
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Model           string                       `json:"model"`
+	Messages        []general_models.TextMessage `json:"messages"`
+	Temperature     *float32                     `json:"temperature,omitempty"`      // Optional field (pointer to float32)
+	ReasoningEffort *string                      `json:"reasoning_effort,omitempty"` // Optional field (pointer to string)
+	Stream          bool                         `json:"stream"`
 }
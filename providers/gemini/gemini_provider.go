@@ -46,29 +46,139 @@ func NewGeminiChatProvider(config *GeminiConfig) contracts.IChatAIProvider {
 	}
 }
 
-func (geminiProvider *GeminiConfig) ChatCompletionRequest(ctx context.Context, userInput string, prompt string) <-chan models.StreamResponse {
+func (geminiProvider *GeminiConfig) ChatCompletionRequest(ctx context.Context, messages []models.Message) <-chan models.StreamResponse {
+	systemInstruction, contents := toGeminiContents(messages)
+	return geminiProvider.generateContent(ctx, systemInstruction, contents, nil, "")
+}
+
+// ChatCompletionRequestWithTools sends the tools to the model with the messages. Gemini does not identify the calls,
+// they are answered in order, so the calls are identified by the number of model contents before them.
+func (geminiProvider *GeminiConfig) ChatCompletionRequestWithTools(ctx context.Context, messages []models.Message, tools []models.ToolDefinition) <-chan models.StreamResponse {
+	var declarations []gemini_models.FunctionDeclaration
+	for _, tool := range tools {
+		declarations = append(declarations, gemini_models.FunctionDeclaration{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  geminiSchema(tool.Parameters),
+		})
+	}
+	var geminiTools []gemini_models.Tool
+	if len(declarations) > 0 {
+		geminiTools = []gemini_models.Tool{{FunctionDeclarations: declarations}}
+	}
+
+	systemInstruction, contents := toGeminiContents(messages)
+	modelContents := 0
+	for _, content := range contents {
+		if content.Role == "model" {
+			modelContents++
+		}
+	}
+
+	return geminiProvider.generateContent(ctx, systemInstruction, contents, geminiTools, fmt.Sprintf("call_%d", modelContents))
+}
+
+// toGeminiContents converts the messages to the system instruction and the contents of the generate content API.
+// The assistant messages are model contents with their function calls, and the tool messages are user contents with
+// function responses; the consecutive messages of a role are merged, as Gemini expects the roles to alternate.
+func toGeminiContents(messages []models.Message) (*gemini_models.Content, []gemini_models.Content) {
+	var systemInstruction *gemini_models.Content
+	system, messages := models.SplitSystemPrompt(messages)
+	if system != "" {
+		systemInstruction = &gemini_models.Content{Parts: []gemini_models.Part{{Text: system}}}
+	}
+
+	var contents []gemini_models.Content
+	for _, message := range messages {
+		content := gemini_models.Content{Role: "user"}
+		switch message.Role {
+		case models.RoleAssistant:
+			content.Role = "model"
+			if text := message.Text(); text != "" {
+				content.Parts = append(content.Parts, gemini_models.Part{Text: text})
+			}
+			for _, call := range message.ToolCalls {
+				content.Parts = append(content.Parts, gemini_models.Part{
+					FunctionCall: &gemini_models.FunctionCall{Name: call.Name, Args: models.ArgumentsObject(call.Arguments)},
+				})
+			}
+		case models.RoleTool:
+			key := "content"
+			if message.IsError {
+				key = "error"
+			}
+			response, _ := json.Marshal(map[string]string{key: message.Text()})
+			content.Parts = append(content.Parts, gemini_models.Part{
+				FunctionResponse: &gemini_models.FunctionResponse{Name: message.ToolName, Response: response},
+			})
+		default:
+			content.Parts = append(content.Parts, gemini_models.Part{Text: message.Text()})
+		}
+		if len(content.Parts) == 0 {
+			continue
+		}
+
+		if last := len(contents) - 1; last >= 0 && contents[last].Role == content.Role {
+			contents[last].Parts = append(contents[last].Parts, content.Parts...)
+			continue
+		}
+		contents = append(contents, content)
+	}
+	return systemInstruction, contents
+}
+
+// unsupportedSchemaKeys are the keys of JSON schemas Gemini rejects in the parameters of functions
+var unsupportedSchemaKeys = []string{"$schema", "additionalProperties"}
+
+// geminiSchema removes the keys Gemini rejects from the JSON schema of the parameters of a function
+func geminiSchema(parameters json.RawMessage) json.RawMessage {
+	var schema interface{}
+	if err := json.Unmarshal(parameters, &schema); err != nil {
+		return parameters
+	}
+
+	var clean func(value interface{})
+	clean = func(value interface{}) {
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			for _, key := range unsupportedSchemaKeys {
+				delete(typed, key)
+			}
+			for _, child := range typed {
+				clean(child)
+			}
+		case []interface{}:
+			for _, child := range typed {
+				clean(child)
+			}
+		}
+	}
+	clean(schema)
+
+	cleaned, err := json.Marshal(schema)
+	if err != nil {
+		return parameters
+	}
+	return cleaned
+}
+
+// generateContent sends the system instruction and the contents to the generate content API, identifying the function
+// calls of the answer with callPrefix
+func (geminiProvider *GeminiConfig) generateContent(ctx context.Context, systemInstruction *gemini_models.Content, contents []gemini_models.Content, tools []gemini_models.Tool, callPrefix string) <-chan models.StreamResponse {
 	responseChan := make(chan models.StreamResponse)
 	var markdownBuffer strings.Builder
 
 	go func() {
 		defer close(responseChan)
 
-		// Combine system prompt and user input into a single user message
-		fullContent := fmt.Sprintf("%s\n\n%s", prompt, userInput)
-
 		reqBody := gemini_models.GeminiChatCompletionRequest{
-			Contents: []gemini_models.Content{
-				{
-					Role: "user",
-					Parts: []gemini_models.Part{
-						{Text: fullContent},
-					},
-				},
-			},
+			SystemInstruction: systemInstruction,
+			Contents:          contents,
 			GenerationConfig: &gemini_models.GenerationConfig{
 				Temperature:     geminiProvider.Temperature,
 				MaxOutputTokens: geminiProvider.MaxTokens,
 			},
+			Tools: tools,
 		}
 
 		jsonData, err := json.Marshal(reqBody)
@@ -120,10 +230,21 @@ func (geminiProvider *GeminiConfig) ChatCompletionRequest(ctx context.Context, u
 			return
 		}
 
+		var toolCalls []models.ToolCall
 		if len(fullResponse.Candidates) > 0 && len(fullResponse.Candidates[0].Content.Parts) > 0 {
-			content := fullResponse.Candidates[0].Content.Parts[0].Text
-			markdownBuffer.WriteString(content)
-			responseChan <- models.StreamResponse{Content: markdownBuffer.String()}
+			for _, part := range fullResponse.Candidates[0].Content.Parts {
+				markdownBuffer.WriteString(part.Text)
+				if part.FunctionCall != nil {
+					toolCalls = append(toolCalls, models.ToolCall{
+						ID:        fmt.Sprintf("%s_%d", callPrefix, len(toolCalls)),
+						Name:      part.FunctionCall.Name,
+						Arguments: string(models.ArgumentsObject(string(part.FunctionCall.Args))),
+					})
+				}
+			}
+			if markdownBuffer.Len() > 0 {
+				responseChan <- models.StreamResponse{Content: markdownBuffer.String()}
+			}
 		}
 
 		if fullResponse.UsageMetadata != nil {
@@ -132,7 +253,6 @@ func (geminiProvider *GeminiConfig) ChatCompletionRequest(ctx context.Context, u
 				fullResponse.UsageMetadata.CandidatesTokenCount,
 			)
 			// 显示本次使用的token统计
-			fmt.Print("\n")
 			geminiProvider.TokenManagement.DisplayTokenUsage(
 				"gemini",
 				geminiProvider.Model,
@@ -141,6 +261,10 @@ func (geminiProvider *GeminiConfig) ChatCompletionRequest(ctx context.Context, u
 			)
 		}
 
+		if len(toolCalls) > 0 {
+			responseChan <- models.StreamResponse{ToolCalls: toolCalls}
+		}
+
 		responseChan <- models.StreamResponse{Done: true}
 	}()
 
@@ -0,0 +1,91 @@
+package gemini
+
+import (
+	"encoding/json"
+	"testing"
+
+	gemini_models "github.com/meysamhadeli/codai/providers/gemini/models"
+	"github.com/meysamhadeli/codai/providers/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToGeminiContents(t *testing.T) {
+	readCall := models.ToolCall{ID: "call_1", Name: "read_file", Arguments: `{"path":"main.go"}`}
+	listCall := models.ToolCall{ID: "call_2", Name: "list_files", Arguments: `{}`}
+
+	tests := []struct {
+		name       string
+		messages   []models.Message
+		wantSystem *gemini_models.Content
+		want       []gemini_models.Content
+	}{
+		{
+			name:       "roles merged",
+			messages:   models.NewConversation("prompt", []models.Message{models.NewTextMessage(models.RoleUser, "hi"), models.NewTextMessage(models.RoleAssistant, "")}, "again"),
+			wantSystem: &gemini_models.Content{Parts: []gemini_models.Part{{Text: "prompt"}}},
+			want: []gemini_models.Content{
+				{Role: "user", Parts: []gemini_models.Part{{Text: "hi"}, {Text: "again"}}},
+			},
+		},
+		{
+			name: "function calls and responses",
+			messages: []models.Message{
+				models.NewTextMessage(models.RoleUser, "read main.go"),
+				models.NewAssistantMessage("", []models.ToolCall{readCall, listCall}),
+				models.NewToolMessage(models.ToolResult{CallID: "call_1", Name: "read_file", Content: "package main"}),
+				models.NewToolMessage(models.ToolResult{CallID: "call_2", Name: "list_files", Content: "denied", IsError: true}),
+				models.NewAssistantMessage("It is the main package.", nil),
+			},
+			want: []gemini_models.Content{
+				{Role: "user", Parts: []gemini_models.Part{{Text: "read main.go"}}},
+				{Role: "model", Parts: []gemini_models.Part{
+					{FunctionCall: &gemini_models.FunctionCall{Name: "read_file", Args: json.RawMessage(`{"path":"main.go"}`)}},
+					{FunctionCall: &gemini_models.FunctionCall{Name: "list_files", Args: json.RawMessage(`{}`)}},
+				}},
+				{Role: "user", Parts: []gemini_models.Part{
+					{FunctionResponse: &gemini_models.FunctionResponse{Name: "read_file", Response: json.RawMessage(`{"content":"package main"}`)}},
+					{FunctionResponse: &gemini_models.FunctionResponse{Name: "list_files", Response: json.RawMessage(`{"error":"denied"}`)}},
+				}},
+				{Role: "model", Parts: []gemini_models.Part{{Text: "It is the main package."}}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			system, contents := toGeminiContents(test.messages)
+			assert.Equal(t, test.wantSystem, system)
+			assert.Equal(t, test.want, contents)
+		})
+	}
+}
+
+func TestGeminiSchema(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters string
+		want       string
+	}{
+		{
+			name:       "unsupported keys removed",
+			parameters: `{"$schema":"http://json-schema.org/draft-07/schema#","type":"object","additionalProperties":false,"properties":{"path":{"type":"string"}}}`,
+			want:       `{"properties":{"path":{"type":"string"}},"type":"object"}`,
+		},
+		{
+			name:       "nested schemas",
+			parameters: `{"type":"object","properties":{"edits":{"type":"array","items":{"type":"object","additionalProperties":false}}},"anyOf":[{"additionalProperties":true}]}`,
+			want:       `{"anyOf":[{}],"properties":{"edits":{"items":{"type":"object"},"type":"array"}},"type":"object"}`,
+		},
+		{
+			name:       "invalid schema kept",
+			parameters: `not json`,
+			want:       `not json`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, string(geminiSchema(json.RawMessage(test.parameters))))
+		})
+	}
+}
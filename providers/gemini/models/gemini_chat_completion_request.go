@@ -1,18 +1,48 @@
 package models
 
+import "encoding/json"
+
 // GeminiChatCompletionRequest represents the request structure for Gemini API
 type GeminiChatCompletionRequest struct {
-	Contents         []Content         `json:"contents"`
-	GenerationConfig *GenerationConfig `json:"generationConfig,omitempty"`
+	SystemInstruction *Content          `json:"systemInstruction,omitempty"` // System prompt, apart from the contents
+	Contents          []Content         `json:"contents"`
+	GenerationConfig  *GenerationConfig `json:"generationConfig,omitempty"`
+	Tools             []Tool            `json:"tools,omitempty"`
 }
 
 type Content struct {
-	Role  string `json:"role"`
+	Role  string `json:"role,omitempty"` // "user" or "model", none for the system instruction
 	Parts []Part `json:"parts"`
 }
 
 type Part struct {
-	Text string `json:"text"`
+	Text             string            `json:"text,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// FunctionCall is a call of a function by the model, with its arguments as a JSON object
+type FunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// FunctionResponse is the result of a function call sent back to the model
+type FunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Tool holds the functions the model may call
+type Tool struct {
+	FunctionDeclarations []FunctionDeclaration `json:"functionDeclarations"`
+}
+
+// FunctionDeclaration describes a function with the schema of its arguments
+type FunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
 }
 
 type GenerationConfig struct {
@@ -1,15 +1,12 @@
 package models
 
+import general_models "github.com/meysamhadeli/codai/providers/models"
+
 // GrokChatCompletionRequest represents the request structure for Grok API
 type GrokChatCompletionRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	Temperature *float32  `json:"temperature,omitempty"`
-	MaxTokens   int       `json:"max_tokens,omitempty"`
-	Stream      bool      `json:"stream"`
-}
-
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Model       string                       `json:"model"`
+	Messages    []general_models.TextMessage `json:"messages"`
+	Temperature *float32                     `json:"temperature,omitempty"`
+	MaxTokens   int                          `json:"max_tokens,omitempty"`
+	Stream      bool                         `json:"stream"`
 }
@@ -49,7 +49,7 @@ func NewGrokChatProvider(config *GrokConfig) contracts.IChatAIProvider {
 	}
 }
 
-func (grokProvider *GrokConfig) ChatCompletionRequest(ctx context.Context, userInput string, prompt string) <-chan models.StreamResponse {
+func (grokProvider *GrokConfig) ChatCompletionRequest(ctx context.Context, messages []models.Message) <-chan models.StreamResponse {
 	responseChan := make(chan models.StreamResponse)
 	var markdownBuffer strings.Builder
 	var usage grok_models.Usage
@@ -58,11 +58,8 @@ func (grokProvider *GrokConfig) ChatCompletionRequest(ctx context.Context, userI
 		defer close(responseChan)
 
 		reqBody := grok_models.GrokChatCompletionRequest{
-			Model: grokProvider.Model,
-			Messages: []grok_models.Message{
-				{Role: "system", Content: prompt},
-				{Role: "user", Content: userInput},
-			},
+			Model:       grokProvider.Model,
+			Messages:    models.ToTextMessages(messages),
 			Temperature: grokProvider.Temperature,
 			MaxTokens:   grokProvider.MaxTokens,
 			Stream:      true,
@@ -151,7 +148,6 @@ func (grokProvider *GrokConfig) ChatCompletionRequest(ctx context.Context, userI
 		if usage.TotalTokens > 0 {
 			grokProvider.TokenManagement.UsedTokens(usage.PromptTokens, usage.CompletionTokens)
 			// 显示本次使用的token统计
-			fmt.Print("\n")
 			grokProvider.TokenManagement.DisplayTokenUsage(
 				"grok",
 				grokProvider.Model,
@@ -47,20 +47,54 @@ func NewMistralChatProvider(config *MistralConfig) contracts.IChatAIProvider {
 	}
 }
 
-func (mistralProvider *MistralConfig) ChatCompletionRequest(ctx context.Context, userInput string, prompt string) <-chan models.StreamResponse {
+func (mistralProvider *MistralConfig) ChatCompletionRequest(ctx context.Context, messages []models.Message) <-chan models.StreamResponse {
+	return mistralProvider.streamChatCompletion(ctx, toMistralMessages(messages), nil)
+}
+
+// ChatCompletionRequestWithTools sends the tools to the model with the messages
+func (mistralProvider *MistralConfig) ChatCompletionRequestWithTools(ctx context.Context, messages []models.Message, tools []models.ToolDefinition) <-chan models.StreamResponse {
+	var mistralTools []mistral_models.Tool
+	for _, tool := range tools {
+		mistralTools = append(mistralTools, mistral_models.Tool{
+			Type:     "function",
+			Function: mistral_models.FunctionDefinition{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters},
+		})
+	}
+
+	return mistralProvider.streamChatCompletion(ctx, toMistralMessages(messages), mistralTools)
+}
+
+// toMistralMessages converts the messages to the chat completion API, the tool messages name their function
+func toMistralMessages(messages []models.Message) []mistral_models.Message {
+	mistralMessages := make([]mistral_models.Message, 0, len(messages))
+	for _, message := range messages {
+		mistralMessage := mistral_models.Message{Role: string(message.Role), Content: message.Text(), ToolCallID: message.ToolCallID, Name: message.ToolName}
+		for _, call := range message.ToolCalls {
+			mistralMessage.ToolCalls = append(mistralMessage.ToolCalls, mistral_models.ToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: mistral_models.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		mistralMessages = append(mistralMessages, mistralMessage)
+	}
+	return mistralMessages
+}
+
+// streamChatCompletion sends the messages to the chat completion API and streams the answer
+func (mistralProvider *MistralConfig) streamChatCompletion(ctx context.Context, messages []mistral_models.Message, tools []mistral_models.Tool) <-chan models.StreamResponse {
 	responseChan := make(chan models.StreamResponse)
 	var markdownBuffer strings.Builder
 	var usage mistral_models.Usage
+	var toolCalls []models.ToolCall
 
 	go func() {
 		defer close(responseChan)
 
 		reqBody := mistral_models.MistralChatCompletionRequest{
-			Model: mistralProvider.Model,
-			Messages: []mistral_models.Message{
-				{Role: "system", Content: prompt},
-				{Role: "user", Content: userInput},
-			},
+			Model:       mistralProvider.Model,
+			Messages:    messages,
+			Tools:       tools,
 			Temperature: mistralProvider.Temperature,
 			MaxTokens:   mistralProvider.MaxTokens,
 			Stream:      true,
@@ -118,6 +152,10 @@ func (mistralProvider *MistralConfig) ChatCompletionRequest(ctx context.Context,
 
 			if strings.HasPrefix(line, "data:") {
 				jsonPart := strings.TrimPrefix(line, "data:")
+				// The end of the stream is marked by [DONE]
+				if strings.TrimSpace(jsonPart) == "[DONE]" {
+					break
+				}
 				var response mistral_models.MistralChatCompletionResponse
 				if err := json.Unmarshal([]byte(jsonPart), &response); err != nil {
 					responseChan <- models.StreamResponse{Err: fmt.Errorf("error unmarshalling chunk: %v", err)}
@@ -129,6 +167,10 @@ func (mistralProvider *MistralConfig) ChatCompletionRequest(ctx context.Context,
 				}
 
 				if len(response.Choices) > 0 {
+					for _, call := range response.Choices[0].Delta.ToolCalls {
+						toolCalls = append(toolCalls, models.ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+					}
+
 					content := response.Choices[0].Delta.Content
 					markdownBuffer.WriteString(content)
 
@@ -144,11 +186,14 @@ func (mistralProvider *MistralConfig) ChatCompletionRequest(ctx context.Context,
 			responseChan <- models.StreamResponse{Content: markdownBuffer.String()}
 		}
 
+		if len(toolCalls) > 0 {
+			responseChan <- models.StreamResponse{ToolCalls: toolCalls}
+		}
+
 		responseChan <- models.StreamResponse{Done: true}
 		if usage.TotalTokens > 0 {
 			mistralProvider.TokenManagement.UsedTokens(usage.PromptTokens, usage.CompletionTokens)
 			// 显示本次使用的token统计
-			fmt.Print("\n")
 			mistralProvider.TokenManagement.DisplayTokenUsage(
 				"mistral",
 				mistralProvider.Model,
@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 // MistralChatCompletionRequest represents the request structure for Mistral API
 type MistralChatCompletionRequest struct {
 	Model       string    `json:"model"`
@@ -7,9 +9,39 @@ type MistralChatCompletionRequest struct {
 	Temperature *float32  `json:"temperature,omitempty"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
 	Stream      bool      `json:"stream"`
+	Tools       []Tool    `json:"tools,omitempty"` // Tools the model may call
 }
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // Calls of an assistant message
+	ToolCallID string     `json:"tool_call_id,omitempty"` // Call answered by a tool message
+	Name       string     `json:"name,omitempty"`         // Function answered by a tool message
+}
+
+// Tool is a function the model may call
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition describes a function with the JSON schema of its arguments
+type FunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolCall is a call of a function by the model
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall is the name of a called function with its arguments in JSON
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
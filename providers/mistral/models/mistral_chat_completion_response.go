@@ -11,7 +11,8 @@ type Choice struct {
 }
 
 type Delta struct {
-	Content string `json:"content"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls"` // Mistral streams every tool call whole
 }
 
 type Usage struct {
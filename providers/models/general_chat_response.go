@@ -1,9 +1,53 @@
 package models
 
+import "strings"
+
 type StreamResponse struct {
-	Content string // Holds content chunks
-	Err     error  // Holds error details
-	Done    bool   // Signals end of stream
+	Content   string     // Holds content chunks
+	Err       error      // Holds error details
+	Done      bool       // Signals end of stream
+	ToolCalls []ToolCall // Holds the tool calls of the model, sent before Done
+}
+
+// Answer is the whole answer of a chat completion request
+type Answer struct {
+	Content   string
+	ToolCalls []ToolCall
+	Done      bool // Tells whether the provider finished the answer, false when it was cut by a cancellation or an error
+}
+
+// CollectAnswer reads the responses of a request until the provider closes the channel and returns the whole answer,
+// passing each chunk of content to onChunk when it is not nil. The first error of the provider or of onChunk is
+// returned once the channel is drained, and nothing sent after Done is part of the answer.
+func CollectAnswer(responses <-chan StreamResponse, onChunk func(content string) error) (Answer, error) {
+	var answer Answer
+	var builder strings.Builder
+	var firstErr error
+	for response := range responses {
+		if response.Err != nil {
+			if firstErr == nil {
+				firstErr = response.Err
+			}
+			continue
+		}
+		if answer.Done || firstErr != nil {
+			continue
+		}
+		if response.Done {
+			answer.Done = true
+			continue
+		}
+		answer.ToolCalls = append(answer.ToolCalls, response.ToolCalls...)
+		if response.Content == "" {
+			continue
+		}
+		builder.WriteString(response.Content)
+		if onChunk != nil {
+			firstErr = onChunk(response.Content)
+		}
+	}
+	answer.Content = builder.String()
+	return answer, firstErr
 }
 
 type Error struct {
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stream(responses ...StreamResponse) <-chan StreamResponse {
+	responseChan := make(chan StreamResponse, len(responses))
+	for _, response := range responses {
+		responseChan <- response
+	}
+	close(responseChan)
+	return responseChan
+}
+
+func TestCollectAnswer(t *testing.T) {
+	var chunks []string
+	answer, err := CollectAnswer(stream(
+		StreamResponse{Content: "Hello\n"},
+		StreamResponse{Content: "world"},
+		StreamResponse{ToolCalls: []ToolCall{{ID: "call_1", Name: "read_file"}}},
+		StreamResponse{Done: true},
+		StreamResponse{Content: "world"},
+	), func(content string) error {
+		chunks = append(chunks, content)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Hello\nworld", answer.Content)
+	assert.Equal(t, []string{"Hello\n", "world"}, chunks)
+	assert.Equal(t, []ToolCall{{ID: "call_1", Name: "read_file"}}, answer.ToolCalls)
+	assert.True(t, answer.Done)
+}
+
+func TestCollectAnswer_Errors(t *testing.T) {
+	providerErr := errors.New("rate limited")
+	answer, err := CollectAnswer(stream(
+		StreamResponse{Content: "Hello"},
+		StreamResponse{Err: providerErr},
+	), nil)
+	assert.ErrorIs(t, err, providerErr)
+	assert.Equal(t, "Hello", answer.Content)
+	assert.False(t, answer.Done)
+
+	chunkErr := errors.New("output closed")
+	calls := 0
+	_, err = CollectAnswer(stream(
+		StreamResponse{Content: "Hello"},
+		StreamResponse{Content: "world"},
+		StreamResponse{Done: true},
+	), func(content string) error {
+		calls++
+		return chunkErr
+	})
+	assert.ErrorIs(t, err, chunkErr)
+	assert.Equal(t, 1, calls)
+}
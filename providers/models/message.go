@@ -0,0 +1,137 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Role is the author of a message
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+)
+
+// ContentType is the kind of content of a part of a message
+type ContentType string
+
+const (
+	ContentText ContentType = "text"
+)
+
+// MetadataTime is the metadata key of the time a message was added to the history, in RFC 3339
+const MetadataTime = "time"
+
+// MetadataSummary is the metadata key marking the message summarizing the older turns of a compacted history
+const MetadataSummary = "summary"
+
+// ContentPart is a part of the content of a message
+type ContentPart struct {
+	Type ContentType `json:"type"`
+	Text string      `json:"text,omitempty"`
+}
+
+// Message is a message of a conversation with the model. The system prompt is the first message, then the user and
+// assistant messages alternate, with the tool messages answering the tool calls of an assistant message.
+type Message struct {
+	Role       Role              `json:"role"`
+	Parts      []ContentPart     `json:"parts,omitempty"`
+	ToolCalls  []ToolCall        `json:"tool_calls,omitempty"`   // Calls of an assistant message
+	ToolCallID string            `json:"tool_call_id,omitempty"` // Call answered by a tool message
+	ToolName   string            `json:"tool_name,omitempty"`    // Tool of the call answered by a tool message
+	IsError    bool              `json:"is_error,omitempty"`     // Whether the tool of a tool message failed
+	Metadata   map[string]string `json:"metadata,omitempty"`     // Not sent to the model
+}
+
+// NewTextMessage creates a message of the role with a text content
+func NewTextMessage(role Role, text string) Message {
+	return Message{Role: role, Parts: []ContentPart{{Type: ContentText, Text: text}}}
+}
+
+// NewAssistantMessage creates the message of an answer of the model with its tool calls
+func NewAssistantMessage(content string, calls []ToolCall) Message {
+	message := NewTextMessage(RoleAssistant, content)
+	message.ToolCalls = calls
+	return message
+}
+
+// NewToolMessage creates the message sending the result of a tool call back to the model
+func NewToolMessage(result ToolResult) Message {
+	message := NewTextMessage(RoleTool, result.Content)
+	message.ToolCallID = result.CallID
+	message.ToolName = result.Name
+	message.IsError = result.IsError
+	return message
+}
+
+// NewConversation creates the messages of a request: the system prompt, the history and the user input
+func NewConversation(prompt string, history []Message, userInput string) []Message {
+	messages := make([]Message, 0, len(history)+2)
+	messages = append(messages, NewTextMessage(RoleSystem, prompt))
+	messages = append(messages, history...)
+	return append(messages, NewTextMessage(RoleUser, userInput))
+}
+
+// Text returns the text parts of the message joined together
+func (message Message) Text() string {
+	var text strings.Builder
+	for _, part := range message.Parts {
+		if part.Type == ContentText {
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String()
+}
+
+// WithTime returns the message with the time in its metadata, keeping the time it may already have
+func (message Message) WithTime(now time.Time) Message {
+	if _, ok := message.Metadata[MetadataTime]; ok {
+		return message
+	}
+	metadata := make(map[string]string, len(message.Metadata)+1)
+	for key, value := range message.Metadata {
+		metadata[key] = value
+	}
+	metadata[MetadataTime] = now.Format(time.RFC3339)
+	message.Metadata = metadata
+	return message
+}
+
+// SplitSystemPrompt returns the text of the leading system messages and the other messages, for the APIs taking the
+// system prompt apart from the messages
+func SplitSystemPrompt(messages []Message) (string, []Message) {
+	var system []string
+	for len(messages) > 0 && messages[0].Role == RoleSystem {
+		system = append(system, messages[0].Text())
+		messages = messages[1:]
+	}
+	return strings.Join(system, "\n\n"), messages
+}
+
+// TextMessage is a message of the chat completion APIs offering no tools, with its text only
+type TextMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ToTextMessages converts the messages to the APIs offering no tools. The tool messages are dropped with the assistant
+// messages carrying only tool calls, and the messages of a role following each other are joined, as these APIs expect
+// the user and assistant messages to alternate.
+func ToTextMessages(messages []Message) []TextMessage {
+	converted := make([]TextMessage, 0, len(messages))
+	for _, message := range messages {
+		text := message.Text()
+		if message.Role == RoleTool || (message.Role == RoleAssistant && len(message.ToolCalls) > 0 && text == "") {
+			continue
+		}
+		if last := len(converted) - 1; last >= 0 && converted[last].Role == string(message.Role) && message.Role != RoleSystem {
+			converted[last].Content += "\n\n" + text
+			continue
+		}
+		converted = append(converted, TextMessage{Role: string(message.Role), Content: text})
+	}
+	return converted
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToTextMessages(t *testing.T) {
+	call := ToolCall{ID: "call_1", Name: "read_file", Arguments: `{"path":"main.go"}`}
+	tests := []struct {
+		name     string
+		messages []Message
+		want     []TextMessage
+	}{
+		{
+			name:     "conversation",
+			messages: NewConversation("prompt", []Message{NewTextMessage(RoleUser, "hi"), NewTextMessage(RoleAssistant, "hello")}, "bye"),
+			want: []TextMessage{
+				{Role: "system", Content: "prompt"},
+				{Role: "user", Content: "hi"},
+				{Role: "assistant", Content: "hello"},
+				{Role: "user", Content: "bye"},
+			},
+		},
+		{
+			name: "tool calls only",
+			messages: []Message{
+				NewTextMessage(RoleUser, "read main.go"),
+				NewAssistantMessage("", []ToolCall{call}),
+				NewToolMessage(ToolResult{CallID: call.ID, Name: call.Name, Content: "package main"}),
+				NewAssistantMessage("It is the main package.", nil),
+			},
+			want: []TextMessage{
+				{Role: "user", Content: "read main.go"},
+				{Role: "assistant", Content: "It is the main package."},
+			},
+		},
+		{
+			name: "tool calls with text",
+			messages: []Message{
+				NewTextMessage(RoleUser, "read main.go"),
+				NewAssistantMessage("Let me read it.", []ToolCall{call}),
+				NewToolMessage(ToolResult{CallID: call.ID, Name: call.Name, Content: "package main"}),
+				NewAssistantMessage("It is the main package.", nil),
+			},
+			want: []TextMessage{
+				{Role: "user", Content: "read main.go"},
+				{Role: "assistant", Content: "Let me read it.\n\nIt is the main package."},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, ToTextMessages(test.messages))
+		})
+	}
+}
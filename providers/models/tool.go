@@ -0,0 +1,49 @@
+package models
+
+import "encoding/json"
+
+// ToolDefinition is a tool the model may call, with the JSON schema of its arguments
+type ToolDefinition struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+}
+
+// ToolCall is the call of a tool requested by the model, with its arguments in JSON
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolResult is the result of a tool call sent back to the model
+type ToolResult struct {
+	CallID  string
+	Name    string
+	Content string
+	IsError bool
+}
+
+// AppendToolCallDelta adds a chunk of a streamed tool call to the calls, the chunks of a call share its index
+func AppendToolCallDelta(calls []ToolCall, index int, id string, name string, arguments string) []ToolCall {
+	for len(calls) <= index {
+		calls = append(calls, ToolCall{})
+	}
+	call := &calls[index]
+	if id != "" {
+		call.ID = id
+	}
+	call.Name += name
+	call.Arguments += arguments
+	return calls
+}
+
+// ArgumentsObject returns the arguments of a call as a JSON object, for the APIs taking them as an object instead of
+// a string. Arguments that are not an object are replaced by an empty object.
+func ArgumentsObject(arguments string) json.RawMessage {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(arguments), &object); err != nil || object == nil {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendToolCallDelta(t *testing.T) {
+	type delta struct {
+		index     int
+		id        string
+		name      string
+		arguments string
+	}
+	tests := []struct {
+		name   string
+		deltas []delta
+		want   []ToolCall
+	}{
+		{
+			name: "chunks of a call",
+			deltas: []delta{
+				{index: 0, id: "call_1", name: "read_", arguments: `{"pa`},
+				{index: 0, name: "file", arguments: `th":"main.go"}`},
+			},
+			want: []ToolCall{{ID: "call_1", Name: "read_file", Arguments: `{"path":"main.go"}`}},
+		},
+		{
+			name: "interleaved calls",
+			deltas: []delta{
+				{index: 0, id: "call_1", name: "read_file"},
+				{index: 1, id: "call_2", name: "list_files", arguments: "{}"},
+				{index: 0, arguments: `{"path":"main.go"}`},
+			},
+			want: []ToolCall{
+				{ID: "call_1", Name: "read_file", Arguments: `{"path":"main.go"}`},
+				{ID: "call_2", Name: "list_files", Arguments: "{}"},
+			},
+		},
+		{
+			name:   "call out of order",
+			deltas: []delta{{index: 1, id: "call_2", name: "list_files"}},
+			want:   []ToolCall{{}, {ID: "call_2", Name: "list_files"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []ToolCall
+			for _, delta := range test.deltas {
+				calls = AppendToolCallDelta(calls, delta.index, delta.id, delta.name, delta.arguments)
+			}
+			assert.Equal(t, test.want, calls)
+		})
+	}
+}
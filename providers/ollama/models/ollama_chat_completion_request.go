@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 // OllamaChatCompletionRequest Define the request body structure
 type OllamaChatCompletionRequest struct {
 	Model           string    `json:"model"`
@@ -7,10 +9,37 @@ type OllamaChatCompletionRequest struct {
 	Temperature     *float32  `json:"temperature,omitempty"`      // Optional field (pointer to float32)
 	ReasoningEffort *string   `json:"reasoning_effort,omitempty"` // Optional field (pointer to string)
 	Stream          bool      `json:"stream"`
+	Tools           []Tool    `json:"tools,omitempty"` // Tools the model may call
 }
 
 // Message Define the request body structure
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"` // Calls of an assistant message
+	ToolName  string     `json:"tool_name,omitempty"`  // Function answered by a tool message
+}
+
+// Tool is a function the model may call
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition describes a function with the JSON schema of its arguments
+type FunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolCall is a call of a function by the model, Ollama does not identify the calls
+type ToolCall struct {
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall is the name of a called function with its arguments as a JSON object
+type FunctionCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
 }
@@ -14,6 +14,7 @@ type OllamaChatCompletionResponse struct {
 
 // OllamaMessage represents the content of the message from the assistant.
 type OllamaMessage struct {
-	Role    string `json:"role"`    // Role of the message sender (e.g., "assistant")
-	Content string `json:"content"` // The content of the message
+	Role      string     `json:"role"`       // Role of the message sender (e.g., "assistant")
+	Content   string     `json:"content"`    // The content of the message
+	ToolCalls []ToolCall `json:"tool_calls"` // The tools the model calls
 }
@@ -50,20 +50,60 @@ func NewOllamaChatProvider(config *OllamaConfig) contracts.IChatAIProvider {
 	}
 }
 
-func (ollamaProvider *OllamaConfig) ChatCompletionRequest(ctx context.Context, userInput string, prompt string) <-chan models.StreamResponse {
+func (ollamaProvider *OllamaConfig) ChatCompletionRequest(ctx context.Context, messages []models.Message) <-chan models.StreamResponse {
+	return ollamaProvider.streamChat(ctx, toOllamaMessages(messages), nil, "")
+}
+
+// ChatCompletionRequestWithTools sends the tools to the model with the messages. Ollama answers the calls in order,
+// without identifiers, so the calls are identified by the number of assistant messages before them.
+func (ollamaProvider *OllamaConfig) ChatCompletionRequestWithTools(ctx context.Context, messages []models.Message, tools []models.ToolDefinition) <-chan models.StreamResponse {
+	var ollamaTools []ollama_models.Tool
+	for _, tool := range tools {
+		ollamaTools = append(ollamaTools, ollama_models.Tool{
+			Type:     "function",
+			Function: ollama_models.FunctionDefinition{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters},
+		})
+	}
+
+	assistantMessages := 0
+	for _, message := range messages {
+		if message.Role == models.RoleAssistant {
+			assistantMessages++
+		}
+	}
+
+	return ollamaProvider.streamChat(ctx, toOllamaMessages(messages), ollamaTools, fmt.Sprintf("call_%d", assistantMessages))
+}
+
+// toOllamaMessages converts the messages to the chat API, the tool messages name their function
+func toOllamaMessages(messages []models.Message) []ollama_models.Message {
+	ollamaMessages := make([]ollama_models.Message, 0, len(messages))
+	for _, message := range messages {
+		ollamaMessage := ollama_models.Message{Role: string(message.Role), Content: message.Text(), ToolName: message.ToolName}
+		for _, call := range message.ToolCalls {
+			ollamaMessage.ToolCalls = append(ollamaMessage.ToolCalls, ollama_models.ToolCall{
+				Function: ollama_models.FunctionCall{Name: call.Name, Arguments: models.ArgumentsObject(call.Arguments)},
+			})
+		}
+		ollamaMessages = append(ollamaMessages, ollamaMessage)
+	}
+	return ollamaMessages
+}
+
+// streamChat sends the messages to the chat API and streams the answer, identifying its tool calls with callPrefix
+func (ollamaProvider *OllamaConfig) streamChat(ctx context.Context, messages []ollama_models.Message, tools []ollama_models.Tool, callPrefix string) <-chan models.StreamResponse {
 	responseChan := make(chan models.StreamResponse)
 	var markdownBuffer strings.Builder // Buffer to accumulate content until newline
+	var toolCalls []models.ToolCall    // Tool calls of the answer
 
 	go func() {
 		defer close(responseChan)
 
 		// Prepare the request body
 		reqBody := ollama_models.OllamaChatCompletionRequest{
-			Model: ollamaProvider.Model,
-			Messages: []ollama_models.Message{
-				{Role: "system", Content: prompt},
-				{Role: "user", Content: userInput},
-			},
+			Model:       ollamaProvider.Model,
+			Messages:    messages,
+			Tools:       tools,
 			Stream:      true,
 			Temperature: ollamaProvider.Temperature,
 		}
@@ -146,17 +186,29 @@ func (ollamaProvider *OllamaConfig) ChatCompletionRequest(ctx context.Context, u
 				}
 			}
 
+			for _, call := range response.Message.ToolCalls {
+				toolCalls = append(toolCalls, models.ToolCall{
+					ID:        fmt.Sprintf("%s_%d", callPrefix, len(toolCalls)),
+					Name:      call.Function.Name,
+					Arguments: string(models.ArgumentsObject(string(call.Function.Arguments))),
+				})
+			}
+
 			// Check if the response is marked as done
 			if response.Done {
 				//	// Signal end of stream
 				responseChan <- models.StreamResponse{Content: markdownBuffer.String()}
+				markdownBuffer.Reset()
+
+				if len(toolCalls) > 0 {
+					responseChan <- models.StreamResponse{ToolCalls: toolCalls}
+				}
 				responseChan <- models.StreamResponse{Done: true}
 
 				// Count total tokens usage
 				if response.PromptEvalCount > 0 {
 					ollamaProvider.TokenManagement.UsedTokens(response.PromptEvalCount, response.EvalCount)
 					// 显示本次使用的token统计
-					fmt.Print("\n")
 					ollamaProvider.TokenManagement.DisplayTokenUsage(
 						"ollama",
 						ollamaProvider.Model,
@@ -54,21 +54,55 @@ func NewOpenAIChatProvider(config *OpenAIConfig) contracts.IChatAIProvider {
 	}
 }
 
-func (openAIProvider *OpenAIConfig) ChatCompletionRequest(ctx context.Context, userInput string, prompt string) <-chan models.StreamResponse {
+func (openAIProvider *OpenAIConfig) ChatCompletionRequest(ctx context.Context, messages []models.Message) <-chan models.StreamResponse {
+	return openAIProvider.streamChatCompletion(ctx, toOpenAIMessages(messages), nil)
+}
+
+// ChatCompletionRequestWithTools sends the tools to the model with the messages
+func (openAIProvider *OpenAIConfig) ChatCompletionRequestWithTools(ctx context.Context, messages []models.Message, tools []models.ToolDefinition) <-chan models.StreamResponse {
+	var openAITools []openai_models.Tool
+	for _, tool := range tools {
+		openAITools = append(openAITools, openai_models.Tool{
+			Type:     "function",
+			Function: openai_models.FunctionDefinition{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters},
+		})
+	}
+
+	return openAIProvider.streamChatCompletion(ctx, toOpenAIMessages(messages), openAITools)
+}
+
+// toOpenAIMessages converts the messages to the chat completion API, with the tool calls of the assistant messages
+func toOpenAIMessages(messages []models.Message) []openai_models.Message {
+	openAIMessages := make([]openai_models.Message, 0, len(messages))
+	for _, message := range messages {
+		openAIMessage := openai_models.Message{Role: string(message.Role), Content: message.Text(), ToolCallID: message.ToolCallID}
+		for _, call := range message.ToolCalls {
+			openAIMessage.ToolCalls = append(openAIMessage.ToolCalls, openai_models.ToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: openai_models.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		openAIMessages = append(openAIMessages, openAIMessage)
+	}
+	return openAIMessages
+}
+
+// streamChatCompletion sends the messages to the chat completion API and streams the answer
+func (openAIProvider *OpenAIConfig) streamChatCompletion(ctx context.Context, messages []openai_models.Message, tools []openai_models.Tool) <-chan models.StreamResponse {
 	responseChan := make(chan models.StreamResponse)
 	var markdownBuffer strings.Builder // Buffer to accumulate content until newline
 	var usage openai_models.Usage      // Variable to hold usage data
+	var toolCalls []models.ToolCall    // Tool calls assembled from their chunks, by index
 
 	go func() {
 		defer close(responseChan)
 
 		// Prepare the request body
 		reqBody := openai_models.OpenAIChatCompletionRequest{
-			Model: openAIProvider.Model,
-			Messages: []openai_models.Message{
-				{Role: "system", Content: prompt},
-				{Role: "user", Content: userInput},
-			},
+			Model:           openAIProvider.Model,
+			Messages:        messages,
+			Tools:           tools,
 			Stream:          true,
 			Temperature:     openAIProvider.Temperature,
 			ReasoningEffort: openAIProvider.ReasoningEffort,
@@ -138,6 +172,11 @@ func (openAIProvider *OpenAIConfig) ChatCompletionRequest(ctx context.Context, u
 			if line == "data: [DONE]\n" {
 				// Send the final content
 				responseChan <- models.StreamResponse{Content: markdownBuffer.String()}
+				markdownBuffer.Reset()
+
+				if len(toolCalls) > 0 {
+					responseChan <- models.StreamResponse{ToolCalls: toolCalls}
+				}
 
 				responseChan <- models.StreamResponse{Done: true}
 
@@ -145,7 +184,6 @@ func (openAIProvider *OpenAIConfig) ChatCompletionRequest(ctx context.Context, u
 				if usage.TotalTokens > 0 {
 					openAIProvider.TokenManagement.UsedTokens(usage.PromptTokens, usage.CompletionTokens)
 					// 显示本次使用的token统计
-					fmt.Print("\n")
 					openAIProvider.TokenManagement.DisplayTokenUsage(
 						"openai",
 						openAIProvider.Model,
@@ -172,6 +210,13 @@ func (openAIProvider *OpenAIConfig) ChatCompletionRequest(ctx context.Context, u
 					usage = response.Usage // Capture the usage data for later use
 				}
 
+				// Accumulate the chunks of the tool calls
+				if len(response.Choices) > 0 {
+					for _, delta := range response.Choices[0].Delta.ToolCalls {
+						toolCalls = models.AppendToolCallDelta(toolCalls, delta.Index, delta.ID, delta.Function.Name, delta.Function.Arguments)
+					}
+				}
+
 				// Accumulate and send response content
 				if len(response.Choices) > 0 {
 					content := response.Choices[0].Delta.Content
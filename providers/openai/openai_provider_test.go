@@ -0,0 +1,48 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/meysamhadeli/codai/providers/models"
+	openai_models "github.com/meysamhadeli/codai/providers/openai/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToOpenAIMessages(t *testing.T) {
+	call := models.ToolCall{ID: "call_1", Name: "read_file", Arguments: `{"path":"main.go"}`}
+
+	tests := []struct {
+		name     string
+		messages []models.Message
+		want     []openai_models.Message
+	}{
+		{
+			name:     "text messages",
+			messages: models.NewConversation("prompt", []models.Message{models.NewTextMessage(models.RoleAssistant, "hello")}, "hi"),
+			want: []openai_models.Message{
+				{Role: "system", Content: "prompt"},
+				{Role: "assistant", Content: "hello"},
+				{Role: "user", Content: "hi"},
+			},
+		},
+		{
+			name: "tool calls",
+			messages: []models.Message{
+				models.NewAssistantMessage("", []models.ToolCall{call}),
+				models.NewToolMessage(models.ToolResult{CallID: "call_1", Name: "read_file", Content: "package main"}),
+			},
+			want: []openai_models.Message{
+				{Role: "assistant", ToolCalls: []openai_models.ToolCall{
+					{ID: "call_1", Type: "function", Function: openai_models.FunctionCall{Name: "read_file", Arguments: `{"path":"main.go"}`}},
+				}},
+				{Role: "tool", Content: "package main", ToolCallID: "call_1"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, toOpenAIMessages(test.messages))
+		})
+	}
+}
@@ -55,22 +55,56 @@ func NewOpenRouterChatProvider(config *OpenRouterConfig) contracts.IChatAIProvid
 	}
 }
 
-func (openRouterProvider *OpenRouterConfig) ChatCompletionRequest(ctx context.Context, userInput string, prompt string) <-chan general_models.StreamResponse {
+func (openRouterProvider *OpenRouterConfig) ChatCompletionRequest(ctx context.Context, messages []general_models.Message) <-chan general_models.StreamResponse {
+	return openRouterProvider.streamChatCompletion(ctx, toOpenRouterMessages(messages), nil)
+}
+
+// ChatCompletionRequestWithTools sends the tools to the model with the messages
+func (openRouterProvider *OpenRouterConfig) ChatCompletionRequestWithTools(ctx context.Context, messages []general_models.Message, tools []general_models.ToolDefinition) <-chan general_models.StreamResponse {
+	var openRouterTools []models.Tool
+	for _, tool := range tools {
+		openRouterTools = append(openRouterTools, models.Tool{
+			Type:     "function",
+			Function: models.FunctionDefinition{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters},
+		})
+	}
+
+	return openRouterProvider.streamChatCompletion(ctx, toOpenRouterMessages(messages), openRouterTools)
+}
+
+// toOpenRouterMessages converts the messages to the chat completion API, with the tool calls of the assistant messages
+func toOpenRouterMessages(messages []general_models.Message) []models.Message {
+	converted := make([]models.Message, 0, len(messages))
+	for _, message := range messages {
+		convertedMessage := models.Message{Role: string(message.Role), Content: message.Text(), ToolCallID: message.ToolCallID}
+		for _, call := range message.ToolCalls {
+			convertedMessage.ToolCalls = append(convertedMessage.ToolCalls, models.ToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: models.FunctionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		converted = append(converted, convertedMessage)
+	}
+	return converted
+}
+
+// streamChatCompletion sends the messages to the chat completion API and streams the answer
+func (openRouterProvider *OpenRouterConfig) streamChatCompletion(ctx context.Context, messages []models.Message, tools []models.Tool) <-chan general_models.StreamResponse {
 	responseChan := make(chan general_models.StreamResponse)
-	var markdownBuffer strings.Builder // Buffer to accumulate content until newline
-	var usage models.Usage             // Variable to hold usage data
-	var tokensCounted bool             // Flag to prevent duplicate token counting
+	var markdownBuffer strings.Builder      // Buffer to accumulate content until newline
+	var usage models.Usage                  // Variable to hold usage data
+	var tokensCounted bool                  // Flag to prevent duplicate token counting
+	var toolCalls []general_models.ToolCall // Tool calls assembled from their chunks, by index
 
 	go func() {
 		defer close(responseChan)
 
 		// Prepare the request body
 		reqBody := models.OpenRouterChatCompletionRequest{
-			Model: openRouterProvider.Model,
-			Messages: []models.Message{
-				{Role: "system", Content: prompt},
-				{Role: "user", Content: userInput},
-			},
+			Model:           openRouterProvider.Model,
+			Messages:        messages,
+			Tools:           tools,
 			Stream:          true,
 			Temperature:     openRouterProvider.Temperature,
 			ReasoningEffort: openRouterProvider.ReasoningEffort,
@@ -161,6 +195,12 @@ func (openRouterProvider *OpenRouterConfig) ChatCompletionRequest(ctx context.Co
 				// Accumulate and send response content
 				if len(response.Choices) > 0 {
 					choice := response.Choices[0]
+
+					// Accumulate the chunks of the tool calls
+					for _, delta := range choice.Delta.ToolCalls {
+						toolCalls = general_models.AppendToolCallDelta(toolCalls, delta.Index, delta.ID, delta.Function.Name, delta.Function.Arguments)
+					}
+
 					content := choice.Delta.Content
 					markdownBuffer.WriteString(content)
 
@@ -171,8 +211,13 @@ func (openRouterProvider *OpenRouterConfig) ChatCompletionRequest(ctx context.Co
 					}
 
 					// Check for completion using FinishReason
-					if choice.FinishReason == "stop" {
+					if choice.FinishReason == "stop" || choice.FinishReason == "tool_calls" {
 						responseChan <- general_models.StreamResponse{Content: markdownBuffer.String()}
+						markdownBuffer.Reset()
+
+						if len(toolCalls) > 0 {
+							responseChan <- general_models.StreamResponse{ToolCalls: toolCalls}
+						}
 
 						responseChan <- general_models.StreamResponse{Done: true}
 
@@ -181,7 +226,6 @@ func (openRouterProvider *OpenRouterConfig) ChatCompletionRequest(ctx context.Co
 							openRouterProvider.TokenManagement.UsedTokens(usage.PromptTokens, usage.CompletionTokens)
 							tokensCounted = true
 							// 显示本次使用的token统计
-							fmt.Print("\n")
 							openRouterProvider.TokenManagement.DisplayTokenUsage(
 								"openrouter",
 								openRouterProvider.Model,
@@ -209,7 +253,6 @@ func (openRouterProvider *OpenRouterConfig) ChatCompletionRequest(ctx context.Co
 			}
 			openRouterProvider.TokenManagement.UsedTokens(usage.PromptTokens, usage.CompletionTokens)
 			// 显示本次使用的token统计
-			fmt.Print("\n")
 			openRouterProvider.TokenManagement.DisplayTokenUsage(
 				"openrouter",
 				openRouterProvider.Model,
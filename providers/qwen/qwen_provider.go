@@ -48,7 +48,7 @@ func NewQwenChatProvider(config *QwenConfig) contracts.IChatAIProvider {
 	}
 }
 
-func (qwenProvider *QwenConfig) ChatCompletionRequest(ctx context.Context, userInput string, prompt string) <-chan models.StreamResponse {
+func (qwenProvider *QwenConfig) ChatCompletionRequest(ctx context.Context, messages []models.Message) <-chan models.StreamResponse {
 	responseChan := make(chan models.StreamResponse)
 	var markdownBuffer strings.Builder
 	var usage qwen_models.Usage
@@ -57,11 +57,8 @@ func (qwenProvider *QwenConfig) ChatCompletionRequest(ctx context.Context, userI
 		defer close(responseChan)
 
 		reqBody := qwen_models.QwenChatCompletionRequest{
-			Model: qwenProvider.Model,
-			Messages: []qwen_models.Message{
-				{Role: "system", Content: prompt},
-				{Role: "user", Content: userInput},
-			},
+			Model:       qwenProvider.Model,
+			Messages:    models.ToTextMessages(messages),
 			Temperature: qwenProvider.Temperature,
 			MaxTokens:   qwenProvider.MaxTokens,
 			Stream:      true,
@@ -149,7 +146,6 @@ func (qwenProvider *QwenConfig) ChatCompletionRequest(ctx context.Context, userI
 		if usage.TotalTokens > 0 {
 			qwenProvider.TokenManagement.UsedTokens(usage.PromptTokens, usage.CompletionTokens)
 			// 显示本次使用的token统计
-			fmt.Print("\n")
 			qwenProvider.TokenManagement.DisplayTokenUsage(
 				"qwen",
 				qwenProvider.Model,
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/meysamhadeli/codai/code_analyzer"
+	output_models "github.com/meysamhadeli/codai/output/models"
+	"github.com/meysamhadeli/codai/patch"
+	"github.com/meysamhadeli/codai/server/models"
+)
+
+// The display modes of the context of the project
+var contextModes = map[string]bool{"info": true, "relevant": true, "full": true}
+
+func (server *Server) handleContext(writer http.ResponseWriter, request *http.Request) {
+	response, err := server.ProjectContext(request.URL.Query().Get("mode"))
+	if err != nil {
+		writeError(writer, errorStatus(err), err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, response)
+}
+
+// ProjectContext returns the files of the project in a display mode, the mode of the configuration when it is empty
+func (server *Server) ProjectContext(mode string) (*models.ContextResponse, error) {
+	dependencies := server.dependencies
+	if mode == "" {
+		mode = dependencies.Config.FileDisplayMode
+	}
+	if !contextModes[mode] {
+		return nil, fmt.Errorf("%w: unknown display mode '%s', supported modes are: info, relevant, full", ErrInvalidRequest, mode)
+	}
+
+	fullContext, err := dependencies.Analyzer.GetProjectFilesWithDisplayMode(dependencies.Cwd, mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the context of the project: %w", err)
+	}
+
+	response := &models.ContextResponse{Mode: mode, Files: []models.ContextFile{}}
+	for i, fileData := range fullContext.FileData {
+		content := fileData.Code
+		if i < len(fullContext.RawCodes) {
+			content = fullContext.RawCodes[i]
+		}
+		response.Files = append(response.Files, models.ContextFile{Path: fileData.RelativePath, Content: content})
+	}
+	return response, nil
+}
+
+func (server *Server) handleExtract(writer http.ResponseWriter, request *http.Request) {
+	var extractRequest models.ExtractRequest
+	if !readJSON(writer, request, &extractRequest) {
+		return
+	}
+
+	changes, err := server.ExtractChanges(extractRequest.Text, extractRequest.EditFormat)
+	if err != nil {
+		writeError(writer, errorStatus(err), err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, models.ExtractResponse{Changes: changes})
+}
+
+// ExtractChanges extracts the code changes of an answer of the AI in an edit format, the format of the configuration
+// when it is empty
+func (server *Server) ExtractChanges(text string, editFormat string) ([]output_models.CodeChange, error) {
+	if editFormat == "" {
+		editFormat = server.dependencies.Config.EditFormat
+	}
+	if editFormat != "diff" && editFormat != "search_replace" {
+		return nil, fmt.Errorf("%w: unknown edit format '%s', supported formats are: diff, search_replace", ErrInvalidRequest, editFormat)
+	}
+	return toOutputChanges(extractChanges(server.dependencies.Analyzer, text, editFormat)), nil
+}
+
+func (server *Server) handleApply(writer http.ResponseWriter, request *http.Request) {
+	var applyRequest models.ApplyRequest
+	if !readJSON(writer, request, &applyRequest) {
+		return
+	}
+
+	response, err := server.ApplyChanges(applyRequest.Changes, applyRequest.DryRun)
+	if err != nil {
+		writeError(writer, errorStatus(err), err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, response)
+}
+
+// ApplyChanges stages all the changes in a transaction and writes them to the project together, recording them for
+// undo, or only returns their patch for a dry run. Changes rejected by the path policy or leaving a file unparsable
+// are skipped.
+func (server *Server) ApplyChanges(changes []output_models.CodeChange, dryRun bool) (*models.ApplyResponse, error) {
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("%w: there are no changes to apply", ErrInvalidRequest)
+	}
+
+	// Concurrent requests must not stage changes of the same files from the same content
+	server.applying.Lock()
+	defer server.applying.Unlock()
+
+	dependencies := server.dependencies
+	transaction := dependencies.Analyzer.BeginTransaction()
+	defer transaction.Discard()
+
+	stager := code_analyzer.NewChangeStager(dependencies.Cwd, dependencies.Analyzer, transaction)
+	response := &models.ApplyResponse{Results: []models.ApplyResult{}}
+	for _, change := range changes {
+		response.Results = append(response.Results, stage(stager, change))
+	}
+	response.Patch, _ = stager.Patch()
+
+	if dryRun || len(transaction.StagedPaths()) == 0 {
+		return response, nil
+	}
+
+	record, err := transaction.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply changes: %w", err)
+	}
+	for i, result := range response.Results {
+		if result.Status == output_models.ChangeAccepted {
+			response.Results[i].Status = output_models.ChangeApplied
+		}
+	}
+
+	if dependencies.ChangeJournal != nil {
+		if err := dependencies.ChangeJournal.Record(record); err != nil {
+			return nil, fmt.Errorf("applied the changes but failed to record them for undo: %w", err)
+		}
+	}
+	// The next requests must see the changed files, not their cached content
+	_ = dependencies.Analyzer.ClearCache()
+
+	return response, nil
+}
+
+// stage stages the change of a file with the stager and returns its result
+func stage(stager *code_analyzer.ChangeStager, change output_models.CodeChange) models.ApplyResult {
+	result := models.ApplyResult{Path: change.Path}
+
+	hunks, err := stager.Stage(change.Path, change.Code)
+	for _, hunk := range hunks {
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case patch.Addition:
+				result.Added++
+			case patch.Deletion:
+				result.Removed++
+			}
+		}
+	}
+
+	var rejectedError *code_analyzer.PathRejectedError
+	switch {
+	case errors.As(err, &rejectedError):
+		result.Status, result.Error = output_models.ChangeRejected, err.Error()
+	case err != nil:
+		result.Status, result.Error = output_models.ChangeFailed, err.Error()
+	case len(hunks) == 0:
+		result.Status = output_models.ChangeUnchanged
+	default:
+		result.Status = output_models.ChangeAccepted
+	}
+	return result
+}
+
+func (server *Server) handleCacheStats(writer http.ResponseWriter, request *http.Request) {
+	stats, err := server.dependencies.Analyzer.GetCacheStats()
+	if err != nil {
+		writeError(writer, http.StatusInternalServerError, fmt.Errorf("failed to get the cache stats: %w", err))
+		return
+	}
+	writeJSON(writer, http.StatusOK, stats)
+}
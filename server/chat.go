@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	contracts_analyzer "github.com/meysamhadeli/codai/code_analyzer/contracts"
+	analyzer_models "github.com/meysamhadeli/codai/code_analyzer/models"
+	output_models "github.com/meysamhadeli/codai/output/models"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/meysamhadeli/codai/server/models"
+)
+
+// The events of a streamed answer
+const (
+	eventChunk  = "chunk"
+	eventStatus = "status"
+	eventDone   = "done"
+	eventError  = "error"
+)
+
+// eventStream writes server-sent events, or nothing when the answer is not streamed
+type eventStream struct {
+	writer  http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newEventStream starts the server-sent events of a response, or returns nil when the request does not ask for them
+func newEventStream(writer http.ResponseWriter, request *http.Request, stream bool) *eventStream {
+	if !stream && !strings.Contains(request.Header.Get("Accept"), "text/event-stream") {
+		return nil
+	}
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		return nil
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &eventStream{writer: writer, flusher: flusher}
+}
+
+func (stream *eventStream) send(event string, value interface{}) {
+	if stream == nil {
+		return
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(stream.writer, "event: %s\ndata: %s\n\n", event, data)
+	stream.flusher.Flush()
+}
+
+func (server *Server) handleChat(writer http.ResponseWriter, request *http.Request) {
+	session, ok := server.pathSession(writer, request)
+	if !ok {
+		return
+	}
+
+	var chatRequest models.ChatRequest
+	if !readJSON(writer, request, &chatRequest) {
+		return
+	}
+	message := strings.TrimSpace(chatRequest.Message)
+	if message == "" {
+		writeError(writer, http.StatusBadRequest, fmt.Errorf("the message is empty"))
+		return
+	}
+
+	if !session.chatting.TryLock() {
+		writeError(writer, http.StatusConflict, fmt.Errorf("session '%s' is answering a message", session.id))
+		return
+	}
+	defer session.chatting.Unlock()
+
+	stream := newEventStream(writer, request, chatRequest.Stream)
+	response, status, err := server.chat(request.Context(), session, message, stream)
+	if err != nil {
+		if stream != nil {
+			stream.send(eventError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeError(writer, status, err)
+		return
+	}
+
+	if stream != nil {
+		stream.send(eventDone, response)
+		return
+	}
+	writeJSON(writer, http.StatusOK, response)
+}
+
+// chat answers a message of a session like the 'code' command: with the context of the project and the history of
+// the session, and a second request with the full content of the files the AI asked for. It returns the HTTP status
+// of the error when it fails.
+func (server *Server) chat(ctx context.Context, session *session, message string, stream *eventStream) (*models.ChatResponse, int, error) {
+	dependencies := server.dependencies
+	fullContext, err := dependencies.Analyzer.GetProjectFilesWithDisplayMode(dependencies.Cwd, dependencies.Config.FileDisplayMode)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to load the context of the project: %w", err)
+	}
+
+	session.mu.Lock()
+	history := append([]provider_models.Message(nil), session.chatHistory.GetHistory()...)
+	session.mu.Unlock()
+
+	_, inputBefore, outputBefore := session.tokenManagement.GetCurrentTokenUsage()
+
+	answer, exchange, err := server.complete(ctx, session, fullContext.RawCodes, history, message, "", stream)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+
+	// Send the full files the AI asked for when it only had the summary of their code
+	if requestedContext, err := dependencies.Analyzer.TryGetInCompletedCodeBlocK(answer); requestedContext != "" && err == nil {
+		stream.send(eventStatus, models.StatusEvent{Message: "Auto-accepting additional context for complete code blocks..."})
+		if answer, exchange, err = server.complete(ctx, session, fullContext.RawCodes, history, message, requestedContext, stream); err != nil {
+			return nil, http.StatusBadGateway, err
+		}
+	}
+
+	_, inputAfter, outputAfter := session.tokenManagement.GetCurrentTokenUsage()
+
+	session.mu.Lock()
+	session.chatHistory.AddMessages(exchange...)
+	session.inputTokens, session.outputTokens = inputAfter, outputAfter
+	session.mu.Unlock()
+
+	return &models.ChatResponse{
+		Content: answer,
+		Changes: toOutputChanges(extractChanges(dependencies.Analyzer, answer, dependencies.Config.EditFormat)),
+		Usage:   server.usage(session, output_models.ScopeRequest, inputAfter-inputBefore, outputAfter-outputBefore),
+	}, http.StatusOK, nil
+}
+
+// complete sends a request to the AI of a session, streaming the chunks of its answer, and returns the whole answer
+// with the user and assistant messages of the exchange to keep in the history
+func (server *Server) complete(ctx context.Context, session *session, codes []string, history []provider_models.Message, message string, requestedContext string, stream *eventStream) (string, []provider_models.Message, error) {
+	dependencies := server.dependencies
+	finalPrompt, userInputPrompt := dependencies.Analyzer.GeneratePromptWithEditFormat(codes, message, requestedContext, dependencies.Config.EditFormat)
+
+	answer, err := provider_models.CollectAnswer(session.provider.ChatCompletionRequest(ctx, provider_models.NewConversation(finalPrompt, history, userInputPrompt)), func(content string) error {
+		stream.send(eventChunk, models.ChunkEvent{Content: content})
+		return nil
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get AI response: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	return answer.Content, []provider_models.Message{
+		provider_models.NewTextMessage(provider_models.RoleUser, userInputPrompt),
+		provider_models.NewTextMessage(provider_models.RoleAssistant, answer.Content),
+	}, nil
+}
+
+// extractChanges extracts the code changes of a text in an edit format, the 'code' format when it is empty
+func extractChanges(analyzer contracts_analyzer.ICodeAnalyzer, text string, editFormat string) []analyzer_models.CodeChange {
+	if editFormat == "search_replace" {
+		return analyzer.ExtractSearchReplaceChanges(text)
+	}
+	return analyzer.ExtractCodeChanges(text)
+}
+
+func toOutputChanges(changes []analyzer_models.CodeChange) []output_models.CodeChange {
+	outputChanges := make([]output_models.CodeChange, 0, len(changes))
+	for _, change := range changes {
+		outputChanges = append(outputChanges, output_models.CodeChange{Path: change.RelativePath, Code: change.Code})
+	}
+	return outputChanges
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	output_models "github.com/meysamhadeli/codai/output/models"
+)
+
+// ErrorResponse is the body of the failed requests
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// HealthResponse tells the server is running
+type HealthResponse struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
+	Root    string `json:"root"`
+}
+
+// Session is a chat session with its own history and token usage. HistoryLength counts the messages it answered.
+type Session struct {
+	ID            string                   `json:"id"`
+	CreatedAt     time.Time                `json:"created_at"`
+	HistoryLength int                      `json:"history_length"`
+	Usage         output_models.TokenUsage `json:"usage"`
+}
+
+// ChatRequest sends a message to the AI in a session. The answer is streamed as server-sent events when Stream is
+// set or the request accepts 'text/event-stream'.
+type ChatRequest struct {
+	Message string `json:"message"`
+	Stream  bool   `json:"stream,omitempty"`
+}
+
+// ChatResponse is the answer of the AI with the code changes extracted from it and the token usage of the request
+type ChatResponse struct {
+	Content string                     `json:"content"`
+	Changes []output_models.CodeChange `json:"changes"`
+	Usage   output_models.TokenUsage   `json:"usage"`
+}
+
+// ChunkEvent is a streamed chunk of the answer of the AI
+type ChunkEvent struct {
+	Content string `json:"content"`
+}
+
+// StatusEvent is a status message of a streamed answer, like the AI asking for the full content of files
+type StatusEvent struct {
+	Message string `json:"message"`
+}
+
+// ContextFile is a file of the project with its content in the requested display mode
+type ContextFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// ContextResponse is the context of the project sent to the AI
+type ContextResponse struct {
+	Mode  string        `json:"mode"`
+	Files []ContextFile `json:"files"`
+}
+
+// ExtractRequest extracts the code changes of an answer of the AI, in the edit format of the configuration when
+// EditFormat is empty
+type ExtractRequest struct {
+	Text       string `json:"text"`
+	EditFormat string `json:"edit_format,omitempty"`
+}
+
+// ExtractResponse are the code changes extracted from a text
+type ExtractResponse struct {
+	Changes []output_models.CodeChange `json:"changes"`
+}
+
+// ApplyRequest applies code changes to the project, or only previews them as a patch when DryRun is set
+type ApplyRequest struct {
+	Changes []output_models.CodeChange `json:"changes"`
+	DryRun  bool                       `json:"dry_run,omitempty"`
+}
+
+// ApplyResult is the result of the change of a file
+type ApplyResult struct {
+	Path    string                     `json:"path"`
+	Status  output_models.ChangeStatus `json:"status"`
+	Added   int                        `json:"added"`
+	Removed int                        `json:"removed"`
+	Error   string                     `json:"error,omitempty"`
+}
+
+// ApplyResponse are the results of the changes, with the unified diff of the accepted ones
+type ApplyResponse struct {
+	Results []ApplyResult `json:"results"`
+	Patch   string        `json:"patch"`
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	contracts_journal "github.com/meysamhadeli/codai/change_journal/contracts"
+	contracts_analyzer "github.com/meysamhadeli/codai/code_analyzer/contracts"
+	"github.com/meysamhadeli/codai/config"
+	contracts_provider "github.com/meysamhadeli/codai/providers/contracts"
+	"github.com/meysamhadeli/codai/server/models"
+	contracts_token "github.com/meysamhadeli/codai/token_management/contracts"
+)
+
+// maxRequestBodyBytes limits the size of the JSON bodies of the requests
+const maxRequestBodyBytes = 32 << 20
+
+// shutdownTimeout is how long the server waits for the running requests when it stops
+const shutdownTimeout = 10 * time.Second
+
+// ErrInvalidRequest is returned for the requests with invalid parameters
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Dependencies are the dependencies of the commands the server shares between its sessions
+type Dependencies struct {
+	Cwd           string
+	Config        *config.Config
+	Analyzer      contracts_analyzer.ICodeAnalyzer
+	ChangeJournal contracts_journal.IChangeJournal
+	// NewChatProvider creates the AI provider of a session, counting its tokens with tokenManagement
+	NewChatProvider func(tokenManagement contracts_token.ITokenManagement) (contracts_provider.IChatAIProvider, error)
+}
+
+// Server serves the pipeline of codai over HTTP with JSON bodies. Every session has its own chat history and token
+// usage; the project files and the analyzer are shared.
+type Server struct {
+	dependencies *Dependencies
+	sessions     *sessionStore
+	// token is the bearer token the requests must send, when it is not empty
+	token string
+	// applying serializes the changes written to the project by concurrent requests
+	applying sync.Mutex
+}
+
+// NewServer creates the server of the project of dependencies, requiring the bearer token on every request when it
+// is not empty
+func NewServer(dependencies *Dependencies, token string) *Server {
+	return &Server{
+		dependencies: dependencies,
+		sessions:     newSessionStore(),
+		token:        token,
+	}
+}
+
+// Handler returns the HTTP handler of the API
+func (server *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", server.handleHealth)
+	mux.HandleFunc("GET /sessions", server.handleListSessions)
+	mux.HandleFunc("POST /sessions", server.handleCreateSession)
+	mux.HandleFunc("GET /sessions/{id}", server.handleGetSession)
+	mux.HandleFunc("DELETE /sessions/{id}", server.handleDeleteSession)
+	mux.HandleFunc("POST /sessions/{id}/chat", server.handleChat)
+	mux.HandleFunc("DELETE /sessions/{id}/history", server.handleClearHistory)
+	mux.HandleFunc("GET /sessions/{id}/tokens", server.handleTokens)
+	mux.HandleFunc("GET /context", server.handleContext)
+	mux.HandleFunc("POST /changes/extract", server.handleExtract)
+	mux.HandleFunc("POST /changes/apply", server.handleApply)
+	mux.HandleFunc("GET /cache/stats", server.handleCacheStats)
+	return server.protect(server.authenticate(mux))
+}
+
+// Serve serves the API on listener until ctx is done, then waits for the running requests
+func (server *Server) Serve(ctx context.Context, listener net.Listener) error {
+	httpServer := &http.Server{
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- httpServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// protect rejects the requests a web page may send to the server: the requests naming a domain in their Host header,
+// as a DNS rebinding attack does, and the requests from the Origin of another host
+func (server *Server) protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		host, _, err := net.SplitHostPort(request.Host)
+		if err != nil {
+			host = request.Host
+		}
+		if host != "localhost" && net.ParseIP(strings.Trim(host, "[]")) == nil {
+			writeError(writer, http.StatusForbidden, fmt.Errorf("host %q is not allowed, use the address of the server", request.Host))
+			return
+		}
+		if origin := request.Header.Get("Origin"); origin != "" {
+			if originURL, err := url.Parse(origin); err != nil || originURL.Host != request.Host {
+				writeError(writer, http.StatusForbidden, fmt.Errorf("origin %q is not allowed", origin))
+				return
+			}
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+// authenticate rejects the requests without the bearer token of the server
+func (server *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if server.token != "" {
+			token, ok := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(server.token)) != 1 {
+				writeError(writer, http.StatusUnauthorized, fmt.Errorf("missing or invalid bearer token"))
+				return
+			}
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+func (server *Server) handleHealth(writer http.ResponseWriter, request *http.Request) {
+	writeJSON(writer, http.StatusOK, models.HealthResponse{Status: "ok", Version: config.DefaultConfig.Version, Root: server.dependencies.Cwd})
+}
+
+// readJSON decodes the body of a request, rejecting unknown fields so typos don't go unnoticed. The body must be sent
+// as 'application/json', which a web page cannot send to another origin without the consent of the server.
+func readJSON(writer http.ResponseWriter, request *http.Request, value interface{}) bool {
+	if mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		writeError(writer, http.StatusUnsupportedMediaType, fmt.Errorf("the request body must be sent as 'application/json'"))
+		return false
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxRequestBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		writeError(writer, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(writer http.ResponseWriter, status int, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	_ = json.NewEncoder(writer).Encode(value)
+}
+
+// errorStatus is the HTTP status of an error, 400 for invalid requests
+func errorStatus(err error) int {
+	if errors.Is(err, ErrInvalidRequest) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(writer http.ResponseWriter, status int, err error) {
+	writeJSON(writer, status, models.ErrorResponse{Error: err.Error()})
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/meysamhadeli/codai/code_analyzer"
+	"github.com/meysamhadeli/codai/config"
+	output_models "github.com/meysamhadeli/codai/output/models"
+	"github.com/meysamhadeli/codai/providers"
+	contracts_provider "github.com/meysamhadeli/codai/providers/contracts"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/meysamhadeli/codai/server/models"
+	contracts_token "github.com/meysamhadeli/codai/token_management/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAnswer = "Here is the change:\n\nFile: main.go\n```go\npackage main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n```\n"
+
+// fakeProvider streams its answer in two chunks and counts 10 input and 5 output tokens per request
+type fakeProvider struct {
+	tokenManagement contracts_token.ITokenManagement
+
+	mu          sync.Mutex
+	userPrompts []string
+}
+
+func (provider *fakeProvider) ChatCompletionRequest(ctx context.Context, messages []provider_models.Message) <-chan provider_models.StreamResponse {
+	provider.mu.Lock()
+	provider.userPrompts = append(provider.userPrompts, messages[len(messages)-1].Text())
+	provider.mu.Unlock()
+
+	responses := make(chan provider_models.StreamResponse, 4)
+	go func() {
+		defer close(responses)
+		responses <- provider_models.StreamResponse{Content: testAnswer[:10]}
+		responses <- provider_models.StreamResponse{Content: testAnswer[10:]}
+		provider.tokenManagement.UsedTokens(10, 5)
+		responses <- provider_models.StreamResponse{Done: true}
+		responses <- provider_models.StreamResponse{Content: testAnswer}
+	}()
+	return responses
+}
+
+func newTestServer(t *testing.T, token string) (*httptest.Server, string) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n}\n"), 0644))
+
+	dependencies := &Dependencies{
+		Cwd:      dir,
+		Config:   &config.Config{FileDisplayMode: "full", EditFormat: "diff", AIProviderConfig: &providers.AIProviderConfig{Provider: "openai", Model: "gpt-4o"}},
+		Analyzer: code_analyzer.NewCodeAnalyzer(dir, false),
+		NewChatProvider: func(tokenManagement contracts_token.ITokenManagement) (contracts_provider.IChatAIProvider, error) {
+			return &fakeProvider{tokenManagement: tokenManagement}, nil
+		},
+	}
+
+	httpServer := httptest.NewServer(NewServer(dependencies, token).Handler())
+	t.Cleanup(httpServer.Close)
+	return httpServer, dir
+}
+
+func doJSON(t *testing.T, method string, url string, body interface{}, result interface{}) int {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		assert.NoError(t, err)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	request, err := http.NewRequest(method, url, reader)
+	assert.NoError(t, err)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+
+	if result != nil {
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(result))
+	}
+	return response.StatusCode
+}
+
+func createSession(t *testing.T, url string) models.Session {
+	var session models.Session
+	assert.Equal(t, http.StatusCreated, doJSON(t, http.MethodPost, url+"/sessions", nil, &session))
+	assert.NotEmpty(t, session.ID)
+	return session
+}
+
+func TestChat(t *testing.T) {
+	httpServer, _ := newTestServer(t, "")
+	session := createSession(t, httpServer.URL)
+
+	var response models.ChatResponse
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodPost, httpServer.URL+"/sessions/"+session.ID+"/chat", models.ChatRequest{Message: "Say hello"}, &response))
+	assert.Equal(t, testAnswer, response.Content, "the content sent after Done is ignored")
+	assert.Equal(t, []output_models.CodeChange{{Path: "main.go", Code: "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}"}}, response.Changes)
+	assert.Equal(t, output_models.ScopeRequest, response.Usage.Scope)
+	assert.Equal(t, 15, response.Usage.TotalTokens)
+
+	var tokens output_models.TokenUsage
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodGet, httpServer.URL+"/sessions/"+session.ID+"/tokens", nil, &tokens))
+	assert.Equal(t, output_models.ScopeSession, tokens.Scope)
+	assert.Equal(t, 10, tokens.InputTokens)
+	assert.Equal(t, 5, tokens.OutputTokens)
+
+	var errorResponse models.ErrorResponse
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, http.MethodPost, httpServer.URL+"/sessions/"+session.ID+"/chat", models.ChatRequest{Message: " "}, &errorResponse))
+	assert.Equal(t, http.StatusNotFound, doJSON(t, http.MethodPost, httpServer.URL+"/sessions/unknown/chat", models.ChatRequest{Message: "Hi"}, &errorResponse))
+}
+
+func TestChat_Stream(t *testing.T) {
+	httpServer, _ := newTestServer(t, "")
+	session := createSession(t, httpServer.URL)
+
+	body, _ := json.Marshal(models.ChatRequest{Message: "Say hello"})
+	request, _ := http.NewRequest(http.MethodPost, httpServer.URL+"/sessions/"+session.ID+"/chat", bytes.NewReader(body))
+	request.Header.Set("Accept", "text/event-stream")
+	request.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	var events []string
+	var content strings.Builder
+	var done models.ChatResponse
+	scanner := bufio.NewScanner(response.Body)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+			events = append(events, event)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			switch event {
+			case eventChunk:
+				var chunk models.ChunkEvent
+				assert.NoError(t, json.Unmarshal([]byte(data), &chunk))
+				content.WriteString(chunk.Content)
+			case eventDone:
+				assert.NoError(t, json.Unmarshal([]byte(data), &done))
+			}
+		}
+	}
+
+	assert.Equal(t, []string{eventChunk, eventChunk, eventDone}, events)
+	assert.Equal(t, testAnswer, content.String())
+	assert.Equal(t, testAnswer, done.Content)
+	assert.Len(t, done.Changes, 1)
+}
+
+func TestSessions_AreIsolated(t *testing.T) {
+	httpServer, _ := newTestServer(t, "")
+	first, second := createSession(t, httpServer.URL), createSession(t, httpServer.URL)
+
+	var wait sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wait.Add(2)
+		go func() {
+			defer wait.Done()
+			doJSON(t, http.MethodPost, httpServer.URL+"/sessions/"+first.ID+"/chat", models.ChatRequest{Message: "Hi"}, nil)
+		}()
+		go func() {
+			defer wait.Done()
+			doJSON(t, http.MethodGet, httpServer.URL+"/sessions/"+second.ID, nil, nil)
+		}()
+	}
+	wait.Wait()
+	doJSON(t, http.MethodPost, httpServer.URL+"/sessions/"+second.ID+"/chat", models.ChatRequest{Message: "Hi"}, nil)
+
+	var sessions []models.Session
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodGet, httpServer.URL+"/sessions", nil, &sessions))
+	assert.Len(t, sessions, 2)
+	for _, session := range sessions {
+		if session.ID == second.ID {
+			assert.Equal(t, 15, session.Usage.TotalTokens)
+			assert.Equal(t, 1, session.HistoryLength)
+		} else {
+			// A session answers one message at a time, the others are rejected while it is busy
+			assert.Positive(t, session.HistoryLength)
+			assert.Equal(t, session.HistoryLength*15, session.Usage.TotalTokens)
+		}
+	}
+
+	var cleared models.Session
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodDelete, httpServer.URL+"/sessions/"+second.ID+"/history", nil, &cleared))
+	assert.Equal(t, 0, cleared.HistoryLength)
+
+	assert.Equal(t, http.StatusNoContent, doJSON(t, http.MethodDelete, httpServer.URL+"/sessions/"+second.ID, nil, nil))
+	assert.Equal(t, http.StatusNotFound, doJSON(t, http.MethodGet, httpServer.URL+"/sessions/"+second.ID, nil, &models.ErrorResponse{}))
+}
+
+func TestContextAndCacheStats(t *testing.T) {
+	httpServer, _ := newTestServer(t, "")
+
+	var context models.ContextResponse
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodGet, httpServer.URL+"/context?mode=full", nil, &context))
+	assert.Equal(t, "full", context.Mode)
+	assert.Len(t, context.Files, 1)
+	assert.Equal(t, "main.go", context.Files[0].Path)
+	assert.Contains(t, context.Files[0].Content, "**File: main.go**")
+
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, http.MethodGet, httpServer.URL+"/context?mode=everything", nil, &models.ErrorResponse{}))
+
+	var stats map[string]interface{}
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodGet, httpServer.URL+"/cache/stats", nil, &stats))
+}
+
+func TestExtractAndApply(t *testing.T) {
+	httpServer, dir := newTestServer(t, "")
+
+	var extracted models.ExtractResponse
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodPost, httpServer.URL+"/changes/extract", models.ExtractRequest{Text: testAnswer}, &extracted))
+	assert.Len(t, extracted.Changes, 1)
+
+	changes := append(extracted.Changes,
+		output_models.CodeChange{Path: "broken.go", Code: "package main\n\nfunc {"},
+		output_models.CodeChange{Path: "../outside.go", Code: "package main\n"},
+	)
+
+	var dryRun models.ApplyResponse
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodPost, httpServer.URL+"/changes/apply", models.ApplyRequest{Changes: changes, DryRun: true}, &dryRun))
+	assert.Equal(t, []output_models.ChangeStatus{output_models.ChangeAccepted, output_models.ChangeFailed, output_models.ChangeRejected},
+		[]output_models.ChangeStatus{dryRun.Results[0].Status, dryRun.Results[1].Status, dryRun.Results[2].Status})
+	assert.Positive(t, dryRun.Results[0].Added)
+	assert.Contains(t, dryRun.Patch, "--- a/main.go\n+++ b/main.go\n")
+	assert.Contains(t, dryRun.Patch, "+\tprintln(\"hello\")\n")
+
+	content, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	assert.Equal(t, "package main\n\nfunc main() {\n}\n", string(content), "a dry run leaves the files as they are")
+
+	var applied models.ApplyResponse
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodPost, httpServer.URL+"/changes/apply", models.ApplyRequest{Changes: changes}, &applied))
+	assert.Equal(t, output_models.ChangeApplied, applied.Results[0].Status)
+	assert.Equal(t, dryRun.Patch, applied.Patch)
+
+	content, _ = os.ReadFile(filepath.Join(dir, "main.go"))
+	assert.Contains(t, string(content), "println(\"hello\")")
+	_, err := os.Stat(filepath.Join(dir, "broken.go"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, http.StatusOK, doJSON(t, http.MethodPost, httpServer.URL+"/changes/apply", models.ApplyRequest{Changes: extracted.Changes}, &applied))
+	assert.Equal(t, output_models.ChangeUnchanged, applied.Results[0].Status)
+}
+
+func TestAuthentication(t *testing.T) {
+	httpServer, _ := newTestServer(t, "secret")
+
+	assert.Equal(t, http.StatusUnauthorized, doJSON(t, http.MethodGet, httpServer.URL+"/health", nil, &models.ErrorResponse{}))
+
+	request, _ := http.NewRequest(http.MethodGet, httpServer.URL+"/health", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestCrossOriginRequests(t *testing.T) {
+	httpServer, dir := newTestServer(t, "")
+	body := `{"changes": [{"path": "main.go", "code": "package main"}]}`
+
+	// A web page can only send a simple request with a text body
+	response, err := http.Post(httpServer.URL+"/changes/apply", "text/plain", strings.NewReader(body))
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, response.StatusCode)
+
+	// A DNS rebinding attack names the domain of the page in the Host header
+	request, _ := http.NewRequest(http.MethodGet, httpServer.URL+"/context", nil)
+	request.Host = "attacker.example:7070"
+	response, err = http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusForbidden, response.StatusCode)
+
+	request, _ = http.NewRequest(http.MethodPost, httpServer.URL+"/changes/apply", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Origin", "https://attacker.example")
+	response, err = http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusForbidden, response.StatusCode)
+
+	content, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {\n}\n", string(content))
+
+	request, _ = http.NewRequest(http.MethodGet, httpServer.URL+"/health", nil)
+	request.Header.Set("Origin", httpServer.URL)
+	response, err = http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode, "the requests of the same origin are allowed")
+}
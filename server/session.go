@@ -0,0 +1,216 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/meysamhadeli/codai/chat_history"
+	contracts_history "github.com/meysamhadeli/codai/chat_history/contracts"
+	"github.com/meysamhadeli/codai/output"
+	output_models "github.com/meysamhadeli/codai/output/models"
+	contracts_provider "github.com/meysamhadeli/codai/providers/contracts"
+	provider_models "github.com/meysamhadeli/codai/providers/models"
+	"github.com/meysamhadeli/codai/server/models"
+	"github.com/meysamhadeli/codai/token_management"
+	contracts_token "github.com/meysamhadeli/codai/token_management/contracts"
+)
+
+// session is a chat session with its own history, token usage and AI provider counting its tokens
+type session struct {
+	id        string
+	createdAt time.Time
+
+	chatHistory     contracts_history.IChatHistory
+	tokenManagement contracts_token.ITokenManagement
+	provider        contracts_provider.IChatAIProvider
+
+	// chatting is held while a chat request runs, a session answers one message at a time
+	chatting sync.Mutex
+	// mu guards the history and the token usage read by the other requests while a chat request runs. The provider
+	// counts the tokens of a request in its own goroutine, so the usage is copied once the request is done.
+	mu           sync.Mutex
+	inputTokens  int
+	outputTokens int
+}
+
+// sessionStore holds the sessions of the server
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[string]*session)}
+}
+
+func (store *sessionStore) add(session *session) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.sessions[session.id] = session
+}
+
+func (store *sessionStore) get(id string) (*session, bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	session, ok := store.sessions[id]
+	return session, ok
+}
+
+func (store *sessionStore) remove(id string) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	_, ok := store.sessions[id]
+	delete(store.sessions, id)
+	return ok
+}
+
+// list returns the sessions from the oldest to the newest
+func (store *sessionStore) list() []*session {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	sessions := make([]*session, 0, len(store.sessions))
+	for _, session := range store.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].createdAt.Before(sessions[j].createdAt)
+	})
+	return sessions
+}
+
+// newSession creates a session with a new history, token counter and AI provider
+func (server *Server) newSession() (*session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	// The token usage is reported in the responses, the token manager only counts it
+	tokenManagement := token_management.NewTokenManagerWithOutput(output.NewJSONOutput(io.Discard, true))
+	provider, err := server.dependencies.NewChatProvider(tokenManagement)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the AI provider: %w", err)
+	}
+
+	return &session{
+		id:              id,
+		createdAt:       time.Now().UTC(),
+		chatHistory:     chat_history.NewChatHistory(),
+		tokenManagement: tokenManagement,
+		provider:        provider,
+	}, nil
+}
+
+func newSessionID() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to create the session id: %w", err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// usage builds a token usage of the session with its cost
+func (server *Server) usage(session *session, scope output_models.TokenUsageScope, inputTokens int, outputTokens int) output_models.TokenUsage {
+	providerConfig := server.dependencies.Config.AIProviderConfig
+	return output_models.TokenUsage{
+		Scope:        scope,
+		Provider:     providerConfig.Provider,
+		Model:        providerConfig.Model,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		TotalTokens:  inputTokens + outputTokens,
+		Cost:         session.tokenManagement.CalculateCost(providerConfig.Provider, providerConfig.Model, inputTokens, outputTokens),
+	}
+}
+
+func (server *Server) describe(session *session) models.Session {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return models.Session{
+		ID:            session.id,
+		CreatedAt:     session.createdAt,
+		HistoryLength: answeredMessages(session.chatHistory.GetHistory()),
+		Usage:         server.usage(session, output_models.ScopeSession, session.inputTokens, session.outputTokens),
+	}
+}
+
+// answeredMessages counts the user messages of a history
+func answeredMessages(history []provider_models.Message) int {
+	count := 0
+	for _, message := range history {
+		if message.Role == provider_models.RoleUser {
+			count++
+		}
+	}
+	return count
+}
+
+// pathSession finds the session of the id in the path of a request, answering 404 when there is none
+func (server *Server) pathSession(writer http.ResponseWriter, request *http.Request) (*session, bool) {
+	session, ok := server.sessions.get(request.PathValue("id"))
+	if !ok {
+		writeError(writer, http.StatusNotFound, fmt.Errorf("session '%s' not found", request.PathValue("id")))
+	}
+	return session, ok
+}
+
+func (server *Server) handleListSessions(writer http.ResponseWriter, request *http.Request) {
+	sessions := []models.Session{}
+	for _, session := range server.sessions.list() {
+		sessions = append(sessions, server.describe(session))
+	}
+	writeJSON(writer, http.StatusOK, sessions)
+}
+
+func (server *Server) handleCreateSession(writer http.ResponseWriter, request *http.Request) {
+	session, err := server.newSession()
+	if err != nil {
+		writeError(writer, http.StatusInternalServerError, err)
+		return
+	}
+	server.sessions.add(session)
+	writeJSON(writer, http.StatusCreated, server.describe(session))
+}
+
+func (server *Server) handleGetSession(writer http.ResponseWriter, request *http.Request) {
+	if session, ok := server.pathSession(writer, request); ok {
+		writeJSON(writer, http.StatusOK, server.describe(session))
+	}
+}
+
+func (server *Server) handleDeleteSession(writer http.ResponseWriter, request *http.Request) {
+	if !server.sessions.remove(request.PathValue("id")) {
+		writeError(writer, http.StatusNotFound, fmt.Errorf("session '%s' not found", request.PathValue("id")))
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (server *Server) handleClearHistory(writer http.ResponseWriter, request *http.Request) {
+	session, ok := server.pathSession(writer, request)
+	if !ok {
+		return
+	}
+	if !session.chatting.TryLock() {
+		writeError(writer, http.StatusConflict, fmt.Errorf("session '%s' is answering a message", session.id))
+		return
+	}
+	defer session.chatting.Unlock()
+
+	session.mu.Lock()
+	session.chatHistory.ClearHistory()
+	session.mu.Unlock()
+	writeJSON(writer, http.StatusOK, server.describe(session))
+}
+
+func (server *Server) handleTokens(writer http.ResponseWriter, request *http.Request) {
+	if session, ok := server.pathSession(writer, request); ok {
+		writeJSON(writer, http.StatusOK, server.describe(session).Usage)
+	}
+}
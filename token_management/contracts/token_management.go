@@ -2,11 +2,14 @@ package contracts
 
 type ITokenManagement interface {
 	UsedTokens(inputToken int, outputToken int)
+	UsedFixTokens(inputToken int, outputToken int)
 	CalculateCost(providerName string, modelName string, inputToken int, outputToken int) float64
+	MaxInputTokens(providerName string, modelName string) int
 	DisplayTokens(chatProviderName string, chatModel string)
 	DisplayLiveTokens(chatProviderName string, chatModel string)
 	DisplayLiveTokensWithPreview(chatProviderName string, chatModel string, previewInput int, previewOutput int)
 	DisplayTokenUsage(chatProviderName string, chatModel string, addedInputTokens int, addedOutputTokens int)
 	GetCurrentTokenUsage() (total int, input int, output int)
+	GetFixTokenUsage() (iterations int, input int, output int)
 	ClearToken()
 }
@@ -3,10 +3,13 @@ package token_management
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/meysamhadeli/codai/constants/lipgloss"
 	"github.com/meysamhadeli/codai/embed_data"
+	"github.com/meysamhadeli/codai/output"
+	contracts_output "github.com/meysamhadeli/codai/output/contracts"
+	output_models "github.com/meysamhadeli/codai/output/models"
 	"github.com/meysamhadeli/codai/token_management/contracts"
 	"log"
+	"os"
 	"strings"
 )
 
@@ -15,6 +18,14 @@ type tokenManager struct {
 	usedToken       int
 	usedInputToken  int
 	usedOutputToken int
+
+	// Tokens spent on follow-up turns asking the AI to fix a failed verify command
+	fixIterations   int
+	fixInputToken   int
+	fixOutputToken  int
+
+	// output renders the token usage
+	output contracts_output.IOutput
 }
 
 type details struct {
@@ -34,10 +45,17 @@ type Models struct {
 
 // NewTokenManager creates a new token manager
 func NewTokenManager() contracts.ITokenManagement {
+	return NewTokenManagerWithOutput(output.NewHumanOutput(os.Stdout, ""))
+}
+
+// NewTokenManagerWithOutput creates a new token manager rendering the token usage with output, as text or as
+// structured events
+func NewTokenManagerWithOutput(output contracts_output.IOutput) contracts.ITokenManagement {
 	return &tokenManager{
 		usedToken:       0,
 		usedInputToken:  0,
 		usedOutputToken: 0,
+		output:          output,
 	}
 }
 
@@ -48,52 +66,52 @@ func (tm *tokenManager) UsedTokens(inputToken int, outputToken int) {
 	tm.usedToken += inputToken + outputToken
 }
 
-func (tm *tokenManager) DisplayTokens(chatProviderName string, chatModel string) {
+// UsedFixTokens records the tokens of one fix iteration for a failed verify command.
+// The tokens are already part of the session total, they are only tracked separately for reporting.
+func (tm *tokenManager) UsedFixTokens(inputToken int, outputToken int) {
+	tm.fixIterations++
+	tm.fixInputToken += inputToken
+	tm.fixOutputToken += outputToken
+}
 
-	cost := tm.CalculateCost(chatProviderName, chatModel, tm.usedInputToken, tm.usedOutputToken)
+func (tm *tokenManager) DisplayTokens(chatProviderName string, chatModel string) {
+	usage := tm.usage(output_models.ScopeSession, chatProviderName, chatModel, tm.usedInputToken, tm.usedOutputToken)
 
-	tokenInfo := fmt.Sprintf("Token Used: %s - Cost: %s $ - Chat Model: %s", fmt.Sprint(tm.usedToken), fmt.Sprintf("%.6f", cost), chatModel)
+	if tm.fixIterations > 0 {
+		usage.FixIterations = tm.fixIterations
+		usage.FixTokens = tm.fixInputToken + tm.fixOutputToken
+		usage.FixCost = tm.CalculateCost(chatProviderName, chatModel, tm.fixInputToken, tm.fixOutputToken)
+	}
 
-	tokenBox := lipgloss.BoxStyle.Render(tokenInfo)
-	fmt.Println(tokenBox)
+	tm.output.TokenUsage(usage)
 }
 
 func (tm *tokenManager) DisplayLiveTokens(chatProviderName string, chatModel string) {
-	cost := tm.CalculateCost(chatProviderName, chatModel, tm.usedInputToken, tm.usedOutputToken)
-	
-	// 使用\r清除当前行并重新打印，实现实时更新效果
-	fmt.Printf("\rToken Used: %d - Cost: $%.6f - Model: %s", tm.usedToken, cost, chatModel)
+	tm.output.TokenUsage(tm.usage(output_models.ScopeLive, chatProviderName, chatModel, tm.usedInputToken, tm.usedOutputToken))
 }
 
 func (tm *tokenManager) DisplayLiveTokensWithPreview(chatProviderName string, chatModel string, previewInput int, previewOutput int) {
 	// 显示当前累计的token + 本次预览的token
-	totalInput := tm.usedInputToken + previewInput
-	totalOutput := tm.usedOutputToken + previewOutput
-	totalTokens := tm.usedToken + previewInput + previewOutput
-	
-	cost := tm.CalculateCost(chatProviderName, chatModel, totalInput, totalOutput)
-	
-	// 使用\r清除当前行并重新打印，实现实时更新效果
-	fmt.Printf("\rToken Used: %d - Cost: $%.6f - Model: %s", totalTokens, cost, chatModel)
+	tm.output.TokenUsage(tm.usage(output_models.ScopeLive, chatProviderName, chatModel, tm.usedInputToken+previewInput, tm.usedOutputToken+previewOutput))
 }
 
 // DisplayTokenUsage shows token usage with additional context about the request
 func (tm *tokenManager) DisplayTokenUsage(chatProviderName string, chatModel string, addedInputTokens int, addedOutputTokens int) {
-	oldTotal := tm.usedToken
-	oldInput := tm.usedInputToken
-	oldOutput := tm.usedOutputToken
-	oldCost := tm.CalculateCost(chatProviderName, chatModel, oldInput, oldOutput)
-	
-	// 计算新增cost
-	newCost := tm.CalculateCost(chatProviderName, chatModel, oldInput+addedInputTokens, oldOutput+addedOutputTokens)
-	
-	if oldTotal > 0 && addedInputTokens+addedOutputTokens > 0 {
-		fmt.Printf("\r[Tokens: +%d input / +%d output = +%d total]  ", 
-			addedInputTokens, addedOutputTokens, addedInputTokens+addedOutputTokens)
-		if newCost > oldCost {
-			fmt.Printf("[Cost: +$%.6f]  ", newCost-oldCost)
-		}
-		fmt.Print("\n")
+	if addedInputTokens+addedOutputTokens > 0 {
+		tm.output.TokenUsage(tm.usage(output_models.ScopeRequest, chatProviderName, chatModel, addedInputTokens, addedOutputTokens))
+	}
+}
+
+// usage builds the token usage of a scope with its cost
+func (tm *tokenManager) usage(scope output_models.TokenUsageScope, chatProviderName string, chatModel string, inputToken int, outputToken int) output_models.TokenUsage {
+	return output_models.TokenUsage{
+		Scope:        scope,
+		Provider:     chatProviderName,
+		Model:        chatModel,
+		InputTokens:  inputToken,
+		OutputTokens: outputToken,
+		TotalTokens:  inputToken + outputToken,
+		Cost:         tm.CalculateCost(chatProviderName, chatModel, inputToken, outputToken),
 	}
 }
 
@@ -101,10 +119,17 @@ func (tm *tokenManager) GetCurrentTokenUsage() (total int, input int, output int
 	return tm.usedToken, tm.usedInputToken, tm.usedOutputToken
 }
 
+func (tm *tokenManager) GetFixTokenUsage() (iterations int, input int, output int) {
+	return tm.fixIterations, tm.fixInputToken, tm.fixOutputToken
+}
+
 func (tm *tokenManager) ClearToken() {
 	tm.usedToken = 0
 	tm.usedInputToken = 0
 	tm.usedOutputToken = 0
+	tm.fixIterations = 0
+	tm.fixInputToken = 0
+	tm.fixOutputToken = 0
 }
 
 func (tm *tokenManager) CalculateCost(providerName string, modelName string, inputToken int, outputToken int) float64 {
@@ -124,6 +149,15 @@ func (tm *tokenManager) CalculateCost(providerName string, modelName string, inp
 	return totalCost
 }
 
+// MaxInputTokens returns the input limit of the model, 0 when it is unknown.
+func (tm *tokenManager) MaxInputTokens(providerName string, modelName string) int {
+	modelDetails, err := getModelDetails(providerName, modelName)
+	if err != nil {
+		return 0
+	}
+	return modelDetails.MaxInputTokens
+}
+
 func getModelDetails(providerName string, modelName string) (details, error) {
 
 	providerName = strings.ToLower(providerName)
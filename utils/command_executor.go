@@ -2,26 +2,91 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+	"time"
 
+	"github.com/meysamhadeli/codai/command_safety"
+	contracts_safety "github.com/meysamhadeli/codai/command_safety/contracts"
+	safety_models "github.com/meysamhadeli/codai/command_safety/models"
 	"github.com/spf13/cobra"
 )
 
 // CommandExecutor handles safe execution of AI-suggested commands
 type CommandExecutor struct {
-	// Add any configuration or dependencies here
+	// root is the directory the commands run in, empty for the current directory
+	root   string
+	safety contracts_safety.ICommandSafety
+
+	// sandbox limits the commands when it isn't nil, see NewCommandExecutorWithSandbox
+	sandbox         *SandboxOptions
+	networkIsolated bool
+}
+
+// CommandExitError is returned when an executed command exits with a non-zero exit code
+type CommandExitError struct {
+	ExitCode int
+}
+
+func (e *CommandExitError) Error() string {
+	return fmt.Sprintf("command exited with code %d", e.ExitCode)
 }
 
-// NewCommandExecutor creates a new command executor instance
+// commandWaitDelay is how long a canceled command gets to exit after the interrupt before it is killed
+const commandWaitDelay = 5 * time.Second
+
+// NewCommandExecutor creates a new command executor instance running the commands in the current directory
 func NewCommandExecutor() *CommandExecutor {
-	return &CommandExecutor{}
+	cwd, _ := os.Getwd()
+	return &CommandExecutor{safety: command_safety.NewCommandSafety(cwd, nil)}
+}
+
+// NewCommandExecutorWithPolicy creates a command executor running the commands in root, checked against the
+// allow and deny rules of policy
+func NewCommandExecutorWithPolicy(root string, policy *safety_models.CommandPolicy) *CommandExecutor {
+	return &CommandExecutor{root: root, safety: command_safety.NewCommandSafety(root, policy)}
+}
+
+// NewCommandExecutorWithSandbox creates a command executor running the commands in the sandboxed execution mode:
+// jailed to root, within the limits of sandbox and with an environment stripped of secrets. The network is
+// denied with Linux namespaces when they are available, otherwise the commands accessing the network are rejected.
+func NewCommandExecutorWithSandbox(root string, policy *safety_models.CommandPolicy, sandbox SandboxOptions) *CommandExecutor {
+	executor := NewCommandExecutorWithPolicy(root, policy)
+	executor.sandbox = &sandbox
+	executor.networkIsolated = sandbox.DenyNetwork && networkIsolationAvailable()
+	return executor
+}
+
+// Sandboxed reports whether the commands run in the sandboxed execution mode
+func (ce *CommandExecutor) Sandboxed() bool {
+	return ce.sandbox != nil
+}
+
+// NetworkIsolated reports whether the sandboxed commands run without network
+func (ce *CommandExecutor) NetworkIsolated() bool {
+	return ce.networkIsolated
+}
+
+// Assess classifies the risk of a command before asking the user to confirm it
+func (ce *CommandExecutor) Assess(command string) safety_models.CommandAssessment {
+	return ce.safety.Assess(command)
+}
+
+// CheckCommand returns why a command is not allowed to run, or nil when it is
+func (ce *CommandExecutor) CheckCommand(command string) error {
+	if command == "" {
+		return fmt.Errorf("empty command provided")
+	}
+	return ce.validateCommand(command)
 }
 
-// ExecuteCommand safely executes a command with user confirmation
+// ExecuteCommand safely executes a command, streaming its output. It returns a *CommandExitError when the command exits
+// with a non-zero exit code, the error of ctx when it is canceled, e.g. by Ctrl+C, and ErrCommandTimeout when it runs
+// longer than the timeout of the sandbox.
 func (ce *CommandExecutor) ExecuteCommand(ctx context.Context, command string) error {
 	if command == "" {
 		return fmt.Errorf("empty command provided")
@@ -32,6 +97,12 @@ func (ce *CommandExecutor) ExecuteCommand(ctx context.Context, command string) e
 		return fmt.Errorf("command validation failed: %v", err)
 	}
 
+	if ce.sandbox != nil && ce.sandbox.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, ce.sandbox.Timeout, ErrCommandTimeout)
+		defer cancel()
+	}
+
 	// Platform-specific execution
 	var cmd *exec.Cmd
 	if runtime.GOOS == "windows" {
@@ -39,41 +110,52 @@ func (ce *CommandExecutor) ExecuteCommand(ctx context.Context, command string) e
 	} else {
 		// Unix-like systems
 		cmd = exec.CommandContext(ctx, "bash", "-c", command)
+
+		// Interrupt the command on cancellation like Ctrl+C in a shell would, and kill it if it doesn't exit
+		cmd.Cancel = func() error {
+			return cmd.Process.Signal(os.Interrupt)
+		}
 	}
+	cmd.WaitDelay = commandWaitDelay
+	cmd.Dir = ce.root
 
 	// Set up pipes for real-time output
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
+	if ce.sandbox != nil {
+		ce.sandboxCommand(cmd, os.Stdout, os.Stderr)
+	}
 
 	fmt.Printf("=>")
 	
 	err := cmd.Run()
+	if ctx.Err() != nil {
+		if errors.Is(context.Cause(ctx), ErrCommandTimeout) {
+			return fmt.Errorf("%w after %s", ErrCommandTimeout, ce.sandbox.Timeout)
+		}
+		return ctx.Err()
+	}
 	if err != nil {
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) && exitError.ExitCode() >= 0 {
+			return &CommandExitError{ExitCode: exitError.ExitCode()}
+		}
 		return fmt.Errorf("command execution failed: %v", err)
 	}
 
 	return nil
 }
 
-// validateCommand performs security checks on the proposed command
+// validateCommand rejects the commands denied by the safety analysis, and the ones leaving the sandbox
 func (ce *CommandExecutor) validateCommand(command string) error {
-	// List of dangerous commands/patterns to reject
-	dangerousPatterns := []string{
-		"rm -rf /",
-		":(){ :|:& };:",  // Fork bomb
-		"> /dev/sda",      // Disk overwrite
-		"wipefs",
-		"fdisk",
-		"mkfs",
-		"dd if=",
+	assessment := ce.safety.Assess(command)
+	if assessment.Denied {
+		return fmt.Errorf("potentially dangerous command detected: %s", strings.Join(assessment.Reasons, "; "))
 	}
 
-	cmdLower := strings.ToLower(command)
-	for _, pattern := range dangerousPatterns {
-		if strings.Contains(cmdLower, strings.ToLower(pattern)) {
-			return fmt.Errorf("potentially dangerous command detected: %s", pattern)
-		}
+	if ce.sandbox != nil {
+		return ce.checkSandbox(assessment)
 	}
 
 	return nil
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	safety_models "github.com/meysamhadeli/codai/command_safety/models"
+)
+
+// SandboxOptions are the limits of the sandboxed execution mode of CommandExecutor
+type SandboxOptions struct {
+	// Timeout is the wall-clock time a command may run, without a limit when zero
+	Timeout time.Duration
+	// MaxOutputBytes caps the output of a command, stdout and stderr together, without a limit when zero
+	MaxOutputBytes int
+	// AllowEnv are the names of the environment variables passed to the command in addition to sandboxEnvAllowlist,
+	// a trailing '*' matches a prefix
+	AllowEnv []string
+	// DenyNetwork runs the command in a network namespace without network, when the system allows it
+	DenyNetwork bool
+}
+
+// ErrCommandTimeout is returned when a sandboxed command runs longer than the timeout of the sandbox
+var ErrCommandTimeout = errors.New("command timed out")
+
+// sandboxEnvAllowlist are the environment variables a sandboxed command gets, enough for shells and build tools
+// but without the API keys and tokens of the environment
+var sandboxEnvAllowlist = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "COLORTERM", "NO_COLOR", "LANG", "LANGUAGE", "LC_*", "TZ",
+	"TMPDIR", "TEMP", "TMP", "SYSTEMROOT", "COMSPEC", "PATHEXT", "USERPROFILE", "APPDATA", "LOCALAPPDATA",
+	"GOPATH", "GOROOT", "GOCACHE", "GOMODCACHE", "GOFLAGS", "GOPROXY", "GOPRIVATE", "CGO_ENABLED",
+	"CARGO_HOME", "RUSTUP_HOME", "NODE_PATH", "NVM_DIR", "JAVA_HOME", "PYTHONPATH", "VIRTUAL_ENV",
+}
+
+// sandboxEnv returns the variables of environ allowed by sandboxEnvAllowlist and the extra names of allow
+func sandboxEnv(environ []string, allow []string) []string {
+	patterns := append(append([]string{}, sandboxEnvAllowlist...), allow...)
+
+	var env []string
+	for _, variable := range environ {
+		name, _, _ := strings.Cut(variable, "=")
+		for _, pattern := range patterns {
+			prefix, isPrefix := strings.CutSuffix(pattern, "*")
+			if name == pattern || isPrefix && strings.HasPrefix(name, prefix) {
+				env = append(env, variable)
+				break
+			}
+		}
+	}
+	return env
+}
+
+// checkSandbox rejects the commands that would leave the project, which is the working directory jail of the sandbox,
+// whatever else they do. When the network can't be isolated it rejects the commands accessing the network too.
+func (ce *CommandExecutor) checkSandbox(assessment safety_models.CommandAssessment) error {
+	switch {
+	case assessment.HasRisk(safety_models.RiskPrivileged):
+		return fmt.Errorf("the sandbox doesn't run privileged commands")
+	case assessment.HasRisk(safety_models.RiskWritesOutsideProject):
+		return fmt.Errorf("the sandbox only runs commands writing inside the project: %s", strings.Join(assessment.Reasons, "; "))
+	case assessment.HasRisk(safety_models.RiskNetwork) && ce.sandbox.DenyNetwork && !ce.networkIsolated:
+		return fmt.Errorf("the sandbox denies the network, which can't be isolated on this system: %s", strings.Join(assessment.Reasons, "; "))
+	}
+	return nil
+}
+
+// sandboxCommand applies the limits of the sandbox to cmd, which writes its output to stdout and stderr
+func (ce *CommandExecutor) sandboxCommand(cmd *exec.Cmd, stdout io.Writer, stderr io.Writer) {
+	cmd.Env = sandboxEnv(os.Environ(), ce.sandbox.AllowEnv)
+	if cmd.Dir != "" {
+		if dir, err := filepath.Abs(cmd.Dir); err == nil {
+			cmd.Env = append(cmd.Env, "PWD="+dir)
+		}
+	}
+
+	if ce.sandbox.MaxOutputBytes > 0 {
+		output := &cappedOutput{remaining: ce.sandbox.MaxOutputBytes}
+		stdout, stderr = output.writer(stdout), output.writer(stderr)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if ce.networkIsolated {
+		isolateNetwork(cmd)
+	}
+}
+
+// cappedOutput shares an output size cap between the writers of a command. The output past the cap is dropped,
+// without failing the writes, so the command isn't stopped by a broken pipe.
+type cappedOutput struct {
+	mu        sync.Mutex
+	remaining int
+	truncated bool
+}
+
+type cappedWriter struct {
+	output *cappedOutput
+	writer io.Writer
+}
+
+func (output *cappedOutput) writer(w io.Writer) io.Writer {
+	return &cappedWriter{output: output, writer: w}
+}
+
+func (w *cappedWriter) Write(p []byte) (int, error) {
+	w.output.mu.Lock()
+	defer w.output.mu.Unlock()
+
+	if w.output.truncated {
+		return len(p), nil
+	}
+	if len(p) <= w.output.remaining {
+		w.output.remaining -= len(p)
+		_, err := w.writer.Write(p)
+		return len(p), err
+	}
+
+	_, _ = w.writer.Write(p[:w.output.remaining])
+	w.output.remaining = 0
+	w.output.truncated = true
+	_, err := fmt.Fprintln(w.writer, "\n... output truncated by the sandbox")
+	return len(p), err
+}
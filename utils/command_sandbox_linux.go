@@ -0,0 +1,37 @@
+//go:build linux
+
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+var (
+	networkIsolationOnce      sync.Once
+	networkIsolationSupported bool
+)
+
+// isolateNetwork runs cmd in new user and network namespaces, where it has no network interface but a loopback
+// that is down. The user keeps its own uid and gid inside the namespace.
+func isolateNetwork(cmd *exec.Cmd) {
+	uid, gid := os.Getuid(), os.Getgid()
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}},
+	}
+}
+
+// networkIsolationAvailable reports whether the namespaces of isolateNetwork can be created, which kernels and
+// container runtimes may forbid to unprivileged users
+func networkIsolationAvailable() bool {
+	networkIsolationOnce.Do(func() {
+		cmd := exec.Command("bash", "-c", ":")
+		isolateNetwork(cmd)
+		networkIsolationSupported = cmd.Run() == nil
+	})
+	return networkIsolationSupported
+}
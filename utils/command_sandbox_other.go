@@ -0,0 +1,13 @@
+//go:build !linux
+
+package utils
+
+import "os/exec"
+
+// isolateNetwork is a no-op, network namespaces only exist on Linux
+func isolateNetwork(cmd *exec.Cmd) {}
+
+// networkIsolationAvailable reports that the network can't be isolated outside of Linux
+func networkIsolationAvailable() bool {
+	return false
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"HOME=/home/dev",
+		"LC_ALL=C.UTF-8",
+		"OPENAI_API_KEY=sk-secret",
+		"GITHUB_TOKEN=ghp_secret",
+		"PATHS=/not/path",
+		"MY_TOOL_HOME=/opt/tool",
+		"EXTRA=1",
+	}
+
+	assert.Equal(t, []string{"PATH=/usr/bin", "HOME=/home/dev", "LC_ALL=C.UTF-8"}, sandboxEnv(environ, nil))
+	assert.Equal(t, []string{"PATH=/usr/bin", "HOME=/home/dev", "LC_ALL=C.UTF-8", "MY_TOOL_HOME=/opt/tool", "EXTRA=1"},
+		sandboxEnv(environ, []string{"MY_*", "EXTRA"}))
+}
+
+func TestCappedOutput(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	output := &cappedOutput{remaining: 8}
+	stdoutWriter, stderrWriter := output.writer(&stdout), output.writer(&stderr)
+
+	write := func(w io.Writer, text string) {
+		n, err := w.Write([]byte(text))
+		require.NoError(t, err)
+		assert.Equal(t, len(text), n, "the writes past the cap don't fail")
+	}
+	write(stdoutWriter, "hello")
+	write(stderrWriter, "world")
+	write(stdoutWriter, "dropped")
+
+	assert.Equal(t, "hello", stdout.String())
+	assert.Equal(t, "wor\n... output truncated by the sandbox\n", stderr.String())
+	assert.True(t, output.truncated)
+}
+
+func TestExecuteCommand_Timeout(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the sandbox is tested on Linux")
+	}
+
+	executor := NewCommandExecutorWithSandbox(t.TempDir(), nil, SandboxOptions{Timeout: 200 * time.Millisecond})
+	start := time.Now()
+	err := executor.ExecuteCommand(context.Background(), "sleep 5")
+	assert.ErrorIs(t, err, ErrCommandTimeout)
+	assert.Less(t, time.Since(start), 4*time.Second)
+}
+
+func TestCheckCommand_NetworkWithoutNamespaces(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the sandbox is tested on Linux")
+	}
+
+	executor := NewCommandExecutorWithSandbox(t.TempDir(), nil, SandboxOptions{DenyNetwork: true})
+	executor.networkIsolated = false
+	assert.ErrorContains(t, executor.CheckCommand("curl https://example.com"), "can't be isolated")
+	assert.NoError(t, executor.CheckCommand("ls"))
+
+	executor.networkIsolated = true
+	assert.NoError(t, executor.CheckCommand("curl https://example.com"))
+
+	allowed := NewCommandExecutorWithSandbox(t.TempDir(), nil, SandboxOptions{})
+	assert.NoError(t, allowed.CheckCommand("curl https://example.com"), "the network is only checked when it is denied")
+}
+
+func TestCheckCommand_WritesOutsideProject(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	// The network is isolated and allowed, so only the writes outside of the project reject the commands
+	isolated := NewCommandExecutorWithSandbox(t.TempDir(), nil, SandboxOptions{DenyNetwork: true})
+	isolated.networkIsolated = true
+	allowed := NewCommandExecutorWithSandbox(t.TempDir(), nil, SandboxOptions{})
+
+	for _, executor := range []*CommandExecutor{isolated, allowed} {
+		for _, command := range []string{
+			"curl https://example.com -o ~/.bashrc",
+			"echo x > /tmp/a && curl https://example.com",
+			"git clone https://x /tmp/y",
+			"echo x > /tmp/a",
+		} {
+			assert.ErrorContains(t, executor.CheckCommand(command), "only runs commands writing inside the project", command)
+		}
+		assert.NoError(t, executor.CheckCommand("curl https://example.com -o out.txt"))
+	}
+}
@@ -21,13 +21,13 @@ type CommitMessageRequest struct {
 // CommitMessageGenerator generates commit messages using AI
 type CommitMessageGenerator struct {
 	aiProvider interface {
-		ChatCompletionRequest(ctx context.Context, userPrompt string, systemPrompt string) <-chan models.StreamResponse
+		ChatCompletionRequest(ctx context.Context, messages []models.Message) <-chan models.StreamResponse
 	}
 }
 
 // NewCommitMessageGenerator creates a new commit message generator
 func NewCommitMessageGenerator(aiProvider interface {
-	ChatCompletionRequest(ctx context.Context, userPrompt string, systemPrompt string) <-chan models.StreamResponse
+	ChatCompletionRequest(ctx context.Context, messages []models.Message) <-chan models.StreamResponse
 }) *CommitMessageGenerator {
 	return &CommitMessageGenerator{aiProvider: aiProvider}
 }
@@ -37,17 +37,45 @@ func (g *CommitMessageGenerator) GenerateCommitMessage(ctx context.Context, requ
 	systemPrompt := g.createCommitSystemPrompt()
 	userPrompt := createCommitUserPrompt(request)
 	
-	responseChan := g.aiProvider.ChatCompletionRequest(ctx, userPrompt, systemPrompt)
+	responseChan := g.aiProvider.ChatCompletionRequest(ctx, models.NewConversation(systemPrompt, nil, userPrompt))
 	
-	var messageBuilder strings.Builder
-	for response := range responseChan {
-		if response.Err != nil {
-			return "", fmt.Errorf("failed to generate commit message: %w", response.Err)
-		}
-		messageBuilder.WriteString(response.Content)
+	answer, err := models.CollectAnswer(responseChan, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate commit message: %w", err)
+	}
+	if !answer.Done {
+		return "", fmt.Errorf("failed to generate commit message: the AI response was interrupted")
 	}
 	
-	return messageBuilder.String(), nil
+	return cleanCommitMessage(answer.Content), nil
+}
+
+// cleanCommitMessage removes the code fences and quotes the AI sometimes wraps the commit message in
+func cleanCommitMessage(message string) string {
+	return strings.Trim(StripCodeFence(message), "\"")
+}
+
+// StripCodeFence returns the text of an AI response without the markdown code fence the AI sometimes wraps it in,
+// with its language tag
+func StripCodeFence(response string) string {
+	text := strings.TrimSpace(response)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if newline := strings.Index(text, "\n"); newline >= 0 {
+			text = text[newline+1:]
+		}
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+	return strings.TrimSpace(text)
+}
+
+// TruncateDiff limits a diff to maxLines lines to avoid overwhelming the AI
+func TruncateDiff(diff string, maxLines int) string {
+	lines := strings.Split(diff, "\n")
+	if len(lines) <= maxLines {
+		return diff
+	}
+	return strings.Join(lines[:maxLines], "\n") + fmt.Sprintf("\n... (truncated %d more lines)", len(lines)-maxLines)
 }
 
 func (g *CommitMessageGenerator) createCommitSystemPrompt() string {
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripCodeFence(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{name: "plain", response: "  go test ./...\n", want: "go test ./..."},
+		{name: "fence", response: "```\ngo test ./...\n```", want: "go test ./..."},
+		{name: "fence with a language", response: "```bash\ngo test ./...\n```\n", want: "go test ./..."},
+		{name: "single line fence", response: "```go test ./...```", want: "go test ./..."},
+		{name: "fence inside", response: "Run:\n```\nls\n```", want: "Run:\n```\nls\n```"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, StripCodeFence(test.response))
+		})
+	}
+}
+
+func TestCleanCommitMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{name: "plain", message: "Fix the parser\n", want: "Fix the parser"},
+		{name: "quotes", message: `"Fix the parser"`, want: "Fix the parser"},
+		{name: "fence with a language", message: "```text\nFix the parser\n\n- Handle empty input\n```", want: "Fix the parser\n\n- Handle empty input"},
+		{name: "quotes in a fence", message: "```\n\"Fix the parser\"\n```", want: "Fix the parser"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, cleanCommitMessage(test.message))
+		})
+	}
+}
+
+func TestTruncateDiff(t *testing.T) {
+	diff := "line 1\nline 2\nline 3\nline 4"
+
+	assert.Equal(t, diff, TruncateDiff(diff, 4))
+	assert.Equal(t, diff, TruncateDiff(diff, 10))
+	assert.Equal(t, "line 1\nline 2\n... (truncated 2 more lines)", TruncateDiff(diff, 2))
+}
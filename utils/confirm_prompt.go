@@ -3,6 +3,7 @@ package utils
 import (
 	"bufio"
 	"fmt"
+	safety_models "github.com/meysamhadeli/codai/command_safety/models"
 	"github.com/meysamhadeli/codai/constants/lipgloss"
 	"strings"
 )
@@ -11,8 +12,8 @@ import (
 func ConfirmPrompt(path string, reader *bufio.Reader) (bool, error) {
 
 	// Styled prompt message
-	fmt.Print("\r")
-	fmt.Printf(lipgloss.BlueSky.Render(fmt.Sprintf("Do you want to accept the change for file %v%s", lipgloss.LightBlueB.Render(path), lipgloss.BlueSky.Render(" ? (y/n): "))))
+	fmt.Fprint(PromptOutput, "\r")
+	fmt.Fprint(PromptOutput, lipgloss.BlueSky.Render(fmt.Sprintf("Do you want to accept the change for file %v%s", lipgloss.LightBlueB.Render(path), lipgloss.BlueSky.Render(" ? (y/n): "))))
 
 	// Read user input
 	input, _ := reader.ReadString('\n')
@@ -29,8 +30,8 @@ func ConfirmPrompt(path string, reader *bufio.Reader) (bool, error) {
 func ConfirmAdditinalContext(reader *bufio.Reader) (bool, error) {
 
 	// Styled prompt message
-	fmt.Print("\r")
-	fmt.Printf(lipgloss.Gray.Render(fmt.Sprintf("Do you want to add above files to context %s", lipgloss.Gray.Render("? (y/n): "))))
+	fmt.Fprint(PromptOutput, "\r")
+	fmt.Fprint(PromptOutput, lipgloss.Gray.Render(fmt.Sprintf("Do you want to add above files to context %s", lipgloss.Gray.Render("? (y/n): "))))
 
 	for {
 		// Read user input
@@ -48,3 +49,168 @@ func ConfirmAdditinalContext(reader *bufio.Reader) (bool, error) {
 		}
 	}
 }
+
+// ConfirmFixRequest prompts the user to send the failed verify command output to the AI for a fix
+func ConfirmFixRequest(iteration int, maxIterations int, reader *bufio.Reader) bool {
+
+	// Styled prompt message
+	fmt.Fprint(PromptOutput, "\r")
+	fmt.Fprint(PromptOutput, lipgloss.BlueSky.Render(fmt.Sprintf("Do you want to send the output to the AI for a fix (iteration %d/%d) ? (y/n): ", iteration, maxIterations)))
+
+	// Read user input
+	input, _ := reader.ReadString('\n')
+	input = strings.TrimSpace(input)
+
+	return input == "y" || input == "Y"
+}
+
+// CheckpointsDecision is the answer of the user for the checkpoint commits at the end of a session
+type CheckpointsDecision int
+
+const (
+	// CheckpointsKeep keeps the checkpoint commits
+	CheckpointsKeep CheckpointsDecision = iota
+	// CheckpointsSquash squashes the checkpoint commits into one commit
+	CheckpointsSquash
+	// CheckpointsDrop drops the checkpoint commits, keeping their changes
+	CheckpointsDrop
+)
+
+// CheckpointsPrompt asks the user whether to keep, squash or drop the checkpoint commits of the session
+func CheckpointsPrompt(count int, reader *bufio.Reader) CheckpointsDecision {
+	for {
+		fmt.Fprint(PromptOutput, "\r")
+		fmt.Fprint(PromptOutput, lipgloss.BlueSky.Render(fmt.Sprintf("Keep, squash or drop the %d git checkpoint commit(s) of this session ? (k/s/d): ", count)))
+
+		input, err := reader.ReadString('\n')
+		if err != nil && strings.TrimSpace(input) == "" {
+			return CheckpointsKeep
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "k":
+			return CheckpointsKeep
+		case "s":
+			return CheckpointsSquash
+		case "d":
+			return CheckpointsDrop
+		}
+	}
+}
+
+// CommitMessageDecision is the answer of the user for a generated commit message
+type CommitMessageDecision int
+
+const (
+	// CommitMessageAccept commits with the message
+	CommitMessageAccept CommitMessageDecision = iota
+	// CommitMessageEdit opens the message in an editor
+	CommitMessageEdit
+	// CommitMessageRegenerate asks the AI for another message
+	CommitMessageRegenerate
+	// CommitMessageAbort aborts the commit
+	CommitMessageAbort
+)
+
+// CommitMessagePrompt asks the user what to do with a generated commit message
+func CommitMessagePrompt(reader *bufio.Reader) CommitMessageDecision {
+	for {
+		fmt.Fprint(PromptOutput, "\r")
+		fmt.Fprint(PromptOutput, lipgloss.BlueSky.Render("Commit with this message ? (y/e/r/n, ? for help): "))
+
+		input, err := reader.ReadString('\n')
+		if err != nil && strings.TrimSpace(input) == "" {
+			return CommitMessageAbort
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y":
+			return CommitMessageAccept
+		case "e":
+			return CommitMessageEdit
+		case "r":
+			return CommitMessageRegenerate
+		case "n":
+			return CommitMessageAbort
+		case "?":
+			fmt.Fprintln(PromptOutput, lipgloss.Gray.Render("y - commit with this message\ne - edit the message in $VISUAL or $EDITOR\nr - generate another message\nn - abort the commit"))
+		}
+	}
+}
+
+// ConfirmCommand asks the user to confirm running a command, requiring a stronger answer for the riskier commands:
+// 'y' up to writing the project, 'yes' for writing outside of it or accessing the network, and 'privileged' for
+// commands that change the system. The prompt lists every risk of the command.
+func ConfirmCommand(assessment safety_models.CommandAssessment, reader *bufio.Reader) bool {
+	switch assessment.Risk {
+	case safety_models.RiskReadOnly, safety_models.RiskWritesProject:
+		fmt.Fprint(PromptOutput, lipgloss.BlueSky.Render("\nExecute this command? [y/N]: "))
+		input, _ := reader.ReadString('\n')
+		input = strings.TrimSpace(input)
+		return input == "y" || input == "Y"
+	case safety_models.RiskPrivileged:
+		fmt.Fprint(PromptOutput, lipgloss.Red.Render(fmt.Sprintf("\nThis command %s. Type 'privileged' to execute it: ", riskDescription(assessment.Risks))))
+		input, _ := reader.ReadString('\n')
+		return strings.TrimSpace(input) == "privileged"
+	default:
+		fmt.Fprint(PromptOutput, lipgloss.Yellow.Render(fmt.Sprintf("\nThis command %s. Type 'yes' to execute it: ", riskDescription(assessment.Risks))))
+		input, _ := reader.ReadString('\n')
+		return strings.ToLower(strings.TrimSpace(input)) == "yes"
+	}
+}
+
+// riskDescription describes what a command of the risk levels can do, e.g. 'accesses the network and writes outside
+// of the project'
+func riskDescription(risks []safety_models.RiskLevel) string {
+	var descriptions []string
+	for _, risk := range risks {
+		switch risk {
+		case safety_models.RiskPrivileged:
+			descriptions = append(descriptions, "runs with elevated privileges")
+		case safety_models.RiskNetwork:
+			descriptions = append(descriptions, "accesses the network")
+		case safety_models.RiskWritesOutsideProject:
+			descriptions = append(descriptions, "writes outside of the project")
+		case safety_models.RiskWritesProject:
+			descriptions = append(descriptions, "writes the project")
+		}
+	}
+	if len(descriptions) < 2 {
+		return strings.Join(descriptions, "")
+	}
+	return strings.Join(descriptions[:len(descriptions)-1], ", ") + " and " + descriptions[len(descriptions)-1]
+}
+
+// ToolCallDecision is the answer of the user for a tool call of the AI
+type ToolCallDecision int
+
+const (
+	// ToolCallAllow runs the tool call
+	ToolCallAllow ToolCallDecision = iota
+	// ToolCallDeny tells the AI the user denied the tool call
+	ToolCallDeny
+	// ToolCallAlways runs the tool call and the next calls of the same tool in the session
+	ToolCallAlways
+)
+
+// ToolCallPrompt asks the user whether to run a tool call of the AI, the tool being described by its description
+func ToolCallPrompt(description string, reader *bufio.Reader) ToolCallDecision {
+	for {
+		fmt.Fprint(PromptOutput, "\r")
+		fmt.Fprint(PromptOutput, lipgloss.BlueSky.Render(fmt.Sprintf("Allow the call of %s%s", lipgloss.LightBlueB.Render(description), lipgloss.BlueSky.Render(" ? (y/n/a for always): "))))
+
+		input, err := reader.ReadString('\n')
+		if err != nil && strings.TrimSpace(input) == "" {
+			return ToolCallDeny
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y":
+			return ToolCallAllow
+		case "n":
+			return ToolCallDeny
+		case "a":
+			return ToolCallAlways
+		}
+	}
+}
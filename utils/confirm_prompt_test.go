@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	safety_models "github.com/meysamhadeli/codai/command_safety/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfirmCommand(t *testing.T) {
+	output := PromptOutput
+	t.Cleanup(func() { PromptOutput = output })
+
+	tests := []struct {
+		name       string
+		assessment safety_models.CommandAssessment
+		input      string
+		prompt     string
+		confirmed  bool
+	}{
+		{
+			name:       "writes project",
+			assessment: safety_models.CommandAssessment{Risk: safety_models.RiskWritesProject, Risks: []safety_models.RiskLevel{safety_models.RiskWritesProject}},
+			input:      "y\n",
+			prompt:     "Execute this command? [y/N]",
+			confirmed:  true,
+		},
+		{
+			name: "network writing outside the project",
+			assessment: safety_models.CommandAssessment{Risk: safety_models.RiskNetwork,
+				Risks: []safety_models.RiskLevel{safety_models.RiskNetwork, safety_models.RiskWritesOutsideProject}},
+			input:     "y\n",
+			prompt:    "This command accesses the network and writes outside of the project. Type 'yes'",
+			confirmed: false,
+		},
+		{
+			name: "privileged",
+			assessment: safety_models.CommandAssessment{Risk: safety_models.RiskPrivileged,
+				Risks: []safety_models.RiskLevel{safety_models.RiskPrivileged, safety_models.RiskNetwork, safety_models.RiskWritesOutsideProject}},
+			input:     "privileged\n",
+			prompt:    "This command runs with elevated privileges, accesses the network and writes outside of the project. Type 'privileged'",
+			confirmed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var prompt bytes.Buffer
+			PromptOutput = &prompt
+			confirmed := ConfirmCommand(test.assessment, bufio.NewReader(strings.NewReader(test.input)))
+			assert.Equal(t, test.confirmed, confirmed)
+			assert.Contains(t, prompt.String(), test.prompt)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/meysamhadeli/codai/constants/lipgloss"
+)
+
+// ReviewDecision is the answer of the user for one reviewed hunk
+type ReviewDecision int
+
+const (
+	// ReviewAccept accepts the hunk
+	ReviewAccept ReviewDecision = iota
+	// ReviewReject rejects the hunk
+	ReviewReject
+	// ReviewAcceptAll accepts the hunk and every remaining hunk of every remaining file
+	ReviewAcceptAll
+	// ReviewQuit rejects the hunk and every remaining hunk of every remaining file
+	ReviewQuit
+	// ReviewEdit opens the proposed content of the whole file in an editor
+	ReviewEdit
+)
+
+// HunkPrompt asks the user what to do with the hunk of a file that was just rendered
+func HunkPrompt(path string, index, total int, reader *bufio.Reader) (ReviewDecision, error) {
+	for {
+		fmt.Fprint(PromptOutput, "\r")
+		fmt.Fprint(PromptOutput, lipgloss.BlueSky.Render(fmt.Sprintf("Accept hunk %d/%d of %s", index, total, lipgloss.LightBlueB.Render(path)))+lipgloss.BlueSky.Render(" ? (y/n/a/e/q, ? for help): "))
+
+		input, err := reader.ReadString('\n')
+		if err != nil && strings.TrimSpace(input) == "" {
+			return ReviewQuit, err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y":
+			return ReviewAccept, nil
+		case "n":
+			return ReviewReject, nil
+		case "a":
+			return ReviewAcceptAll, nil
+		case "e":
+			return ReviewEdit, nil
+		case "q":
+			return ReviewQuit, nil
+		case "?":
+			fmt.Fprintln(PromptOutput, lipgloss.Gray.Render("y - accept this hunk\nn - reject this hunk\na - accept this hunk and all remaining changes\ne - edit the proposed file in $VISUAL or $EDITOR\nq - reject this hunk and all remaining changes"))
+		}
+	}
+}
+
+// SyntaxDecision is the answer of the user for a change that does not parse
+type SyntaxDecision int
+
+const (
+	// SyntaxReject rejects the change
+	SyntaxReject SyntaxDecision = iota
+	// SyntaxApplyAnyway applies the change despite the syntax errors
+	SyntaxApplyAnyway
+	// SyntaxSendToAI rejects the change and asks the AI for a corrected version
+	SyntaxSendToAI
+)
+
+// SyntaxErrorPrompt asks the user what to do with a change of a file that does not parse
+func SyntaxErrorPrompt(path string, reader *bufio.Reader) SyntaxDecision {
+	for {
+		fmt.Fprint(PromptOutput, "\r")
+		fmt.Fprint(PromptOutput, lipgloss.BlueSky.Render(fmt.Sprintf("Apply %s anyway, reject it, or send the errors to the AI", lipgloss.LightBlueB.Render(path)))+lipgloss.BlueSky.Render(" ? (a/r/s): "))
+
+		input, err := reader.ReadString('\n')
+		if err != nil && strings.TrimSpace(input) == "" {
+			return SyntaxReject
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "a":
+			return SyntaxApplyAnyway
+		case "r":
+			return SyntaxReject
+		case "s":
+			return SyntaxSendToAI
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// EditInEditor writes content to a temporary file named after path, opens it in $VISUAL or $EDITOR and returns
+// the saved content
+func EditInEditor(path string, content string) (string, error) {
+	editor := strings.Fields(editorCommand())
+	if len(editor) == 0 {
+		return "", fmt.Errorf("no editor configured, set $VISUAL or $EDITOR")
+	}
+
+	// Keep the extension, so the editor can highlight the syntax of the file
+	tempFile, err := os.CreateTemp("", "codai-*-"+filepath.Base(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath)
+
+	if _, err := tempFile.WriteString(content); err != nil {
+		tempFile.Close()
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	cmd := exec.Command(editor[0], append(editor[1:], tempPath)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", editor[0], err)
+	}
+
+	edited, err := os.ReadFile(tempPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited file: %w", err)
+	}
+
+	return string(edited), nil
+}
+
+// editorCommand returns the editor configured by $VISUAL or $EDITOR, falling back to the platform default
+func editorCommand() string {
+	if visual := strings.TrimSpace(os.Getenv("VISUAL")); visual != "" {
+		return visual
+	}
+	if editor := strings.TrimSpace(os.Getenv("EDITOR")); editor != "" {
+		return editor
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/meysamhadeli/codai/providers/contracts"
+)
+
+// maxCheckpointDiffLines limits the diff sent to the AI to generate a checkpoint commit message
+const maxCheckpointDiffLines = 300
+
+// GitCheckpoint is a commit created for one accepted AI change set
+type GitCheckpoint struct {
+	Hash    string
+	Subject string
+	Prompt  string
+}
+
+// GitCheckpoints creates a git commit for each accepted AI change set and squashes or drops them at the end of a session
+type GitCheckpoints struct {
+	git         *GitOperations
+	generator   *CommitMessageGenerator
+	base        string
+	checkpoints []GitCheckpoint
+}
+
+// NewGitCheckpoints creates a new GitCheckpoints instance
+func NewGitCheckpoints(workingDir string, aiProvider contracts.IChatAIProvider) *GitCheckpoints {
+	return &GitCheckpoints{
+		git:       NewGitOperations(workingDir),
+		generator: NewCommitMessageGenerator(aiProvider),
+	}
+}
+
+// CheckGitRepo checks if checkpoints can be created in the working directory
+func (c *GitCheckpoints) CheckGitRepo() error {
+	if err := c.git.CheckGitRepo(); err != nil {
+		return err
+	}
+	_, err := c.git.GetHead()
+	return err
+}
+
+// Checkpoints returns the checkpoint commits that can still be squashed or dropped, oldest first
+func (c *GitCheckpoints) Checkpoints() []GitCheckpoint {
+	return c.checkpoints
+}
+
+// Checkpoint commits the given files with a message generated by the AI and the user prompt in the body.
+// Other staged changes of the user are left staged. It returns nil when the files have no change to commit.
+// When HEAD moved since the last checkpoint, e.g. because the user committed, a new series of checkpoints starts.
+func (c *GitCheckpoints) Checkpoint(ctx context.Context, paths []string, prompt string) (*GitCheckpoint, error) {
+	head, err := c.git.GetHead()
+	if err != nil {
+		return nil, err
+	}
+	if len(c.checkpoints) == 0 || c.checkpoints[len(c.checkpoints)-1].Hash != head {
+		c.base, c.checkpoints = head, nil
+	}
+
+	if err := c.git.AddPaths(paths); err != nil {
+		return nil, err
+	}
+
+	diff, err := c.git.GetPathsDiff(paths)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return nil, nil
+	}
+
+	branch, _ := c.git.GetBranchName()
+	message := c.generateMessage(ctx, CommitMessageRequest{
+		StagedDiff: TruncateDiff(diff, maxCheckpointDiffLines),
+		WorkingDir: c.git.workingDir,
+		Branch:     branch,
+		UserInput:  prompt,
+	}, fmt.Sprintf("Apply AI changes to %s", strings.Join(paths, ", ")))
+
+	if strings.TrimSpace(prompt) != "" {
+		message += "\n\nPrompt:\n" + strings.TrimSpace(prompt)
+	}
+
+	if err := c.git.CommitPaths(message, paths); err != nil {
+		return nil, err
+	}
+
+	hash, err := c.git.GetHead()
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := GitCheckpoint{Hash: hash, Subject: strings.SplitN(message, "\n", 2)[0], Prompt: prompt}
+	c.checkpoints = append(c.checkpoints, checkpoint)
+	return &checkpoint, nil
+}
+
+// Squash replaces the checkpoint commits with one commit, with a message generated by the AI for all of their changes
+func (c *GitCheckpoints) Squash(ctx context.Context) error {
+	if err := c.checkHead(); err != nil {
+		return err
+	}
+
+	diff, err := c.git.GetRangeDiff(c.base, c.checkpoints[len(c.checkpoints)-1].Hash)
+	if err != nil {
+		return err
+	}
+
+	var subjects, prompts []string
+	for _, checkpoint := range c.checkpoints {
+		subjects = append(subjects, "- "+checkpoint.Subject)
+		if strings.TrimSpace(checkpoint.Prompt) != "" {
+			prompts = append(prompts, "- "+strings.TrimSpace(checkpoint.Prompt))
+		}
+	}
+
+	branch, _ := c.git.GetBranchName()
+	message := c.generateMessage(ctx, CommitMessageRequest{
+		StagedDiff: TruncateDiff(diff, maxCheckpointDiffLines),
+		WorkingDir: c.git.workingDir,
+		Branch:     branch,
+		UserInput:  strings.Join(prompts, "\n"),
+	}, fmt.Sprintf("Apply AI changes\n\n%s", strings.Join(subjects, "\n")))
+
+	if len(prompts) > 0 {
+		message += "\n\nPrompts:\n" + strings.Join(prompts, "\n")
+	}
+
+	if err := c.git.ReplaceCommits(c.base, message); err != nil {
+		return err
+	}
+
+	c.checkpoints = nil
+	return nil
+}
+
+// Drop removes the checkpoint commits from the history, keeping their changes staged in the working tree
+func (c *GitCheckpoints) Drop() error {
+	if err := c.checkHead(); err != nil {
+		return err
+	}
+
+	if err := c.git.ResetSoft(c.base); err != nil {
+		return err
+	}
+
+	c.checkpoints = nil
+	return nil
+}
+
+// checkHead makes sure HEAD is still the last checkpoint, so squashing or dropping never touches commits of the user
+func (c *GitCheckpoints) checkHead() error {
+	if len(c.checkpoints) == 0 {
+		return fmt.Errorf("there are no checkpoint commits in this session")
+	}
+
+	head, err := c.git.GetHead()
+	if err != nil {
+		return err
+	}
+	if head != c.checkpoints[len(c.checkpoints)-1].Hash {
+		return fmt.Errorf("HEAD moved since the last checkpoint commit, leaving the checkpoint commits as they are")
+	}
+	return nil
+}
+
+// generateMessage asks the AI for a commit message, returning fallback when it fails
+func (c *GitCheckpoints) generateMessage(ctx context.Context, request CommitMessageRequest, fallback string) string {
+	message, err := c.generator.GenerateCommitMessage(ctx, request)
+	if err != nil || message == "" {
+		return fallback
+	}
+	return message
+}
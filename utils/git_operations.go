@@ -53,8 +53,8 @@ func (g *GitOperations) AddFiles() error {
 func (g *GitOperations) Commit(message string) error {
 	cmd := exec.Command("git", "commit", "-m", message)
 	cmd.Dir = g.workingDir
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to create commit: %w", err)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create commit: %s", strings.TrimSpace(string(output)))
 	}
 	return nil
 }
@@ -167,4 +167,115 @@ func (g *GitOperations) GenerateCommitRequest(ctx context.Context, recentCommits
 	commitMetadataBuilder.WriteString("")
 	
 	return commitMetadataBuilder.String()
-}
\ No newline at end of file
+}
+
+// GetHead returns the hash of the commit HEAD points to
+func (g *GitOperations) GetHead() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", "HEAD")
+	cmd.Dir = g.workingDir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve HEAD, the repository has no commits yet: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// AddPaths adds the given files to staging, including their deletion
+func (g *GitOperations) AddPaths(paths []string) error {
+	cmd := exec.Command("git", append([]string{"add", "-A", "--"}, paths...)...)
+	cmd.Dir = g.workingDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to add files to git: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// GetPathsDiff returns the diff of the staged changes of the given files
+func (g *GitOperations) GetPathsDiff(paths []string) (string, error) {
+	cmd := exec.Command("git", append([]string{"diff", "--cached", "--unified=3", "--"}, paths...)...)
+	cmd.Dir = g.workingDir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get git diff: %w", err)
+	}
+	return string(output), nil
+}
+
+// CommitPaths creates a git commit of the given files only, leaving any other staged change staged
+func (g *GitOperations) CommitPaths(message string, paths []string) error {
+	cmd := exec.Command("git", append([]string{"commit", "-m", message, "--only", "--"}, paths...)...)
+	cmd.Dir = g.workingDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create commit: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// GetRangeDiff returns the diff between two commits
+func (g *GitOperations) GetRangeDiff(from string, to string) (string, error) {
+	cmd := exec.Command("git", "diff", "--unified=3", from, to)
+	cmd.Dir = g.workingDir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get git diff: %w", err)
+	}
+	return string(output), nil
+}
+
+// ReplaceCommits moves the current branch to a single commit with the tree of HEAD on top of parent,
+// replacing the commits in between. The index and working tree are left untouched.
+func (g *GitOperations) ReplaceCommits(parent string, message string) error {
+	cmd := exec.Command("git", "commit-tree", "HEAD^{tree}", "-p", parent, "-F", "-")
+	cmd.Dir = g.workingDir
+	cmd.Stdin = strings.NewReader(message)
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to create commit: %w", err)
+	}
+
+	return g.ResetSoft(strings.TrimSpace(string(output)))
+}
+
+// ResetSoft moves the current branch to the given commit, keeping the index and working tree
+func (g *GitOperations) ResetSoft(commit string) error {
+	cmd := exec.Command("git", "reset", "--soft", commit)
+	cmd.Dir = g.workingDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to reset to %s: %s", commit, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// GetRevisionDiff returns the diff of a revision range like 'main..feature' or 'HEAD~3..HEAD'
+func (g *GitOperations) GetRevisionDiff(revisions string) (string, error) {
+	cmd := exec.Command("git", "diff", "--unified=3", revisions, "--")
+	cmd.Dir = g.workingDir
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("failed to get git diff of %s: %s", revisions, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("failed to get git diff of %s: %w", revisions, err)
+	}
+	return string(output), nil
+}
+
+// GetFileAtRevision returns the content of the file at path in the commit of revision, or in the index when revision
+// is empty
+func (g *GitOperations) GetFileAtRevision(revision string, path string) (string, error) {
+	cmd := exec.Command("git", "show", revision+":"+path)
+	cmd.Dir = g.workingDir
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("failed to read %s at %s: %s", path, revision, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("failed to read %s at %s: %w", path, revision, err)
+	}
+	return string(output), nil
+}
+
+// GetBranchDiff returns the diff of the current branch since it diverged from base
+func (g *GitOperations) GetBranchDiff(base string) (string, error) {
+	return g.GetRevisionDiff(base + "...HEAD")
+}
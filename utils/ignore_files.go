@@ -90,12 +90,14 @@ func IsDefaultIgnored(path string) bool {
 		"dist",
 		"out",
 		".cache",
+		".codai",
 		"node_modules",
 		"*.exe",
 		"*.dll",
 		"*.log",
 		"*.bak",
 		"*.bkp",
+		"*.patch",
 		".mp3",
 		".wav",
 		".aac",
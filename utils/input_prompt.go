@@ -5,16 +5,21 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 
 	"github.com/meysamhadeli/codai/constants/lipgloss"
 )
 
+// PromptOutput is where the prompts asking the user for input are shown. Commands writing structured output to
+// stdout show them on stderr instead.
+var PromptOutput io.Writer = os.Stdout
+
 // InputPrompt prompts the user to enter their request for code assistance in a charming way
 func InputPrompt(reader *bufio.Reader) (string, error) {
 
 	// Beautifully styled prompt message
-	fmt.Print(lipgloss.BlueSky.Render("> "))
+	fmt.Fprint(PromptOutput, lipgloss.BlueSky.Render("> "))
 
 	// Read user input
 	userInput, err := reader.ReadString('\n')
@@ -41,7 +46,7 @@ func InputPromptWithContext(ctx context.Context, reader *bufio.Reader) (string,
 	// Start a goroutine to read input
 	go func() {
 		// Beautifully styled prompt message
-		fmt.Print(lipgloss.BlueSky.Render("> "))
+		fmt.Fprint(PromptOutput, lipgloss.BlueSky.Render("> "))
 		
 		userInput, err := reader.ReadString('\n')
 		
@@ -64,7 +69,7 @@ func InputPromptWithContext(ctx context.Context, reader *bufio.Reader) (string,
 	// Wait for either input or context cancellation
 	select {
 	case <-ctx.Done():
-		fmt.Println() // Print newline for clean exit
+		fmt.Fprintln(PromptOutput) // Print newline for clean exit
 		return "", ctx.Err()
 	case err := <-errChan:
 		return "", err
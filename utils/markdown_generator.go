@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/alecthomas/chroma/v2/quick"
+	"io"
 	"os"
 	"strings"
 )
@@ -35,15 +36,15 @@ func RenderAndPrintMarkdown(line string, language string, theme string) error {
 	return nil
 }
 
-// RenderAndPrintMarkdownWithContext handles the rendering of markdown content with cancellation support
-func RenderAndPrintMarkdownWithContext(ctx context.Context, content string, language string, theme string) error {
+// RenderAndPrintMarkdownWithContext handles the rendering of markdown content to writer with cancellation support
+func RenderAndPrintMarkdownWithContext(ctx context.Context, writer io.Writer, content string, language string, theme string) error {
 	lines := strings.Split(content, "\n")
 	
 	for i, line := range lines {
 		// Check for context cancellation before each line
 		select {
 		case <-ctx.Done():
-			fmt.Printf("\n\n🔄 Output interrupted...\n")
+			fmt.Fprintf(writer, "\n\n🔄 Output interrupted...\n")
 			return ctx.Err()
 		default:
 		}
@@ -55,24 +56,24 @@ func RenderAndPrintMarkdownWithContext(ctx context.Context, content string, lang
 		
 		if strings.HasPrefix(line, "+") && isCodeBlock {
 			coloredLine := "\x1b[92m" + line + "\x1b[0m\n"
-			fmt.Print(coloredLine)
+			fmt.Fprint(writer, coloredLine)
 		} else if strings.HasPrefix(line, "-") && isCodeBlock {
 			coloredLine := "\x1b[91m" + line + "\x1b[0m\n"
-			fmt.Print(coloredLine)
+			fmt.Fprint(writer, coloredLine)
 		} else {
 			// Use a buffer to capture the highlight output
 			var buf bytes.Buffer
 			if err := quick.Highlight(&buf, line+"\n", language, "terminal256", theme); err != nil {
 				return err
 			}
-			fmt.Print(buf.String())
+			fmt.Fprint(writer, buf.String())
 		}
 		
 		// Check for cancellation more frequently for responsive interruption
 		if i%5 == 0 {
 			select {
 			case <-ctx.Done():
-				fmt.Printf("\n\n🔄 Output interrupted...\n")
+				fmt.Fprintf(writer, "\n\n🔄 Output interrupted...\n")
 				return ctx.Err()
 			default:
 			}